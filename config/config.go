@@ -0,0 +1,299 @@
+package config
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type By int
+
+const (
+	ByDefault By = iota
+	ByConfig
+	ByFlag
+	BySet
+)
+
+func (by By) String() string {
+	switch by {
+	case ByDefault:
+		return "default"
+	case ByConfig:
+		return "config"
+	case ByFlag:
+		return "flag"
+	case BySet:
+		return "set"
+	default:
+		panic(fmt.Sprintf("config: unexpected by: %d", by))
+	}
+}
+
+type Config struct {
+	mutex sync.RWMutex
+	vars  map[string]*Variable
+	fs    *flag.FlagSet
+}
+
+type Variable struct {
+	cfg    *Config
+	name   string
+	val    value
+	by     By
+	hidden bool
+	noSet  bool
+}
+
+type value interface {
+	String() string
+	Set(s string) error
+}
+
+type boolValue struct {
+	p *bool
+}
+
+type intValue struct {
+	p *int64
+}
+
+type stringValue struct {
+	p *string
+}
+
+type Value struct {
+	Name   string
+	Value  string
+	By     By
+	Hidden bool
+}
+
+// NewConfig returns an empty configuration; variables are also registered as flags in fs,
+// if fs is not nil.
+func NewConfig(fs *flag.FlagSet) *Config {
+	return &Config{
+		vars: map[string]*Variable{},
+		fs:   fs,
+	}
+}
+
+func (cfg *Config) newVariable(name string, val value, usage string) *Variable {
+	if _, ok := cfg.vars[name]; ok {
+		panic(fmt.Sprintf("config: variable already defined: %s", name))
+	}
+
+	v := &Variable{
+		cfg:  cfg,
+		name: name,
+		val:  val,
+	}
+	cfg.vars[name] = v
+	if cfg.fs != nil {
+		cfg.fs.Var(flagValue{v}, name, usage)
+	}
+	return v
+}
+
+func (cfg *Config) Bool(p *bool, name string, def bool, usage string) *Variable {
+	*p = def
+	return cfg.newVariable(name, boolValue{p}, usage)
+}
+
+func (cfg *Config) Int(p *int64, name string, def int64, usage string) *Variable {
+	*p = def
+	return cfg.newVariable(name, intValue{p}, usage)
+}
+
+func (cfg *Config) String(p *string, name string, def string, usage string) *Variable {
+	*p = def
+	return cfg.newVariable(name, stringValue{p}, usage)
+}
+
+// Hide the variable from SHOW CONFIG.
+func (v *Variable) Hide() *Variable {
+	v.hidden = true
+	return v
+}
+
+// NoSet prevents the variable from being changed by SET.
+func (v *Variable) NoSet() *Variable {
+	v.noSet = true
+	return v
+}
+
+func (v *Variable) set(s string, by By) error {
+	v.cfg.mutex.Lock()
+	defer v.cfg.mutex.Unlock()
+
+	if by < v.by {
+		return nil
+	}
+	err := v.val.Set(s)
+	if err != nil {
+		return err
+	}
+	v.by = by
+	return nil
+}
+
+// Set changes the value of a variable using SET.
+func (cfg *Config) Set(name, s string) error {
+	v, ok := cfg.vars[name]
+	if !ok {
+		return fmt.Errorf("config: variable not found: %s", name)
+	} else if v.noSet {
+		return fmt.Errorf("config: variable may not be set: %s", name)
+	}
+
+	err := v.set(s, BySet)
+	if err != nil {
+		return fmt.Errorf("config: %s: %s", name, err)
+	}
+	return nil
+}
+
+// Get returns the value of a variable as a string.
+func (cfg *Config) Get(name string) (string, bool) {
+	v, ok := cfg.vars[name]
+	if !ok {
+		return "", false
+	}
+
+	cfg.mutex.RLock()
+	defer cfg.mutex.RUnlock()
+	return v.val.String(), true
+}
+
+// Values returns all variables sorted by name.
+func (cfg *Config) Values() []Value {
+	cfg.mutex.RLock()
+	defer cfg.mutex.RUnlock()
+
+	vals := make([]Value, 0, len(cfg.vars))
+	for _, v := range cfg.vars {
+		vals = append(vals,
+			Value{
+				Name:   v.name,
+				Value:  v.val.String(),
+				By:     v.by,
+				Hidden: v.hidden,
+			})
+	}
+
+	sort.Slice(vals,
+		func(i, j int) bool {
+			return vals[i].Name < vals[j].Name
+		})
+	return vals
+}
+
+// Load reads a config file; each line is `name = value`, with # starting a comment. Values
+// set by flags are not changed.
+func (cfg *Config) Load(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return cfg.load(f, filename)
+}
+
+func (cfg *Config) load(r io.Reader, filename string) error {
+	scan := bufio.NewScanner(r)
+	line := 0
+	for scan.Scan() {
+		line += 1
+		s := strings.TrimSpace(scan.Text())
+		if s == "" || s[0] == '#' {
+			continue
+		}
+
+		name, val, ok := strings.Cut(s, "=")
+		if !ok {
+			return fmt.Errorf("config: %s:%d: expected name = value", filename, line)
+		}
+		name = strings.TrimSpace(name)
+		val = strings.TrimSpace(val)
+		if len(val) > 0 && val[0] == '"' {
+			var err error
+			val, err = strconv.Unquote(val)
+			if err != nil {
+				return fmt.Errorf("config: %s:%d: %s", filename, line, err)
+			}
+		}
+
+		v, ok := cfg.vars[name]
+		if !ok {
+			return fmt.Errorf("config: %s:%d: variable not found: %s", filename, line, name)
+		}
+		err := v.set(val, ByConfig)
+		if err != nil {
+			return fmt.Errorf("config: %s:%d: %s: %s", filename, line, name, err)
+		}
+	}
+
+	return scan.Err()
+}
+
+type flagValue struct {
+	v *Variable
+}
+
+func (fv flagValue) String() string {
+	if fv.v == nil {
+		return ""
+	}
+	return fv.v.val.String()
+}
+
+func (fv flagValue) Set(s string) error {
+	return fv.v.set(s, ByFlag)
+}
+
+func (fv flagValue) IsBoolFlag() bool {
+	_, ok := fv.v.val.(boolValue)
+	return ok
+}
+
+func (bv boolValue) String() string {
+	return strconv.FormatBool(*bv.p)
+}
+
+func (bv boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*bv.p = b
+	return nil
+}
+
+func (iv intValue) String() string {
+	return strconv.FormatInt(*iv.p, 10)
+}
+
+func (iv intValue) Set(s string) error {
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*iv.p = i
+	return nil
+}
+
+func (sv stringValue) String() string {
+	return *sv.p
+}
+
+func (sv stringValue) Set(s string) error {
+	*sv.p = s
+	return nil
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfig(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	cfg := NewConfig(fs)
+
+	var b1, b2 bool
+	var i1, i2 int64
+	var s1, s2 string
+	cfg.Bool(&b1, "b1", true, "usage")
+	cfg.Bool(&b2, "b2", false, "usage").Hide()
+	cfg.Int(&i1, "i1", 123, "usage")
+	cfg.Int(&i2, "i2", 456, "usage").NoSet()
+	cfg.String(&s1, "s1", "abc", "usage")
+	cfg.String(&s2, "s2", "def", "usage")
+
+	want := []Value{
+		{Name: "b1", Value: "true", By: ByDefault},
+		{Name: "b2", Value: "false", By: ByDefault, Hidden: true},
+		{Name: "i1", Value: "123", By: ByDefault},
+		{Name: "i2", Value: "456", By: ByDefault},
+		{Name: "s1", Value: "abc", By: ByDefault},
+		{Name: "s2", Value: "def", By: ByDefault},
+	}
+	if got := cfg.Values(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Values() got %v want %v", got, want)
+	}
+
+	err := fs.Parse([]string{"-b2", "-i1", "789"})
+	if err != nil {
+		t.Fatalf("Parse() failed with %s", err)
+	}
+	if !b2 || i1 != 789 {
+		t.Errorf("Parse() got b2 = %v, i1 = %d", b2, i1)
+	}
+	err = fs.Parse([]string{"-i2", "abc"})
+	if err == nil {
+		t.Errorf("Parse() did not fail")
+	}
+
+	err = cfg.load(strings.NewReader(`
+# comment
+i1 = 1000
+i2 = 2000
+s1 = "quoted # string"
+s2 = unquoted
+`), "test.cfg")
+	if err != nil {
+		t.Fatalf("load() failed with %s", err)
+	}
+
+	for _, c := range []string{
+		"i1 1000",
+		"i3 = 3000",
+		"b1 = maybe",
+		`s1 = "abc`,
+	} {
+		err = cfg.load(strings.NewReader(c), "test.cfg")
+		if err == nil {
+			t.Errorf("load(%s) did not fail", c)
+		}
+	}
+
+	err = cfg.Set("s2", "xyz")
+	if err != nil {
+		t.Errorf("Set(s2) failed with %s", err)
+	}
+	err = cfg.Set("i1", "4000")
+	if err != nil {
+		t.Errorf("Set(i1) failed with %s", err)
+	}
+	err = cfg.Set("i2", "4000")
+	if err == nil {
+		t.Errorf("Set(i2) did not fail")
+	}
+	err = cfg.Set("b1", "maybe")
+	if err == nil {
+		t.Errorf("Set(b1) did not fail")
+	}
+	err = cfg.Set("s3", "xyz")
+	if err == nil {
+		t.Errorf("Set(s3) did not fail")
+	}
+
+	want = []Value{
+		{Name: "b1", Value: "true", By: ByDefault},
+		{Name: "b2", Value: "true", By: ByFlag, Hidden: true},
+		{Name: "i1", Value: "4000", By: BySet},
+		{Name: "i2", Value: "2000", By: ByConfig},
+		{Name: "s1", Value: "quoted # string", By: ByConfig},
+		{Name: "s2", Value: "xyz", By: BySet},
+	}
+	if got := cfg.Values(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Values() got %v want %v", got, want)
+	}
+
+	if val, ok := cfg.Get("s1"); !ok || val != "quoted # string" {
+		t.Errorf("Get(s1) got %s, %v", val, ok)
+	}
+	if _, ok := cfg.Get("s3"); ok {
+		t.Errorf("Get(s3) did not fail")
+	}
+}
@@ -0,0 +1,636 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/leftmike/maho/parser/sql"
+	"github.com/leftmike/maho/storage"
+	"github.com/leftmike/maho/types"
+)
+
+func columnNumber(tt *TableType, col types.Identifier) (types.ColumnNum, bool) {
+	for num, nam := range tt.ColumnNames {
+		if nam == col {
+			return types.ColumnNum(num), true
+		}
+	}
+	return 0, false
+}
+
+func (tt *TableType) columnDefaults() []sql.Expr {
+	if len(tt.ColumnDefaults) < len(tt.ColumnNames) {
+		return append(slices.Clone(tt.ColumnDefaults),
+			make([]sql.Expr, len(tt.ColumnNames)-len(tt.ColumnDefaults))...)
+	}
+	return slices.Clone(tt.ColumnDefaults)
+}
+
+func (tt *TableType) constraintNumber(cn types.Identifier) (int, bool) {
+	for cdx, con := range tt.Constraints {
+		if con.Name == cn {
+			return cdx, true
+		}
+	}
+	return 0, false
+}
+
+func (tt *TableType) foreignKeyNumber(cn types.Identifier) (int, bool) {
+	for fdx, fk := range tt.ForeignKeys {
+		if fk.Name == cn {
+			return fdx, true
+		}
+	}
+	return 0, false
+}
+
+func (tt *TableType) constraintExists(cn types.Identifier) bool {
+	if _, ok := tt.constraintNumber(cn); ok {
+		return true
+	}
+	_, ok := tt.foreignKeyNumber(cn)
+	return ok
+}
+
+// ForeignKeyIndex returns the name of an index which can be used to find the rows which
+// reference a row of the referenced table: the key of the index must be the columns of the
+// foreign key.
+func (tt *TableType) ForeignKeyIndex(fk ForeignKey) (types.Identifier, bool) {
+	for _, it := range tt.Indexes {
+		if len(it.Key) != len(fk.Columns) {
+			continue
+		}
+
+		match := true
+		for _, ck := range it.Key {
+			if !slices.Contains(fk.Columns, ck.Column()) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return it.Name, true
+		}
+	}
+	return 0, false
+}
+
+func referencesColumn(e sql.Expr, col types.Identifier) bool {
+	return !sql.WalkExpr(e,
+		func(e sql.Expr) bool {
+			if ref, ok := e.(sql.Ref); ok && ref[len(ref)-1] == col {
+				return false
+			}
+			return true
+		})
+}
+
+// renameColumn returns a copy of e with references to column col changed to nam.
+func renameColumn(e sql.Expr, col, nam types.Identifier) sql.Expr {
+	switch e := e.(type) {
+	case sql.Ref:
+		if e[len(e)-1] == col {
+			ref := slices.Clone(e)
+			ref[len(ref)-1] = nam
+			return ref
+		}
+	case *sql.SExpr:
+		args := make([]sql.Expr, 0, len(e.Args))
+		for _, arg := range e.Args {
+			args = append(args, renameColumn(arg, col, nam))
+		}
+		return &sql.SExpr{Name: e.Name, Args: args}
+	case *sql.UnaryExpr:
+		return &sql.UnaryExpr{Op: e.Op, Expr: renameColumn(e.Expr, col, nam)}
+	case *sql.BinaryExpr:
+		return &sql.BinaryExpr{
+			Op:    e.Op,
+			Left:  renameColumn(e.Left, col, nam),
+			Right: renameColumn(e.Right, col, nam),
+		}
+	}
+	return e
+}
+
+func (tbl *table) storageColumn(col types.ColumnNum) types.ColumnNum {
+	if tbl.rowid {
+		return col + 1
+	}
+	return col
+}
+
+// alterTable calls fn to change the table type and the storage table, and then saves the
+// new version of the table type.
+func (tx *transaction) alterTable(ctx context.Context, tn types.TableName,
+	fn func(tbl *table, tt *TableType) error) error {
+
+	if tn.Database == types.SYSTEM || tn.Schema == types.METADATA {
+		return fmt.Errorf("engine: table %s may not be altered", tn)
+	}
+
+	tr := tablesRow{
+		Database: tn.Database.String(),
+		Schema:   tn.Schema.String(),
+		Table:    tn.Table.String(),
+	}
+	err := TypedTableLookup(ctx, tx.tx, tablesTypedInfo, &tr)
+	if err == io.EOF {
+		return fmt.Errorf("engine: table not found: %s", tn)
+	} else if err != nil {
+		return err
+	}
+
+	tt, err := DecodeTableType(tr.Type)
+	if err != nil {
+		return err
+	}
+	tbl, err := tx.openTable(ctx, tn, storage.TableId(tr.TableId), tt)
+	if err != nil {
+		return err
+	}
+
+	ntt := *tt
+	err = fn(tbl, &ntt)
+	if err != nil {
+		return err
+	}
+	ntt.Version += 1
+
+	buf, err := ntt.Encode()
+	if err != nil {
+		return err
+	}
+	return TypedTableUpdate(ctx, tx.tx, tablesTypedInfo, &tr, &tr,
+		func(row types.Row) (interface{}, error) {
+			return &struct {
+				Type []byte
+			}{
+				Type: buf,
+			}, nil
+		})
+}
+
+// RenameTable changes the name of table tn to ntn, which must be in the same database; the
+// table keeps its table id, so storage is unaffected other than the name it keeps.
+func (tx *transaction) RenameTable(ctx context.Context, tn, ntn types.TableName) error {
+	if tn.Database == types.SYSTEM || tn.Schema == types.METADATA {
+		return fmt.Errorf("engine: table %s may not be altered", tn)
+	} else if ntn.Database != tn.Database {
+		return fmt.Errorf("engine: table %s: unable to rename to a different database: %s", tn,
+			ntn)
+	} else if ntn.Schema == types.METADATA {
+		return fmt.Errorf("engine: schema name reserved: %s", ntn.SchemaName())
+	}
+
+	tr := tablesRow{
+		Database: tn.Database.String(),
+		Schema:   tn.Schema.String(),
+		Table:    tn.Table.String(),
+	}
+	err := TypedTableLookup(ctx, tx.tx, tablesTypedInfo, &tr)
+	if err == io.EOF {
+		return fmt.Errorf("engine: table not found: %s", tn)
+	} else if err != nil {
+		return err
+	}
+
+	err = TypedTableLookup(ctx, tx.tx, schemasTypedInfo,
+		&schemasRow{
+			Database: ntn.Database.String(),
+			Schema:   ntn.Schema.String(),
+		})
+	if err == io.EOF {
+		return fmt.Errorf("engine: schema not found: %s", ntn.SchemaName())
+	} else if err != nil {
+		return err
+	}
+
+	err = TypedTableLookup(ctx, tx.tx, tablesTypedInfo,
+		&tablesRow{
+			Database: ntn.Database.String(),
+			Schema:   ntn.Schema.String(),
+			Table:    ntn.Table.String(),
+		})
+	if err == nil {
+		return fmt.Errorf("engine: table already exists: %s", ntn)
+	} else if err != io.EOF {
+		return err
+	}
+
+	tt, err := DecodeTableType(tr.Type)
+	if err != nil {
+		return err
+	}
+
+	// Foreign keys refer to tables by name, so references to and from the table need to
+	// be changed as well.
+	ntt := *tt
+	ntt.ForeignKeys = slices.Clone(tt.ForeignKeys)
+	for fdx := range ntt.ForeignKeys {
+		if ntt.ForeignKeys[fdx].RefTable == tn {
+			ntt.ForeignKeys[fdx].RefTable = ntn
+		}
+	}
+	ntt.ForeignRefs = slices.Clone(tt.ForeignRefs)
+	for fdx := range ntt.ForeignRefs {
+		if ntt.ForeignRefs[fdx].Table == tn {
+			ntt.ForeignRefs[fdx].Table = ntn
+		}
+	}
+	buf, err := ntt.Encode()
+	if err != nil {
+		return err
+	}
+
+	err = TypedTableDelete(ctx, tx.tx, tablesTypedInfo, &tr, &tr,
+		func(row types.Row) (bool, error) {
+			return true, nil
+		})
+	if err != nil {
+		return err
+	}
+	err = TypedTableInsert(ctx, tx.tx, tablesTypedInfo,
+		&tablesRow{
+			Database: ntn.Database.String(),
+			Schema:   ntn.Schema.String(),
+			Table:    ntn.Table.String(),
+			TableId:  tr.TableId,
+			Type:     buf,
+		})
+	if err != nil {
+		return err
+	}
+
+	for _, fk := range tt.ForeignKeys {
+		if fk.RefTable == tn {
+			continue
+		}
+		err = tx.alterTable(ctx, fk.RefTable,
+			func(tbl *table, tt *TableType) error {
+				tt.ForeignRefs = slices.Clone(tt.ForeignRefs)
+				for fdx, fr := range tt.ForeignRefs {
+					if fr.Table == tn && fr.Name == fk.Name {
+						tt.ForeignRefs[fdx].Table = ntn
+					}
+				}
+				return nil
+			})
+		if err != nil {
+			return err
+		}
+	}
+	for _, fr := range tt.ForeignRefs {
+		if fr.Table == tn {
+			continue
+		}
+		err = tx.alterTable(ctx, fr.Table,
+			func(tbl *table, tt *TableType) error {
+				tt.ForeignKeys = slices.Clone(tt.ForeignKeys)
+				for fdx, fk := range tt.ForeignKeys {
+					if fk.Name == fr.Name {
+						tt.ForeignKeys[fdx].RefTable = ntn
+					}
+				}
+				return nil
+			})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.tx.RenameTable(ctx, storage.TableId(tr.TableId), ntn)
+}
+
+func (tx *transaction) AddColumn(ctx context.Context, tn types.TableName, col types.Identifier,
+	ct types.ColumnType, dflt sql.Expr, val types.Value) error {
+
+	return tx.alterTable(ctx, tn,
+		func(tbl *table, tt *TableType) error {
+			if _, ok := columnNumber(tt, col); ok {
+				return fmt.Errorf("engine: table %s: column already exists: %s", tn, col)
+			}
+
+			err := tbl.stbl.AddColumn(ctx, col, ct, val)
+			if err != nil {
+				return err
+			}
+
+			tt.ColumnDefaults = append(tt.columnDefaults(), dflt)
+			tt.ColumnNames = append(slices.Clone(tt.ColumnNames), col)
+			tt.ColumnTypes = append(slices.Clone(tt.ColumnTypes), ct)
+			return nil
+		})
+}
+
+func dropKeyColumn(key []types.ColumnKey, num types.ColumnNum) []types.ColumnKey {
+	nkey := make([]types.ColumnKey, 0, len(key))
+	for _, ck := range key {
+		if ck.Column() > num {
+			ck = types.MakeColumnKey(ck.Column()-1, ck.Reverse())
+		}
+		nkey = append(nkey, ck)
+	}
+	return nkey
+}
+
+func (tx *transaction) DropColumn(ctx context.Context, tn types.TableName,
+	col types.Identifier) error {
+
+	return tx.alterTable(ctx, tn,
+		func(tbl *table, tt *TableType) error {
+			num, ok := columnNumber(tt, col)
+			if !ok {
+				return fmt.Errorf("engine: table %s: column not found: %s", tn, col)
+			}
+			for _, ck := range tt.Key {
+				if ck.Column() == num {
+					return fmt.Errorf("engine: table %s: unable to drop primary key column: %s",
+						tn, col)
+				}
+			}
+			for _, it := range tt.Indexes {
+				for _, ck := range it.Key {
+					if ck.Column() == num {
+						return fmt.Errorf("engine: table %s: column %s used by index %s", tn,
+							col, it.Name)
+					}
+				}
+			}
+			for _, fk := range tt.ForeignKeys {
+				if slices.Contains(fk.Columns, num) {
+					return fmt.Errorf("engine: table %s: column %s used by foreign key %s", tn,
+						col, fk.Name)
+				}
+			}
+
+			err := tbl.stbl.DropColumn(ctx, tbl.storageColumn(num))
+			if err != nil {
+				return err
+			}
+
+			tt.ColumnDefaults = slices.Delete(tt.columnDefaults(), int(num), int(num)+1)
+			tt.ColumnNames = slices.Delete(slices.Clone(tt.ColumnNames), int(num), int(num)+1)
+			tt.ColumnTypes = slices.Delete(slices.Clone(tt.ColumnTypes), int(num), int(num)+1)
+			if tt.Key != nil {
+				tt.Key = dropKeyColumn(tt.Key, num)
+			}
+
+			indexes := make([]IndexType, 0, len(tt.Indexes))
+			for _, it := range tt.Indexes {
+				indexes = append(indexes,
+					IndexType{
+						Name:    it.Name,
+						Key:     dropKeyColumn(it.Key, num),
+						IndexId: it.IndexId,
+					})
+			}
+			tt.Indexes = indexes
+
+			fks := make([]ForeignKey, 0, len(tt.ForeignKeys))
+			for _, fk := range tt.ForeignKeys {
+				cols := make([]types.ColumnNum, 0, len(fk.Columns))
+				for _, c := range fk.Columns {
+					if c > num {
+						c -= 1
+					}
+					cols = append(cols, c)
+				}
+				fk.Columns = cols
+				fks = append(fks, fk)
+			}
+			tt.ForeignKeys = fks
+
+			// Constraints on the column, including CHECK constraints which reference it, are
+			// dropped along with the column.
+			var constraints []Constraint
+			for _, con := range tt.Constraints {
+				if con.Type == sql.CheckConstraint {
+					if referencesColumn(con.Check, col) {
+						continue
+					}
+				} else if con.Column == num {
+					continue
+				} else if con.Column > num {
+					con.Column -= 1
+				}
+				constraints = append(constraints, con)
+			}
+			tt.Constraints = constraints
+
+			var identities []IdentityColumn
+			for _, ic := range tt.Identities {
+				if ic.Column == num {
+					err := tx.DropSequence(ctx, ic.Sequence, true)
+					if err != nil {
+						return err
+					}
+					continue
+				} else if ic.Column > num {
+					ic.Column -= 1
+				}
+				identities = append(identities, ic)
+			}
+			tt.Identities = identities
+			return nil
+		})
+}
+
+func (tx *transaction) AlterColumn(ctx context.Context, tn types.TableName,
+	col, nam types.Identifier, ct types.ColumnType, dflt sql.Expr) error {
+
+	return tx.alterTable(ctx, tn,
+		func(tbl *table, tt *TableType) error {
+			num, ok := columnNumber(tt, col)
+			if !ok {
+				return fmt.Errorf("engine: table %s: column not found: %s", tn, col)
+			}
+			if nam != col {
+				if _, ok := columnNumber(tt, nam); ok {
+					return fmt.Errorf("engine: table %s: column already exists: %s", tn, nam)
+				}
+			}
+
+			if nam != col || ct != tt.ColumnTypes[num] {
+				err := tbl.stbl.UpdateColumn(ctx, tbl.storageColumn(num), nam, ct)
+				if err != nil {
+					return err
+				}
+			}
+
+			tt.ColumnDefaults = tt.columnDefaults()
+			tt.ColumnDefaults[num] = dflt
+			tt.ColumnNames = slices.Clone(tt.ColumnNames)
+			tt.ColumnNames[num] = nam
+			tt.ColumnTypes = slices.Clone(tt.ColumnTypes)
+			tt.ColumnTypes[num] = ct
+
+			var constraints []Constraint
+			for _, con := range tt.Constraints {
+				switch con.Type {
+				case sql.CheckConstraint:
+					if nam != col {
+						con.Check = renameColumn(con.Check, col, nam)
+					}
+				case sql.DefaultConstraint:
+					if con.Column == num && dflt == nil {
+						continue
+					}
+				case sql.NotNullConstraint:
+					if con.Column == num && !ct.NotNull {
+						continue
+					}
+				}
+				constraints = append(constraints, con)
+			}
+			tt.Constraints = constraints
+			return nil
+		})
+}
+
+// AddConstraint adds a CHECK constraint to the table; the caller is responsible for making
+// sure that the existing rows satisfy the constraint.
+func (tx *transaction) AddConstraint(ctx context.Context, tn types.TableName,
+	con Constraint) error {
+
+	if con.Type != sql.CheckConstraint {
+		panic(fmt.Sprintf("engine: table %s: unexpected constraint type: %s", tn, con.Type))
+	}
+
+	return tx.alterTable(ctx, tn,
+		func(tbl *table, tt *TableType) error {
+			if tt.constraintExists(con.Name) {
+				return fmt.Errorf("engine: table %s: constraint already exists: %s", tn,
+					con.Name)
+			}
+
+			tt.Constraints = append(slices.Clone(tt.Constraints), con)
+			return nil
+		})
+}
+
+func (tx *transaction) DropConstraint(ctx context.Context, tn types.TableName,
+	cn types.Identifier) error {
+
+	var rtn types.TableName
+	err := tx.alterTable(ctx, tn,
+		func(tbl *table, tt *TableType) error {
+			if fdx, ok := tt.foreignKeyNumber(cn); ok {
+				rtn = tt.ForeignKeys[fdx].RefTable
+				tt.ForeignKeys = slices.Delete(slices.Clone(tt.ForeignKeys), fdx, fdx+1)
+				return nil
+			}
+
+			cdx, ok := tt.constraintNumber(cn)
+			if !ok {
+				return fmt.Errorf("engine: table %s: constraint not found: %s", tn, cn)
+			}
+
+			con := tt.Constraints[cdx]
+			switch con.Type {
+			case sql.DefaultConstraint:
+				tt.ColumnDefaults = tt.columnDefaults()
+				tt.ColumnDefaults[con.Column] = nil
+			case sql.NotNullConstraint:
+				ct := tt.ColumnTypes[con.Column]
+				ct.NotNull = false
+				err := tbl.stbl.UpdateColumn(ctx, tbl.storageColumn(con.Column),
+					tt.ColumnNames[con.Column], ct)
+				if err != nil {
+					return err
+				}
+
+				tt.ColumnTypes = slices.Clone(tt.ColumnTypes)
+				tt.ColumnTypes[con.Column] = ct
+			}
+
+			tt.Constraints = slices.Delete(slices.Clone(tt.Constraints), cdx, cdx+1)
+			return nil
+		})
+	if err != nil || rtn == (types.TableName{}) {
+		return err
+	}
+
+	return tx.alterTable(ctx, rtn,
+		func(tbl *table, tt *TableType) error {
+			tt.ForeignRefs = slices.DeleteFunc(slices.Clone(tt.ForeignRefs),
+				func(fr ForeignRef) bool {
+					return fr.Table == tn && fr.Name == cn
+				})
+			return nil
+		})
+}
+
+// AddForeignKey adds a foreign key to table tn, and creates an index on the columns of the
+// foreign key if there is not already one; the caller is responsible for making sure that the
+// existing rows satisfy the foreign key.
+func (tx *transaction) AddForeignKey(ctx context.Context, tn types.TableName,
+	fk ForeignKey) error {
+
+	rtn := fk.RefTable
+	if rtn.Database == types.SYSTEM || rtn.Schema == types.METADATA {
+		return fmt.Errorf("engine: table %s: foreign key %s may not reference table %s", tn,
+			fk.Name, rtn)
+	} else if rtn.Database != tn.Database {
+		return fmt.Errorf("engine: table %s: foreign key %s references a different database: %s",
+			tn, fk.Name, rtn)
+	}
+
+	rtbl, err := tx.OpenTable(ctx, rtn)
+	if err != nil {
+		return err
+	}
+	rtt := rtbl.Type()
+	if len(rtt.Key) == 0 {
+		return fmt.Errorf("engine: table %s: foreign key %s: %s does not have a primary key",
+			tn, fk.Name, rtn)
+	} else if len(rtt.Key) != len(fk.Columns) {
+		return fmt.Errorf("engine: table %s: foreign key %s: wrong number of columns: %d", tn,
+			fk.Name, len(fk.Columns))
+	}
+
+	err = tx.alterTable(ctx, tn,
+		func(tbl *table, tt *TableType) error {
+			if tt.constraintExists(fk.Name) {
+				return fmt.Errorf("engine: table %s: constraint already exists: %s", tn,
+					fk.Name)
+			}
+
+			for cdx, col := range fk.Columns {
+				rcol := rtt.Key[cdx].Column()
+				if tt.ColumnTypes[col].Type != rtt.ColumnTypes[rcol].Type {
+					return fmt.Errorf(
+						"engine: table %s: foreign key %s: column %s does not match type of %s",
+						tn, fk.Name, tt.ColumnNames[col], rtt.ColumnNames[rcol])
+				}
+			}
+
+			if _, ok := tt.ForeignKeyIndex(fk); !ok {
+				key := make([]types.ColumnKey, 0, len(fk.Columns))
+				for _, col := range fk.Columns {
+					key = append(key, types.MakeColumnKey(col, false))
+				}
+				err := tbl.createIndex(ctx, tt, fk.Name, key, false)
+				if err != nil {
+					return err
+				}
+			}
+
+			tt.ForeignKeys = append(slices.Clone(tt.ForeignKeys), fk)
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+
+	return tx.alterTable(ctx, rtn,
+		func(tbl *table, tt *TableType) error {
+			tt.ForeignRefs = append(slices.Clone(tt.ForeignRefs),
+				ForeignRef{
+					Table: tn,
+					Name:  fk.Name,
+				})
+			return nil
+		})
+}
@@ -0,0 +1,378 @@
+package engine_test
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/leftmike/maho/engine"
+	"github.com/leftmike/maho/parser/sql"
+	"github.com/leftmike/maho/testutil"
+	"github.com/leftmike/maho/types"
+)
+
+func selectAll(t *testing.T, tx engine.Transaction, tn types.TableName) (*engine.TableType,
+	[]types.Row) {
+
+	t.Helper()
+
+	ctx := context.Background()
+	tbl, err := tx.OpenTable(ctx, tn)
+	if err != nil {
+		t.Fatalf("OpenTable(%s) failed with %s", tn, err)
+	}
+	rows, err := tbl.Rows(ctx, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Rows(%s) failed with %s", tn, err)
+	}
+	defer rows.Close(ctx)
+
+	var all []types.Row
+	for {
+		row, err := rows.Next(ctx)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Next(%s) failed with %s", tn, err)
+		}
+		all = append(all, row)
+	}
+	return tbl.Type(), all
+}
+
+func TestAlterColumns(t *testing.T) {
+	eng := newEngine(t)
+	ctx := context.Background()
+
+	tn := types.TableName{
+		Database: types.MAHO,
+		Schema:   types.PUBLIC,
+		Table:    types.ID("test", false),
+	}
+	c1 := types.ID("c1", false)
+	c2 := types.ID("c2", false)
+	c3 := types.ID("c3", false)
+	c4 := types.ID("c4", false)
+
+	colNames, colTypes, primary := testutil.MustParseColumns("c1 int primary key, c2 text")
+	tx := eng.Begin()
+	err := tx.CreateTable(ctx, tn, colNames, colTypes, primary, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateTable(%s) failed with %s", tn, err)
+	}
+	tbl, err := tx.OpenTable(ctx, tn)
+	if err != nil {
+		t.Fatalf("OpenTable(%s) failed with %s", tn, err)
+	}
+	err = tbl.Insert(ctx, testutil.MustParseRows("(1, 'one'), (2, 'two'), (3, 'three')"))
+	if err != nil {
+		t.Fatalf("Insert(%s) failed with %s", tn, err)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		t.Fatalf("Commit() failed with %s", err)
+	}
+
+	dflt := sql.Literal{Value: types.Int64Value(10)}
+	tx = eng.Begin()
+	err = tx.AddColumn(ctx, tn, c3, types.Int64ColType, dflt, types.Int64Value(10))
+	if err != nil {
+		t.Fatalf("AddColumn(%s, %s) failed with %s", tn, c3, err)
+	}
+	err = tx.AddColumn(ctx, tn, c3, types.Int64ColType, nil, nil)
+	if err == nil {
+		t.Errorf("AddColumn(%s, %s) did not fail", tn, c3)
+	}
+	err = tx.AddColumn(ctx, tn, c4, types.BoolColType, nil, nil)
+	if err == nil {
+		t.Errorf("AddColumn(%s, %s) did not fail", tn, c4)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		t.Fatalf("Commit() failed with %s", err)
+	}
+
+	tx = eng.Begin()
+	tt, rows := selectAll(t, tx, tn)
+	if tt.Version != 2 {
+		t.Errorf("Version(%s) got %d want 2", tn, tt.Version)
+	}
+	if !reflect.DeepEqual(tt.ColumnNames, []types.Identifier{c1, c2, c3}) {
+		t.Errorf("ColumnNames(%s) got %v", tn, tt.ColumnNames)
+	}
+	if !reflect.DeepEqual(tt.ColumnDefaults, []sql.Expr{nil, nil, dflt}) {
+		t.Errorf("ColumnDefaults(%s) got %v", tn, tt.ColumnDefaults)
+	}
+	want := testutil.MustParseRows("(1, 'one', 10), (2, 'two', 10), (3, 'three', 10)")
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("Rows(%s) got %v want %v", tn, rows, want)
+	}
+
+	err = tx.DropColumn(ctx, tn, c1)
+	if err == nil {
+		t.Errorf("DropColumn(%s, %s) did not fail", tn, c1)
+	}
+	err = tx.DropColumn(ctx, tn, c4)
+	if err == nil {
+		t.Errorf("DropColumn(%s, %s) did not fail", tn, c4)
+	}
+	err = tx.DropColumn(ctx, tn, c2)
+	if err != nil {
+		t.Fatalf("DropColumn(%s, %s) failed with %s", tn, c2, err)
+	}
+	err = tx.AlterColumn(ctx, tn, c3, c4, types.NullStringColType, nil)
+	if err != nil {
+		t.Fatalf("AlterColumn(%s, %s) failed with %s", tn, c3, err)
+	}
+	err = tx.AlterColumn(ctx, tn, c1, c4, tt.ColumnTypes[0], nil)
+	if err == nil {
+		t.Errorf("AlterColumn(%s, %s) did not fail", tn, c1)
+	}
+
+	tt, rows = selectAll(t, tx, tn)
+	if tt.Version != 4 {
+		t.Errorf("Version(%s) got %d want 4", tn, tt.Version)
+	}
+	if !reflect.DeepEqual(tt.ColumnNames, []types.Identifier{c1, c4}) {
+		t.Errorf("ColumnNames(%s) got %v", tn, tt.ColumnNames)
+	}
+	if !reflect.DeepEqual(tt.ColumnDefaults, []sql.Expr{nil, nil}) {
+		t.Errorf("ColumnDefaults(%s) got %v", tn, tt.ColumnDefaults)
+	}
+	want = testutil.MustParseRows("(1, '10'), (2, '10'), (3, '10')")
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("Rows(%s) got %v want %v", tn, rows, want)
+	}
+	err = tx.Rollback()
+	if err != nil {
+		t.Fatalf("Rollback() failed with %s", err)
+	}
+}
+
+func TestRenameTable(t *testing.T) {
+	eng := newEngine(t)
+	ctx := context.Background()
+
+	tn := types.TableName{
+		Database: types.MAHO,
+		Schema:   types.PUBLIC,
+		Table:    types.ID("test", false),
+	}
+	ntn := types.TableName{
+		Database: types.MAHO,
+		Schema:   types.PUBLIC,
+		Table:    types.ID("test2", false),
+	}
+	sn := types.SchemaName{
+		Database: types.MAHO,
+		Schema:   types.ID("other", false),
+	}
+	stn := types.TableName{
+		Database: sn.Database,
+		Schema:   sn.Schema,
+		Table:    ntn.Table,
+	}
+
+	colNames, colTypes, primary := testutil.MustParseColumns("c1 int primary key, c2 text")
+	tx := eng.Begin()
+	err := tx.CreateSchema(ctx, sn)
+	if err != nil {
+		t.Fatalf("CreateSchema(%s) failed with %s", sn, err)
+	}
+	for _, tn := range []types.TableName{tn, ntn} {
+		err = tx.CreateTable(ctx, tn, colNames, colTypes, primary, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("CreateTable(%s) failed with %s", tn, err)
+		}
+	}
+	tbl, err := tx.OpenTable(ctx, tn)
+	if err != nil {
+		t.Fatalf("OpenTable(%s) failed with %s", tn, err)
+	}
+	want := testutil.MustParseRows("(1, 'one'), (2, 'two'), (3, 'three')")
+	err = tbl.Insert(ctx, want)
+	if err != nil {
+		t.Fatalf("Insert(%s) failed with %s", tn, err)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		t.Fatalf("Commit() failed with %s", err)
+	}
+
+	tx = eng.Begin()
+	for _, c := range []struct {
+		tn, ntn types.TableName
+	}{
+		{tn, ntn},
+		{tn, types.TableName{
+			Database: types.MAHO,
+			Schema:   types.ID("missing", false),
+			Table:    ntn.Table,
+		}},
+		{tn, types.TableName{
+			Database: types.ID("other", false),
+			Schema:   types.PUBLIC,
+			Table:    ntn.Table,
+		}},
+		{tn, types.TableName{
+			Database: types.MAHO,
+			Schema:   types.METADATA,
+			Table:    ntn.Table,
+		}},
+		{types.TableName{
+			Database: types.MAHO,
+			Schema:   types.PUBLIC,
+			Table:    types.ID("missing", false),
+		}, stn},
+		{types.TableName{
+			Database: types.SYSTEM,
+			Schema:   types.INFO,
+			Table:    types.TABLES,
+		}, stn},
+	} {
+		err = tx.RenameTable(ctx, c.tn, c.ntn)
+		if err == nil {
+			t.Errorf("RenameTable(%s, %s) did not fail", c.tn, c.ntn)
+		}
+	}
+
+	rtn := types.TableName{
+		Database: types.MAHO,
+		Schema:   types.PUBLIC,
+		Table:    types.ID("test3", false),
+	}
+	err = tx.RenameTable(ctx, tn, rtn)
+	if err != nil {
+		t.Fatalf("RenameTable(%s, %s) failed with %s", tn, rtn, err)
+	}
+	err = tx.RenameTable(ctx, rtn, stn)
+	if err != nil {
+		t.Fatalf("RenameTable(%s, %s) failed with %s", rtn, stn, err)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		t.Fatalf("Commit() failed with %s", err)
+	}
+
+	tx = eng.Begin()
+	for _, tn := range []types.TableName{tn, rtn} {
+		_, err = tx.OpenTable(ctx, tn)
+		if err == nil {
+			t.Errorf("OpenTable(%s) did not fail", tn)
+		}
+	}
+	_, rows := selectAll(t, tx, stn)
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("Rows(%s) got %v want %v", stn, rows, want)
+	}
+	err = tx.Rollback()
+	if err != nil {
+		t.Fatalf("Rollback() failed with %s", err)
+	}
+}
+
+func TestConstraints(t *testing.T) {
+	eng := newEngine(t)
+	ctx := context.Background()
+
+	tn := types.TableName{
+		Database: types.MAHO,
+		Schema:   types.PUBLIC,
+		Table:    types.ID("test", false),
+	}
+	c1 := types.ID("c1", false)
+	c2 := types.ID("c2", false)
+	c4 := types.ID("c4", false)
+	chk1 := types.ID("chk1", false)
+	chk2 := types.ID("chk2", false)
+	nn := types.ID("nn", false)
+
+	colNames, colTypes, primary := testutil.MustParseColumns(
+		"c1 int primary key, c2 int not null, c3 int")
+	cons := []engine.Constraint{
+		{
+			Name: chk1,
+			Type: sql.CheckConstraint,
+			Check: &sql.BinaryExpr{
+				Op:    sql.GreaterThanOp,
+				Left:  sql.Ref{c1},
+				Right: sql.Literal{Value: types.Int64Value(0)},
+			},
+		},
+		{
+			Name:   nn,
+			Type:   sql.NotNullConstraint,
+			Column: 1,
+		},
+	}
+
+	tx := eng.Begin()
+	err := tx.CreateTable(ctx, tn, colNames, colTypes, primary, nil,
+		[]engine.Constraint{cons[0], cons[0]}, nil)
+	if err == nil {
+		t.Errorf("CreateTable(%s) did not fail", tn)
+	}
+	err = tx.CreateTable(ctx, tn, colNames, colTypes, primary, nil, cons, nil)
+	if err != nil {
+		t.Fatalf("CreateTable(%s) failed with %s", tn, err)
+	}
+
+	con := engine.Constraint{
+		Name: chk2,
+		Type: sql.CheckConstraint,
+		Check: &sql.BinaryExpr{
+			Op:    sql.LessThanOp,
+			Left:  sql.Ref{types.ID("c3", false)},
+			Right: sql.Ref{c2},
+		},
+	}
+	err = tx.AddConstraint(ctx, tn, con)
+	if err != nil {
+		t.Errorf("AddConstraint(%s, %s) failed with %s", tn, chk2, err)
+	}
+	err = tx.AddConstraint(ctx, tn, con)
+	if err == nil {
+		t.Errorf("AddConstraint(%s, %s) did not fail", tn, chk2)
+	}
+
+	err = tx.DropConstraint(ctx, tn, nn)
+	if err != nil {
+		t.Errorf("DropConstraint(%s, %s) failed with %s", tn, nn, err)
+	}
+	err = tx.DropConstraint(ctx, tn, nn)
+	if err == nil {
+		t.Errorf("DropConstraint(%s, %s) did not fail", tn, nn)
+	}
+
+	tt, _ := selectAll(t, tx, tn)
+	if tt.ColumnTypes[1].NotNull {
+		t.Errorf("ColumnTypes(%s)[1].NotNull got true want false", tn)
+	}
+	if !reflect.DeepEqual(tt.Constraints, []engine.Constraint{cons[0], con}) {
+		t.Errorf("Constraints(%s) got %v", tn, tt.Constraints)
+	}
+
+	err = tx.AlterColumn(ctx, tn, c2, c4, tt.ColumnTypes[1], nil)
+	if err != nil {
+		t.Errorf("AlterColumn(%s, %s) failed with %s", tn, c2, err)
+	}
+	tt, _ = selectAll(t, tx, tn)
+	if s := tt.Constraints[1].Check.String(); s != "(c3 < c4)" {
+		t.Errorf("Constraints(%s)[1] got %s want (c3 < c4)", tn, s)
+	}
+
+	err = tx.DropColumn(ctx, tn, c4)
+	if err != nil {
+		t.Errorf("DropColumn(%s, %s) failed with %s", tn, c4, err)
+	}
+	tt, _ = selectAll(t, tx, tn)
+	if !reflect.DeepEqual(tt.Constraints, []engine.Constraint{cons[0]}) {
+		t.Errorf("Constraints(%s) got %v", tn, tt.Constraints)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		t.Fatalf("Commit() failed with %s", err)
+	}
+}
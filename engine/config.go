@@ -0,0 +1,52 @@
+package engine
+
+import (
+	"context"
+
+	"github.com/leftmike/maho/types"
+)
+
+type configRow struct {
+	Name   string `maho:"size=128,primary"`
+	Value  string `maho:"size=4096"`
+	By     string `maho:"size=16"`
+	Hidden bool
+}
+
+var (
+	configTableName = types.TableName{
+		Database: types.SYSTEM,
+		Schema:   types.INFO,
+		Table:    types.CONFIG,
+	}
+	configTypedInfo = MakeTypedInfo(0, configTableName, configRow{})
+)
+
+func configTableType() *TableType {
+	tt := configTypedInfo.TableType()
+
+	// BY is a reserved keyword, so a reference to the column is parsed as the keyword.
+	tt.ColumnNames = append([]types.Identifier(nil), tt.ColumnNames...)
+	tt.ColumnNames[2] = types.BY
+	return tt
+}
+
+func (tx *transaction) openConfigTable(ctx context.Context, tn types.TableName) (Table, error) {
+	var rows []types.Row
+	for _, val := range tx.eng.cfg.Values() {
+		rows = append(rows,
+			configTypedInfo.structToRow(
+				&configRow{
+					Name:   val.Name,
+					Value:  val.Value,
+					By:     val.By.String(),
+					Hidden: val.Hidden,
+				}))
+	}
+
+	return &virtualTable{
+		tn:   tn,
+		tt:   configTableType(),
+		rows: rows,
+	}, nil
+}
@@ -7,7 +7,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"slices"
+	"sync"
+	"time"
 
+	"github.com/leftmike/maho/config"
 	"github.com/leftmike/maho/parser/sql"
 	"github.com/leftmike/maho/storage"
 	"github.com/leftmike/maho/types"
@@ -18,11 +22,18 @@ type Engine interface {
 	DropDatabase(dn types.Identifier, ifExists bool) error
 	ListDatabases() ([]types.Identifier, error)
 	Begin() Transaction
+	BeginReadOnly(asOf time.Time) (Transaction, error)
+	Config() *config.Config
 }
 
 type Transaction interface {
 	Commit(ctx context.Context) error
 	Rollback() error
+	NextStmt()
+	SetIsolation(level storage.IsolationLevel) error
+	SetReadOnly() error
+	Savepoint() storage.Savepoint
+	RollbackTo(sp storage.Savepoint) error
 
 	CreateSchema(ctx context.Context, sn types.SchemaName) error
 	DropSchema(ctx context.Context, sn types.SchemaName, ifExists bool) error
@@ -30,20 +41,51 @@ type Transaction interface {
 
 	OpenTable(ctx context.Context, tn types.TableName) (Table, error)
 	CreateTable(ctx context.Context, tn types.TableName, colNames []types.Identifier,
-		colTypes []types.ColumnType, primary []types.ColumnKey) error
+		colTypes []types.ColumnType, primary []types.ColumnKey, colDefaults []sql.Expr,
+		constraints []Constraint, identities []IdentityColumn) error
 	DropTable(ctx context.Context, tn types.TableName) error
 	ListTables(ctx context.Context, sn types.SchemaName) ([]types.Identifier, error)
 
+	RenameTable(ctx context.Context, tn, ntn types.TableName) error
+
+	AddColumn(ctx context.Context, tn types.TableName, col types.Identifier,
+		ct types.ColumnType, dflt sql.Expr, val types.Value) error
+	DropColumn(ctx context.Context, tn types.TableName, col types.Identifier) error
+	AlterColumn(ctx context.Context, tn types.TableName, col, nam types.Identifier,
+		ct types.ColumnType, dflt sql.Expr) error
+	AddConstraint(ctx context.Context, tn types.TableName, con Constraint) error
+	DropConstraint(ctx context.Context, tn types.TableName, cn types.Identifier) error
+
 	CreateIndex(ctx context.Context, tn types.TableName, in types.Identifier,
-		key []types.ColumnKey) error
+		key []types.ColumnKey, unique bool) error
 	DropIndex(ctx context.Context, tn types.TableName, in types.Identifier) error
+
+	AddForeignKey(ctx context.Context, tn types.TableName, fk ForeignKey) error
+
+	CreateSequence(ctx context.Context, sqn types.TableName, seq Sequence) error
+	DropSequence(ctx context.Context, sqn types.TableName, ifExists bool) error
+	LookupSequence(ctx context.Context, sqn types.TableName) (Sequence, error)
+	AlterSequence(ctx context.Context, sqn types.TableName, seq Sequence) error
+	NextValue(ctx context.Context, sqn types.TableName) (int64, error)
+	SetValue(ctx context.Context, sqn types.TableName, val int64, called bool) error
+	RestartSequence(ctx context.Context, sqn types.TableName, val int64) error
 }
 
 type Table interface {
 	Name() types.TableName
 	Type() *TableType
 	TableId() storage.TableId
-	// XXX
+
+	Rows(ctx context.Context, cols []types.ColumnNum, minRow, maxRow types.Row,
+		pred storage.Predicate) (storage.Rows, error)
+	Scan(ctx context.Context, cols []types.ColumnNum, spec storage.ScanSpec,
+		pred storage.Predicate) (storage.Rows, error)
+	SupportsPredicate(pred storage.Predicate) bool
+	IndexRows(ctx context.Context, in types.Identifier, cols []types.ColumnNum, minRow,
+		maxRow types.Row) (storage.Rows, error)
+	Insert(ctx context.Context, rows []types.Row) error
+	Upsert(ctx context.Context, rows []types.Row) error
+	DeleteRange(ctx context.Context, minRow, maxRow types.Row) (int64, error)
 }
 
 type TableType struct {
@@ -53,11 +95,51 @@ type TableType struct {
 	Key            []types.ColumnKey
 	ColumnDefaults []sql.Expr
 	Indexes        []IndexType
+	Constraints    []Constraint
+	ForeignKeys    []ForeignKey
+	ForeignRefs    []ForeignRef
+	Identities     []IdentityColumn
+}
+
+// Constraint is a named constraint. NOT NULL and DEFAULT constraints are enforced using the
+// column type and the column default; they are only recorded here when they are named.
+type Constraint struct {
+	Name   types.Identifier
+	Type   sql.ConstraintType // DefaultConstraint, NotNullConstraint, or CheckConstraint
+	Column types.ColumnNum    // DefaultConstraint and NotNullConstraint
+	Check  sql.Expr           // CheckConstraint
+}
+
+// IdentityColumn is a SERIAL or IDENTITY column; the default of the column is the next value of
+// Sequence, which is owned by the table.
+type IdentityColumn struct {
+	Column   types.ColumnNum
+	Sequence types.TableName
+	Always   bool
+}
+
+// ForeignKey references the primary key of RefTable; Columns are in the same order as the
+// columns of the primary key.
+type ForeignKey struct {
+	Name     types.Identifier
+	Columns  []types.ColumnNum
+	RefTable types.TableName
+	OnDelete sql.RefAction
+	OnUpdate sql.RefAction
+	Deferred bool // checked when the transaction commits
+}
+
+// ForeignRef is the foreign key Name of Table which references this table.
+type ForeignRef struct {
+	Table types.TableName
+	Name  types.Identifier
 }
 
 type IndexType struct {
-	Name types.Identifier
-	Key  []types.ColumnKey
+	Name    types.Identifier
+	Key     []types.ColumnKey
+	IndexId storage.IndexId
+	Unique  bool // rows with a NULL in the key are never duplicates
 }
 
 var (
@@ -65,17 +147,33 @@ var (
 )
 
 type engine struct {
-	store storage.Store
+	store     storage.Store
+	cfg       *config.Config
+	mutex     sync.Mutex
+	sequences map[int64]*sequenceValue
+	saveMutex sync.Mutex // serializes saving sequences
 }
 
 type transaction struct {
-	tx storage.Transaction
+	eng       *engine
+	tx        storage.Transaction
+	sequences map[int64]usedSequence
+	restarted map[int64]*restartedSequence
+	readOnly  bool
+}
+
+type savepoint struct {
+	sp        storage.Savepoint
+	restarted map[int64]restartedSequence
 }
 
 type table struct {
-	tn  types.TableName
-	tt  *TableType
-	tid storage.TableId
+	tx    *transaction
+	tn    types.TableName
+	tt    *TableType
+	tid   storage.TableId
+	stbl  storage.Table
+	rowid bool // storage prepended a rowid column
 }
 
 const (
@@ -88,9 +186,15 @@ const (
 	nextTableIdSequence                 = "next_table_id"
 )
 
-func NewEngine(store storage.Store) Engine {
+func NewEngine(store storage.Store, cfg *config.Config) Engine {
+	if cfg == nil {
+		cfg = config.NewConfig(nil)
+	}
+
 	return &engine{
-		store: store,
+		store:     store,
+		cfg:       cfg,
+		sequences: map[int64]*sequenceValue{},
 	}
 }
 
@@ -106,8 +210,15 @@ var (
 )
 
 type sequencesRow struct {
-	Sequence string `maho:"size=128,primary"`
-	Current  int64
+	Sequence   string `maho:"size=128,primary"`
+	Current    int64
+	SequenceId int64
+	Start      int64
+	Increment  int64
+	MinValue   int64
+	MaxValue   int64
+	Cycle      bool
+	Called     bool
 }
 
 type databasesRow struct {
@@ -207,16 +318,52 @@ func (eng *engine) ListDatabases() ([]types.Identifier, error) {
 
 func (eng *engine) Begin() Transaction {
 	return &transaction{
-		tx: eng.store.Begin(),
+		eng: eng,
+		tx:  eng.store.Begin(),
+	}
+}
+
+func (eng *engine) BeginReadOnly(asOf time.Time) (Transaction, error) {
+	stx, err := eng.store.BeginReadOnly(asOf)
+	if err != nil {
+		return nil, err
 	}
+	return &transaction{
+		eng:      eng,
+		tx:       stx,
+		readOnly: true,
+	}, nil
+}
+
+func (eng *engine) Config() *config.Config {
+	return eng.cfg
 }
 
 func (tx *transaction) Commit(ctx context.Context) error {
 	if tx.tx == nil {
 		return errTransactionComplete
 	}
-	err := tx.tx.Commit(ctx)
+
+	var reserved []reservedSequence
+	var err error
+	if len(tx.sequences) > 0 || len(tx.restarted) > 0 {
+		tx.eng.saveMutex.Lock()
+		defer tx.eng.saveMutex.Unlock()
+
+		reserved, err = tx.saveSequences(ctx)
+	}
+	if err != nil {
+		tx.tx.Rollback()
+	} else {
+		err = tx.tx.Commit(ctx)
+	}
 	tx.tx = nil
+	if err == nil {
+		tx.commitRestarts()
+		tx.eng.commitReserved(reserved)
+	}
+	tx.sequences = nil
+	tx.restarted = nil
 	return err
 }
 
@@ -226,10 +373,80 @@ func (tx *transaction) Rollback() error {
 	}
 	err := tx.tx.Rollback()
 	tx.tx = nil
+	tx.sequences = nil
+	tx.restarted = nil
 	return err
 }
 
+func (tx *transaction) NextStmt() {
+	tx.tx.NextStmt()
+}
+
+func (tx *transaction) SetIsolation(level storage.IsolationLevel) error {
+	if tx.tx == nil {
+		return errTransactionComplete
+	}
+	return tx.tx.SetIsolation(level)
+}
+
+func (tx *transaction) SetReadOnly() error {
+	if tx.tx == nil {
+		return errTransactionComplete
+	}
+	err := tx.tx.SetReadOnly()
+	if err != nil {
+		return err
+	}
+	tx.readOnly = true
+	return nil
+}
+
+// Savepoints only include the sequences restarted by the transaction: like nextval, other
+// changes to sequences are never rolled back.
+func (tx *transaction) Savepoint() storage.Savepoint {
+	tx.eng.mutex.Lock()
+	defer tx.eng.mutex.Unlock()
+
+	restarted := map[int64]restartedSequence{}
+	for sid, rs := range tx.restarted {
+		restarted[sid] = *rs
+	}
+	return savepoint{
+		sp:        tx.tx.Savepoint(),
+		restarted: restarted,
+	}
+}
+
+func (tx *transaction) RollbackTo(sp storage.Savepoint) error {
+	if tx.tx == nil {
+		return errTransactionComplete
+	}
+
+	s := sp.(savepoint)
+	err := tx.tx.RollbackTo(s.sp)
+	if err != nil {
+		return err
+	}
+
+	tx.eng.mutex.Lock()
+	defer tx.eng.mutex.Unlock()
+
+	tx.restarted = nil
+	for sid, rs := range s.restarted {
+		rs := rs
+		if tx.restarted == nil {
+			tx.restarted = map[int64]*restartedSequence{}
+		}
+		tx.restarted[sid] = &rs
+	}
+	return nil
+}
+
 func (tx *transaction) CreateSchema(ctx context.Context, sn types.SchemaName) error {
+	if sn.Schema == types.METADATA {
+		return fmt.Errorf("engine: schema name reserved: %s", sn)
+	}
+
 	err := TypedTableLookup(ctx, tx.tx, databasesTypedInfo,
 		&databasesRow{
 			Database: sn.Database.String(),
@@ -306,6 +523,12 @@ func (tx *transaction) ListSchemas(ctx context.Context, dn types.Identifier) ([]
 }
 
 func (tx *transaction) OpenTable(ctx context.Context, tn types.TableName) (Table, error) {
+	if tn.Schema == types.METADATA {
+		return tx.openMetadataTable(ctx, tn)
+	} else if tn == configTableName {
+		return tx.openConfigTable(ctx, tn)
+	}
+
 	tr := tablesRow{
 		Database: tn.Database.String(),
 		Schema:   tn.Schema.String(),
@@ -323,10 +546,35 @@ func (tx *transaction) OpenTable(ctx context.Context, tn types.TableName) (Table
 		return nil, err
 	}
 
+	return tx.openTable(ctx, tn, storage.TableId(tr.TableId), tt)
+}
+
+func (tx *transaction) openTable(ctx context.Context, tn types.TableName, tid storage.TableId,
+	tt *TableType) (*table, error) {
+
+	colNames, colTypes, primary := tx.tx.Store().SetupColumns(tt.ColumnNames, tt.ColumnTypes,
+		tt.Key)
+
+	var rowid bool
+	if len(colNames) != len(tt.ColumnNames) {
+		if len(colNames) != len(tt.ColumnNames)+1 || colNames[0] != types.ROWID {
+			panic(fmt.Sprintf("engine: %s: unexpected storage columns: %v", tn, colNames))
+		}
+		rowid = true
+	}
+
+	stbl, err := tx.tx.OpenTable(ctx, tid, tn, colNames, colTypes, primary)
+	if err != nil {
+		return nil, err
+	}
+
 	return &table{
-		tn:  tn,
-		tt:  tt,
-		tid: storage.TableId(tr.TableId),
+		tx:    tx,
+		tn:    tn,
+		tt:    tt,
+		tid:   tid,
+		stbl:  stbl,
+		rowid: rowid,
 	}, nil
 }
 
@@ -374,7 +622,8 @@ func (tx *transaction) nextTableId(ctx context.Context) (int64, error) {
 }
 
 func (tx *transaction) CreateTable(ctx context.Context, tn types.TableName,
-	colNames []types.Identifier, colTypes []types.ColumnType, primary []types.ColumnKey) error {
+	colNames []types.Identifier, colTypes []types.ColumnType, primary []types.ColumnKey,
+	colDefaults []sql.Expr, constraints []Constraint, identities []IdentityColumn) error {
 
 	err := TypedTableLookup(ctx, tx.tx, schemasTypedInfo,
 		&schemasRow{
@@ -387,12 +636,27 @@ func (tx *transaction) CreateTable(ctx context.Context, tn types.TableName,
 		return err
 	}
 
+	for cdx, con := range constraints {
+		for _, c := range constraints[:cdx] {
+			if c.Name == con.Name {
+				return fmt.Errorf("engine: table %s: duplicate constraint name: %s", tn, con.Name)
+			}
+		}
+	}
+
+	if colDefaults != nil && len(colDefaults) != len(colNames) {
+		panic(fmt.Sprintf("engine: table %s: len(colDefaults) != len(colNames): %d %d", tn,
+			len(colDefaults), len(colNames)))
+	}
+
 	tt := TableType{
-		Version:     1,
-		ColumnNames: colNames,
-		ColumnTypes: colTypes,
-		Key:         primary,
-		// XXX: ColumnDefaults
+		Version:        1,
+		ColumnNames:    colNames,
+		ColumnTypes:    colTypes,
+		Key:            primary,
+		ColumnDefaults: colDefaults,
+		Constraints:    constraints,
+		Identities:     identities,
 		// XXX: Indexes
 	}
 	buf, err := tt.Encode()
@@ -403,7 +667,7 @@ func (tx *transaction) CreateTable(ctx context.Context, tn types.TableName,
 	if err != nil {
 		return err
 	}
-	return TypedTableInsert(ctx, tx.tx, tablesTypedInfo,
+	err = TypedTableInsert(ctx, tx.tx, tablesTypedInfo,
 		&tablesRow{
 			Database: tn.Database.String(),
 			Schema:   tn.Schema.String(),
@@ -411,32 +675,217 @@ func (tx *transaction) CreateTable(ctx context.Context, tn types.TableName,
 			TableId:  tid,
 			Type:     buf,
 		})
+	if err != nil {
+		return err
+	}
+
+	scolNames, colTypes, primary := tx.tx.Store().SetupColumns(colNames, colTypes, primary)
+	err = tx.tx.CreateTable(ctx, storage.TableId(tid), tn, scolNames, colTypes, primary)
+	if err != nil {
+		return err
+	}
+
+	if len(scolNames) != len(colNames) {
+		return tx.createRowidSequence(ctx, storage.TableId(tid))
+	}
+	return nil
 }
 
+// DropTable drops table tn along with the sequences it owns; the table must not be referenced
+// by a foreign key of another table.
 func (tx *transaction) DropTable(ctx context.Context, tn types.TableName) error {
-	// XXX
-	return nil
+	if tn.Database == types.SYSTEM || tn.Schema == types.METADATA {
+		return fmt.Errorf("engine: table %s may not be dropped", tn)
+	}
+
+	tr := tablesRow{
+		Database: tn.Database.String(),
+		Schema:   tn.Schema.String(),
+		Table:    tn.Table.String(),
+	}
+	err := TypedTableLookup(ctx, tx.tx, tablesTypedInfo, &tr)
+	if err == io.EOF {
+		return fmt.Errorf("engine: table not found: %s", tn)
+	} else if err != nil {
+		return err
+	}
+
+	tt, err := DecodeTableType(tr.Type)
+	if err != nil {
+		return err
+	}
+	for _, fr := range tt.ForeignRefs {
+		if fr.Table != tn {
+			return fmt.Errorf("engine: table %s: referenced by foreign key %s on %s", tn,
+				fr.Name, fr.Table)
+		}
+	}
+
+	for _, fk := range tt.ForeignKeys {
+		if fk.RefTable == tn {
+			continue
+		}
+		err = tx.alterTable(ctx, fk.RefTable,
+			func(tbl *table, tt *TableType) error {
+				tt.ForeignRefs = slices.DeleteFunc(slices.Clone(tt.ForeignRefs),
+					func(fr ForeignRef) bool {
+						return fr.Table == tn && fr.Name == fk.Name
+					})
+				return nil
+			})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, ic := range tt.Identities {
+		err = tx.DropSequence(ctx, ic.Sequence, true)
+		if err != nil {
+			return err
+		}
+	}
+	tid := storage.TableId(tr.TableId)
+	colNames, _, _ := tx.tx.Store().SetupColumns(tt.ColumnNames, tt.ColumnTypes, tt.Key)
+	if len(colNames) != len(tt.ColumnNames) {
+		sr := &sequencesRow{
+			Sequence: rowidSequence(tid),
+		}
+		err = TypedTableDelete(ctx, tx.tx, sequencesTypedInfo, sr, sr,
+			func(row types.Row) (bool, error) {
+				return true, nil
+			})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = TypedTableDelete(ctx, tx.tx, tablesTypedInfo, &tr, &tr,
+		func(row types.Row) (bool, error) {
+			return true, nil
+		})
+	if err != nil {
+		return err
+	}
+	return tx.tx.DropTable(ctx, tid)
+}
+
+func (tx *transaction) selectTables(ctx context.Context, dn, sn types.Identifier,
+	fn func(tr *tablesRow) error) error {
+
+	return TypedTableSelect(ctx, tx.tx, tablesTypedInfo,
+		&tablesRow{
+			Database: dn.String(),
+			Schema:   sn.String(),
+		}, nil, func(row types.Row) error {
+			var tr tablesRow
+			tablesTypedInfo.RowToStruct(row, &tr)
+
+			if tr.Database != dn.String() || (sn != 0 && tr.Schema != sn.String()) {
+				return io.EOF
+			}
+			return fn(&tr)
+		})
 }
 
 func (tx *transaction) ListTables(ctx context.Context, sn types.SchemaName) ([]types.Identifier,
 	error) {
 
-	// XXX
-	return nil, nil
+	err := TypedTableLookup(ctx, tx.tx, schemasTypedInfo,
+		&schemasRow{
+			Database: sn.Database.String(),
+			Schema:   sn.Schema.String(),
+		})
+	if err == io.EOF {
+		return nil, fmt.Errorf("engine: schema not found: %s", sn)
+	} else if err != nil {
+		return nil, err
+	}
+
+	var tables []types.Identifier
+	err = tx.selectTables(ctx, sn.Database, sn.Schema,
+		func(tr *tablesRow) error {
+			tables = append(tables, types.ID(tr.Table, true))
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return tables, nil
 }
 
 func (tx *transaction) CreateIndex(ctx context.Context, tn types.TableName, in types.Identifier,
-	key []types.ColumnKey) error {
+	key []types.ColumnKey, unique bool) error {
+
+	return tx.alterTable(ctx, tn,
+		func(tbl *table, tt *TableType) error {
+			return tbl.createIndex(ctx, tt, in, key, unique)
+		})
+}
 
-	// XXX
+func (tbl *table) createIndex(ctx context.Context, tt *TableType, in types.Identifier,
+	key []types.ColumnKey, unique bool) error {
+
+	iid := storage.IndexId(1)
+	for _, it := range tt.Indexes {
+		if it.Name == in {
+			return fmt.Errorf("engine: table %s: index already exists: %s", tbl.tn, in)
+		} else if it.IndexId >= iid {
+			iid = it.IndexId + 1
+		}
+	}
+
+	skey := key
+	if tbl.rowid {
+		skey = make([]types.ColumnKey, 0, len(key))
+		for _, ck := range key {
+			skey = append(skey, types.MakeColumnKey(ck.Column()+1, ck.Reverse()))
+		}
+	}
+	err := tbl.stbl.CreateIndex(ctx, iid, skey, unique)
+	if err != nil {
+		return err
+	}
+
+	tt.Indexes = append(slices.Clone(tt.Indexes),
+		IndexType{
+			Name:    in,
+			Key:     key,
+			IndexId: iid,
+			Unique:  unique,
+		})
 	return nil
 }
 
+func (tt *TableType) indexNumber(in types.Identifier) (int, bool) {
+	for idx, it := range tt.Indexes {
+		if it.Name == in {
+			return idx, true
+		}
+	}
+	return 0, false
+}
+
 func (tx *transaction) DropIndex(ctx context.Context, tn types.TableName,
 	in types.Identifier) error {
 
-	// XXX
-	return nil
+	return tx.alterTable(ctx, tn,
+		func(tbl *table, tt *TableType) error {
+			idx, ok := tt.indexNumber(in)
+			if !ok {
+				return fmt.Errorf("engine: table %s: index not found: %s", tn, in)
+			}
+			iid := tt.Indexes[idx].IndexId
+			tt.Indexes = slices.Delete(slices.Clone(tt.Indexes), idx, idx+1)
+
+			for _, fk := range tt.ForeignKeys {
+				if _, ok := tt.ForeignKeyIndex(fk); !ok {
+					return fmt.Errorf("engine: table %s: index %s needed by foreign key %s", tn,
+						in, fk.Name)
+				}
+			}
+
+			return tbl.stbl.DropIndex(ctx, iid)
+		})
 }
 
 func (tbl *table) Name() types.TableName {
@@ -450,3 +899,138 @@ func (tbl *table) Type() *TableType {
 func (tbl *table) TableId() storage.TableId {
 	return tbl.tid
 }
+
+func (tbl *table) Rows(ctx context.Context, cols []types.ColumnNum, minRow, maxRow types.Row,
+	pred storage.Predicate) (storage.Rows, error) {
+
+	if !tbl.rowid {
+		return tbl.stbl.Rows(ctx, cols, minRow, maxRow, pred)
+	}
+
+	if minRow != nil || maxRow != nil {
+		return nil, fmt.Errorf("engine: table %s: no primary key for range", tbl.tn)
+	}
+
+	// Skip the rowid column prepended by storage.
+	if cols == nil {
+		cols = make([]types.ColumnNum, len(tbl.tt.ColumnNames))
+		for idx := range cols {
+			cols[idx] = types.ColumnNum(idx + 1)
+		}
+	} else {
+		cols = rowidColumns(cols)
+	}
+	if pred != nil {
+		pred = rowidPredicateColumns(pred)
+	}
+
+	rows, err := tbl.stbl.Rows(ctx, cols, nil, nil, pred)
+	if err != nil {
+		return nil, err
+	}
+	return rowidRows{rows}, nil
+}
+
+func (tbl *table) Scan(ctx context.Context, cols []types.ColumnNum, spec storage.ScanSpec,
+	pred storage.Predicate) (storage.Rows, error) {
+
+	if !tbl.rowid {
+		return tbl.stbl.Scan(ctx, cols, spec, pred)
+	}
+
+	if spec.MinRow != nil || spec.MaxRow != nil || spec.Prefix != 0 {
+		return nil, fmt.Errorf("engine: table %s: no primary key for range", tbl.tn)
+	}
+
+	if cols == nil {
+		cols = make([]types.ColumnNum, len(tbl.tt.ColumnNames))
+		for idx := range cols {
+			cols[idx] = types.ColumnNum(idx + 1)
+		}
+	} else {
+		cols = rowidColumns(cols)
+	}
+	if pred != nil {
+		pred = rowidPredicateColumns(pred)
+	}
+
+	rows, err := tbl.stbl.Scan(ctx, cols, storage.ScanSpec{Reverse: spec.Reverse}, pred)
+	if err != nil {
+		return nil, err
+	}
+	return rowidRows{rows}, nil
+}
+
+func (tbl *table) SupportsPredicate(pred storage.Predicate) bool {
+	if tbl.rowid {
+		pred = rowidPredicateColumns(pred)
+	}
+	return tbl.stbl.SupportsPredicate(pred)
+}
+
+func (tbl *table) IndexRows(ctx context.Context, in types.Identifier, cols []types.ColumnNum,
+	minRow, maxRow types.Row) (storage.Rows, error) {
+
+	idx, ok := tbl.tt.indexNumber(in)
+	if !ok {
+		return nil, fmt.Errorf("engine: table %s: index not found: %s", tbl.tn, in)
+	}
+	iid := tbl.tt.Indexes[idx].IndexId
+
+	if !tbl.rowid {
+		return tbl.stbl.IndexRows(ctx, iid, cols, minRow, maxRow)
+	}
+
+	if minRow != nil {
+		minRow = append(types.Row{nil}, minRow...)
+	}
+	if maxRow != nil {
+		maxRow = append(types.Row{nil}, maxRow...)
+	}
+	if cols == nil {
+		cols = make([]types.ColumnNum, len(tbl.tt.ColumnNames))
+		for idx := range cols {
+			cols[idx] = types.ColumnNum(idx + 1)
+		}
+	} else {
+		cols = rowidColumns(cols)
+	}
+
+	rows, err := tbl.stbl.IndexRows(ctx, iid, cols, minRow, maxRow)
+	if err != nil {
+		return nil, err
+	}
+	return rowidRows{rows}, nil
+}
+
+func (tbl *table) Insert(ctx context.Context, rows []types.Row) error {
+	if tbl.rowid {
+		rrows := make([]types.Row, 0, len(rows))
+		for _, row := range rows {
+			rowid, err := tbl.tx.nextRowid(ctx, tbl.tn, tbl.tid)
+			if err != nil {
+				return err
+			}
+			rrows = append(rrows, append(types.Row{types.Int64Value(rowid)}, row...))
+		}
+		rows = rrows
+	}
+
+	return tbl.stbl.Insert(ctx, rows)
+}
+
+func (tbl *table) Upsert(ctx context.Context, rows []types.Row) error {
+	if tbl.rowid {
+		return fmt.Errorf("engine: table %s: no primary key for upsert", tbl.tn)
+	}
+
+	return tbl.stbl.Upsert(ctx, rows)
+}
+
+func (tbl *table) DeleteRange(ctx context.Context, minRow, maxRow types.Row) (int64, error) {
+	if tbl.rowid && (minRow != nil || maxRow != nil) {
+		return 0, fmt.Errorf("engine: table %s: no primary key for range", tbl.tn)
+	}
+
+	return tbl.stbl.DeleteRange(ctx, minRow, maxRow)
+}
@@ -3,11 +3,13 @@ package engine_test
 import (
 	"context"
 	"fmt"
+	"io"
 	"reflect"
 	"slices"
 	"testing"
 
 	"github.com/leftmike/maho/engine"
+	"github.com/leftmike/maho/parser/sql"
 	"github.com/leftmike/maho/storage"
 	"github.com/leftmike/maho/storage/basic"
 	"github.com/leftmike/maho/testutil"
@@ -40,6 +42,21 @@ func TestTableType(t *testing.T) {
 				types.MakeColumnKey(2, true),
 				types.MakeColumnKey(5, false),
 			},
+			ColumnDefaults: []sql.Expr{
+				nil,
+				sql.Literal{Value: types.Int64Value(123)},
+				&sql.SExpr{
+					Name: types.ID("nextval", false),
+					Args: []sql.Expr{sql.Literal{Value: types.StringValue("seq")}},
+				},
+				nil,
+				&sql.BinaryExpr{
+					Op:    sql.ConcatOp,
+					Left:  sql.Literal{Value: types.StringValue("abc")},
+					Right: &sql.SExpr{Name: types.ID("now", false)},
+				},
+				nil,
+			},
 		},
 	}
 
@@ -71,7 +88,7 @@ func newEngine(t *testing.T) engine.Engine {
 		t.Fatalf("Init() failed with %s", err)
 	}
 
-	return engine.NewEngine(store)
+	return engine.NewEngine(store, nil)
 }
 
 type createDatabase struct {
@@ -470,12 +487,279 @@ func TestTable(t *testing.T) {
 			primary:  primary2,
 			tid:      513,
 		},
+		listTables{
+			sn: types.SchemaName{
+				Database: types.MAHO,
+				Schema:   types.PUBLIC,
+			},
+			tables: []types.Identifier{types.ID("test", false), types.ID("test2", false)},
+		},
+		listTables{
+			sn: types.SchemaName{
+				Database: types.MAHO,
+				Schema:   types.ID("not_schema", false),
+			},
+			fail: true,
+		},
 		commit{},
 	})
 
 	// XXX: test CreateTable and OpenTable
 }
 
+func TestDropTable(t *testing.T) {
+	ctx := context.Background()
+	s := t.TempDir()
+	store, err := basic.NewStore(s)
+	if err != nil {
+		t.Fatalf("NewStore(%s) failed with %s", s, err)
+	}
+	err = engine.Init(store)
+	if err != nil {
+		t.Fatalf("Init() failed with %s", err)
+	}
+	eng := engine.NewEngine(store, nil)
+
+	tn := types.TableName{
+		Database: types.MAHO,
+		Schema:   types.PUBLIC,
+		Table:    types.ID("test", false),
+	}
+	colNames, colTypes, primary := testutil.MustParseColumns("c1 int primary key, c2 text")
+	tx := eng.Begin()
+	err = tx.CreateTable(ctx, tn, colNames, colTypes, primary, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateTable(%s) failed with %s", tn, err)
+	}
+	tbl, err := tx.OpenTable(ctx, tn)
+	if err != nil {
+		t.Fatalf("OpenTable(%s) failed with %s", tn, err)
+	}
+	tid := tbl.TableId()
+	err = tbl.Insert(ctx, testutil.MustParseRows("(1, 'one'), (2, 'two'), (3, 'three')"))
+	if err != nil {
+		t.Fatalf("Insert(%s) failed with %s", tn, err)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		t.Fatalf("Commit() failed with %s", err)
+	}
+
+	tx = eng.Begin()
+	err = tx.DropTable(ctx, tn)
+	if err != nil {
+		t.Fatalf("DropTable(%s) failed with %s", tn, err)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		t.Fatalf("Commit() failed with %s", err)
+	}
+
+	tx = eng.Begin()
+	_, err = tx.OpenTable(ctx, tn)
+	if err == nil {
+		t.Errorf("OpenTable(%s) did not fail", tn)
+	}
+	err = tx.DropTable(ctx, tn)
+	if err == nil {
+		t.Errorf("DropTable(%s) did not fail", tn)
+	}
+	tables, err := tx.ListTables(ctx, tn.SchemaName())
+	if err != nil {
+		t.Errorf("ListTables(%s) failed with %s", tn.SchemaName(), err)
+	} else if slices.Contains(tables, tn.Table) {
+		t.Errorf("ListTables(%s) got %v", tn.SchemaName(), tables)
+	}
+	err = tx.Rollback()
+	if err != nil {
+		t.Fatalf("Rollback() failed with %s", err)
+	}
+
+	// A table referenced by a foreign key of another table can not be dropped; dropping the
+	// other table removes the reference.
+	ctn := types.TableName{
+		Database: types.MAHO,
+		Schema:   types.PUBLIC,
+		Table:    types.ID("child", false),
+	}
+	tx = eng.Begin()
+	err = tx.CreateTable(ctx, tn, colNames, colTypes, primary, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateTable(%s) failed with %s", tn, err)
+	}
+	err = tx.CreateTable(ctx, ctn, colNames, colTypes, primary, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateTable(%s) failed with %s", ctn, err)
+	}
+	err = tx.AddForeignKey(ctx, ctn,
+		engine.ForeignKey{
+			Name:     types.ID("child_fk", false),
+			Columns:  []types.ColumnNum{0},
+			RefTable: tn,
+		})
+	if err != nil {
+		t.Fatalf("AddForeignKey(%s) failed with %s", ctn, err)
+	}
+	err = tx.DropTable(ctx, tn)
+	if err == nil {
+		t.Errorf("DropTable(%s) did not fail", tn)
+	}
+	err = tx.DropTable(ctx, ctn)
+	if err != nil {
+		t.Errorf("DropTable(%s) failed with %s", ctn, err)
+	}
+	err = tx.DropTable(ctx, tn)
+	if err != nil {
+		t.Errorf("DropTable(%s) failed with %s", tn, err)
+	}
+	err = tx.Rollback()
+	if err != nil {
+		t.Fatalf("Rollback() failed with %s", err)
+	}
+
+	// The table is gone from storage: it can be created again, and it has no rows.
+	stx := store.Begin()
+	defer stx.Rollback()
+	err, panicked := testutil.ErrorPanicked(func() error {
+		return stx.CreateTable(ctx, tid, tn, colNames, colTypes, primary)
+	})
+	if panicked {
+		t.Fatalf("CreateTable(%d) panicked", tid)
+	} else if err != nil {
+		t.Fatalf("CreateTable(%d) failed with %s", tid, err)
+	}
+	stbl, err := stx.OpenTable(ctx, tid, tn, colNames, colTypes, primary)
+	if err != nil {
+		t.Fatalf("OpenTable(%d) failed with %s", tid, err)
+	}
+	rows, err := stbl.Rows(ctx, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Rows(%d) failed with %s", tid, err)
+	}
+	defer rows.Close(ctx)
+	row, err := rows.Next(ctx)
+	if err != io.EOF {
+		t.Errorf("Next(%d) got %v, %v want io.EOF", tid, row, err)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	eng := newEngine(t)
+
+	colNames, colTypes, primary := testutil.MustParseColumns(
+		"c1 int primary key, c2 char(64) not null")
+	testEngine(t, eng.Begin(), []interface{}{
+		createSchema{
+			sn: types.SchemaName{
+				Database: types.MAHO,
+				Schema:   types.METADATA,
+			},
+			fail: true,
+		},
+		createTable{
+			tn: types.TableName{
+				Database: types.MAHO,
+				Schema:   types.PUBLIC,
+				Table:    types.ID("test", false),
+			},
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		commit{},
+	})
+
+	cases := []struct {
+		tn   types.TableName
+		rows []types.Row
+		fail bool
+	}{
+		{
+			tn: types.TableName{Database: types.MAHO, Schema: types.METADATA,
+				Table: types.SCHEMAS},
+			rows: []types.Row{
+				{types.StringValue("public")},
+			},
+		},
+		{
+			tn: types.TableName{Database: types.MAHO, Schema: types.METADATA,
+				Table: types.TABLES},
+			rows: []types.Row{
+				{types.StringValue("public"), types.StringValue("test")},
+			},
+		},
+		{
+			tn: types.TableName{Database: types.MAHO, Schema: types.METADATA,
+				Table: types.COLUMNS},
+			rows: []types.Row{
+				{types.StringValue("public"), types.StringValue("test"), types.Int64Value(1),
+					types.StringValue("c1"), types.StringValue("INT"),
+					types.BoolValue(true), nil},
+				{types.StringValue("public"), types.StringValue("test"), types.Int64Value(2),
+					types.StringValue("c2"), types.StringValue("CHAR(64)"),
+					types.BoolValue(true), nil},
+			},
+		},
+		{
+			tn: types.TableName{Database: types.MAHO, Schema: types.METADATA,
+				Table: types.CONSTRAINTS},
+			rows: []types.Row{
+				{types.StringValue("public"), types.StringValue("test"),
+					types.StringValue("primary"), types.StringValue("PRIMARY KEY"),
+					types.StringValue("(c1)")},
+			},
+		},
+		{
+			tn: types.TableName{Database: types.MAHO, Schema: types.METADATA,
+				Table: types.ID("not_table", false)},
+			fail: true,
+		},
+		{
+			tn: types.TableName{Database: types.ID("not_db", false), Schema: types.METADATA,
+				Table: types.TABLES},
+			fail: true,
+		},
+	}
+
+	ctx := context.Background()
+	for _, c := range cases {
+		tx := eng.Begin()
+		tbl, err := tx.OpenTable(ctx, c.tn)
+		if c.fail {
+			if err == nil {
+				t.Errorf("OpenTable(%s) did not fail", c.tn)
+			}
+		} else if err != nil {
+			t.Errorf("OpenTable(%s) failed with %s", c.tn, err)
+		} else {
+			rows, err := tbl.Rows(ctx, nil, nil, nil, nil)
+			if err != nil {
+				t.Fatalf("Rows(%s) failed with %s", c.tn, err)
+			}
+			var got []types.Row
+			for {
+				row, err := rows.Next(ctx)
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatalf("Next(%s) failed with %s", c.tn, err)
+				}
+				got = append(got, row)
+			}
+			rows.Close(ctx)
+
+			if !reflect.DeepEqual(got, c.rows) {
+				t.Errorf("Rows(%s) got %v want %v", c.tn, got, c.rows)
+			}
+			err = tbl.Insert(ctx, c.rows)
+			if err == nil {
+				t.Errorf("Insert(%s) did not fail", c.tn)
+			}
+		}
+		tx.Rollback()
+	}
+}
+
 type createSchema struct {
 	sn   types.SchemaName
 	fail bool
@@ -502,6 +786,12 @@ type openTable struct {
 	fail     bool
 }
 
+type listTables struct {
+	sn     types.SchemaName
+	tables []types.Identifier
+	fail   bool
+}
+
 type createTable struct {
 	tn       types.TableName
 	colNames []types.Identifier
@@ -578,8 +868,23 @@ func testEngine(t *testing.T, tx engine.Transaction, cases []interface{}) {
 					t.Errorf("TableId(%s) got %d want %d", c.tn, tid, c.tid)
 				}
 			}
+		case listTables:
+			tables, err := tx.ListTables(ctx, c.sn)
+			if c.fail {
+				if err == nil {
+					t.Errorf("ListTables(%s) did not fail", c.sn)
+				}
+			} else if err != nil {
+				t.Errorf("ListTables(%s) failed with %s", c.sn, err)
+			} else {
+				slices.Sort(tables)
+				slices.Sort(c.tables)
+				if !reflect.DeepEqual(tables, c.tables) {
+					t.Errorf("ListTables(%s) got %v want %v", c.sn, tables, c.tables)
+				}
+			}
 		case createTable:
-			err := tx.CreateTable(ctx, c.tn, c.colNames, c.colTypes, c.primary)
+			err := tx.CreateTable(ctx, c.tn, c.colNames, c.colTypes, c.primary, nil, nil, nil)
 			if c.fail {
 				if err == nil {
 					t.Errorf("CreateTable(%s) did not fail", c.tn)
@@ -2,6 +2,7 @@ package engine
 
 import (
 	"context"
+	"math"
 
 	"github.com/leftmike/maho/storage"
 	"github.com/leftmike/maho/types"
@@ -16,8 +17,12 @@ var (
 			ti: sequencesTypedInfo,
 			structs: []interface{}{
 				&sequencesRow{
-					Sequence: nextTableIdSequence,
-					Current:  int64(maxReservedTableId + 1),
+					Sequence:  nextTableIdSequence,
+					Current:   int64(maxReservedTableId + 1),
+					Start:     int64(maxReservedTableId + 1),
+					Increment: 1,
+					MinValue:  int64(maxReservedTableId + 1),
+					MaxValue:  math.MaxInt64,
 				},
 			},
 		},
@@ -0,0 +1,271 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/leftmike/maho/parser/sql"
+	"github.com/leftmike/maho/types"
+)
+
+type schemasMetadataRow struct {
+	SchemaName string `maho:"size=128,primary"`
+}
+
+type tablesMetadataRow struct {
+	SchemaName string `maho:"size=128,primary"`
+	TableName  string `maho:"size=128,primary"`
+}
+
+type columnsMetadataRow struct {
+	SchemaName   string `maho:"size=128,primary"`
+	TableName    string `maho:"size=128,primary"`
+	Position     int64  `maho:"primary"`
+	ColumnName   string `maho:"size=128"`
+	DataType     string `maho:"size=128"`
+	NotNull      bool
+	DefaultValue *string `maho:"size=4096"`
+}
+
+type constraintsMetadataRow struct {
+	SchemaName     string  `maho:"size=128,primary"`
+	TableName      string  `maho:"size=128,primary"`
+	ConstraintName string  `maho:"size=128,primary"`
+	ConstraintType string  `maho:"size=128"`
+	Details        *string `maho:"size=4096"`
+}
+
+type metadataTable struct {
+	ti      *TypedInfo
+	structs func(ctx context.Context, tx *transaction, dn types.Identifier) ([]interface{},
+		error)
+}
+
+var (
+	metadataTables = map[types.Identifier]metadataTable{
+		types.SCHEMAS: {
+			ti: MakeTypedInfo(0, types.TableName{Schema: types.METADATA, Table: types.SCHEMAS},
+				schemasMetadataRow{}),
+			structs: schemasMetadata,
+		},
+		types.TABLES: {
+			ti: MakeTypedInfo(0, types.TableName{Schema: types.METADATA, Table: types.TABLES},
+				tablesMetadataRow{}),
+			structs: tablesMetadata,
+		},
+		types.COLUMNS: {
+			ti: MakeTypedInfo(0, types.TableName{Schema: types.METADATA, Table: types.COLUMNS},
+				columnsMetadataRow{}),
+			structs: columnsMetadata,
+		},
+		types.CONSTRAINTS: {
+			ti: MakeTypedInfo(0,
+				types.TableName{Schema: types.METADATA, Table: types.CONSTRAINTS},
+				constraintsMetadataRow{}),
+			structs: constraintsMetadata,
+		},
+	}
+)
+
+func (tx *transaction) openMetadataTable(ctx context.Context, tn types.TableName) (Table,
+	error) {
+
+	mt, ok := metadataTables[tn.Table]
+	if !ok {
+		return nil, fmt.Errorf("engine: table not found: %s", tn)
+	}
+
+	err := TypedTableLookup(ctx, tx.tx, databasesTypedInfo,
+		&databasesRow{
+			Database: tn.Database.String(),
+		})
+	if err == io.EOF {
+		return nil, fmt.Errorf("engine: database not found: %s", tn.Database)
+	} else if err != nil {
+		return nil, err
+	}
+
+	structs, err := mt.structs(ctx, tx, tn.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]types.Row, 0, len(structs))
+	for _, st := range structs {
+		rows = append(rows, mt.ti.structToRow(st))
+	}
+
+	return &virtualTable{
+		tn:   tn,
+		tt:   mt.ti.TableType(),
+		rows: rows,
+	}, nil
+}
+
+func schemasMetadata(ctx context.Context, tx *transaction, dn types.Identifier) ([]interface{},
+	error) {
+
+	schemas, err := tx.ListSchemas(ctx, dn)
+	if err != nil {
+		return nil, err
+	}
+
+	var structs []interface{}
+	for _, sn := range schemas {
+		structs = append(structs,
+			&schemasMetadataRow{
+				SchemaName: sn.String(),
+			})
+	}
+	return structs, nil
+}
+
+func tablesMetadata(ctx context.Context, tx *transaction, dn types.Identifier) ([]interface{},
+	error) {
+
+	var structs []interface{}
+	err := tx.selectTables(ctx, dn, 0,
+		func(tr *tablesRow) error {
+			structs = append(structs,
+				&tablesMetadataRow{
+					SchemaName: tr.Schema,
+					TableName:  tr.Table,
+				})
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return structs, nil
+}
+
+func columnsMetadata(ctx context.Context, tx *transaction, dn types.Identifier) ([]interface{},
+	error) {
+
+	var structs []interface{}
+	err := tx.selectTables(ctx, dn, 0,
+		func(tr *tablesRow) error {
+			tt, err := DecodeTableType(tr.Type)
+			if err != nil {
+				return err
+			}
+
+			for cdx, col := range tt.ColumnNames {
+				ct := tt.ColumnTypes[cdx]
+				notNull := ct.NotNull
+				for _, ck := range tt.Key {
+					if ck.Column() == types.ColumnNum(cdx) {
+						notNull = true
+					}
+				}
+
+				var dflt *string
+				if cdx < len(tt.ColumnDefaults) && tt.ColumnDefaults[cdx] != nil {
+					s := tt.ColumnDefaults[cdx].String()
+					dflt = &s
+				}
+
+				structs = append(structs,
+					&columnsMetadataRow{
+						SchemaName:   tr.Schema,
+						TableName:    tr.Table,
+						Position:     int64(cdx + 1),
+						ColumnName:   col.String(),
+						DataType:     ct.String(),
+						NotNull:      notNull,
+						DefaultValue: dflt,
+					})
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return structs, nil
+}
+
+func formatKey(key []types.ColumnKey, colNames []types.Identifier) string {
+	var buf strings.Builder
+	buf.WriteRune('(')
+	for kdx, ck := range key {
+		if kdx > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(colNames[ck.Column()].String())
+		if ck.Reverse() {
+			buf.WriteString(" DESC")
+		}
+	}
+	buf.WriteRune(')')
+	return buf.String()
+}
+
+func constraintsMetadata(ctx context.Context, tx *transaction, dn types.Identifier) (
+	[]interface{}, error) {
+
+	var structs []interface{}
+	err := tx.selectTables(ctx, dn, 0,
+		func(tr *tablesRow) error {
+			tt, err := DecodeTableType(tr.Type)
+			if err != nil {
+				return err
+			}
+
+			if len(tt.Key) > 0 {
+				details := formatKey(tt.Key, tt.ColumnNames)
+				structs = append(structs,
+					&constraintsMetadataRow{
+						SchemaName:     tr.Schema,
+						TableName:      tr.Table,
+						ConstraintName: types.PRIMARY_QUOTED.String(),
+						ConstraintType: "PRIMARY KEY",
+						Details:        &details,
+					})
+			}
+
+			for _, con := range tt.Constraints {
+				var details string
+				if con.Type == sql.CheckConstraint {
+					details = con.Check.String()
+				} else {
+					details = fmt.Sprintf("(%s)", tt.ColumnNames[con.Column])
+				}
+				structs = append(structs,
+					&constraintsMetadataRow{
+						SchemaName:     tr.Schema,
+						TableName:      tr.Table,
+						ConstraintName: con.Name.String(),
+						ConstraintType: con.Type.String(),
+						Details:        &details,
+					})
+			}
+
+			for _, fk := range tt.ForeignKeys {
+				var buf strings.Builder
+				buf.WriteRune('(')
+				for cdx, col := range fk.Columns {
+					if cdx > 0 {
+						buf.WriteString(", ")
+					}
+					buf.WriteString(tt.ColumnNames[col].String())
+				}
+				fmt.Fprintf(&buf, ") REFERENCES %s", fk.RefTable)
+				details := buf.String()
+				structs = append(structs,
+					&constraintsMetadataRow{
+						SchemaName:     tr.Schema,
+						TableName:      tr.Table,
+						ConstraintName: fk.Name.String(),
+						ConstraintType: "FOREIGN KEY",
+						Details:        &details,
+					})
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return structs, nil
+}
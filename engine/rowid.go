@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"context"
+
+	"github.com/leftmike/maho/storage"
+	"github.com/leftmike/maho/types"
+)
+
+// Tables without a primary key have a rowid column prepended by storage; the rowid column is
+// not visible above the engine, so column numbers need to be shifted by one.
+
+type rowidRows struct {
+	storage.Rows
+}
+
+type rowidRef struct {
+	storage.RowRef
+}
+
+type rowidPredicate struct {
+	pred storage.ColumnPredicate
+}
+
+func rowidColumns(cols []types.ColumnNum) []types.ColumnNum {
+	rcols := make([]types.ColumnNum, 0, len(cols))
+	for _, col := range cols {
+		rcols = append(rcols, col+1)
+	}
+	return rcols
+}
+
+func (rr rowidRows) Current() (storage.RowRef, error) {
+	ref, err := rr.Rows.Current()
+	if err != nil {
+		return nil, err
+	}
+	return rowidRef{ref}, nil
+}
+
+func (rr rowidRef) Update(ctx context.Context, cols []types.ColumnNum, vals []types.Value) error {
+	return rr.RowRef.Update(ctx, rowidColumns(cols), vals)
+}
+
+// rowidPredicateColumns returns pred with the column numbers shifted by one.
+func rowidPredicateColumns(pred storage.Predicate) storage.Predicate {
+	switch pred := pred.(type) {
+	case storage.AndPredicate:
+		ap := make(storage.AndPredicate, 0, len(pred))
+		for _, p := range pred {
+			ap = append(ap, rowidPredicateColumns(p))
+		}
+		return ap
+	case storage.OrPredicate:
+		op := make(storage.OrPredicate, 0, len(pred))
+		for _, p := range pred {
+			op = append(op, rowidPredicateColumns(p))
+		}
+		return op
+	case storage.NotPredicate:
+		return storage.NotPredicate{Predicate: rowidPredicateColumns(pred.Predicate)}
+	case storage.ComparePredicate:
+		pred.Column += 1
+		return pred
+	case storage.ColumnsPredicate:
+		pred.Left += 1
+		pred.Right += 1
+		return pred
+	case storage.NullPredicate:
+		pred.Column += 1
+		return pred
+	case storage.InPredicate:
+		pred.Column += 1
+		return pred
+	case storage.ColumnPredicate:
+		return rowidPredicate{pred}
+	}
+
+	// Leave it to storage to decline the predicate.
+	return pred
+}
+
+func (rp rowidPredicate) Column() types.ColumnNum {
+	return rp.pred.Column() + 1
+}
+
+func (rp rowidPredicate) BoolPred(b types.BoolValue) bool {
+	return rp.pred.(storage.BoolPredicate).BoolPred(b)
+}
+
+func (rp rowidPredicate) StringPred(s types.StringValue) bool {
+	return rp.pred.(storage.StringPredicate).StringPred(s)
+}
+
+func (rp rowidPredicate) BytesPred(b types.BytesValue) bool {
+	return rp.pred.(storage.BytesPredicate).BytesPred(b)
+}
+
+func (rp rowidPredicate) Float64Pred(f types.Float64Value) bool {
+	return rp.pred.(storage.Float64Predicate).Float64Pred(f)
+}
+
+func (rp rowidPredicate) Int64Pred(i types.Int64Value) bool {
+	return rp.pred.(storage.Int64Predicate).Int64Pred(i)
+}
@@ -0,0 +1,486 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/leftmike/maho/storage"
+	"github.com/leftmike/maho/types"
+)
+
+type Sequence struct {
+	Start     int64
+	Increment int64
+	MinValue  int64
+	MaxValue  int64
+	Cycle     bool
+}
+
+// reservedValues is the number of values of a sequence reserved each time it is saved.
+const reservedValues = 32
+
+// sequenceValue is the current value of a sequence. Changes to the value are not transactional:
+// they are shared by all transactions as soon as they are made. So that values are never
+// reused after a crash, a transaction which commits saves each sequence it used, unless
+// its values are already reserved, as if reservedValues more values had been returned.
+type sequenceValue struct {
+	current int64
+	called  bool
+	count   int64 // number of values returned
+	saved   int64 // number of values reserved by the saved sequence
+}
+
+// usedSequence is a sequence used by a transaction; count is the number of values which had
+// been returned when it was last used.
+type usedSequence struct {
+	sequence string
+	value    *sequenceValue
+	count    int64
+}
+
+// reservedSequence is a sequence saved by a transaction; value is nil if the sequence was
+// dropped.
+type reservedSequence struct {
+	sid   int64
+	value *sequenceValue
+	count int64
+}
+
+// restartedSequence is the value of a sequence restarted by a transaction. Unlike other changes
+// to the value, restarts are transactional: the value is private to the transaction until it
+// commits, and is discarded if it rolls back.
+type restartedSequence struct {
+	sequence string
+	value    sequenceValue
+}
+
+func (tx *transaction) lookupSequence(ctx context.Context, sqn types.TableName) (*sequencesRow,
+	error) {
+
+	sr := &sequencesRow{
+		Sequence: sqn.String(),
+	}
+	err := TypedTableLookup(ctx, tx.tx, sequencesTypedInfo, sr)
+	if err == io.EOF {
+		return nil, fmt.Errorf("engine: sequence not found: %s", sqn)
+	} else if err != nil {
+		return nil, err
+	}
+	return sr, nil
+}
+
+func (tx *transaction) CreateSequence(ctx context.Context, sqn types.TableName,
+	seq Sequence) error {
+
+	err := TypedTableLookup(ctx, tx.tx, schemasTypedInfo,
+		&schemasRow{
+			Database: sqn.Database.String(),
+			Schema:   sqn.Schema.String(),
+		})
+	if err == io.EOF {
+		return fmt.Errorf("engine: schema not found: %s", sqn.SchemaName())
+	} else if err != nil {
+		return err
+	}
+
+	err = TypedTableLookup(ctx, tx.tx, sequencesTypedInfo,
+		&sequencesRow{
+			Sequence: sqn.String(),
+		})
+	if err == nil {
+		return fmt.Errorf("engine: sequence already exists: %s", sqn)
+	} else if err != io.EOF {
+		return err
+	}
+
+	// Sequence ids are never reused, so the value of a dropped sequence can not be confused
+	// with the value of a new sequence with the same name.
+	sid, err := tx.nextTableId(ctx)
+	if err != nil {
+		return err
+	}
+
+	return TypedTableInsert(ctx, tx.tx, sequencesTypedInfo,
+		&sequencesRow{
+			Sequence:   sqn.String(),
+			Current:    seq.Start,
+			SequenceId: sid,
+			Start:      seq.Start,
+			Increment:  seq.Increment,
+			MinValue:   seq.MinValue,
+			MaxValue:   seq.MaxValue,
+			Cycle:      seq.Cycle,
+		})
+}
+
+func (tx *transaction) DropSequence(ctx context.Context, sqn types.TableName,
+	ifExists bool) error {
+
+	sr := &sequencesRow{
+		Sequence: sqn.String(),
+	}
+	var deleted bool
+	err := TypedTableDelete(ctx, tx.tx, sequencesTypedInfo, sr, sr,
+		func(row types.Row) (bool, error) {
+			deleted = true
+			return true, nil
+		})
+	if err != nil {
+		return err
+	} else if !deleted {
+		if ifExists {
+			return nil
+		}
+		return fmt.Errorf("engine: sequence not found: %s", sqn)
+	}
+	return nil
+}
+
+func (tx *transaction) LookupSequence(ctx context.Context, sqn types.TableName) (Sequence,
+	error) {
+
+	sr, err := tx.lookupSequence(ctx, sqn)
+	if err != nil {
+		return Sequence{}, err
+	}
+
+	return Sequence{
+		Start:     sr.Start,
+		Increment: sr.Increment,
+		MinValue:  sr.MinValue,
+		MaxValue:  sr.MaxValue,
+		Cycle:     sr.Cycle,
+	}, nil
+}
+
+func (tx *transaction) AlterSequence(ctx context.Context, sqn types.TableName,
+	seq Sequence) error {
+
+	var found bool
+	sr := &sequencesRow{
+		Sequence: sqn.String(),
+	}
+	err := TypedTableUpdate(ctx, tx.tx, sequencesTypedInfo, sr, sr,
+		func(row types.Row) (interface{}, error) {
+			found = true
+			return &struct {
+				Start     int64
+				Increment int64
+				MinValue  int64
+				MaxValue  int64
+				Cycle     bool
+			}{
+				Start:     seq.Start,
+				Increment: seq.Increment,
+				MinValue:  seq.MinValue,
+				MaxValue:  seq.MaxValue,
+				Cycle:     seq.Cycle,
+			}, nil
+		})
+	if err != nil {
+		return err
+	} else if !found {
+		return fmt.Errorf("engine: sequence not found: %s", sqn)
+	}
+	return nil
+}
+
+// sequenceValue returns the current value of sequence sr; eng.mutex must be held.
+func (tx *transaction) sequenceValue(sr *sequencesRow) *sequenceValue {
+	if rs, ok := tx.restarted[sr.SequenceId]; ok {
+		return &rs.value
+	}
+
+	sv, ok := tx.eng.sequences[sr.SequenceId]
+	if !ok {
+		sv = &sequenceValue{
+			current: sr.Current,
+			called:  sr.Called,
+		}
+		tx.eng.sequences[sr.SequenceId] = sv
+	}
+	return sv
+}
+
+// useSequence records that the transaction used the sequence sr; eng.mutex must be held.
+func (tx *transaction) useSequence(sr *sequencesRow, sv *sequenceValue) {
+	if tx.sequences == nil {
+		tx.sequences = map[int64]usedSequence{}
+	}
+	tx.sequences[sr.SequenceId] = usedSequence{
+		sequence: sr.Sequence,
+		value:    sv,
+		count:    sv.count,
+	}
+}
+
+func nextSequenceValue(sr *sequencesRow, cur int64) (int64, bool) {
+	if sr.Increment > 0 {
+		if cur < sr.MaxValue && uint64(sr.MaxValue)-uint64(cur) >= uint64(sr.Increment) {
+			return cur + sr.Increment, true
+		} else if sr.Cycle {
+			return sr.MinValue, true
+		}
+	} else {
+		if cur > sr.MinValue && uint64(cur)-uint64(sr.MinValue) >= uint64(-sr.Increment) {
+			return cur + sr.Increment, true
+		} else if sr.Cycle {
+			return sr.MaxValue, true
+		}
+	}
+	return 0, false
+}
+
+func (tx *transaction) nextValue(sr *sequencesRow) (int64, bool) {
+	tx.eng.mutex.Lock()
+	defer tx.eng.mutex.Unlock()
+
+	sv := tx.sequenceValue(sr)
+	val := sv.current
+	if sv.called {
+		var ok bool
+		val, ok = nextSequenceValue(sr, sv.current)
+		if !ok {
+			return 0, false
+		}
+	}
+
+	sv.current = val
+	sv.called = true
+	sv.count += 1
+	tx.useSequence(sr, sv)
+	return val, true
+}
+
+// NextValue advances the sequence sqn and returns the new value.
+func (tx *transaction) NextValue(ctx context.Context, sqn types.TableName) (int64, error) {
+	if tx.readOnly {
+		return 0, fmt.Errorf("engine: sequence %s: unable to change in a read only transaction",
+			sqn)
+	}
+
+	sr, err := tx.lookupSequence(ctx, sqn)
+	if err != nil {
+		return 0, err
+	}
+
+	val, ok := tx.nextValue(sr)
+	if !ok {
+		if sr.Increment > 0 {
+			return 0, fmt.Errorf("engine: sequence %s: reached maximum value: %d", sqn,
+				sr.MaxValue)
+		}
+		return 0, fmt.Errorf("engine: sequence %s: reached minimum value: %d", sqn,
+			sr.MinValue)
+	}
+	return val, nil
+}
+
+func rowidSequence(tid storage.TableId) string {
+	return fmt.Sprintf("rowid_%d", tid)
+}
+
+// createRowidSequence creates the sequence used to generate rowids for table tid.
+func (tx *transaction) createRowidSequence(ctx context.Context, tid storage.TableId) error {
+	return TypedTableInsert(ctx, tx.tx, sequencesTypedInfo,
+		&sequencesRow{
+			Sequence:   rowidSequence(tid),
+			Current:    1,
+			SequenceId: int64(tid),
+			Start:      1,
+			Increment:  1,
+			MinValue:   1,
+			MaxValue:   math.MaxInt64,
+		})
+}
+
+func (tx *transaction) nextRowid(ctx context.Context, tn types.TableName,
+	tid storage.TableId) (int64, error) {
+
+	sr := &sequencesRow{
+		Sequence: rowidSequence(tid),
+	}
+	err := TypedTableLookup(ctx, tx.tx, sequencesTypedInfo, sr)
+	if err == io.EOF {
+		panic(fmt.Sprintf("engine: table %s: missing rowid sequence", tn))
+	} else if err != nil {
+		return 0, err
+	}
+
+	val, ok := tx.nextValue(sr)
+	if !ok {
+		return 0, fmt.Errorf("engine: table %s: out of rowids", tn)
+	}
+	return val, nil
+}
+
+// SetValue sets the current value of the sequence sqn to val; if called is false, the next
+// call to NextValue will return val.
+func (tx *transaction) SetValue(ctx context.Context, sqn types.TableName, val int64,
+	called bool) error {
+
+	if tx.readOnly {
+		return fmt.Errorf("engine: sequence %s: unable to change in a read only transaction", sqn)
+	}
+
+	sr, err := tx.lookupSequence(ctx, sqn)
+	if err != nil {
+		return err
+	} else if val < sr.MinValue || val > sr.MaxValue {
+		return fmt.Errorf("engine: sequence %s: value out of bounds (%d, %d): %d", sqn,
+			sr.MinValue, sr.MaxValue, val)
+	}
+
+	tx.eng.mutex.Lock()
+	defer tx.eng.mutex.Unlock()
+
+	if rs, ok := tx.restarted[sr.SequenceId]; ok {
+		rs.value.current = val
+		rs.value.called = called
+		return nil
+	}
+
+	// The value is replaced, rather than changed, so that values reserved for the old value
+	// are not mistaken for values reserved for the new value.
+	sv := &sequenceValue{
+		current: val,
+		called:  called,
+		count:   1,
+	}
+	tx.eng.sequences[sr.SequenceId] = sv
+	tx.useSequence(sr, sv)
+	return nil
+}
+
+// RestartSequence restarts the sequence sqn so that the next call to NextValue will return val.
+func (tx *transaction) RestartSequence(ctx context.Context, sqn types.TableName, val int64) error {
+	if tx.readOnly {
+		return fmt.Errorf("engine: sequence %s: unable to change in a read only transaction", sqn)
+	}
+
+	sr, err := tx.lookupSequence(ctx, sqn)
+	if err != nil {
+		return err
+	} else if val < sr.MinValue || val > sr.MaxValue {
+		return fmt.Errorf("engine: sequence %s: value out of bounds (%d, %d): %d", sqn,
+			sr.MinValue, sr.MaxValue, val)
+	}
+
+	tx.eng.mutex.Lock()
+	defer tx.eng.mutex.Unlock()
+
+	if tx.restarted == nil {
+		tx.restarted = map[int64]*restartedSequence{}
+	}
+	tx.restarted[sr.SequenceId] = &restartedSequence{
+		sequence: sr.Sequence,
+		value: sequenceValue{
+			current: val,
+		},
+	}
+	return nil
+}
+
+// commitRestarts makes the sequences restarted by the transaction visible to all transactions.
+func (tx *transaction) commitRestarts() {
+	tx.eng.mutex.Lock()
+	defer tx.eng.mutex.Unlock()
+
+	for sid, rs := range tx.restarted {
+		tx.eng.sequences[sid] = &rs.value
+	}
+	tx.restarted = nil
+}
+
+// reserveValues returns the current value to save for sequence sr to reserve the next
+// reservedValues values, and the number of values reserved.
+func reserveValues(sr *sequencesRow, sv *sequenceValue) (int64, bool, int64) {
+	cur := sv.current
+	called := sv.called
+	var cnt int64
+	for cnt < reservedValues {
+		if called {
+			val, ok := nextSequenceValue(sr, cur)
+			if !ok {
+				break
+			}
+			cur = val
+		}
+		called = true
+		cnt += 1
+	}
+	return cur, called, cnt
+}
+
+// saveSequences saves, as part of the transaction, the sequences restarted by the transaction
+// and the sequences it used whose values are not already reserved. eng.saveMutex must be held
+// until the transaction completes, so that concurrent transactions do not save the same
+// sequence.
+func (tx *transaction) saveSequences(ctx context.Context) ([]reservedSequence, error) {
+	eng := tx.eng
+	eng.mutex.Lock()
+	unsaved := map[int64]string{}
+	for sid, rs := range tx.restarted {
+		unsaved[sid] = rs.sequence
+	}
+	for sid, us := range tx.sequences {
+		if eng.sequences[sid] == us.value && us.count <= us.value.saved {
+			continue
+		}
+		unsaved[sid] = us.sequence
+	}
+	eng.mutex.Unlock()
+
+	var reserved []reservedSequence
+	for sid, seqName := range unsaved {
+		rs := reservedSequence{
+			sid: sid,
+		}
+		sr := &sequencesRow{
+			Sequence: seqName,
+		}
+		err := TypedTableUpdate(ctx, tx.tx, sequencesTypedInfo, sr, sr,
+			func(row types.Row) (interface{}, error) {
+				var sr sequencesRow
+				sequencesTypedInfo.RowToStruct(row, &sr)
+				if sr.SequenceId != sid {
+					return nil, nil
+				}
+
+				eng.mutex.Lock()
+				defer eng.mutex.Unlock()
+
+				rs.value = tx.sequenceValue(&sr)
+				current, called, cnt := reserveValues(&sr, rs.value)
+				rs.count = rs.value.count + cnt
+				return &struct {
+					Current int64
+					Called  bool
+				}{
+					Current: current,
+					Called:  called,
+				}, nil
+			})
+		if err != nil {
+			return nil, err
+		}
+		reserved = append(reserved, rs)
+	}
+	return reserved, nil
+}
+
+// commitReserved records the values reserved by a transaction which committed.
+func (eng *engine) commitReserved(reserved []reservedSequence) {
+	eng.mutex.Lock()
+	defer eng.mutex.Unlock()
+
+	for _, rs := range reserved {
+		if rs.value == nil {
+			delete(eng.sequences, rs.sid)
+		} else if rs.count > rs.value.saved {
+			rs.value.saved = rs.count
+		}
+	}
+}
@@ -0,0 +1,409 @@
+package engine_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/leftmike/maho/engine"
+	"github.com/leftmike/maho/storage/basic"
+	"github.com/leftmike/maho/types"
+)
+
+func nextValue(t *testing.T, tx engine.Transaction, sqn types.TableName, want int64) {
+	t.Helper()
+
+	val, err := tx.NextValue(context.Background(), sqn)
+	if err != nil {
+		t.Errorf("NextValue(%s) failed with %s", sqn, err)
+	} else if val != want {
+		t.Errorf("NextValue(%s) got %d want %d", sqn, val, want)
+	}
+}
+
+func TestSequences(t *testing.T) {
+	eng := newEngine(t)
+	ctx := context.Background()
+
+	sqn := types.TableName{
+		Database: types.MAHO,
+		Schema:   types.PUBLIC,
+		Table:    types.ID("seq", false),
+	}
+	seq := engine.Sequence{
+		Start:     10,
+		Increment: 5,
+		MinValue:  1,
+		MaxValue:  math.MaxInt64,
+	}
+
+	tx := eng.Begin()
+	err := tx.CreateSequence(ctx, sqn, seq)
+	if err != nil {
+		t.Fatalf("CreateSequence(%s) failed with %s", sqn, err)
+	}
+	nextValue(t, tx, sqn, 10)
+	nextValue(t, tx, sqn, 15)
+	err = tx.CreateSequence(ctx, sqn, seq)
+	if err == nil {
+		t.Errorf("CreateSequence(%s) did not fail", sqn)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		t.Fatalf("Commit() failed with %s", err)
+	}
+
+	tx = eng.Begin()
+	nextValue(t, tx, sqn, 20)
+	err = tx.Rollback()
+	if err != nil {
+		t.Fatalf("Rollback() failed with %s", err)
+	}
+
+	tx = eng.Begin()
+	nextValue(t, tx, sqn, 25)
+	rseq, err := tx.LookupSequence(ctx, sqn)
+	if err != nil {
+		t.Errorf("LookupSequence(%s) failed with %s", sqn, err)
+	} else if rseq != seq {
+		t.Errorf("LookupSequence(%s) got %v want %v", sqn, rseq, seq)
+	}
+
+	seq.Increment = -1
+	seq.MinValue = 20
+	seq.MaxValue = 30
+	seq.Cycle = true
+	err = tx.AlterSequence(ctx, sqn, seq)
+	if err != nil {
+		t.Fatalf("AlterSequence(%s) failed with %s", sqn, err)
+	}
+	nextValue(t, tx, sqn, 24)
+	err = tx.SetValue(ctx, sqn, 21, true)
+	if err != nil {
+		t.Fatalf("SetValue(%s) failed with %s", sqn, err)
+	}
+	nextValue(t, tx, sqn, 20)
+	nextValue(t, tx, sqn, 30)
+	err = tx.SetValue(ctx, sqn, 31, true)
+	if err == nil {
+		t.Errorf("SetValue(%s) did not fail", sqn)
+	}
+	err = tx.SetValue(ctx, sqn, 20, false)
+	if err != nil {
+		t.Fatalf("SetValue(%s) failed with %s", sqn, err)
+	}
+	nextValue(t, tx, sqn, 20)
+
+	seq.Cycle = false
+	err = tx.AlterSequence(ctx, sqn, seq)
+	if err != nil {
+		t.Fatalf("AlterSequence(%s) failed with %s", sqn, err)
+	}
+	_, err = tx.NextValue(ctx, sqn)
+	if err == nil {
+		t.Errorf("NextValue(%s) did not fail", sqn)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		t.Fatalf("Commit() failed with %s", err)
+	}
+
+	tx = eng.Begin()
+	err = tx.DropSequence(ctx, sqn, false)
+	if err != nil {
+		t.Fatalf("DropSequence(%s) failed with %s", sqn, err)
+	}
+	_, err = tx.NextValue(ctx, sqn)
+	if err == nil {
+		t.Errorf("NextValue(%s) did not fail", sqn)
+	}
+	err = tx.DropSequence(ctx, sqn, false)
+	if err == nil {
+		t.Errorf("DropSequence(%s) did not fail", sqn)
+	}
+	err = tx.DropSequence(ctx, sqn, true)
+	if err != nil {
+		t.Errorf("DropSequence(%s, true) failed with %s", sqn, err)
+	}
+
+	seq = engine.Sequence{
+		Start:     1,
+		Increment: 1,
+		MinValue:  1,
+		MaxValue:  math.MaxInt64,
+	}
+	err = tx.CreateSequence(ctx, sqn, seq)
+	if err != nil {
+		t.Fatalf("CreateSequence(%s) failed with %s", sqn, err)
+	}
+	nextValue(t, tx, sqn, 1)
+	err = tx.Rollback()
+	if err != nil {
+		t.Fatalf("Rollback() failed with %s", err)
+	}
+
+	tx = eng.Begin()
+	rseq, err = tx.LookupSequence(ctx, sqn)
+	if err != nil {
+		t.Errorf("LookupSequence(%s) failed with %s", sqn, err)
+	} else if rseq.MinValue != 20 {
+		t.Errorf("LookupSequence(%s) got %v", sqn, rseq)
+	}
+	err = tx.SetValue(ctx, sqn, 30, false)
+	if err != nil {
+		t.Fatalf("SetValue(%s) failed with %s", sqn, err)
+	}
+	nextValue(t, tx, sqn, 30)
+	err = tx.Commit(ctx)
+	if err != nil {
+		t.Fatalf("Commit() failed with %s", err)
+	}
+
+	sqn.Schema = types.ID("missing", false)
+	tx = eng.Begin()
+	err = tx.CreateSequence(ctx, sqn, seq)
+	if err == nil {
+		t.Errorf("CreateSequence(%s) did not fail", sqn)
+	}
+	tx.Rollback()
+}
+
+func TestRestartSequence(t *testing.T) {
+	eng := newEngine(t)
+	ctx := context.Background()
+
+	sqn := types.TableName{
+		Database: types.MAHO,
+		Schema:   types.PUBLIC,
+		Table:    types.ID("seq", false),
+	}
+
+	tx := eng.Begin()
+	err := tx.CreateSequence(ctx, sqn,
+		engine.Sequence{
+			Start:     1,
+			Increment: 1,
+			MinValue:  1,
+			MaxValue:  math.MaxInt64,
+		})
+	if err != nil {
+		t.Fatalf("CreateSequence(%s) failed with %s", sqn, err)
+	}
+	nextValue(t, tx, sqn, 1)
+	nextValue(t, tx, sqn, 2)
+	err = tx.Commit(ctx)
+	if err != nil {
+		t.Fatalf("Commit() failed with %s", err)
+	}
+
+	tx = eng.Begin()
+	err = tx.RestartSequence(ctx, sqn, 1)
+	if err != nil {
+		t.Fatalf("RestartSequence(%s) failed with %s", sqn, err)
+	}
+	nextValue(t, tx, sqn, 1)
+	err = tx.Rollback()
+	if err != nil {
+		t.Fatalf("Rollback() failed with %s", err)
+	}
+
+	tx = eng.Begin()
+	nextValue(t, tx, sqn, 3)
+	err = tx.RestartSequence(ctx, sqn, 10)
+	if err != nil {
+		t.Fatalf("RestartSequence(%s) failed with %s", sqn, err)
+	}
+	nextValue(t, tx, sqn, 10)
+	sp := tx.Savepoint()
+	nextValue(t, tx, sqn, 11)
+	err = tx.RestartSequence(ctx, sqn, 20)
+	if err != nil {
+		t.Fatalf("RestartSequence(%s) failed with %s", sqn, err)
+	}
+	nextValue(t, tx, sqn, 20)
+	err = tx.RollbackTo(sp)
+	if err != nil {
+		t.Fatalf("RollbackTo() failed with %s", err)
+	}
+	nextValue(t, tx, sqn, 11)
+	err = tx.RestartSequence(ctx, sqn, 0)
+	if err == nil {
+		t.Errorf("RestartSequence(%s) did not fail", sqn)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		t.Fatalf("Commit() failed with %s", err)
+	}
+
+	tx = eng.Begin()
+	nextValue(t, tx, sqn, 12)
+	err = tx.Commit(ctx)
+	if err != nil {
+		t.Fatalf("Commit() failed with %s", err)
+	}
+}
+
+func TestSequencesConcurrent(t *testing.T) {
+	s := t.TempDir()
+	store, err := basic.NewMVCCStore(s)
+	if err != nil {
+		t.Fatalf("NewMVCCStore(%s) failed with %s", s, err)
+	}
+	err = engine.Init(store)
+	if err != nil {
+		t.Fatalf("Init() failed with %s", err)
+	}
+	eng := engine.NewEngine(store, nil)
+	ctx := context.Background()
+
+	sqn := types.TableName{
+		Database: types.MAHO,
+		Schema:   types.PUBLIC,
+		Table:    types.ID("seq", false),
+	}
+	tx := eng.Begin()
+	err = tx.CreateSequence(ctx, sqn,
+		engine.Sequence{
+			Start:     1,
+			Increment: 1,
+			MinValue:  1,
+			MaxValue:  math.MaxInt64,
+		})
+	if err != nil {
+		t.Fatalf("CreateSequence(%s) failed with %s", sqn, err)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		t.Fatalf("Commit() failed with %s", err)
+	}
+
+	// Saving the values of the sequence must not cause the transactions to fail.
+	const workers = 8
+	const count = 50
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w += 1 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for cnt := 0; cnt < count; cnt += 1 {
+				tx := eng.Begin()
+				_, err := tx.NextValue(ctx, sqn)
+				if err != nil {
+					t.Errorf("NextValue(%s) failed with %s", sqn, err)
+				}
+				err = tx.Commit(ctx)
+				if err != nil {
+					t.Errorf("Commit() failed with %s", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// A new engine does not reuse values of the sequence.
+	eng = engine.NewEngine(store, nil)
+	tx = eng.Begin()
+	val, err := tx.NextValue(ctx, sqn)
+	if err != nil {
+		t.Errorf("NextValue(%s) failed with %s", sqn, err)
+	} else if val <= workers*count {
+		t.Errorf("NextValue(%s) got %d want more than %d", sqn, val, workers*count)
+	}
+	err = tx.Rollback()
+	if err != nil {
+		t.Fatalf("Rollback() failed with %s", err)
+	}
+}
+
+// crashLog keeps the items of committed transactions in memory; once crashAfter commits have
+// been logged, later commits fail, as if the process had crashed.
+type crashLog struct {
+	commits    [][]basic.Item
+	crashAfter int
+}
+
+func (cl *crashLog) Commit(items []basic.Item, all func(fn func(it basic.Item) bool)) error {
+	if cl.crashAfter > 0 && len(cl.commits) >= cl.crashAfter {
+		return errors.New("crashed")
+	}
+	cl.commits = append(cl.commits, items)
+	return nil
+}
+
+func (cl *crashLog) load(fn func(it basic.Item)) error {
+	for _, items := range cl.commits {
+		for _, it := range items {
+			fn(it)
+		}
+	}
+	return nil
+}
+
+func TestSequencesCrash(t *testing.T) {
+	ctx := context.Background()
+	sqn := types.TableName{
+		Database: types.MAHO,
+		Schema:   types.PUBLIC,
+		Table:    types.ID("seq", false),
+	}
+
+	for _, mvcc := range []bool{false, true} {
+		cl := &crashLog{}
+		store, err := basic.NewLogStore("crash", cl, cl.load, mvcc)
+		if err != nil {
+			t.Fatalf("NewLogStore() failed with %s", err)
+		}
+		err = engine.Init(store)
+		if err != nil {
+			t.Fatalf("Init() failed with %s", err)
+		}
+		eng := engine.NewEngine(store, nil)
+
+		tx := eng.Begin()
+		err = tx.CreateSequence(ctx, sqn,
+			engine.Sequence{
+				Start:     1,
+				Increment: 1,
+				MinValue:  1,
+				MaxValue:  math.MaxInt64,
+			})
+		if err != nil {
+			t.Fatalf("CreateSequence(%s) failed with %s", sqn, err)
+		}
+		err = tx.Commit(ctx)
+		if err != nil {
+			t.Fatalf("Commit() failed with %s", err)
+		}
+
+		// Crash as soon as the transaction which used the values commits.
+		tx = eng.Begin()
+		nextValue(t, tx, sqn, 1)
+		nextValue(t, tx, sqn, 2)
+		nextValue(t, tx, sqn, 3)
+		cl.crashAfter = len(cl.commits) + 1
+		err = tx.Commit(ctx)
+		if err != nil {
+			t.Fatalf("Commit() failed with %s", err)
+		}
+
+		store, err = basic.NewLogStore("crash", &crashLog{}, cl.load, mvcc)
+		if err != nil {
+			t.Fatalf("NewLogStore() failed with %s", err)
+		}
+		eng = engine.NewEngine(store, nil)
+		tx = eng.Begin()
+		val, err := tx.NextValue(ctx, sqn)
+		if err != nil {
+			t.Errorf("NextValue(%s) failed with %s", sqn, err)
+		} else if val <= 3 {
+			t.Errorf("NextValue(%s) got %d want more than 3", sqn, val)
+		}
+		err = tx.Rollback()
+		if err != nil {
+			t.Fatalf("Rollback() failed with %s", err)
+		}
+	}
+}
@@ -67,7 +67,7 @@ func TestNextTableId(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	eng := NewEngine(store)
+	eng := NewEngine(store, nil)
 	var tx *transaction
 	for _, c := range cases {
 		switch c := c.(type) {
@@ -157,13 +157,28 @@ func TestMakeTypedTableInfo(t *testing.T) {
 				colNames: []types.Identifier{
 					types.ID("sequence", true),
 					types.ID("current", true),
+					types.ID("sequence_id", true),
+					types.ID("start", true),
+					types.ID("increment", true),
+					types.ID("min_value", true),
+					types.ID("max_value", true),
+					types.ID("cycle", true),
+					types.ID("called", true),
 				},
 				colTypes: []types.ColumnType{
 					{Type: types.StringType, Size: 128, NotNull: true},
 					{Type: types.Int64Type, Size: 8, NotNull: true},
+					{Type: types.Int64Type, Size: 8, NotNull: true},
+					{Type: types.Int64Type, Size: 8, NotNull: true},
+					{Type: types.Int64Type, Size: 8, NotNull: true},
+					{Type: types.Int64Type, Size: 8, NotNull: true},
+					{Type: types.Int64Type, Size: 8, NotNull: true},
+					{Type: types.BoolType, Size: 1, NotNull: true},
+					{Type: types.BoolType, Size: 1, NotNull: true},
 				},
-				primary:  []types.ColumnKey{types.MakeColumnKey(0, false)},
-				fldNames: []string{"Sequence", "Current"},
+				primary: []types.ColumnKey{types.MakeColumnKey(0, false)},
+				fldNames: []string{"Sequence", "Current", "SequenceId", "Start", "Increment",
+					"MinValue", "MaxValue", "Cycle", "Called"},
 			},
 		},
 		{
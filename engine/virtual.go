@@ -0,0 +1,196 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/leftmike/maho/encode"
+	"github.com/leftmike/maho/storage"
+	"github.com/leftmike/maho/types"
+)
+
+// virtualTable is a read only table whose rows are computed when it is opened.
+type virtualTable struct {
+	tn   types.TableName
+	tt   *TableType
+	rows []types.Row
+}
+
+type virtualRows struct {
+	vt   *virtualTable
+	cols []types.ColumnNum
+	rows []types.Row
+	next int
+}
+
+func (vt *virtualTable) Name() types.TableName {
+	return vt.tn
+}
+
+func (vt *virtualTable) Type() *TableType {
+	return vt.tt
+}
+
+func (_ *virtualTable) TableId() storage.TableId {
+	return 0
+}
+
+func (vt *virtualTable) SupportsPredicate(pred storage.Predicate) bool {
+	return storage.CheckPredicate(pred, vt.tt.ColumnTypes) == nil
+}
+
+func (vt *virtualTable) Rows(ctx context.Context, cols []types.ColumnNum, minRow,
+	maxRow types.Row, pred storage.Predicate) (storage.Rows, error) {
+
+	if pred != nil {
+		err := storage.CheckPredicate(pred, vt.tt.ColumnTypes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var minKey, maxKey []byte
+	if minRow != nil {
+		minKey = encode.MakeKey(vt.tt.Key, minRow)
+	}
+	if maxRow != nil {
+		maxKey = encode.MakeKey(vt.tt.Key, maxRow)
+	}
+
+	var rows []types.Row
+	for _, row := range vt.rows {
+		if minKey != nil || maxKey != nil {
+			key := encode.MakeKey(vt.tt.Key, row)
+			if minKey != nil && bytes.Compare(key, minKey) < 0 {
+				continue
+			} else if maxKey != nil && bytes.Compare(maxKey, key) < 0 {
+				continue
+			}
+		}
+		if pred != nil && !storage.EvalPredicate(pred, row) {
+			continue
+		}
+
+		rows = append(rows, row)
+	}
+
+	return &virtualRows{
+		vt:   vt,
+		cols: cols,
+		rows: rows,
+	}, nil
+}
+
+func (vt *virtualTable) Scan(ctx context.Context, cols []types.ColumnNum, spec storage.ScanSpec,
+	pred storage.Predicate) (storage.Rows, error) {
+
+	if pred != nil {
+		err := storage.CheckPredicate(pred, vt.tt.ColumnTypes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	key := vt.tt.Key
+	if spec.Prefix > 0 {
+		key = key[:spec.Prefix]
+	}
+	var minKey, maxKey []byte
+	if spec.MinRow != nil {
+		minKey = encode.MakeKey(key, spec.MinRow)
+	}
+	if spec.MaxRow != nil {
+		maxKey = encode.MakeKey(key, spec.MaxRow)
+	}
+
+	var rows []types.Row
+	for _, row := range vt.rows {
+		if minKey != nil || maxKey != nil {
+			k := encode.MakeKey(key, row)
+			if minKey != nil {
+				cmp := bytes.Compare(k, minKey)
+				if cmp < 0 || (spec.MinExclusive && cmp == 0) {
+					continue
+				}
+			}
+			if maxKey != nil {
+				cmp := bytes.Compare(k, maxKey)
+				if cmp > 0 || (spec.MaxExclusive && cmp == 0) {
+					continue
+				}
+			}
+		}
+		if pred != nil && !storage.EvalPredicate(pred, row) {
+			continue
+		}
+
+		rows = append(rows, row)
+	}
+
+	slices.SortStableFunc(rows,
+		func(row1, row2 types.Row) int {
+			return bytes.Compare(encode.MakeKey(vt.tt.Key, row1), encode.MakeKey(vt.tt.Key, row2))
+		})
+	if spec.Reverse {
+		slices.Reverse(rows)
+	}
+
+	return &virtualRows{
+		vt:   vt,
+		cols: cols,
+		rows: rows,
+	}, nil
+}
+
+func (vt *virtualTable) IndexRows(ctx context.Context, in types.Identifier,
+	cols []types.ColumnNum, minRow, maxRow types.Row) (storage.Rows, error) {
+
+	return nil, fmt.Errorf("engine: table %s: index not found: %s", vt.tn, in)
+}
+
+func (vt *virtualTable) Insert(ctx context.Context, rows []types.Row) error {
+	return fmt.Errorf("engine: table %s is read only", vt.tn)
+}
+
+func (vt *virtualTable) Upsert(ctx context.Context, rows []types.Row) error {
+	return fmt.Errorf("engine: table %s is read only", vt.tn)
+}
+
+func (vt *virtualTable) DeleteRange(ctx context.Context, minRow, maxRow types.Row) (int64,
+	error) {
+
+	return 0, fmt.Errorf("engine: table %s is read only", vt.tn)
+}
+
+func (vr *virtualRows) Next(ctx context.Context) (types.Row, error) {
+	if vr.next < 0 {
+		panic(fmt.Sprintf("engine: next on closed rows for table %s", vr.vt.tn))
+	}
+
+	if vr.next == len(vr.rows) {
+		return nil, io.EOF
+	}
+
+	vr.next += 1
+	if vr.cols != nil {
+		row := make([]types.Value, len(vr.cols))
+		for idx, col := range vr.cols {
+			row[idx] = vr.rows[vr.next-1][col]
+		}
+		return row, nil
+	}
+
+	return vr.rows[vr.next-1], nil
+}
+
+func (vr *virtualRows) Current() (storage.RowRef, error) {
+	return nil, fmt.Errorf("engine: table %s is read only", vr.vt.tn)
+}
+
+func (vr *virtualRows) Close(ctx context.Context) error {
+	vr.next = -1
+	return nil
+}
@@ -0,0 +1,241 @@
+package evaluate
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/leftmike/maho/engine"
+	"github.com/leftmike/maho/parser/sql"
+	"github.com/leftmike/maho/storage"
+	"github.com/leftmike/maho/types"
+)
+
+type checkConstraint struct {
+	name  types.Identifier
+	check expr
+}
+
+// rowChecker converts the values of a row to the column types of a table and then checks
+// the NOT NULL and CHECK constraints.
+type rowChecker struct {
+	tn     types.TableName
+	tt     *engine.TableType
+	checks []checkConstraint
+}
+
+func makeConstraintName(names []types.Identifier, nam string) types.Identifier {
+	cn := types.ID(nam, false)
+	for n := 2; ; n += 1 {
+		if !slices.Contains(names, cn) {
+			return cn
+		}
+		cn = types.ID(fmt.Sprintf("%s_%d", nam, n), false)
+	}
+}
+
+func compileCheck(ctx context.Context, pctx *planContext, cols []column, check sql.Expr) (expr,
+	error) {
+
+	if !sql.WalkExpr(check,
+		func(e sql.Expr) bool {
+			_, ok := e.(*sql.Subquery)
+			return !ok
+		}) {
+
+		return nil, fmt.Errorf("evaluate: subqueries not allowed in check constraint: %s",
+			check)
+	}
+	return compileExpr(ctx, pctx, cols, check)
+}
+
+func newRowChecker(ctx context.Context, pctx *planContext, tn types.TableName,
+	tt *engine.TableType) (*rowChecker, error) {
+
+	rc := &rowChecker{
+		tn: tn,
+		tt: tt,
+	}
+
+	cols := tableColumns(tn, tt)
+	for _, con := range tt.Constraints {
+		if con.Type != sql.CheckConstraint {
+			continue
+		}
+
+		ce, err := compileCheck(ctx, pctx, cols, con.Check)
+		if err != nil {
+			return nil, err
+		}
+		rc.checks = append(rc.checks, checkConstraint{name: con.Name, check: ce})
+	}
+
+	return rc, nil
+}
+
+func (rc *rowChecker) notNullError(num types.ColumnNum) error {
+	for _, con := range rc.tt.Constraints {
+		if con.Type == sql.NotNullConstraint && con.Column == num {
+			return fmt.Errorf("evaluate: %s: not null constraint %s violated: %s", rc.tn,
+				con.Name, rc.tt.ColumnNames[num])
+		}
+	}
+	return fmt.Errorf("evaluate: %s: not null constraint violated: %s", rc.tn,
+		rc.tt.ColumnNames[num])
+}
+
+func checkValue(ctx context.Context, tn types.TableName, cc checkConstraint,
+	row types.Row) error {
+
+	val, err := evalBool(ctx, cc.check, row)
+	if err != nil {
+		return err
+	} else if val == types.BoolValue(false) {
+		return fmt.Errorf("evaluate: %s: check constraint %s violated: %s", tn, cc.name,
+			cc.check)
+	}
+	return nil
+}
+
+// checkRow converts the values of row in place.
+func (rc *rowChecker) checkRow(ctx context.Context, row types.Row) error {
+	for num, ct := range rc.tt.ColumnTypes {
+		if row[num] == nil {
+			if ct.NotNull {
+				return rc.notNullError(types.ColumnNum(num))
+			}
+			continue
+		}
+
+		val, err := types.ConvertValue(ct, row[num])
+		if err != nil {
+			return fmt.Errorf("evaluate: %s: %s: %s", rc.tn, rc.tt.ColumnNames[num], err)
+		}
+		row[num] = val
+	}
+
+	for _, cc := range rc.checks {
+		err := checkValue(ctx, rc.tn, cc, row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// conflictingRow returns the row of tbl, and a reference to it, which has the same key as row in
+// the primary key, if idx is negative, or in unique index idx. The reference is nil if there is
+// no such row.
+func conflictingRow(ctx context.Context, tbl engine.Table, idx int, row types.Row) (
+	storage.RowRef, types.Row, error) {
+
+	var rows storage.Rows
+	var err error
+	if idx < 0 {
+		rows, err = tbl.Rows(ctx, nil, row, row, nil)
+	} else {
+		it := tbl.Type().Indexes[idx]
+		if keyValues(keyColumns(it.Key), row) == nil {
+			return nil, nil, nil
+		}
+		rows, err = tbl.IndexRows(ctx, it.Name, nil, row, row)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var cref storage.RowRef
+	var crow types.Row
+	err = eachRow(ctx, rows, nil,
+		func(ref storage.RowRef, row types.Row) error {
+			cref = ref
+			crow = row
+			return nil
+		})
+	if err != nil {
+		return nil, nil, err
+	}
+	return cref, crow, nil
+}
+
+func createConstraints(ctx context.Context, tx engine.Transaction, stmt *sql.CreateTable) (
+	[]engine.Constraint, error) {
+
+	cols := make([]column, 0, len(stmt.Columns))
+	for _, col := range stmt.Columns {
+		cols = append(cols, column{table: stmt.Table.Table, name: col})
+	}
+
+	var cons []engine.Constraint
+	var names []types.Identifier
+	for _, c := range stmt.Constraints {
+		if c.Name != 0 {
+			names = append(names, c.Name)
+		}
+		switch c.Type {
+		case sql.DefaultConstraint, sql.NotNullConstraint:
+			cons = append(cons,
+				engine.Constraint{
+					Name:   c.Name,
+					Type:   c.Type,
+					Column: types.ColumnNum(c.ColNum),
+				})
+		case sql.CheckConstraint:
+			_, err := compileCheck(ctx, &planContext{tx: tx}, cols, c.Check)
+			if err != nil {
+				return nil, err
+			}
+
+			cn := c.Name
+			if cn == 0 {
+				if c.ColNum >= 0 {
+					cn = makeConstraintName(names, stmt.Columns[c.ColNum].String()+"_check")
+				} else {
+					cn = makeConstraintName(names, stmt.Table.Table.String()+"_check")
+				}
+				names = append(names, cn)
+			}
+			cons = append(cons,
+				engine.Constraint{
+					Name:  cn,
+					Type:  sql.CheckConstraint,
+					Check: c.Check,
+				})
+		}
+	}
+
+	return cons, nil
+}
+
+func evaluateAddConstraint(ctx context.Context, tx engine.Transaction, tbl engine.Table,
+	c sql.Constraint) error {
+
+	tn := tbl.Name()
+	tt := tbl.Type()
+	pctx := &planContext{tx: tx}
+	ce, err := compileCheck(ctx, pctx, tableColumns(tn, tt), c.Check)
+	if err != nil {
+		return err
+	}
+
+	cn := c.Name
+	if cn == 0 {
+		cn = makeConstraintName(constraintNames(tt), tn.Table.String()+"_check")
+	}
+
+	cc := checkConstraint{name: cn, check: ce}
+	err = modifyRows(ctx, tbl, nil,
+		func(ref storage.RowRef, row types.Row) error {
+			return checkValue(ctx, tn, cc, row)
+		})
+	if err != nil {
+		return err
+	}
+
+	return tx.AddConstraint(ctx, tn,
+		engine.Constraint{
+			Name:  cn,
+			Type:  sql.CheckConstraint,
+			Check: c.Check,
+		})
+}
@@ -0,0 +1,890 @@
+package evaluate
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/leftmike/maho/parser/sql"
+	"github.com/leftmike/maho/types"
+)
+
+const (
+	copyBatchSize = 256
+
+	binarySignature = "PGCOPY\n\377\r\n\000"
+)
+
+// copyReader reads the rows of COPY ... FROM STDIN in the Postgres text, CSV, or binary format.
+// The text and CSV data ends at a line containing only `\.` or at the end of the input; the
+// binary data ends at the trailer.
+type copyReader struct {
+	rr        sql.CopyReader
+	format    sql.CopyFormat
+	colTypes  []types.ColumnType // types of the columns being copied; only for binary
+	delimiter rune
+	null      string
+	quote     rune
+	escape    rune
+	line      int // line of the row most recently read
+	next      int // line of the next rune
+	peek      rune
+	peeked    bool
+	started   bool // the binary header has been read
+	done      bool
+}
+
+func (cr *copyReader) readRune() (rune, error) {
+	if cr.peeked {
+		cr.peeked = false
+		return cr.peek, nil
+	}
+
+	r, _, err := cr.rr.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if r == '\n' {
+		cr.next += 1
+	}
+	return r, nil
+}
+
+func (cr *copyReader) unreadRune(r rune) {
+	cr.peek = r
+	cr.peeked = true
+}
+
+// readRow returns the values of the next row; each value is a string or NULL. At the end of the
+// data, io.EOF is returned.
+func (cr *copyReader) readRow() ([]types.Value, error) {
+	if cr.done {
+		return nil, io.EOF
+	}
+
+	cr.line = cr.next
+	if cr.format == sql.CSVFormat {
+		return cr.readCSV()
+	} else if cr.format == sql.BinaryFormat {
+		return cr.readBinary()
+	}
+
+	fields, err := cr.readText()
+	if err != nil {
+		return nil, err
+	}
+	vals := make([]types.Value, len(fields))
+	for fdx, field := range fields {
+		if field == cr.null {
+			continue
+		}
+		vals[fdx], err = copyValue(field)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return vals, nil
+}
+
+// readText returns the next line, without the trailing newline, split into fields on the
+// delimiter; escaped characters, including the delimiter, are left in place.
+func (cr *copyReader) readText() ([]string, error) {
+	var fields []string
+	var buf strings.Builder
+	var escape, empty bool
+	empty = true
+	for {
+		r, err := cr.readRune()
+		if err == io.EOF {
+			cr.done = true
+			if empty {
+				return nil, io.EOF
+			}
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if r == '\n' {
+			break
+		}
+		empty = false
+
+		if escape {
+			buf.WriteRune(r)
+			escape = false
+		} else if r == '\\' {
+			buf.WriteRune(r)
+			escape = true
+		} else if r == cr.delimiter {
+			fields = append(fields, buf.String())
+			buf.Reset()
+		} else {
+			buf.WriteRune(r)
+		}
+	}
+
+	fields = append(fields, buf.String())
+	if len(fields) == 1 && fields[0] == `\.` {
+		cr.done = true
+		return nil, io.EOF
+	}
+	return fields, nil
+}
+
+// readCSV returns the values of the next row; quoted values may contain newlines. An unquoted
+// value which is the same as the null string is NULL.
+func (cr *copyReader) readCSV() ([]types.Value, error) {
+	var vals []types.Value
+	var buf strings.Builder
+	var quoted, empty bool
+	empty = true
+
+	value := func() {
+		if !quoted && buf.String() == cr.null {
+			vals = append(vals, nil)
+		} else {
+			vals = append(vals, types.StringValue(buf.String()))
+		}
+		buf.Reset()
+		quoted = false
+	}
+
+	for {
+		r, err := cr.readRune()
+		if err == io.EOF {
+			cr.done = true
+			if empty {
+				return nil, io.EOF
+			}
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if r == '\n' {
+			break
+		}
+		empty = false
+
+		if r == cr.delimiter {
+			value()
+			continue
+		} else if r != cr.quote {
+			buf.WriteRune(r)
+			continue
+		}
+
+		quoted = true
+		for {
+			r, err = cr.readRune()
+			if err == io.EOF {
+				return nil, fmt.Errorf("unterminated quoted value")
+			} else if err != nil {
+				return nil, err
+			}
+
+			if r == cr.escape {
+				n, err := cr.readRune()
+				if err == nil {
+					if n == cr.quote || n == cr.escape {
+						buf.WriteRune(n)
+						continue
+					}
+					cr.unreadRune(n)
+				} else if err != io.EOF {
+					return nil, err
+				}
+			}
+			if r == cr.quote {
+				break
+			}
+			buf.WriteRune(r)
+		}
+	}
+
+	if len(vals) == 0 && !quoted && buf.String() == `\.` {
+		cr.done = true
+		return nil, io.EOF
+	}
+	value()
+	return vals, nil
+}
+
+func (cr *copyReader) readBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	for bdx := range b {
+		var err error
+		b[bdx], err = cr.rr.ReadByte()
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func (cr *copyReader) readInt16() (int16, error) {
+	b, err := cr.readBytes(2)
+	if err != nil {
+		return 0, err
+	}
+	return int16(binary.BigEndian.Uint16(b)), nil
+}
+
+func (cr *copyReader) readInt32() (int32, error) {
+	b, err := cr.readBytes(4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.BigEndian.Uint32(b)), nil
+}
+
+func (cr *copyReader) readHeader() error {
+	b, err := cr.readBytes(len(binarySignature))
+	if err != nil {
+		return err
+	} else if string(b) != binarySignature {
+		return fmt.Errorf("invalid binary signature")
+	}
+
+	flags, err := cr.readInt32()
+	if err != nil {
+		return err
+	} else if flags&(1<<16) != 0 {
+		return fmt.Errorf("binary data with oids is not supported")
+	}
+	n, err := cr.readInt32()
+	if err != nil {
+		return err
+	} else if n < 0 {
+		return fmt.Errorf("invalid binary header extension length: %d", n)
+	}
+	_, err = cr.readBytes(int(n))
+	return err
+}
+
+// readBinary returns the values of the next row, converted to the types of the columns being
+// copied; each row counts as a line. If the data is malformed, the rest of the data can not be
+// found, so it is not read.
+func (cr *copyReader) readBinary() ([]types.Value, error) {
+	fields, err := cr.readFields()
+	if err != nil {
+		cr.done = true
+		return nil, err
+	}
+	cr.next += 1
+	if fields == nil {
+		cr.done = true
+		return nil, io.EOF
+	} else if len(fields) != len(cr.colTypes) {
+		return nil, fmt.Errorf("expected %d values, got %d", len(cr.colTypes), len(fields))
+	}
+
+	vals := make([]types.Value, len(fields))
+	for fdx, field := range fields {
+		if field == nil {
+			continue
+		}
+		vals[fdx], err = binaryValue(cr.colTypes[fdx], field)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return vals, nil
+}
+
+// readFields returns the fields of the next row, or nil at the trailer; a NULL field is nil.
+func (cr *copyReader) readFields() ([][]byte, error) {
+	if !cr.started {
+		cr.started = true
+		err := cr.readHeader()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cnt, err := cr.readInt16()
+	if err != nil {
+		return nil, err
+	} else if cnt == -1 {
+		return nil, nil
+	} else if cnt < 0 {
+		return nil, fmt.Errorf("invalid binary field count: %d", cnt)
+	}
+
+	fields := make([][]byte, cnt)
+	for fdx := range fields {
+		n, err := cr.readInt32()
+		if err != nil {
+			return nil, err
+		} else if n == -1 {
+			continue
+		} else if n < 0 {
+			return nil, fmt.Errorf("invalid binary field length: %d", n)
+		}
+		fields[fdx], err = cr.readBytes(int(n))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+// binaryValue converts a field in the binary format, as written by copyWriter.writeBinary, to a
+// value of type ct.
+func binaryValue(ct types.ColumnType, b []byte) (types.Value, error) {
+	switch ct.Type {
+	case types.BoolType:
+		if len(b) == 1 {
+			return types.BoolValue(b[0] != 0), nil
+		}
+	case types.Int64Type:
+		switch len(b) {
+		case 2:
+			return types.Int64Value(int16(binary.BigEndian.Uint16(b))), nil
+		case 4:
+			return types.Int64Value(int32(binary.BigEndian.Uint32(b))), nil
+		case 8:
+			return types.Int64Value(binary.BigEndian.Uint64(b)), nil
+		}
+	case types.Float64Type:
+		switch len(b) {
+		case 4:
+			return types.Float64Value(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+		case 8:
+			return types.Float64Value(math.Float64frombits(binary.BigEndian.Uint64(b))), nil
+		}
+	case types.StringType:
+		if utf8.Valid(b) {
+			return types.StringValue(b), nil
+		}
+		return nil, fmt.Errorf("invalid UTF-8 in binary %s value", ct.Type)
+	case types.BytesType:
+		return types.BytesValue(b), nil
+	}
+	return nil, fmt.Errorf("invalid binary %s value of %d bytes", ct.Type, len(b))
+}
+
+// skip reads and discards the rest of the data.
+func (cr *copyReader) skip() {
+	for {
+		_, err := cr.readRow()
+		if err == io.EOF {
+			return
+		}
+	}
+}
+
+func isOctal(r rune) bool {
+	return r >= '0' && r <= '7'
+}
+
+func hexDigit(r rune) (byte, bool) {
+	switch {
+	case r >= '0' && r <= '9':
+		return byte(r - '0'), true
+	case r >= 'a' && r <= 'f':
+		return byte(r-'a') + 10, true
+	case r >= 'A' && r <= 'F':
+		return byte(r-'A') + 10, true
+	}
+	return 0, false
+}
+
+// copyValue converts a field, from readText, to a value.
+func copyValue(field string) (types.Value, error) {
+	if !strings.ContainsRune(field, '\\') {
+		return types.StringValue(field), nil
+	}
+
+	var buf strings.Builder
+	rs := []rune(field)
+	for rdx := 0; rdx < len(rs); rdx += 1 {
+		if rs[rdx] != '\\' {
+			buf.WriteRune(rs[rdx])
+			continue
+		}
+
+		rdx += 1
+		if rdx == len(rs) {
+			return nil, fmt.Errorf("unexpected end of value after backslash: %s", field)
+		}
+		switch r := rs[rdx]; r {
+		case 'b':
+			buf.WriteByte('\b')
+		case 'f':
+			buf.WriteByte('\f')
+		case 'n':
+			buf.WriteByte('\n')
+		case 'r':
+			buf.WriteByte('\r')
+		case 't':
+			buf.WriteByte('\t')
+		case 'v':
+			buf.WriteByte('\v')
+		case 'x':
+			var b byte
+			var cnt int
+			for cnt < 2 && rdx+1 < len(rs) {
+				d, ok := hexDigit(rs[rdx+1])
+				if !ok {
+					break
+				}
+				b = b*16 + d
+				cnt += 1
+				rdx += 1
+			}
+			if cnt == 0 {
+				buf.WriteRune(r)
+			} else {
+				buf.WriteByte(b)
+			}
+		default:
+			if !isOctal(r) {
+				buf.WriteRune(r)
+				break
+			}
+
+			b := byte(r - '0')
+			for cnt := 1; cnt < 3 && rdx+1 < len(rs) && isOctal(rs[rdx+1]); cnt += 1 {
+				rdx += 1
+				b = b*8 + byte(rs[rdx]-'0')
+			}
+			buf.WriteByte(b)
+		}
+	}
+	return types.StringValue(buf.String()), nil
+}
+
+// evaluateCopy evaluates COPY ... FROM STDIN and COPY ... TO STDOUT. If COPY ... FROM STDIN
+// fails, the rest of the data is read and discarded, so that parsing can continue after it.
+func evaluateCopy(ctx context.Context, pctx *planContext, stmt *sql.Copy) error {
+	if stmt.To {
+		return copyTo(ctx, pctx, stmt)
+	}
+
+	cr := &copyReader{
+		rr:        stmt.From,
+		format:    stmt.Format,
+		delimiter: stmt.Delimiter,
+		null:      stmt.Null,
+		quote:     stmt.Quote,
+		escape:    stmt.Escape,
+		next:      stmt.FromLine,
+	}
+	if stmt.Format == sql.BinaryFormat {
+		cr.next = 1
+	}
+	err := copyFrom(ctx, pctx, stmt, cr)
+	if err != nil {
+		cr.skip()
+	}
+	return err
+}
+
+func copyFrom(ctx context.Context, pctx *planContext, stmt *sql.Copy, cr *copyReader) error {
+	tbl, err := pctx.tx.OpenTable(ctx, stmt.Table)
+	if err != nil {
+		return err
+	}
+	tt := tbl.Type()
+
+	var nums []types.ColumnNum
+	if stmt.Columns == nil {
+		for num := range tt.ColumnNames {
+			nums = append(nums, types.ColumnNum(num))
+		}
+	} else {
+		for _, col := range stmt.Columns {
+			num, ok := columnNumber(col, tt.ColumnNames)
+			if !ok {
+				return fmt.Errorf("evaluate: copy: %s: unknown column: %s", stmt.Table, col)
+			}
+			nums = append(nums, num)
+		}
+	}
+	for _, num := range nums {
+		cr.colTypes = append(cr.colTypes, tt.ColumnTypes[num])
+	}
+
+	// Only the columns which are not copied need defaults.
+	dflts := make([]expr, len(tt.ColumnNames))
+	for num := range dflts {
+		if stmt.Columns != nil && !slices.Contains(nums, types.ColumnNum(num)) {
+			dflts[num], err = compileDefault(ctx, pctx, tt, types.ColumnNum(num))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	rc, err := newRowChecker(ctx, pctx, stmt.Table, tt)
+	if err != nil {
+		return err
+	}
+
+	// The rows are inserted in batches; lines are the lines of the rows of the batch. If a batch
+	// fails, it is inserted again, a row at a time, to find the line of the row which failed.
+	var lines []int
+	insert := func(rows []types.Row) error {
+		sp := pctx.tx.Savepoint()
+		err := tbl.Insert(ctx, rows)
+		if err != nil {
+			err = pctx.tx.RollbackTo(sp)
+			if err != nil {
+				return err
+			}
+			for rdx, row := range rows {
+				err = tbl.Insert(ctx, []types.Row{row})
+				if err != nil {
+					return fmt.Errorf("evaluate: copy: %s: line %d: %s", stmt.Table, lines[rdx],
+						err)
+				}
+			}
+		}
+
+		for _, row := range rows {
+			insertedRow(pctx, tbl, row)
+		}
+		return nil
+	}
+
+	if stmt.Header {
+		_, err = cr.readRow()
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("evaluate: copy: %s: line %d: %s", stmt.Table, cr.line, err)
+		}
+	}
+
+	rows := make([]types.Row, 0, copyBatchSize)
+	for {
+		vals, err := cr.readRow()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("evaluate: copy: %s: line %d: %s", stmt.Table, cr.line, err)
+		}
+		if len(vals) != len(nums) {
+			return fmt.Errorf("evaluate: copy: %s: line %d: expected %d values, got %d",
+				stmt.Table, cr.line, len(nums), len(vals))
+		}
+
+		row := make(types.Row, len(tt.ColumnNames))
+		for num, e := range dflts {
+			if e == nil {
+				continue
+			}
+			row[num], err = e.eval(ctx, nil)
+			if err != nil {
+				return err
+			}
+		}
+		for vdx, val := range vals {
+			num := nums[vdx]
+			sv, ok := val.(types.StringValue)
+			if ok && tt.ColumnTypes[num].Type == types.BytesType &&
+				strings.HasPrefix(string(sv), `\x`) {
+
+				b, err := hex.DecodeString(string(sv[2:]))
+				if err != nil {
+					return fmt.Errorf("evaluate: copy: %s: line %d: %s: %s", stmt.Table, cr.line,
+						tt.ColumnNames[num], err)
+				}
+				val = types.BytesValue(b)
+			}
+			row[num] = val
+		}
+
+		row, err = types.ConvertRow(tt.ColumnTypes, row)
+		if err == nil {
+			err = rc.checkRow(ctx, row)
+		}
+		if err != nil {
+			return fmt.Errorf("evaluate: copy: %s: line %d: %s", stmt.Table, cr.line, err)
+		}
+
+		lines = append(lines, cr.line)
+		rows = append(rows, row)
+		if len(rows) == copyBatchSize {
+			err = insert(rows)
+			if err != nil {
+				return err
+			}
+			rows = make([]types.Row, 0, copyBatchSize)
+			lines = lines[:0]
+		}
+	}
+
+	if len(rows) > 0 {
+		err = insert(rows)
+		if err != nil {
+			return err
+		}
+	}
+	return pctx.checkForeignKeys(ctx)
+}
+
+// copyWriter writes the rows of COPY ... TO STDOUT.
+type copyWriter struct {
+	w          *bufio.Writer
+	stmt       *sql.Copy
+	sizes      []uint32 // sizes of the columns, if known, for the binary format
+	forceQuote []bool
+}
+
+func (cw *copyWriter) writeHeader(cols []column) error {
+	switch cw.stmt.Format {
+	case sql.BinaryFormat:
+		cw.w.WriteString(binarySignature)
+		binary.Write(cw.w, binary.BigEndian, int32(0)) // flags
+		binary.Write(cw.w, binary.BigEndian, int32(0)) // header extension length
+	default:
+		if !cw.stmt.Header {
+			break
+		}
+
+		row := make(types.Row, len(cols))
+		for cdx, col := range cols {
+			row[cdx] = types.StringValue(col.name.String())
+		}
+		saved := cw.forceQuote
+		cw.forceQuote = make([]bool, len(cols))
+		cw.writeRow(row)
+		cw.forceQuote = saved
+	}
+	return nil
+}
+
+func (cw *copyWriter) writeTrailer() error {
+	if cw.stmt.Format == sql.BinaryFormat {
+		binary.Write(cw.w, binary.BigEndian, int16(-1))
+	}
+	return cw.w.Flush()
+}
+
+// copyText returns the text representation of a value which is not NULL.
+func copyText(val types.Value) string {
+	switch val := val.(type) {
+	case types.BoolValue:
+		if val {
+			return "t"
+		}
+		return "f"
+	case types.Int64Value:
+		return strconv.FormatInt(int64(val), 10)
+	case types.Float64Value:
+		return strconv.FormatFloat(float64(val), 'g', -1, 64)
+	case types.StringValue:
+		return string(val)
+	case types.BytesValue:
+		return `\x` + hex.EncodeToString([]byte(val))
+	}
+
+	panic(fmt.Sprintf("evaluate: unexpected value: %#v", val))
+}
+
+func (cw *copyWriter) writeRow(row types.Row) {
+	if cw.stmt.Format == sql.BinaryFormat {
+		cw.writeBinary(row)
+		return
+	}
+
+	for vdx, val := range row {
+		if vdx > 0 {
+			cw.w.WriteRune(cw.stmt.Delimiter)
+		}
+		if val == nil {
+			cw.w.WriteString(cw.stmt.Null)
+		} else if cw.stmt.Format == sql.CSVFormat {
+			cw.writeCSV(copyText(val), cw.forceQuote[vdx])
+		} else {
+			cw.writeText(copyText(val))
+		}
+	}
+	cw.w.WriteByte('\n')
+}
+
+func (cw *copyWriter) writeText(s string) {
+	for _, r := range s {
+		switch r {
+		case '\\':
+			cw.w.WriteString(`\\`)
+		case '\b':
+			cw.w.WriteString(`\b`)
+		case '\f':
+			cw.w.WriteString(`\f`)
+		case '\n':
+			cw.w.WriteString(`\n`)
+		case '\r':
+			cw.w.WriteString(`\r`)
+		case '\t':
+			cw.w.WriteString(`\t`)
+		case '\v':
+			cw.w.WriteString(`\v`)
+		default:
+			if r == cw.stmt.Delimiter {
+				cw.w.WriteByte('\\')
+			}
+			cw.w.WriteRune(r)
+		}
+	}
+}
+
+func (cw *copyWriter) writeCSV(s string, force bool) {
+	if !force && s != cw.stmt.Null && s != `\.` &&
+		!strings.ContainsAny(s, string([]rune{cw.stmt.Delimiter, cw.stmt.Quote, '\r', '\n'})) {
+
+		cw.w.WriteString(s)
+		return
+	}
+
+	cw.w.WriteRune(cw.stmt.Quote)
+	for _, r := range s {
+		if r == cw.stmt.Quote || r == cw.stmt.Escape {
+			cw.w.WriteRune(cw.stmt.Escape)
+		}
+		cw.w.WriteRune(r)
+	}
+	cw.w.WriteRune(cw.stmt.Quote)
+}
+
+func (cw *copyWriter) writeBinary(row types.Row) {
+	binary.Write(cw.w, binary.BigEndian, int16(len(row)))
+	for vdx, val := range row {
+		var size uint32
+		if cw.sizes != nil {
+			size = cw.sizes[vdx]
+		}
+
+		var b []byte
+		switch val := val.(type) {
+		case nil:
+			binary.Write(cw.w, binary.BigEndian, int32(-1))
+			continue
+		case types.BoolValue:
+			if val {
+				b = []byte{1}
+			} else {
+				b = []byte{0}
+			}
+		case types.Int64Value:
+			switch size {
+			case 2:
+				b = binary.BigEndian.AppendUint16(nil, uint16(val))
+			case 4:
+				b = binary.BigEndian.AppendUint32(nil, uint32(val))
+			default:
+				b = binary.BigEndian.AppendUint64(nil, uint64(val))
+			}
+		case types.Float64Value:
+			if size == 4 {
+				b = binary.BigEndian.AppendUint32(nil, math.Float32bits(float32(val)))
+			} else {
+				b = binary.BigEndian.AppendUint64(nil, math.Float64bits(float64(val)))
+			}
+		case types.StringValue:
+			b = []byte(val)
+		case types.BytesValue:
+			b = []byte(val)
+		default:
+			panic(fmt.Sprintf("evaluate: unexpected value: %#v", val))
+		}
+		binary.Write(cw.w, binary.BigEndian, int32(len(b)))
+		cw.w.Write(b)
+	}
+}
+
+func copyTo(ctx context.Context, pctx *planContext, stmt *sql.Copy) error {
+	if pctx.ses == nil {
+		return fmt.Errorf("evaluate: %s: requires a session", stmt)
+	} else if pctx.ses.copyOutput == nil {
+		return fmt.Errorf("evaluate: %s: no output", stmt)
+	}
+
+	cw := &copyWriter{
+		w:    bufio.NewWriter(pctx.ses.copyOutput),
+		stmt: stmt,
+	}
+
+	var p plan
+	var err error
+	if stmt.Query != nil {
+		p, err = planQuery(ctx, pctx, stmt.Query)
+		if err != nil {
+			return err
+		}
+	} else {
+		p, err = planTable(ctx, pctx, stmt.Table, 0)
+		if err != nil {
+			return err
+		}
+		tt := p.(*scanPlan).tbl.Type()
+
+		if stmt.Columns == nil {
+			for _, ct := range tt.ColumnTypes {
+				cw.sizes = append(cw.sizes, ct.Size)
+			}
+		} else {
+			pp := &projectPlan{plan: p}
+			cols := p.columns()
+			for _, col := range stmt.Columns {
+				num, ok := columnNumber(col, tt.ColumnNames)
+				if !ok {
+					return fmt.Errorf("evaluate: copy: %s: unknown column: %s", stmt.Table, col)
+				}
+				pp.cols = append(pp.cols, cols[num])
+				pp.exprs = append(pp.exprs,
+					columnRef{idx: int(num), ref: sql.Ref{cols[num].table, cols[num].name}})
+				cw.sizes = append(cw.sizes, tt.ColumnTypes[num].Size)
+			}
+			p = pp
+		}
+	}
+
+	cols := p.columns()
+	cw.forceQuote = make([]bool, len(cols))
+	for cdx := range cols {
+		cw.forceQuote[cdx] = stmt.ForceQuoteAll
+	}
+	for _, fq := range stmt.ForceQuote {
+		cdx, err := findColumn(cols, sql.Ref{fq})
+		if err != nil {
+			return fmt.Errorf("evaluate: copy: force quote: %s", err)
+		}
+		cw.forceQuote[cdx] = true
+	}
+
+	r, err := p.rows(ctx)
+	if err != nil {
+		return err
+	}
+	err = cw.writeHeader(cols)
+	if err != nil {
+		r.Close(ctx)
+		return err
+	}
+	for {
+		row, err := r.Next(ctx)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			r.Close(ctx)
+			return err
+		}
+		cw.writeRow(row)
+	}
+
+	err = r.Close(ctx)
+	if err != nil {
+		return err
+	}
+	return cw.writeTrailer()
+}
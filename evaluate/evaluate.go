@@ -3,6 +3,7 @@ package evaluate
 import (
 	"context"
 	"fmt"
+	"io"
 	"slices"
 
 	"github.com/leftmike/maho/engine"
@@ -11,17 +12,30 @@ import (
 )
 
 func Evaluate(ctx context.Context, tx engine.Transaction, stmt sql.Stmt) error {
+	return evaluate(ctx, &planContext{tx: tx}, stmt)
+}
+
+func evaluate(ctx context.Context, pctx *planContext, stmt sql.Stmt) error {
+	tx := pctx.tx
 	switch stmt := stmt.(type) {
+	case *sql.AlterSequence:
+		return EvaluateAlterSequence(ctx, tx, stmt)
+	case *sql.AlterTable:
+		return EvaluateAlterTable(ctx, tx, stmt)
 	case *sql.Begin:
 		panic("evaluate: begin unexpected")
 	case *sql.Commit:
 		panic("evaluate: commit unexpected")
+	case *sql.Copy:
+		return evaluateCopy(ctx, pctx, stmt)
 	case *sql.CreateDatabase:
 		panic("evaluate: create database unexpected")
 	case *sql.CreateIndex:
 		return EvaluateCreateIndex(ctx, tx, stmt)
 	case *sql.CreateSchema:
 		return tx.CreateSchema(ctx, stmt.Schema)
+	case *sql.CreateSequence:
+		return EvaluateCreateSequence(ctx, tx, stmt)
 	case *sql.CreateTable:
 		return EvaluateCreateTable(ctx, tx, stmt)
 	case *sql.DropDatabase:
@@ -30,12 +44,25 @@ func Evaluate(ctx context.Context, tx engine.Transaction, stmt sql.Stmt) error {
 		return EvaluateDropIndex(ctx, tx, stmt)
 	case *sql.DropSchema:
 		return tx.DropSchema(ctx, stmt.Schema, stmt.IfExists)
+	case *sql.DropSequence:
+		return EvaluateDropSequence(ctx, tx, stmt)
 	case *sql.DropTable:
 		return EvaluateDropTable(ctx, tx, stmt)
+	case *sql.Delete:
+		_, err := evaluateDelete(ctx, pctx, stmt)
+		return err
+	case *sql.InsertValues:
+		_, err := evaluateInsert(ctx, pctx, stmt)
+		return err
 	case *sql.Rollback:
 		panic("evaluate: rollback unexpected")
 	case *sql.Set:
 		panic("evaluate: set unexpected")
+	case *sql.Truncate:
+		return EvaluateTruncate(ctx, tx, stmt)
+	case *sql.Update:
+		_, err := evaluateUpdate(ctx, pctx, stmt)
+		return err
 	}
 
 	panic(fmt.Sprintf("evaluate: unexpected stmt: %#v", stmt))
@@ -90,7 +117,7 @@ func EvaluateCreateIndex(ctx context.Context, tx engine.Transaction, stmt *sql.C
 			stmt.Table, stmt.Index, col)
 	}
 
-	return tx.CreateIndex(ctx, stmt.Table, stmt.Index, key)
+	return tx.CreateIndex(ctx, stmt.Table, stmt.Index, key, stmt.Key.Unique)
 }
 
 func EvaluateCreateTable(ctx context.Context, tx engine.Transaction, stmt *sql.CreateTable) error {
@@ -102,6 +129,7 @@ func EvaluateCreateTable(ctx context.Context, tx engine.Transaction, stmt *sql.C
 	}
 
 	var primary []types.ColumnKey
+	var uniques []sql.Constraint
 	for _, con := range stmt.Constraints {
 		if con.Type == sql.PrimaryConstraint {
 			var col types.Identifier
@@ -110,15 +138,100 @@ func EvaluateCreateTable(ctx context.Context, tx engine.Transaction, stmt *sql.C
 				return fmt.Errorf("evaluate: create table: %s: primary key: unknown column: %s",
 					stmt.Table, col)
 			}
-			break
+		} else if con.Type == sql.UniqueConstraint {
+			_, col := indexKeyToColumnKey(con.Key, stmt.Columns)
+			if col != 0 {
+				return fmt.Errorf("evaluate: create table: %s: %s: unknown column: %s",
+					stmt.Table, con.Name, col)
+			}
+			uniques = append(uniques, con)
+		}
+	}
+
+	var colDefaults []sql.Expr
+	for cdx, dflt := range stmt.ColumnDefaults {
+		if dflt == nil {
+			continue
+		}
+		qualifySequences(stmt.Table, dflt)
+		_, err := compileExpr(ctx, &planContext{tx: tx}, nil, dflt)
+		if err != nil {
+			return fmt.Errorf("evaluate: create table: %s: %s: %s", stmt.Table, stmt.Columns[cdx],
+				err)
+		}
+		colDefaults = stmt.ColumnDefaults
+	}
+
+	cons, err := createConstraints(ctx, tx, stmt)
+	if err != nil {
+		return fmt.Errorf("evaluate: create table: %s: %s", stmt.Table, err)
+	}
+
+	// The columns of the primary key are NOT NULL.
+	colTypes := stmt.ColumnTypes
+	for _, ck := range primary {
+		if !colTypes[ck.Column()].NotNull {
+			colTypes = slices.Clone(colTypes)
+			colTypes[ck.Column()].NotNull = true
+		}
+	}
+
+	var identities []engine.IdentityColumn
+	for _, ci := range stmt.Identities {
+		col := stmt.Columns[ci.ColNum]
+		sqn, err := createIdentitySequence(ctx, tx, stmt.Table, col, colTypes[ci.ColNum],
+			ci.Options)
+		if err != nil {
+			return fmt.Errorf("evaluate: create table: %s: %s: %s", stmt.Table, col, err)
+		}
+
+		if colDefaults == nil {
+			colDefaults = make([]sql.Expr, len(stmt.Columns))
+			copy(colDefaults, stmt.ColumnDefaults)
+		}
+		colDefaults[ci.ColNum] = &sql.SExpr{
+			Name: types.ID("nextval", false),
+			Args: []sql.Expr{sql.Literal{Value: types.StringValue(sqn.String())}},
+		}
+		if !colTypes[ci.ColNum].NotNull {
+			colTypes = slices.Clone(colTypes)
+			colTypes[ci.ColNum].NotNull = true
+		}
+
+		identities = append(identities,
+			engine.IdentityColumn{
+				Column:   types.ColumnNum(ci.ColNum),
+				Sequence: sqn,
+				Always:   ci.Always,
+			})
+	}
+
+	err = tx.CreateTable(ctx, stmt.Table, stmt.Columns, colTypes, primary, colDefaults,
+		cons, identities)
+	if err != nil {
+		return err
+	}
+
+	// UNIQUE constraints are enforced using unique indexes.
+	for _, con := range uniques {
+		key, _ := indexKeyToColumnKey(con.Key, stmt.Columns)
+		err = tx.CreateIndex(ctx, stmt.Table, con.Name, key, true)
+		if err != nil {
+			return err
 		}
 	}
 
-	err := tx.CreateTable(ctx, stmt.Table, stmt.Columns, stmt.ColumnTypes, primary)
-	// XXX:	ColumnDefaults
-	// XXX: Constraints
-	// XXX: ForeignKeys
-	return err
+	for _, fk := range stmt.ForeignKeys {
+		tbl, err := tx.OpenTable(ctx, stmt.Table)
+		if err != nil {
+			return err
+		}
+		err = evaluateAddForeignKey(ctx, tx, tbl, fk)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func EvaluateDropIndex(ctx context.Context, tx engine.Transaction,
@@ -132,12 +245,256 @@ func EvaluateDropTable(ctx context.Context, tx engine.Transaction,
 	stmt *sql.DropTable) error {
 
 	// XXX: IfExists
-	// XXX: Cascade
 	for _, tn := range stmt.Tables {
-		err := tx.DropTable(ctx, tn)
+		tbl, err := tx.OpenTable(ctx, tn)
+		if err != nil {
+			return err
+		}
+
+		// Foreign keys which reference a dropped table must also be dropped.
+		for _, fr := range tbl.Type().ForeignRefs {
+			if fr.Table == tn {
+				continue
+			} else if !stmt.Cascade && !slices.Contains(stmt.Tables, fr.Table) {
+				return fmt.Errorf("evaluate: drop table: %s: referenced by foreign key %s on %s",
+					tn, fr.Name, fr.Table)
+			}
+
+			err = tx.DropConstraint(ctx, fr.Table, fr.Name)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = tx.DropTable(ctx, tn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func EvaluateTruncate(ctx context.Context, tx engine.Transaction, stmt *sql.Truncate) error {
+	var tbls []engine.Table
+	truncated := func(tn types.TableName) bool {
+		return slices.ContainsFunc(tbls,
+			func(tbl engine.Table) bool {
+				return tbl.Name() == tn
+			})
+	}
+
+	for _, tn := range stmt.Tables {
+		if truncated(tn) {
+			continue
+		}
+		tbl, err := tx.OpenTable(ctx, tn)
+		if err != nil {
+			return err
+		}
+		tbls = append(tbls, tbl)
+	}
+
+	// Tables with foreign keys which reference a truncated table must also be truncated.
+	for idx := 0; idx < len(tbls); idx += 1 {
+		for _, fr := range tbls[idx].Type().ForeignRefs {
+			if truncated(fr.Table) {
+				continue
+			} else if !stmt.Cascade {
+				return fmt.Errorf("evaluate: truncate: %s: referenced by foreign key %s on %s",
+					tbls[idx].Name(), fr.Name, fr.Table)
+			}
+
+			tbl, err := tx.OpenTable(ctx, fr.Table)
+			if err != nil {
+				return err
+			}
+			tbls = append(tbls, tbl)
+		}
+	}
+
+	// Deleting every row takes time proportional to the number of rows, but the storage log
+	// records each table as cleared, rather than each deleted row.
+	for _, tbl := range tbls {
+		_, err := tbl.DeleteRange(ctx, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		if stmt.RestartIdentity {
+			for _, ic := range tbl.Type().Identities {
+				seq, err := tx.LookupSequence(ctx, ic.Sequence)
+				if err != nil {
+					return err
+				}
+				err = tx.RestartSequence(ctx, ic.Sequence, seq.Start)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func evaluateDefault(ctx context.Context, tx engine.Transaction, dflt sql.Expr) (types.Value,
+	error) {
+
+	e, err := compileExpr(ctx, &planContext{tx: tx}, nil, dflt)
+	if err != nil {
+		return nil, err
+	}
+	return e.eval(ctx, nil)
+}
+
+func hasRows(ctx context.Context, tbl engine.Table) (bool, error) {
+	rows, err := tbl.Rows(ctx, nil, nil, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close(ctx)
+
+	_, err = rows.Next(ctx)
+	if err == io.EOF {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func EvaluateAlterTable(ctx context.Context, tx engine.Transaction, stmt *sql.AlterTable) error {
+	for _, act := range stmt.Actions {
+		err := evaluateAlterAction(ctx, tx, stmt.Table, act)
 		if err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+func evaluateAlterAction(ctx context.Context, tx engine.Transaction, tn types.TableName,
+	act sql.AlterAction) error {
+
+	tbl, err := tx.OpenTable(ctx, tn)
+	if err != nil {
+		return err
+	}
+	tt := tbl.Type()
+
+	var col types.Identifier
+	switch act := act.(type) {
+	case *sql.AddColumn:
+		var val types.Value
+		if act.Default != nil {
+			qualifySequences(tn, act.Default)
+
+			// The default is evaluated once to fill in the existing rows, so a volatile default
+			// is only allowed if there are no existing rows.
+			if isVolatile(act.Default) {
+				found, err := hasRows(ctx, tbl)
+				if err != nil {
+					return err
+				} else if found {
+					return fmt.Errorf(
+						"evaluate: alter table: %s: %s: volatile default requires an empty table",
+						tn, act.Column)
+				}
+			} else {
+				val, err = evaluateDefault(ctx, tx, act.Default)
+				if err != nil {
+					return fmt.Errorf("evaluate: alter table: %s: %s: %s", tn, act.Column, err)
+				}
+			}
+		}
+		return tx.AddColumn(ctx, tn, act.Column, act.Type, act.Default, val)
+	case *sql.DropColumn:
+		if _, ok := columnNumber(act.Column, tt.ColumnNames); !ok {
+			if act.IfExists {
+				return nil
+			}
+			return fmt.Errorf("evaluate: alter table: %s: column not found: %s", tn, act.Column)
+		}
+		return tx.DropColumn(ctx, tn, act.Column)
+	case *sql.RenameTable:
+		ntn := tn
+		ntn.Table = act.NewName
+		return tx.RenameTable(ctx, tn, ntn)
+	case *sql.SetSchema:
+		ntn := tn
+		ntn.Schema = act.Schema
+		return tx.RenameTable(ctx, tn, ntn)
+	case *sql.AlterColumn:
+		col = act.Column
+	case *sql.RenameColumn:
+		col = act.Column
+	case *sql.AddConstraint:
+		return evaluateAddConstraint(ctx, tx, tbl, act.Constraint)
+	case *sql.DropConstraint:
+		if act.Name != 0 {
+			if !slices.Contains(constraintNames(tt), act.Name) {
+
+				if act.IfExists {
+					return nil
+				}
+				return fmt.Errorf("evaluate: alter table: %s: constraint not found: %s", tn,
+					act.Name)
+			}
+			return tx.DropConstraint(ctx, tn, act.Name)
+		}
+		col = act.Column
+	case *sql.AddForeignKey:
+		return evaluateAddForeignKey(ctx, tx, tbl, &act.ForeignKey)
+	default:
+		panic(fmt.Sprintf("evaluate: unexpected alter action: %#v", act))
+	}
+
+	num, ok := columnNumber(col, tt.ColumnNames)
+	if !ok {
+		return fmt.Errorf("evaluate: alter table: %s: column not found: %s", tn, col)
+	}
+
+	nam := col
+	ct := tt.ColumnTypes[num]
+	var dflt sql.Expr
+	if int(num) < len(tt.ColumnDefaults) {
+		dflt = tt.ColumnDefaults[num]
+	}
+
+	switch act := act.(type) {
+	case *sql.AlterColumn:
+		switch act.Action {
+		case sql.SetColumnDefault:
+			qualifySequences(tn, act.Default)
+			_, err := compileExpr(ctx, &planContext{tx: tx}, nil, act.Default)
+			if err != nil {
+				return fmt.Errorf("evaluate: alter table: %s: %s: %s", tn, col, err)
+			}
+			dflt = act.Default
+		case sql.SetColumnNotNull:
+			ct.NotNull = true
+		case sql.SetColumnType:
+			notNull := ct.NotNull
+			ct = act.Type
+			ct.NotNull = notNull
+		default:
+			panic(fmt.Sprintf("evaluate: unexpected alter column action: %d", act.Action))
+		}
+	case *sql.RenameColumn:
+		nam = act.NewName
+	case *sql.DropConstraint:
+		switch act.Type {
+		case sql.DefaultConstraint:
+			dflt = nil
+		case sql.NotNullConstraint:
+			if slices.Contains(primaryKeyColumns(tt), num) {
+				return fmt.Errorf("evaluate: alter table: %s: %s: column is in the primary key",
+					tn, col)
+			}
+			ct.NotNull = false
+		default:
+			panic(fmt.Sprintf("evaluate: unexpected constraint type: %s", act.Type))
+		}
+	}
+
+	return tx.AlterColumn(ctx, tn, col, nam, ct, dflt)
+}
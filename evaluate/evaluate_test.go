@@ -15,6 +15,7 @@ import (
 	"github.com/leftmike/maho/evaluate"
 	"github.com/leftmike/maho/parser"
 	"github.com/leftmike/maho/parser/sql"
+	"github.com/leftmike/maho/storage"
 	"github.com/leftmike/maho/testutil"
 	"github.com/leftmike/maho/types"
 )
@@ -266,8 +267,9 @@ CreateTable(db.sn.t4, [c1 c2], [INT BOOL], [2 -1])`,
 				trace: "ListTables(db.sn)",
 			},
 			{
-				stmt:  mustParse("drop table t1"),
-				trace: "DropTable(db.sn.t1)",
+				stmt: mustParse("drop table t1"),
+				trace: `OpenTable(db.sn.t1)
+DropTable(db.sn.t1)`,
 			},
 			{
 				fn: func(t *testing.T, tx engine.Transaction) {
@@ -276,8 +278,9 @@ CreateTable(db.sn.t4, [c1 c2], [INT BOOL], [2 -1])`,
 				trace: "ListTables(db.sn)",
 			},
 			{
-				stmt:  mustParse("drop table t3"),
-				trace: "DropTable(db.sn.t3)",
+				stmt: mustParse("drop table t3"),
+				trace: `OpenTable(db.sn.t3)
+DropTable(db.sn.t3)`,
 			},
 			{
 				fn: func(t *testing.T, tx engine.Transaction) {
@@ -304,7 +307,7 @@ CreateTable(db.sn.t2, [c1 c2], [INT BOOL], [])`,
 			{
 				stmt: mustParse("create index i1 on t1 (c1)"),
 				trace: `OpenTable(db.sn.t1)
-CreateIndex(db.sn.t1, i1, [1])`,
+CreateIndex(db.sn.t1, i1, [1], false)`,
 			},
 			{
 				stmt:  mustParse("create index i1 on t1 (c1)"),
@@ -319,7 +322,7 @@ CreateIndex(db.sn.t1, i1, [1])`,
 			{
 				stmt: mustParse("create index i2 on t1 (c2, c1)"),
 				trace: `OpenTable(db.sn.t1)
-CreateIndex(db.sn.t1, i2, [2 1])`,
+CreateIndex(db.sn.t1, i2, [2 1], false)`,
 			},
 			{
 				fn: func(t *testing.T, tx engine.Transaction) {
@@ -344,7 +347,7 @@ CreateIndex(db.sn.t1, i2, [2 1])`,
 			{
 				stmt: mustParse("create index i3 on t1 (c2)"),
 				trace: `OpenTable(db.sn.t1)
-CreateIndex(db.sn.t1, i3, [2])`,
+CreateIndex(db.sn.t1, i3, [2], false)`,
 			},
 			{
 				fn: func(t *testing.T, tx engine.Transaction) {
@@ -426,21 +429,24 @@ func (r resolver) ResolveSchema(sn types.SchemaName) types.SchemaName {
 }
 
 type evalTx struct {
-	trace   io.Writer
-	schemas map[types.SchemaName]struct{}
-	tables  map[types.TableName]*evalTable
+	trace     io.Writer
+	schemas   map[types.SchemaName]struct{}
+	tables    map[types.TableName]*evalTable
+	sequences map[types.TableName]int64
 }
 
 type evalTable struct {
-	name types.TableName
-	tt   *engine.TableType
+	name  types.TableName
+	tt    *engine.TableType
+	trace io.Writer
 }
 
 func newEvalTx(trace io.Writer) *evalTx {
 	return &evalTx{
-		trace:   trace,
-		schemas: map[types.SchemaName]struct{}{},
-		tables:  map[types.TableName]*evalTable{},
+		trace:     trace,
+		schemas:   map[types.SchemaName]struct{}{},
+		tables:    map[types.TableName]*evalTable{},
+		sequences: map[types.TableName]int64{},
 	}
 }
 
@@ -454,6 +460,28 @@ func (tx *evalTx) Rollback() error {
 	return nil
 }
 
+func (tx *evalTx) NextStmt() {}
+
+func (tx *evalTx) SetIsolation(level storage.IsolationLevel) error {
+	fmt.Fprintf(tx.trace, "SetIsolation(%s)\n", level)
+	return nil
+}
+
+func (tx *evalTx) SetReadOnly() error {
+	fmt.Fprintln(tx.trace, "SetReadOnly()")
+	return nil
+}
+
+func (tx *evalTx) Savepoint() storage.Savepoint {
+	fmt.Fprintln(tx.trace, "Savepoint()")
+	return nil
+}
+
+func (tx *evalTx) RollbackTo(sp storage.Savepoint) error {
+	fmt.Fprintln(tx.trace, "RollbackTo()")
+	return nil
+}
+
 func (tx *evalTx) CreateSchema(ctx context.Context, sn types.SchemaName) error {
 	fmt.Fprintf(tx.trace, "CreateSchema(%s)\n", sn)
 
@@ -507,7 +535,9 @@ func (tx *evalTx) OpenTable(ctx context.Context, tn types.TableName) (engine.Tab
 }
 
 func (tx *evalTx) CreateTable(ctx context.Context, tn types.TableName,
-	colNames []types.Identifier, colTypes []types.ColumnType, primary []types.ColumnKey) error {
+	colNames []types.Identifier, colTypes []types.ColumnType, primary []types.ColumnKey,
+	colDefaults []sql.Expr, constraints []engine.Constraint,
+	identities []engine.IdentityColumn) error {
 
 	fmt.Fprintf(tx.trace, "CreateTable(%s, %v, %v, %v)\n", tn, colNames, colTypes, primary)
 
@@ -517,11 +547,14 @@ func (tx *evalTx) CreateTable(ctx context.Context, tn types.TableName,
 	tx.tables[tn] = &evalTable{
 		name: tn,
 		tt: &engine.TableType{
-			Version:     1,
-			ColumnNames: slices.Clone(colNames),
-			ColumnTypes: slices.Clone(colTypes),
-			Key:         slices.Clone(primary),
+			Version:        1,
+			ColumnNames:    slices.Clone(colNames),
+			ColumnTypes:    slices.Clone(colTypes),
+			Key:            slices.Clone(primary),
+			ColumnDefaults: slices.Clone(colDefaults),
+			Constraints:    slices.Clone(constraints),
 		},
+		trace: tx.trace,
 	}
 	return nil
 }
@@ -555,10 +588,102 @@ func (tx *evalTx) ListTables(ctx context.Context, sn types.SchemaName) ([]types.
 	return ids, nil
 }
 
+func (tx *evalTx) RenameTable(ctx context.Context, tn, ntn types.TableName) error {
+	fmt.Fprintf(tx.trace, "RenameTable(%s, %s)\n", tn, ntn)
+
+	if _, ok := tx.tables[ntn]; ok {
+		return fmt.Errorf("rename table: table already exists: %s", ntn)
+	}
+	tbl := tx.tables[tn]
+	delete(tx.tables, tn)
+	tbl.name = ntn
+	tx.tables[ntn] = tbl
+	return nil
+}
+
+func (tx *evalTx) AddColumn(ctx context.Context, tn types.TableName, col types.Identifier,
+	ct types.ColumnType, dflt sql.Expr, val types.Value) error {
+
+	fmt.Fprintf(tx.trace, "AddColumn(%s, %s, %s, %v, %v)\n", tn, col, ct, dflt, val)
+
+	tbl := tx.tables[tn]
+	tbl.tt.ColumnNames = append(tbl.tt.ColumnNames, col)
+	tbl.tt.ColumnTypes = append(tbl.tt.ColumnTypes, ct)
+	return nil
+}
+
+func (tx *evalTx) DropColumn(ctx context.Context, tn types.TableName,
+	col types.Identifier) error {
+
+	fmt.Fprintf(tx.trace, "DropColumn(%s, %s)\n", tn, col)
+
+	tbl := tx.tables[tn]
+	num := slices.Index(tbl.tt.ColumnNames, col)
+	tbl.tt.ColumnNames = slices.Delete(tbl.tt.ColumnNames, num, num+1)
+	tbl.tt.ColumnTypes = slices.Delete(tbl.tt.ColumnTypes, num, num+1)
+	return nil
+}
+
+func (tx *evalTx) AlterColumn(ctx context.Context, tn types.TableName,
+	col, nam types.Identifier, ct types.ColumnType, dflt sql.Expr) error {
+
+	fmt.Fprintf(tx.trace, "AlterColumn(%s, %s, %s, %s, %v, %v)\n", tn, col, nam, ct, ct.NotNull,
+		dflt)
+
+	tbl := tx.tables[tn]
+	num := slices.Index(tbl.tt.ColumnNames, col)
+	tbl.tt.ColumnNames[num] = nam
+	tbl.tt.ColumnTypes[num] = ct
+	return nil
+}
+
+func (tx *evalTx) AddConstraint(ctx context.Context, tn types.TableName,
+	con engine.Constraint) error {
+
+	fmt.Fprintf(tx.trace, "AddConstraint(%s, %s, %s, %s)\n", tn, con.Name, con.Type, con.Check)
+
+	tbl, ok := tx.tables[tn]
+	if !ok {
+		return fmt.Errorf("add constraint: table not found: %s", tn)
+	}
+	tbl.tt.Constraints = append(tbl.tt.Constraints, con)
+	return nil
+}
+
+func (tx *evalTx) DropConstraint(ctx context.Context, tn types.TableName,
+	cn types.Identifier) error {
+
+	fmt.Fprintf(tx.trace, "DropConstraint(%s, %s)\n", tn, cn)
+
+	tbl, ok := tx.tables[tn]
+	if !ok {
+		return fmt.Errorf("drop constraint: table not found: %s", tn)
+	}
+	tbl.tt.Constraints = slices.DeleteFunc(tbl.tt.Constraints,
+		func(con engine.Constraint) bool {
+			return con.Name == cn
+		})
+	return nil
+}
+
+func (tx *evalTx) AddForeignKey(ctx context.Context, tn types.TableName,
+	fk engine.ForeignKey) error {
+
+	fmt.Fprintf(tx.trace, "AddForeignKey(%s, %s, %v, %s)\n", tn, fk.Name, fk.Columns,
+		fk.RefTable)
+
+	tbl, ok := tx.tables[tn]
+	if !ok {
+		return fmt.Errorf("add foreign key: table not found: %s", tn)
+	}
+	tbl.tt.ForeignKeys = append(tbl.tt.ForeignKeys, fk)
+	return nil
+}
+
 func (tx *evalTx) CreateIndex(ctx context.Context, tn types.TableName, in types.Identifier,
-	key []types.ColumnKey) error {
+	key []types.ColumnKey, unique bool) error {
 
-	fmt.Fprintf(tx.trace, "CreateIndex(%s, %s, %v)\n", tn, in, key)
+	fmt.Fprintf(tx.trace, "CreateIndex(%s, %s, %v, %v)\n", tn, in, key, unique)
 
 	tbl := tx.tables[tn]
 	if slices.ContainsFunc(tbl.tt.Indexes,
@@ -571,8 +696,9 @@ func (tx *evalTx) CreateIndex(ctx context.Context, tn types.TableName, in types.
 
 	tbl.tt.Indexes = append(tbl.tt.Indexes,
 		engine.IndexType{
-			Name: in,
-			Key:  slices.Clone(key),
+			Name:   in,
+			Key:    slices.Clone(key),
+			Unique: unique,
 		})
 	return nil
 }
@@ -602,6 +728,63 @@ func (tx *evalTx) DropIndex(ctx context.Context, tn types.TableName,
 	return nil
 }
 
+func (tx *evalTx) CreateSequence(ctx context.Context, sqn types.TableName,
+	seq engine.Sequence) error {
+
+	fmt.Fprintf(tx.trace, "CreateSequence(%s, %v)\n", sqn, seq)
+
+	tx.sequences[sqn] = seq.Start - seq.Increment
+	return nil
+}
+
+func (tx *evalTx) DropSequence(ctx context.Context, sqn types.TableName, ifExists bool) error {
+	fmt.Fprintf(tx.trace, "DropSequence(%s, %v)\n", sqn, ifExists)
+
+	delete(tx.sequences, sqn)
+	return nil
+}
+
+func (tx *evalTx) LookupSequence(ctx context.Context, sqn types.TableName) (engine.Sequence,
+	error) {
+
+	fmt.Fprintf(tx.trace, "LookupSequence(%s)\n", sqn)
+
+	if _, ok := tx.sequences[sqn]; !ok {
+		return engine.Sequence{}, fmt.Errorf("lookup sequence: sequence not found: %s", sqn)
+	}
+	return engine.Sequence{}, nil
+}
+
+func (tx *evalTx) AlterSequence(ctx context.Context, sqn types.TableName,
+	seq engine.Sequence) error {
+
+	fmt.Fprintf(tx.trace, "AlterSequence(%s, %v)\n", sqn, seq)
+	return nil
+}
+
+func (tx *evalTx) SetValue(ctx context.Context, sqn types.TableName, val int64,
+	called bool) error {
+
+	fmt.Fprintf(tx.trace, "SetValue(%s, %d, %v)\n", sqn, val, called)
+
+	tx.sequences[sqn] = val
+	return nil
+}
+
+func (tx *evalTx) RestartSequence(ctx context.Context, sqn types.TableName, val int64) error {
+	fmt.Fprintf(tx.trace, "RestartSequence(%s, %d)\n", sqn, val)
+
+	tx.sequences[sqn] = val - 1
+	return nil
+}
+
+func (tx *evalTx) NextValue(ctx context.Context, sqn types.TableName) (int64, error) {
+	fmt.Fprintf(tx.trace, "NextValue(%s)\n", sqn)
+
+	tx.sequences[sqn] += 1
+	return tx.sequences[sqn], nil
+}
+
 func (tbl *evalTable) Name() types.TableName {
 	return tbl.name
 }
@@ -609,3 +792,186 @@ func (tbl *evalTable) Name() types.TableName {
 func (tbl *evalTable) Type() *engine.TableType {
 	return tbl.tt
 }
+
+func (tbl *evalTable) TableId() storage.TableId {
+	return 0
+}
+
+func (tbl *evalTable) Rows(ctx context.Context, cols []types.ColumnNum, minRow,
+	maxRow types.Row, pred storage.Predicate) (storage.Rows, error) {
+
+	return nil, fmt.Errorf("rows: not implemented: %s", tbl.name)
+}
+
+func (tbl *evalTable) Scan(ctx context.Context, cols []types.ColumnNum, spec storage.ScanSpec,
+	pred storage.Predicate) (storage.Rows, error) {
+
+	return nil, fmt.Errorf("scan: not implemented: %s", tbl.name)
+}
+
+func (tbl *evalTable) SupportsPredicate(pred storage.Predicate) bool {
+	return false
+}
+
+func (tbl *evalTable) IndexRows(ctx context.Context, in types.Identifier,
+	cols []types.ColumnNum, minRow, maxRow types.Row) (storage.Rows, error) {
+
+	return nil, fmt.Errorf("index rows: not implemented: %s", tbl.name)
+}
+
+func (tbl *evalTable) Insert(ctx context.Context, rows []types.Row) error {
+	fmt.Fprintf(tbl.trace, "Insert(%s, %v)\n", tbl.name, rows)
+	return nil
+}
+
+func (tbl *evalTable) Upsert(ctx context.Context, rows []types.Row) error {
+	fmt.Fprintf(tbl.trace, "Upsert(%s, %v)\n", tbl.name, rows)
+	return nil
+}
+
+func (tbl *evalTable) DeleteRange(ctx context.Context, minRow, maxRow types.Row) (int64,
+	error) {
+
+	fmt.Fprintf(tbl.trace, "DeleteRange(%s, %v, %v)\n", tbl.name, minRow, maxRow)
+	return 0, nil
+}
+
+func TestEvaluateAlterTable(t *testing.T) {
+	testEvaluate(t,
+		[]evaluateCase{
+			{
+				stmt: mustParse("create table t1 (c1 int primary key, c2 bool)"),
+				trace: `OpenTable(db.sn.t1)
+CreateTable(db.sn.t1, [c1 c2], [INT BOOL], [1])`,
+			},
+			{
+				stmt: mustParse("alter table t1 add c3 bigint default 1 + 2, add column c4 text"),
+				trace: `OpenTable(db.sn.t1)
+AddColumn(db.sn.t1, c3, BIGINT, (1 + 2), 3)
+OpenTable(db.sn.t1)
+AddColumn(db.sn.t1, c4, TEXT, <nil>, <nil>)`,
+			},
+			{
+				stmt:  mustParse("alter table t1 add c5 int default c1"),
+				trace: "OpenTable(db.sn.t1)",
+				fail:  true,
+			},
+			{
+				stmt:  mustParse("alter table t2 add c5 int"),
+				trace: "OpenTable(db.sn.t2)",
+				fail:  true,
+			},
+			{
+				stmt: mustParse(
+					"alter table t1 alter c2 set not null, alter column c3 set default 123"),
+				trace: `OpenTable(db.sn.t1)
+AlterColumn(db.sn.t1, c2, c2, BOOL, true, <nil>)
+OpenTable(db.sn.t1)
+AlterColumn(db.sn.t1, c3, c3, BIGINT, false, 123)`,
+			},
+			{
+				stmt: mustParse("alter table t1 alter c2 drop not null, alter c3 type int"),
+				trace: `OpenTable(db.sn.t1)
+AlterColumn(db.sn.t1, c2, c2, BOOL, false, <nil>)
+OpenTable(db.sn.t1)
+AlterColumn(db.sn.t1, c3, c3, INT, false, <nil>)`,
+			},
+			{
+				stmt: mustParse("alter table t1 rename c4 to c5"),
+				trace: `OpenTable(db.sn.t1)
+AlterColumn(db.sn.t1, c4, c5, TEXT, false, <nil>)`,
+			},
+			{
+				stmt:  mustParse("alter table t1 rename c4 to c6"),
+				trace: "OpenTable(db.sn.t1)",
+				fail:  true,
+			},
+			{
+				stmt: mustParse("alter table t1 drop column c5, drop column if exists c5"),
+				trace: `OpenTable(db.sn.t1)
+DropColumn(db.sn.t1, c5)
+OpenTable(db.sn.t1)`,
+			},
+			{
+				stmt:  mustParse("alter table t1 drop column c5"),
+				trace: "OpenTable(db.sn.t1)",
+				fail:  true,
+			},
+			{
+				stmt: mustParse("alter table t1 rename to t2"),
+				trace: `OpenTable(db.sn.t1)
+RenameTable(db.sn.t1, db.sn.t2)`,
+			},
+			{
+				stmt:  mustParse("alter table t1 rename to t3"),
+				trace: "OpenTable(db.sn.t1)",
+				fail:  true,
+			},
+			{
+				stmt: mustParse("alter table t2 set schema s2"),
+				trace: `OpenTable(db.sn.t2)
+RenameTable(db.sn.t2, db.s2.t2)`,
+			},
+		})
+}
+
+func TestEvaluateInsert(t *testing.T) {
+	testEvaluate(t,
+		[]evaluateCase{
+			{
+				stmt: mustParse("create table t1 (c1 int primary key, c2 int default 10, " +
+					"c3 int default nextval('db.sn.seq'), c4 text)"),
+				trace: `OpenTable(db.sn.t1)
+CreateTable(db.sn.t1, [c1 c2 c3 c4], [INT INT INT TEXT], [1])`,
+			},
+			{
+				stmt: mustParse("insert into t1 values (1, 2, 3, 'four')"),
+				trace: `OpenTable(db.sn.t1)
+Insert(db.sn.t1, [(1, 2, 3, 'four')])`,
+			},
+			{
+				stmt: mustParse("insert into t1 values (2), (3, default, default, 'three')"),
+				trace: `OpenTable(db.sn.t1)
+NextValue(db.sn.seq)
+NextValue(db.sn.seq)
+Insert(db.sn.t1, [(2, 10, 1, NULL) (3, 10, 2, 'three')])`,
+			},
+			{
+				stmt: mustParse("insert into t1 (c4, c1) values ('five', 5)"),
+				trace: `OpenTable(db.sn.t1)
+NextValue(db.sn.seq)
+Insert(db.sn.t1, [(5, 10, 3, 'five')])`,
+			},
+			{
+				stmt:  mustParse("insert into t1 (c1, c5) values (6, 6)"),
+				trace: "OpenTable(db.sn.t1)",
+				fail:  true,
+			},
+			{
+				stmt:  mustParse("insert into t1 values (7, 7, 7, 'seven', 7)"),
+				trace: "OpenTable(db.sn.t1)",
+				fail:  true,
+			},
+			{
+				stmt: mustParse("create table t2 (c1 int default nextval('seq'))"),
+				trace: `OpenTable(db.sn.t2)
+CreateTable(db.sn.t2, [c1], [INT], [])`,
+			},
+			{
+				stmt: mustParse("insert into t2 values (default)"),
+				trace: `OpenTable(db.sn.t2)
+NextValue(db.sn.seq)
+Insert(db.sn.t2, [(4)])`,
+			},
+			{
+				stmt:  mustParse("create table t3 (c1 int default c2, c2 int)"),
+				trace: "OpenTable(db.sn.t3)",
+				fail:  true,
+			},
+			{
+				stmt:  mustParse("create table t3 (c1 int default not_a_function())"),
+				trace: "OpenTable(db.sn.t3)",
+				fail:  true,
+			},
+		})
+}
@@ -0,0 +1,501 @@
+package evaluate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/leftmike/maho/parser/sql"
+	"github.com/leftmike/maho/types"
+)
+
+type expr interface {
+	String() string
+	eval(ctx context.Context, row types.Row) (types.Value, error)
+}
+
+type column struct {
+	table types.Identifier
+	name  types.Identifier
+}
+
+type literal struct {
+	val types.Value
+}
+
+type columnRef struct {
+	idx int
+	ref sql.Ref
+}
+
+type unaryExpr struct {
+	op   sql.Op
+	expr expr
+}
+
+type binaryExpr struct {
+	op    sql.Op
+	left  expr
+	right expr
+}
+
+type callExpr struct {
+	pctx *planContext
+	name types.Identifier
+	fn   *function
+	args []expr
+}
+
+type subqueryExpr struct {
+	pctx *planContext
+	op   sql.SubqueryOp
+	cmp  sql.Op
+	expr expr
+	plan plan
+	stmt sql.Stmt
+}
+
+var (
+	errDivideByZero = errors.New("evaluate: divide by zero")
+)
+
+func findColumn(cols []column, ref sql.Ref) (int, error) {
+	var tbl, nam types.Identifier
+	switch len(ref) {
+	case 1:
+		nam = ref[0]
+	case 2:
+		tbl = ref[0]
+		nam = ref[1]
+	default:
+		return 0, fmt.Errorf("evaluate: reference not supported: %s", ref)
+	}
+
+	idx := -1
+	for cdx, col := range cols {
+		if col.name == nam && (tbl == 0 || col.table == tbl) {
+			if idx >= 0 {
+				return 0, fmt.Errorf("evaluate: ambiguous reference: %s", ref)
+			}
+			idx = cdx
+		}
+	}
+	if idx < 0 {
+		return 0, fmt.Errorf("evaluate: unknown column: %s", ref)
+	}
+	return idx, nil
+}
+
+func compileExpr(ctx context.Context, pctx *planContext, cols []column, e sql.Expr) (expr,
+	error) {
+
+	switch e := e.(type) {
+	case sql.Literal:
+		return literal{e.Value}, nil
+	case *sql.Literal:
+		return literal{e.Value}, nil
+	case sql.Ref:
+		idx, err := findColumn(cols, e)
+		if err != nil {
+			return nil, err
+		}
+		return columnRef{idx: idx, ref: e}, nil
+	case *sql.UnaryExpr:
+		ce, err := compileExpr(ctx, pctx, cols, e.Expr)
+		if err != nil {
+			return nil, err
+		}
+		if e.Op == sql.NoOp {
+			return ce, nil
+		}
+		return &unaryExpr{op: e.Op, expr: ce}, nil
+	case *sql.BinaryExpr:
+		left, err := compileExpr(ctx, pctx, cols, e.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := compileExpr(ctx, pctx, cols, e.Right)
+		if err != nil {
+			return nil, err
+		}
+		return &binaryExpr{op: e.Op, left: left, right: right}, nil
+	case *sql.SExpr:
+		fn, ok := functions[e.Name]
+		if !ok {
+			return nil, fmt.Errorf("evaluate: function not found: %s", e.Name)
+		}
+		if len(e.Args) < fn.minArgs || len(e.Args) > fn.maxArgs {
+			return nil, fmt.Errorf("evaluate: %s: wrong number of arguments: %d", e.Name,
+				len(e.Args))
+		}
+
+		var args []expr
+		for _, arg := range e.Args {
+			ce, err := compileExpr(ctx, pctx, cols, arg)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, ce)
+		}
+		return &callExpr{pctx: pctx, name: e.Name, fn: fn, args: args}, nil
+	case *sql.Subquery:
+		se := &subqueryExpr{
+			pctx: pctx,
+			op:   e.Op,
+			cmp:  e.ExprOp,
+			stmt: e.Stmt,
+		}
+		if e.Expr != nil {
+			var err error
+			se.expr, err = compileExpr(ctx, pctx, cols, e.Expr)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		var err error
+		se.plan, err = planQuery(ctx, pctx, e.Stmt)
+		if err != nil {
+			return nil, err
+		}
+		if se.op != sql.Exists && len(se.plan.columns()) != 1 {
+			return nil, fmt.Errorf("evaluate: subquery must return one column: %s", e.Stmt)
+		}
+		return se, nil
+	}
+
+	return nil, fmt.Errorf("evaluate: unexpected expression: %s", e)
+}
+
+func (l literal) String() string {
+	return types.FormatValue(l.val)
+}
+
+func (l literal) eval(ctx context.Context, row types.Row) (types.Value, error) {
+	return l.val, nil
+}
+
+func (cr columnRef) String() string {
+	return cr.ref.String()
+}
+
+func (cr columnRef) eval(ctx context.Context, row types.Row) (types.Value, error) {
+	return row[cr.idx], nil
+}
+
+func (ue *unaryExpr) String() string {
+	return fmt.Sprintf("(%s %s)", ue.op, ue.expr)
+}
+
+func (ue *unaryExpr) eval(ctx context.Context, row types.Row) (types.Value, error) {
+	val, err := ue.expr.eval(ctx, row)
+	if err != nil || val == nil {
+		return nil, err
+	}
+
+	switch ue.op {
+	case sql.NegateOp:
+		switch val := val.(type) {
+		case types.Int64Value:
+			return -val, nil
+		case types.Float64Value:
+			return -val, nil
+		}
+		return nil, fmt.Errorf("evaluate: %s: expected a number: %s", ue, val)
+	case sql.NotOp:
+		if b, ok := val.(types.BoolValue); ok {
+			return !b, nil
+		}
+		return nil, fmt.Errorf("evaluate: %s: expected a boolean: %s", ue, val)
+	}
+
+	panic(fmt.Sprintf("evaluate: unexpected unary op: %d", ue.op))
+}
+
+func (be *binaryExpr) String() string {
+	return fmt.Sprintf("(%s %s %s)", be.left, be.op, be.right)
+}
+
+func evalBool(ctx context.Context, e expr, row types.Row) (types.Value, error) {
+	val, err := e.eval(ctx, row)
+	if err != nil || val == nil {
+		return nil, err
+	}
+	if _, ok := val.(types.BoolValue); !ok {
+		return nil, fmt.Errorf("evaluate: %s: expected a boolean: %s", e, val)
+	}
+	return val, nil
+}
+
+func (be *binaryExpr) evalLogical(ctx context.Context, row types.Row) (types.Value, error) {
+	left, err := evalBool(ctx, be.left, row)
+	if err != nil {
+		return nil, err
+	}
+	if be.op == sql.AndOp && left == types.BoolValue(false) {
+		return types.BoolValue(false), nil
+	} else if be.op == sql.OrOp && left == types.BoolValue(true) {
+		return types.BoolValue(true), nil
+	}
+
+	right, err := evalBool(ctx, be.right, row)
+	if err != nil {
+		return nil, err
+	}
+	if left == nil {
+		if be.op == sql.AndOp && right == types.BoolValue(false) {
+			return types.BoolValue(false), nil
+		} else if be.op == sql.OrOp && right == types.BoolValue(true) {
+			return types.BoolValue(true), nil
+		}
+		return nil, nil
+	}
+	return right, nil
+}
+
+func isNumber(val types.Value) bool {
+	switch val.(type) {
+	case types.Int64Value, types.Float64Value:
+		return true
+	}
+	return false
+}
+
+func compareValues(op sql.Op, left, right types.Value) (types.Value, error) {
+	if left == nil || right == nil {
+		return nil, nil
+	}
+
+	if !(isNumber(left) && isNumber(right)) {
+		switch left.(type) {
+		case types.BoolValue:
+			_, ok := right.(types.BoolValue)
+			if !ok {
+				return nil, fmt.Errorf("evaluate: unable to compare %s and %s", left, right)
+			}
+		case types.StringValue:
+			_, ok := right.(types.StringValue)
+			if !ok {
+				return nil, fmt.Errorf("evaluate: unable to compare %s and %s", left, right)
+			}
+		case types.BytesValue:
+			_, ok := right.(types.BytesValue)
+			if !ok {
+				return nil, fmt.Errorf("evaluate: unable to compare %s and %s", left, right)
+			}
+		default:
+			return nil, fmt.Errorf("evaluate: unable to compare %s and %s", left, right)
+		}
+	}
+
+	cmp := types.Compare(left, right)
+	switch op {
+	case sql.EqualOp:
+		return types.BoolValue(cmp == 0), nil
+	case sql.NotEqualOp:
+		return types.BoolValue(cmp != 0), nil
+	case sql.GreaterEqualOp:
+		return types.BoolValue(cmp >= 0), nil
+	case sql.GreaterThanOp:
+		return types.BoolValue(cmp > 0), nil
+	case sql.LessEqualOp:
+		return types.BoolValue(cmp <= 0), nil
+	case sql.LessThanOp:
+		return types.BoolValue(cmp < 0), nil
+	}
+
+	panic(fmt.Sprintf("evaluate: unexpected comparison op: %d", op))
+}
+
+func arithmeticInt64(op sql.Op, left, right types.Int64Value) (types.Value, error) {
+	switch op {
+	case sql.AddOp:
+		return left + right, nil
+	case sql.SubtractOp:
+		return left - right, nil
+	case sql.MultiplyOp:
+		return left * right, nil
+	case sql.DivideOp:
+		if right == 0 {
+			return nil, errDivideByZero
+		}
+		return left / right, nil
+	case sql.ModuloOp:
+		if right == 0 {
+			return nil, errDivideByZero
+		}
+		return left % right, nil
+	case sql.BinaryAndOp:
+		return left & right, nil
+	case sql.BinaryOrOp:
+		return left | right, nil
+	case sql.LShiftOp:
+		return left << right, nil
+	case sql.RShiftOp:
+		return left >> right, nil
+	}
+
+	panic(fmt.Sprintf("evaluate: unexpected arithmetic op: %d", op))
+}
+
+func arithmeticFloat64(op sql.Op, left, right types.Float64Value) (types.Value, error) {
+	switch op {
+	case sql.AddOp:
+		return left + right, nil
+	case sql.SubtractOp:
+		return left - right, nil
+	case sql.MultiplyOp:
+		return left * right, nil
+	case sql.DivideOp:
+		if right == 0 {
+			return nil, errDivideByZero
+		}
+		return left / right, nil
+	case sql.ModuloOp:
+		if right == 0 {
+			return nil, errDivideByZero
+		}
+		return types.Float64Value(math.Mod(float64(left), float64(right))), nil
+	}
+
+	return nil, fmt.Errorf("evaluate: expected integer arguments: %s %s", left, right)
+}
+
+func (be *binaryExpr) eval(ctx context.Context, row types.Row) (types.Value, error) {
+	if be.op == sql.AndOp || be.op == sql.OrOp {
+		return be.evalLogical(ctx, row)
+	}
+
+	left, err := be.left.eval(ctx, row)
+	if err != nil {
+		return nil, err
+	}
+	right, err := be.right.eval(ctx, row)
+	if err != nil {
+		return nil, err
+	}
+
+	switch be.op {
+	case sql.EqualOp, sql.NotEqualOp, sql.GreaterEqualOp, sql.GreaterThanOp, sql.LessEqualOp,
+		sql.LessThanOp:
+		return compareValues(be.op, left, right)
+	}
+
+	if left == nil || right == nil {
+		return nil, nil
+	}
+
+	if be.op == sql.ConcatOp {
+		left, err = types.CastValue(types.StringType, left)
+		if err != nil {
+			return nil, err
+		}
+		right, err = types.CastValue(types.StringType, right)
+		if err != nil {
+			return nil, err
+		}
+		return left.(types.StringValue) + right.(types.StringValue), nil
+	}
+
+	if li, ok := left.(types.Int64Value); ok {
+		if ri, ok := right.(types.Int64Value); ok {
+			return arithmeticInt64(be.op, li, ri)
+		}
+	}
+	if !isNumber(left) || !isNumber(right) {
+		return nil, fmt.Errorf("evaluate: expected numbers: %s %s", left, right)
+	}
+
+	lf, _ := types.CastValue(types.Float64Type, left)
+	rf, _ := types.CastValue(types.Float64Type, right)
+	return arithmeticFloat64(be.op, lf.(types.Float64Value), rf.(types.Float64Value))
+}
+
+func (ce *callExpr) String() string {
+	return fmt.Sprintf("%s()", ce.name)
+}
+
+func (ce *callExpr) eval(ctx context.Context, row types.Row) (types.Value, error) {
+	args := make([]types.Value, 0, len(ce.args))
+	for _, arg := range ce.args {
+		val, err := arg.eval(ctx, row)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, val)
+	}
+
+	return ce.fn.fn(ctx, ce.pctx, ce.name, args)
+}
+
+func (se *subqueryExpr) String() string {
+	return fmt.Sprintf("(%s)", se.stmt)
+}
+
+func (se *subqueryExpr) eval(ctx context.Context, row types.Row) (types.Value, error) {
+	rows, err := se.plan.rows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close(ctx)
+
+	var val types.Value
+	if se.expr != nil {
+		val, err = se.expr.eval(ctx, row)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var ret types.Value
+	switch se.op {
+	case sql.Exists:
+		_, err := rows.Next(ctx)
+		if err == io.EOF {
+			return types.BoolValue(false), nil
+		} else if err != nil {
+			return nil, err
+		}
+		return types.BoolValue(true), nil
+	case sql.Any:
+		ret = types.BoolValue(false)
+	case sql.All:
+		ret = types.BoolValue(true)
+	}
+
+	var found bool
+	for {
+		r, err := rows.Next(ctx)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch se.op {
+		case sql.Scalar:
+			if found {
+				return nil, fmt.Errorf("evaluate: subquery returned more than one row: %s",
+					se.stmt)
+			}
+			found = true
+			ret = r[0]
+		case sql.Any, sql.All:
+			b, err := compareValues(se.cmp, val, r[0])
+			if err != nil {
+				return nil, err
+			}
+			if b == nil {
+				ret = nil
+			} else if se.op == sql.Any && b == types.BoolValue(true) {
+				return b, nil
+			} else if se.op == sql.All && b == types.BoolValue(false) {
+				return b, nil
+			}
+		}
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,452 @@
+package evaluate
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/leftmike/maho/engine"
+	"github.com/leftmike/maho/parser/sql"
+	"github.com/leftmike/maho/storage"
+	"github.com/leftmike/maho/types"
+)
+
+// foreignKeyCheck checks that, if table tn has rows which reference key using foreign key fk,
+// the referenced row exists. Checks are done at the end of each statement, or when the
+// transaction commits if the foreign key is deferred.
+type foreignKeyCheck struct {
+	tn  types.TableName
+	fk  types.Identifier
+	key []types.Value
+}
+
+func findForeignKey(tt *engine.TableType, cn types.Identifier) (engine.ForeignKey, bool) {
+	for _, fk := range tt.ForeignKeys {
+		if fk.Name == cn {
+			return fk, true
+		}
+	}
+	return engine.ForeignKey{}, false
+}
+
+func keyColumns(key []types.ColumnKey) []types.ColumnNum {
+	cols := make([]types.ColumnNum, 0, len(key))
+	for _, ck := range key {
+		cols = append(cols, ck.Column())
+	}
+	return cols
+}
+
+func primaryKeyColumns(tt *engine.TableType) []types.ColumnNum {
+	return keyColumns(tt.Key)
+}
+
+// keyValues returns the values of the columns cols of row, or nil if any of them are NULL.
+func keyValues(cols []types.ColumnNum, row types.Row) []types.Value {
+	key := make([]types.Value, 0, len(cols))
+	for _, col := range cols {
+		if row[col] == nil {
+			return nil
+		}
+		key = append(key, row[col])
+	}
+	return key
+}
+
+func keyRow(cnt int, cols []types.ColumnNum, key []types.Value) types.Row {
+	row := make(types.Row, cnt)
+	for cdx, col := range cols {
+		row[col] = key[cdx]
+	}
+	return row
+}
+
+func referencedRowExists(ctx context.Context, tx engine.Transaction, fk engine.ForeignKey,
+	key []types.Value) (bool, error) {
+
+	rtbl, err := tx.OpenTable(ctx, fk.RefTable)
+	if err != nil {
+		return false, err
+	}
+	rtt := rtbl.Type()
+
+	row := keyRow(len(rtt.ColumnNames), primaryKeyColumns(rtt), key)
+	rows, err := rtbl.Rows(ctx, nil, row, row, nil)
+	if err != nil {
+		return false, err
+	}
+
+	var found bool
+	err = eachRow(ctx, rows, nil,
+		func(ref storage.RowRef, row types.Row) error {
+			found = true
+			return nil
+		})
+	return found, err
+}
+
+// referencingRows calls fn with each row of tbl which references key using foreign key fk.
+func referencingRows(ctx context.Context, tbl engine.Table, fk engine.ForeignKey,
+	key []types.Value, fn func(ref storage.RowRef, row types.Row) error) error {
+
+	tt := tbl.Type()
+	in, ok := tt.ForeignKeyIndex(fk)
+	if !ok {
+		panic(fmt.Sprintf("evaluate: %s: missing index for foreign key %s", tbl.Name(),
+			fk.Name))
+	}
+
+	row := keyRow(len(tt.ColumnNames), fk.Columns, key)
+	rows, err := tbl.IndexRows(ctx, in, nil, row, row)
+	if err != nil {
+		return err
+	}
+	return eachRow(ctx, rows, nil, fn)
+}
+
+func (chk foreignKeyCheck) check(ctx context.Context, tx engine.Transaction) error {
+	tbl, err := tx.OpenTable(ctx, chk.tn)
+	if err != nil {
+		return err
+	}
+	fk, ok := findForeignKey(tbl.Type(), chk.fk)
+	if !ok {
+		return nil // The foreign key has been dropped.
+	}
+
+	var found bool
+	err = referencingRows(ctx, tbl, fk, chk.key,
+		func(ref storage.RowRef, row types.Row) error {
+			found = true
+			return nil
+		})
+	if err != nil || !found {
+		return err
+	}
+
+	found, err = referencedRowExists(ctx, tx, fk, chk.key)
+	if err != nil {
+		return err
+	} else if !found {
+		return fmt.Errorf("evaluate: %s: foreign key %s violated: %s not found in %s", chk.tn,
+			fk.Name, types.Row(chk.key), fk.RefTable)
+	}
+	return nil
+}
+
+func checkForeignKeys(ctx context.Context, tx engine.Transaction,
+	checks []foreignKeyCheck) error {
+
+	for _, chk := range checks {
+		err := chk.check(ctx, tx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pctx *planContext) addCheck(tn types.TableName, fk engine.ForeignKey,
+	key []types.Value) {
+
+	chk := foreignKeyCheck{
+		tn:  tn,
+		fk:  fk.Name,
+		key: key,
+	}
+	if pctx.ses != nil && pctx.ses.tx != nil && pctx.ses.constraints.isDeferred(fk) {
+		pctx.ses.deferred = append(pctx.ses.deferred, chk)
+	} else {
+		pctx.checks = append(pctx.checks, chk)
+	}
+}
+
+// checkForeignKeys does the checks for the statement which were not deferred.
+func (pctx *planContext) checkForeignKeys(ctx context.Context) error {
+	checks := pctx.checks
+	pctx.checks = nil
+	return checkForeignKeys(ctx, pctx.tx, checks)
+}
+
+// insertedRow adds checks for the foreign keys of tbl which reference other rows.
+func insertedRow(pctx *planContext, tbl engine.Table, row types.Row) {
+	for _, fk := range tbl.Type().ForeignKeys {
+		if key := keyValues(fk.Columns, row); key != nil {
+			pctx.addCheck(tbl.Name(), fk, key)
+		}
+	}
+}
+
+func deleteRow(ctx context.Context, pctx *planContext, tbl engine.Table, ref storage.RowRef,
+	row types.Row) error {
+
+	err := ref.Delete(ctx)
+	if err != nil {
+		return err
+	}
+	return referencedRowChanged(ctx, pctx, tbl, row, nil)
+}
+
+// updateRow changes row to nrow, where only the columns nums have changed.
+func updateRow(ctx context.Context, pctx *planContext, tbl engine.Table, rc *rowChecker,
+	ref storage.RowRef, row, nrow types.Row, nums []types.ColumnNum) error {
+
+	err := rc.checkRow(ctx, nrow)
+	if err != nil {
+		return err
+	}
+
+	vals := make([]types.Value, len(nums))
+	for ndx, num := range nums {
+		vals[ndx] = nrow[num]
+	}
+	err = ref.Update(ctx, nums, vals)
+	if err != nil {
+		return err
+	}
+
+	tt := tbl.Type()
+	for _, fk := range tt.ForeignKeys {
+		if !slices.ContainsFunc(fk.Columns,
+			func(col types.ColumnNum) bool {
+				return slices.Contains(nums, col)
+			}) {
+
+			continue
+		}
+		if key := keyValues(fk.Columns, nrow); key != nil {
+			pctx.addCheck(tbl.Name(), fk, key)
+		}
+	}
+
+	if types.ColumnKeyUpdated(tt.Key, nums) {
+		return referencedRowChanged(ctx, pctx, tbl, row, nrow)
+	}
+	return nil
+}
+
+// currentRow calls fn with the current version of row, if it still exists. Referential actions
+// can change or delete rows of the table being modified.
+func currentRow(ctx context.Context, tbl engine.Table, row types.Row,
+	fn func(ref storage.RowRef, row types.Row) error) error {
+
+	rows, err := tbl.Rows(ctx, nil, row, row, nil)
+	if err != nil {
+		return err
+	}
+	return eachRow(ctx, rows, nil, fn)
+}
+
+// referencedRowChanged carries out the referential actions of the foreign keys which reference
+// tbl when row is changed to nrow; nrow is nil if row was deleted.
+func referencedRowChanged(ctx context.Context, pctx *planContext, tbl engine.Table,
+	row, nrow types.Row) error {
+
+	tt := tbl.Type()
+	if len(tt.ForeignRefs) == 0 {
+		return nil
+	}
+
+	pkCols := primaryKeyColumns(tt)
+	key := keyValues(pkCols, row)
+	var nkey []types.Value
+	if nrow != nil {
+		nkey = keyValues(pkCols, nrow)
+		if slices.EqualFunc(key, nkey,
+			func(v1, v2 types.Value) bool {
+				return types.Compare(v1, v2) == 0
+			}) {
+
+			return nil
+		}
+	}
+
+	for _, fr := range tt.ForeignRefs {
+		ctbl, err := pctx.tx.OpenTable(ctx, fr.Table)
+		if err != nil {
+			return err
+		}
+		ctt := ctbl.Type()
+		fk, ok := findForeignKey(ctt, fr.Name)
+		if !ok {
+			panic(fmt.Sprintf("evaluate: %s: foreign key not found: %s", fr.Table, fr.Name))
+		}
+
+		action := fk.OnDelete
+		if nrow != nil {
+			action = fk.OnUpdate
+		}
+
+		var vals []types.Value
+		switch action {
+		case sql.NoAction:
+			pctx.addCheck(fr.Table, fk, key)
+			continue
+		case sql.Restrict:
+			var found bool
+			err = referencingRows(ctx, ctbl, fk, key,
+				func(ref storage.RowRef, row types.Row) error {
+					found = true
+					return nil
+				})
+			if err != nil {
+				return err
+			} else if found {
+				return fmt.Errorf("evaluate: %s: foreign key %s violated: %s referenced by %s",
+					tbl.Name(), fk.Name, types.Row(key), fr.Table)
+			}
+			continue
+		case sql.Cascade:
+			if nrow == nil {
+				err = referencingRows(ctx, ctbl, fk, key,
+					func(ref storage.RowRef, row types.Row) error {
+						return deleteRow(ctx, pctx, ctbl, ref, row)
+					})
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			vals = nkey
+		case sql.SetNull:
+			vals = make([]types.Value, len(fk.Columns))
+		case sql.SetDefault:
+			for _, col := range fk.Columns {
+				e, err := compileDefault(ctx, pctx, ctt, col)
+				if err != nil {
+					return err
+				}
+				val, err := e.eval(ctx, nil)
+				if err != nil {
+					return err
+				}
+				vals = append(vals, val)
+			}
+		default:
+			panic(fmt.Sprintf("evaluate: unexpected referential action: %d", action))
+		}
+
+		rc, err := newRowChecker(ctx, pctx, fr.Table, ctt)
+		if err != nil {
+			return err
+		}
+		err = referencingRows(ctx, ctbl, fk, key,
+			func(ref storage.RowRef, row types.Row) error {
+				crow := append(make(types.Row, 0, len(row)), row...)
+				for cdx, col := range fk.Columns {
+					crow[col] = vals[cdx]
+				}
+				return updateRow(ctx, pctx, ctbl, rc, ref, row, crow, fk.Columns)
+			})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func constraintNames(tt *engine.TableType) []types.Identifier {
+	var names []types.Identifier
+	for _, con := range tt.Constraints {
+		names = append(names, con.Name)
+	}
+	for _, fk := range tt.ForeignKeys {
+		names = append(names, fk.Name)
+	}
+	return names
+}
+
+// makeForeignKey converts a foreign key of table tn to the form used by the engine: the
+// referenced columns must be the primary key of the referenced table.
+// XXX: referencing the columns of a unique constraint is not supported
+func makeForeignKey(ctx context.Context, tx engine.Transaction, tn types.TableName,
+	tt *engine.TableType, sfk *sql.ForeignKey) (engine.ForeignKey, error) {
+
+	rtt := tt
+	if sfk.RefTable != tn {
+		rtbl, err := tx.OpenTable(ctx, sfk.RefTable)
+		if err != nil {
+			return engine.ForeignKey{}, err
+		}
+		rtt = rtbl.Type()
+	}
+
+	if len(rtt.Key) == 0 {
+		return engine.ForeignKey{}, fmt.Errorf(
+			"evaluate: %s: foreign key: %s does not have a primary key", tn, sfk.RefTable)
+	} else if len(sfk.FKCols) != len(rtt.Key) ||
+		(sfk.RefCols != nil && len(sfk.RefCols) != len(rtt.Key)) {
+
+		return engine.ForeignKey{}, fmt.Errorf(
+			"evaluate: %s: foreign key: columns must match primary key of %s; "+
+				"only primary keys may be referenced", tn, sfk.RefTable)
+	}
+
+	cols := make([]types.ColumnNum, len(rtt.Key))
+	for kdx, ck := range rtt.Key {
+		fdx := kdx
+		if sfk.RefCols != nil {
+			fdx = slices.Index(sfk.RefCols, rtt.ColumnNames[ck.Column()])
+			if fdx < 0 {
+				return engine.ForeignKey{}, fmt.Errorf(
+					"evaluate: %s: foreign key: columns must match primary key of %s; "+
+						"only primary keys may be referenced", tn, sfk.RefTable)
+			}
+		}
+
+		num, ok := columnNumber(sfk.FKCols[fdx], tt.ColumnNames)
+		if !ok {
+			return engine.ForeignKey{}, fmt.Errorf("evaluate: %s: foreign key: unknown column: %s",
+				tn, sfk.FKCols[fdx])
+		}
+		cols[kdx] = num
+	}
+
+	cn := sfk.Name
+	if cn == 0 {
+		cn = makeConstraintName(constraintNames(tt),
+			fmt.Sprintf("%s_%s_fkey", tn.Table, sfk.FKCols[0]))
+	}
+
+	return engine.ForeignKey{
+		Name:     cn,
+		Columns:  cols,
+		RefTable: sfk.RefTable,
+		OnDelete: sfk.OnDelete,
+		OnUpdate: sfk.OnUpdate,
+		Deferred: sfk.Deferred,
+	}, nil
+}
+
+func evaluateAddForeignKey(ctx context.Context, tx engine.Transaction, tbl engine.Table,
+	sfk *sql.ForeignKey) error {
+
+	tn := tbl.Name()
+	fk, err := makeForeignKey(ctx, tx, tn, tbl.Type(), sfk)
+	if err != nil {
+		return err
+	}
+
+	err = modifyRows(ctx, tbl, nil,
+		func(ref storage.RowRef, row types.Row) error {
+			key := keyValues(fk.Columns, row)
+			if key == nil {
+				return nil
+			}
+
+			found, err := referencedRowExists(ctx, tx, fk, key)
+			if err != nil {
+				return err
+			} else if !found {
+				return fmt.Errorf("evaluate: %s: foreign key %s violated: %s not found in %s",
+					tn, fk.Name, types.Row(key), fk.RefTable)
+			}
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+
+	return tx.AddForeignKey(ctx, tn, fk)
+}
@@ -0,0 +1,239 @@
+package evaluate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/leftmike/maho/parser/sql"
+	"github.com/leftmike/maho/types"
+)
+
+type function struct {
+	minArgs int
+	maxArgs int
+	fn      func(ctx context.Context, pctx *planContext, name types.Identifier,
+		args []types.Value) (types.Value, error)
+}
+
+var (
+	functions = map[types.Identifier]*function{
+		types.ID("abs", false):      {1, 1, absFunc},
+		types.ID("coalesce", false): {1, 64, coalesceFunc},
+		types.ID("currval", false):  {1, 1, currvalFunc},
+		types.ID("is_null", false):  {1, 1, isNullFunc},
+		types.ID("nextval", false):  {1, 1, nextvalFunc},
+		types.ID("now", false):      {0, 0, nowFunc},
+		types.ID("setval", false):   {2, 3, setvalFunc},
+	}
+
+	sequenceFunctions = map[types.Identifier]bool{
+		types.ID("currval", false): true,
+		types.ID("nextval", false): true,
+		types.ID("setval", false):  true,
+	}
+
+	// volatileFunctions may return a different value each time they are called.
+	volatileFunctions = map[types.Identifier]bool{
+		types.ID("nextval", false): true,
+		types.ID("setval", false):  true,
+	}
+)
+
+func absFunc(ctx context.Context, pctx *planContext, name types.Identifier,
+	args []types.Value) (types.Value, error) {
+
+	switch arg := args[0].(type) {
+	case nil:
+		return nil, nil
+	case types.Int64Value:
+		if arg < 0 {
+			return -arg, nil
+		}
+		return arg, nil
+	case types.Float64Value:
+		if arg < 0 {
+			return -arg, nil
+		}
+		return arg, nil
+	}
+
+	return nil, fmt.Errorf("evaluate: %s: expected a number: %s", name, args[0])
+}
+
+func coalesceFunc(ctx context.Context, pctx *planContext, name types.Identifier,
+	args []types.Value) (types.Value, error) {
+
+	for _, arg := range args {
+		if arg != nil {
+			return arg, nil
+		}
+	}
+	return nil, nil
+}
+
+func isNullFunc(ctx context.Context, pctx *planContext, name types.Identifier,
+	args []types.Value) (types.Value, error) {
+
+	return types.BoolValue(args[0] == nil), nil
+}
+
+func parseSequenceName(s string) (types.TableName, bool) {
+	parts := strings.Split(s, ".")
+	for _, part := range parts {
+		if part == "" {
+			return types.TableName{}, false
+		}
+	}
+
+	switch len(parts) {
+	case 1:
+		return types.TableName{Table: types.ID(parts[0], false)}, true
+	case 2:
+		return types.TableName{
+			Schema: types.ID(parts[0], false),
+			Table:  types.ID(parts[1], false),
+		}, true
+	case 3:
+		return types.TableName{
+			Database: types.ID(parts[0], false),
+			Schema:   types.ID(parts[1], false),
+			Table:    types.ID(parts[2], false),
+		}, true
+	}
+	return types.TableName{}, false
+}
+
+func sequenceArg(pctx *planContext, name types.Identifier, arg types.Value) (types.TableName,
+	error) {
+
+	s, ok := arg.(types.StringValue)
+	if !ok {
+		return types.TableName{}, fmt.Errorf("evaluate: %s: expected a string: %s", name, arg)
+	}
+	sqn, ok := parseSequenceName(string(s))
+	if !ok {
+		return types.TableName{}, fmt.Errorf("evaluate: %s: bad sequence name: %s", name, s)
+	}
+	if pctx.ses != nil {
+		sqn = pctx.ses.ResolveTable(sqn)
+	} else if sqn.Database == 0 || sqn.Schema == 0 {
+		return types.TableName{}, fmt.Errorf("evaluate: %s: sequence name must be qualified: %s",
+			name, s)
+	}
+	return sqn, nil
+}
+
+// qualifySequences qualifies the names of the sequences passed to nextval, currval, and setval in
+// the default of a column of tn, so that the default uses the same sequences no matter which
+// session evaluates it. As with SERIAL, a name which is not qualified is in the schema of tn.
+func qualifySequences(tn types.TableName, dflt sql.Expr) {
+	sql.WalkExpr(dflt,
+		func(e sql.Expr) bool {
+			se, ok := e.(*sql.SExpr)
+			if !ok || len(se.Args) == 0 || !sequenceFunctions[se.Name] {
+				return true
+			}
+			l, ok := se.Args[0].(sql.Literal)
+			if !ok {
+				return true
+			}
+			s, ok := l.Value.(types.StringValue)
+			if !ok {
+				return true
+			}
+			sqn, ok := parseSequenceName(string(s))
+			if !ok {
+				return true
+			}
+
+			if sqn.Database == 0 {
+				sqn.Database = tn.Database
+				if sqn.Schema == 0 {
+					sqn.Schema = tn.Schema
+				}
+			}
+			se.Args[0] = sql.Literal{Value: types.StringValue(sqn.String())}
+			return true
+		})
+}
+
+func isVolatile(e sql.Expr) bool {
+	return !sql.WalkExpr(e,
+		func(e sql.Expr) bool {
+			se, ok := e.(*sql.SExpr)
+			return !ok || !volatileFunctions[se.Name]
+		})
+}
+
+func nextvalFunc(ctx context.Context, pctx *planContext, name types.Identifier,
+	args []types.Value) (types.Value, error) {
+
+	sqn, err := sequenceArg(pctx, name, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	val, err := pctx.tx.NextValue(ctx, sqn)
+	if err != nil {
+		return nil, err
+	}
+	if pctx.ses != nil {
+		pctx.ses.setCurrentValue(sqn, val)
+	}
+	return types.Int64Value(val), nil
+}
+
+func currvalFunc(ctx context.Context, pctx *planContext, name types.Identifier,
+	args []types.Value) (types.Value, error) {
+
+	sqn, err := sequenceArg(pctx, name, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if pctx.ses != nil {
+		if val, ok := pctx.ses.currentValues[sqn]; ok {
+			return types.Int64Value(val), nil
+		}
+	}
+	return nil, fmt.Errorf("evaluate: %s: %s: nextval not yet called in this session", name, sqn)
+}
+
+func setvalFunc(ctx context.Context, pctx *planContext, name types.Identifier,
+	args []types.Value) (types.Value, error) {
+
+	sqn, err := sequenceArg(pctx, name, args[0])
+	if err != nil {
+		return nil, err
+	}
+	val, ok := args[1].(types.Int64Value)
+	if !ok {
+		return nil, fmt.Errorf("evaluate: %s: expected an integer: %s", name, args[1])
+	}
+	called := true
+	if len(args) == 3 {
+		b, ok := args[2].(types.BoolValue)
+		if !ok {
+			return nil, fmt.Errorf("evaluate: %s: expected a boolean: %s", name, args[2])
+		}
+		called = bool(b)
+	}
+
+	err = pctx.tx.SetValue(ctx, sqn, int64(val), called)
+	if err != nil {
+		return nil, err
+	}
+	if called && pctx.ses != nil {
+		pctx.ses.setCurrentValue(sqn, int64(val))
+	}
+	return val, nil
+}
+
+func nowFunc(ctx context.Context, pctx *planContext, name types.Identifier,
+	args []types.Value) (types.Value, error) {
+
+	// XXX: there is no timestamp type yet, so return a string.
+	return types.StringValue(time.Now().UTC().Format("2006-01-02 15:04:05.999999Z07:00")), nil
+}
@@ -0,0 +1,154 @@
+package evaluate
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/leftmike/maho/parser/sql"
+	"github.com/leftmike/maho/types"
+)
+
+// joinPlan is a nested loop join; the rows of the right side are read once, before any rows
+// of the left side.
+type joinPlan struct {
+	left  plan
+	right plan
+	typ   sql.JoinType
+	cond  expr
+	cols  []column
+}
+
+type joinRows struct {
+	left     rows
+	rrows    []types.Row
+	typ      sql.JoinType
+	cond     expr
+	lcnt     int
+	rcnt     int
+	lrow     types.Row
+	rdx      int
+	matched  bool
+	rmatched []bool
+	done     bool
+}
+
+func planJoin(ctx context.Context, pctx *planContext, fj sql.FromJoin) (plan, error) {
+	if fj.Using != nil {
+		// XXX: USING also merges the columns with the same name
+		return nil, fmt.Errorf("evaluate: join using not implemented: %s", fj)
+	}
+
+	left, err := planFrom(ctx, pctx, fj.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := planFrom(ctx, pctx, fj.Right)
+	if err != nil {
+		return nil, err
+	}
+
+	jp := &joinPlan{
+		left:  left,
+		right: right,
+		typ:   fj.Type,
+		cols:  append(append([]column(nil), left.columns()...), right.columns()...),
+	}
+	if fj.On != nil {
+		jp.cond, err = compileExpr(ctx, pctx, jp.cols, fj.On)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return jp, nil
+}
+
+func (jp *joinPlan) columns() []column {
+	return jp.cols
+}
+
+func (jp *joinPlan) rows(ctx context.Context) (rows, error) {
+	r, err := jp.right.rows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rrows, err := allRows(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := jp.left.rows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &joinRows{
+		left:     l,
+		rrows:    rrows,
+		typ:      jp.typ,
+		cond:     jp.cond,
+		lcnt:     len(jp.left.columns()),
+		rcnt:     len(jp.right.columns()),
+		rmatched: make([]bool, len(rrows)),
+	}, nil
+}
+
+func (jr *joinRows) Next(ctx context.Context) (types.Row, error) {
+	for !jr.done {
+		if jr.lrow == nil {
+			lrow, err := jr.left.Next(ctx)
+			if err == io.EOF {
+				jr.done = true
+				jr.rdx = 0
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			jr.lrow = lrow
+			jr.rdx = 0
+			jr.matched = false
+		}
+
+		for jr.rdx < len(jr.rrows) {
+			rdx := jr.rdx
+			jr.rdx += 1
+
+			row := append(append(make(types.Row, 0, jr.lcnt+jr.rcnt), jr.lrow...),
+				jr.rrows[rdx]...)
+			if jr.cond != nil {
+				val, err := evalBool(ctx, jr.cond, row)
+				if err != nil {
+					return nil, err
+				} else if val != types.BoolValue(true) {
+					continue
+				}
+			}
+			jr.matched = true
+			jr.rmatched[rdx] = true
+			return row, nil
+		}
+
+		lrow := jr.lrow
+		jr.lrow = nil
+		if !jr.matched && (jr.typ == sql.LeftJoin || jr.typ == sql.FullJoin) {
+			return append(append(make(types.Row, 0, jr.lcnt+jr.rcnt), lrow...),
+				make(types.Row, jr.rcnt)...), nil
+		}
+	}
+
+	if jr.typ == sql.RightJoin || jr.typ == sql.FullJoin {
+		for jr.rdx < len(jr.rrows) {
+			rdx := jr.rdx
+			jr.rdx += 1
+
+			if !jr.rmatched[rdx] {
+				return append(make(types.Row, jr.lcnt, jr.lcnt+jr.rcnt), jr.rrows[rdx]...),
+					nil
+			}
+		}
+	}
+	return nil, io.EOF
+}
+
+func (jr *joinRows) Close(ctx context.Context) error {
+	return jr.left.Close(ctx)
+}
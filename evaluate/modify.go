@@ -0,0 +1,672 @@
+package evaluate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/leftmike/maho/engine"
+	"github.com/leftmike/maho/parser/sql"
+	"github.com/leftmike/maho/storage"
+	"github.com/leftmike/maho/types"
+)
+
+func compileDefault(ctx context.Context, pctx *planContext, tt *engine.TableType,
+	num types.ColumnNum) (expr, error) {
+
+	if int(num) >= len(tt.ColumnDefaults) || tt.ColumnDefaults[num] == nil {
+		return literal{nil}, nil
+	}
+	return compileExpr(ctx, pctx, nil, tt.ColumnDefaults[num])
+}
+
+func generatedAlways(tt *engine.TableType, num types.ColumnNum) bool {
+	for _, ic := range tt.Identities {
+		if ic.Column == num {
+			return ic.Always
+		}
+	}
+	return false
+}
+
+func tableColumns(tn types.TableName, tt *engine.TableType) []column {
+	cols := make([]column, 0, len(tt.ColumnNames))
+	for _, col := range tt.ColumnNames {
+		cols = append(cols, column{table: tn.Table, name: col})
+	}
+	return cols
+}
+
+// eachRow calls fn with each row of rows for which cond is true, along with a reference to the
+// row which can be used to update or delete it; rows is closed.
+func eachRow(ctx context.Context, rows storage.Rows, cond expr,
+	fn func(ref storage.RowRef, row types.Row) error) error {
+
+	for {
+		row, err := rows.Next(ctx)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			rows.Close(ctx)
+			return err
+		}
+
+		if cond != nil {
+			val, err := evalBool(ctx, cond, row)
+			if err != nil {
+				rows.Close(ctx)
+				return err
+			}
+			if val != types.BoolValue(true) {
+				continue
+			}
+		}
+
+		ref, err := rows.Current()
+		if err == nil {
+			err = fn(ref, row)
+		}
+		if err != nil {
+			rows.Close(ctx)
+			return err
+		}
+	}
+
+	return rows.Close(ctx)
+}
+
+// modifyRows calls fn with each row of tbl for which cond is true, along with a reference to
+// the row which can be used to update or delete it. If other tables reference tbl, rows may be
+// changed or deleted by referential actions, so fn is called with the current version of each
+// row.
+func modifyRows(ctx context.Context, tbl engine.Table, cond expr,
+	fn func(ref storage.RowRef, row types.Row) error) error {
+
+	rows, err := tbl.Rows(ctx, nil, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	if len(tbl.Type().ForeignRefs) == 0 {
+		return eachRow(ctx, rows, cond, fn)
+	}
+	return eachRow(ctx, rows, cond,
+		func(ref storage.RowRef, row types.Row) error {
+			return currentRow(ctx, tbl, row, fn)
+		})
+}
+
+func evaluateInsert(ctx context.Context, pctx *planContext, stmt *sql.InsertValues) (Rows, error) {
+	tbl, err := pctx.tx.OpenTable(ctx, stmt.Table)
+	if err != nil {
+		return nil, err
+	}
+	tt := tbl.Type()
+
+	var nums []types.ColumnNum
+	if stmt.Columns == nil {
+		for num := range tt.ColumnNames {
+			nums = append(nums, types.ColumnNum(num))
+		}
+	} else {
+		for _, col := range stmt.Columns {
+			num, ok := columnNumber(col, tt.ColumnNames)
+			if !ok {
+				return nil, fmt.Errorf("evaluate: insert: %s: unknown column: %s", stmt.Table, col)
+			}
+			nums = append(nums, num)
+		}
+	}
+
+	rc, err := newRowChecker(ctx, pctx, stmt.Table, tt)
+	if err != nil {
+		return nil, err
+	}
+	rt, err := newReturning(ctx, pctx, tableColumns(stmt.Table, tt), stmt.Returning)
+	if err != nil {
+		return nil, err
+	}
+
+	dflts := make([]expr, len(tt.ColumnNames))
+	for num := range dflts {
+		dflts[num], err = compileDefault(ctx, pctx, tt, types.ColumnNum(num))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var rows []types.Row
+	if stmt.Query != nil {
+		rows, err = queryInsertRows(ctx, pctx, stmt, tt, nums, dflts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, r := range stmt.Rows {
+		if len(r) > len(nums) {
+			return nil, fmt.Errorf("evaluate: insert: %s: too many values: %d", stmt.Table, len(r))
+		} else if stmt.Columns != nil && len(r) < len(nums) {
+			return nil, fmt.Errorf("evaluate: insert: %s: too few values: %d", stmt.Table, len(r))
+		}
+
+		vals := make([]expr, len(dflts))
+		copy(vals, dflts)
+		for edx, e := range r {
+			if e == nil {
+				continue // DEFAULT
+			} else if generatedAlways(tt, nums[edx]) {
+				// XXX: OVERRIDING SYSTEM VALUE
+				return nil, fmt.Errorf("evaluate: insert: %s: column is generated always: %s",
+					stmt.Table, tt.ColumnNames[nums[edx]])
+			}
+
+			vals[nums[edx]], err = compileExpr(ctx, pctx, nil, e)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		row := make(types.Row, len(vals))
+		for vdx, e := range vals {
+			row[vdx], err = e.eval(ctx, nil)
+			if err != nil {
+				return nil, err
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	for _, row := range rows {
+		err = rc.checkRow(ctx, row)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if stmt.OnConflict != nil {
+		err = insertOnConflict(ctx, pctx, tbl, rc, rt, stmt, rows)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		err = tbl.Insert(ctx, rows)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			insertedRow(pctx, tbl, row)
+			err = rt.addRow(ctx, row)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	err = pctx.checkForeignKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return rt.resultRows(), nil
+}
+
+// queryInsertRows returns the rows to insert for INSERT ... SELECT; the rows returned by the
+// query are read before any are inserted.
+func queryInsertRows(ctx context.Context, pctx *planContext, stmt *sql.InsertValues,
+	tt *engine.TableType, nums []types.ColumnNum, dflts []expr) ([]types.Row, error) {
+
+	p, err := planQuery(ctx, pctx, stmt.Query)
+	if err != nil {
+		return nil, err
+	}
+	cnt := len(p.columns())
+	if cnt > len(nums) {
+		return nil, fmt.Errorf("evaluate: insert: %s: too many values: %d", stmt.Table, cnt)
+	} else if stmt.Columns != nil && cnt < len(nums) {
+		return nil, fmt.Errorf("evaluate: insert: %s: too few values: %d", stmt.Table, cnt)
+	}
+	for _, num := range nums[:cnt] {
+		if generatedAlways(tt, num) {
+			return nil, fmt.Errorf("evaluate: insert: %s: column is generated always: %s",
+				stmt.Table, tt.ColumnNames[num])
+		}
+	}
+
+	r, err := p.rows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	qrows, err := allRows(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]types.Row, 0, len(qrows))
+	for _, qrow := range qrows {
+		row := make(types.Row, len(dflts))
+		for num, e := range dflts {
+			if slices.Contains(nums[:cnt], types.ColumnNum(num)) {
+				continue
+			}
+			row[num], err = e.eval(ctx, nil)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for qdx, val := range qrow {
+			row[nums[qdx]] = val
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// conflictIndexes returns the indexes, where -1 is the primary key, to check for conflicting
+// rows for ON CONFLICT.
+func conflictIndexes(tn types.TableName, tt *engine.TableType, oc *sql.OnConflict) ([]int,
+	error) {
+
+	if oc.Columns == nil {
+		if !oc.DoNothing {
+			return nil, fmt.Errorf("evaluate: insert: %s: on conflict do update requires columns",
+				tn)
+		}
+
+		var idxs []int
+		if len(tt.Key) > 0 {
+			idxs = append(idxs, -1)
+		}
+		for idx, it := range tt.Indexes {
+			if it.Unique {
+				idxs = append(idxs, idx)
+			}
+		}
+		return idxs, nil
+	}
+
+	var cols []types.ColumnNum
+	for _, col := range oc.Columns {
+		num, ok := columnNumber(col, tt.ColumnNames)
+		if !ok {
+			return nil, fmt.Errorf("evaluate: insert: %s: unknown column: %s", tn, col)
+		}
+		cols = append(cols, num)
+	}
+	sameColumns := func(key []types.ColumnKey) bool {
+		if len(key) != len(cols) {
+			return false
+		}
+		for _, ck := range key {
+			if !slices.Contains(cols, ck.Column()) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if len(tt.Key) > 0 && sameColumns(tt.Key) {
+		return []int{-1}, nil
+	}
+	for idx, it := range tt.Indexes {
+		if it.Unique && sameColumns(it.Key) {
+			return []int{idx}, nil
+		}
+	}
+	return nil, fmt.Errorf("evaluate: insert: %s: no primary key or unique index on columns: %v",
+		tn, oc.Columns)
+}
+
+func insertOnConflict(ctx context.Context, pctx *planContext, tbl engine.Table, rc *rowChecker,
+	rt *returning, stmt *sql.InsertValues, rows []types.Row) error {
+
+	tt := tbl.Type()
+	oc := stmt.OnConflict
+	idxs, err := conflictIndexes(stmt.Table, tt, oc)
+	if err != nil {
+		return err
+	}
+
+	// DO UPDATE is evaluated on the conflicting row followed by the row proposed for insertion,
+	// which is referenced as excluded.
+	var cond expr
+	var nums []types.ColumnNum
+	var exprs []expr
+	if !oc.DoNothing {
+		cols := append(tableColumns(stmt.Table, tt),
+			tableColumns(types.TableName{Table: types.ID("excluded", false)}, tt)...)
+		if oc.Where != nil {
+			cond, err = compileExpr(ctx, pctx, cols, oc.Where)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, cu := range oc.ColumnUpdates {
+			num, ok := columnNumber(cu.Column, tt.ColumnNames)
+			if !ok {
+				return fmt.Errorf("evaluate: insert: %s: unknown column: %s", stmt.Table,
+					cu.Column)
+			} else if slices.Contains(nums, num) {
+				return fmt.Errorf("evaluate: insert: %s: column updated more than once: %s",
+					stmt.Table, cu.Column)
+			}
+
+			var e expr
+			if cu.Expr == nil {
+				e, err = compileDefault(ctx, pctx, tt, num)
+			} else if generatedAlways(tt, num) {
+				return fmt.Errorf("evaluate: insert: %s: column is generated always: %s",
+					stmt.Table, cu.Column)
+			} else {
+				e, err = compileExpr(ctx, pctx, cols, cu.Expr)
+			}
+			if err != nil {
+				return err
+			}
+			nums = append(nums, num)
+			exprs = append(exprs, e)
+		}
+	}
+
+	// DO UPDATE may not affect a row inserted or updated by this statement; the rows are
+	// identified by their key in the conflict index.
+	var cols []types.ColumnNum
+	if !oc.DoNothing {
+		if idxs[0] < 0 {
+			cols = keyColumns(tt.Key)
+		} else {
+			cols = keyColumns(tt.Indexes[idxs[0]].Key)
+		}
+	}
+	var affected [][]types.Value
+	affect := func(row types.Row) {
+		if cols != nil {
+			if key := keyValues(cols, row); key != nil {
+				affected = append(affected, key)
+			}
+		}
+	}
+	sameKey := func(key []types.Value) bool {
+		return slices.ContainsFunc(affected,
+			func(akey []types.Value) bool {
+				return slices.EqualFunc(key, akey,
+					func(val1, val2 types.Value) bool {
+						return types.Compare(val1, val2) == 0
+					})
+			})
+	}
+
+	for _, row := range rows {
+		var ref storage.RowRef
+		var crow types.Row
+		for _, idx := range idxs {
+			ref, crow, err = conflictingRow(ctx, tbl, idx, row)
+			if err != nil {
+				return err
+			} else if ref != nil {
+				break
+			}
+		}
+
+		if ref == nil {
+			err = tbl.Insert(ctx, []types.Row{row})
+			if err != nil {
+				return err
+			}
+			insertedRow(pctx, tbl, row)
+			err = rt.addRow(ctx, row)
+			if err != nil {
+				return err
+			}
+			affect(row)
+			continue
+		} else if oc.DoNothing {
+			continue
+		} else if sameKey(keyValues(cols, crow)) {
+			return fmt.Errorf(
+				"evaluate: insert: %s: on conflict do update: cannot affect row a second time",
+				stmt.Table)
+		}
+
+		erow := append(append(make(types.Row, 0, len(crow)+len(row)), crow...), row...)
+		if cond != nil {
+			val, err := evalBool(ctx, cond, erow)
+			if err != nil {
+				return err
+			} else if val != types.BoolValue(true) {
+				continue
+			}
+		}
+
+		nrow := append(make(types.Row, 0, len(crow)), crow...)
+		for edx, e := range exprs {
+			nrow[nums[edx]], err = e.eval(ctx, erow)
+			if err != nil {
+				return err
+			}
+		}
+		err = updateRow(ctx, pctx, tbl, rc, ref, crow, nrow, nums)
+		if err != nil {
+			return err
+		}
+		err = rt.addRow(ctx, nrow)
+		if err != nil {
+			return err
+		}
+		affect(nrow)
+	}
+
+	return nil
+}
+
+// modifyJoinRows calls fn with each row of tbl which, joined with the rows of from, matches
+// cond, along with a reference to the row and all of the matching joined rows. The rows of from
+// are read before any rows of tbl are modified.
+func modifyJoinRows(ctx context.Context, tbl engine.Table, from plan, cond expr,
+	fn func(ref storage.RowRef, row types.Row, jrows []types.Row) error) error {
+
+	r, err := from.rows(ctx)
+	if err != nil {
+		return err
+	}
+	frows, err := allRows(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	return modifyRows(ctx, tbl, nil,
+		func(ref storage.RowRef, row types.Row) error {
+			var jrows []types.Row
+			for _, frow := range frows {
+				jrow := append(append(make(types.Row, 0, len(row)+len(frow)), row...), frow...)
+				if cond != nil {
+					val, err := evalBool(ctx, cond, jrow)
+					if err != nil {
+						return err
+					} else if val != types.BoolValue(true) {
+						continue
+					}
+				}
+				jrows = append(jrows, jrow)
+			}
+
+			if len(jrows) == 0 {
+				return nil
+			}
+			return fn(ref, row, jrows)
+		})
+}
+
+func evaluateUpdate(ctx context.Context, pctx *planContext, stmt *sql.Update) (Rows, error) {
+	tbl, err := pctx.tx.OpenTable(ctx, stmt.Table)
+	if err != nil {
+		return nil, err
+	}
+	tt := tbl.Type()
+	cols := tableColumns(stmt.Table, tt)
+
+	var from plan
+	if stmt.From != nil {
+		from, err = planFrom(ctx, pctx, stmt.From)
+		if err != nil {
+			return nil, err
+		}
+		cols = append(cols, from.columns()...)
+	}
+
+	var cond expr
+	if stmt.Where != nil {
+		cond, err = compileExpr(ctx, pctx, cols, stmt.Where)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var nums []types.ColumnNum
+	var exprs []expr
+	for _, cu := range stmt.ColumnUpdates {
+		num, ok := columnNumber(cu.Column, tt.ColumnNames)
+		if !ok {
+			return nil, fmt.Errorf("evaluate: update: %s: unknown column: %s", stmt.Table,
+				cu.Column)
+		}
+		for _, n := range nums {
+			if n == num {
+				return nil, fmt.Errorf("evaluate: update: %s: column updated more than once: %s",
+					stmt.Table, cu.Column)
+			}
+		}
+
+		var e expr
+		if cu.Expr == nil {
+			e, err = compileDefault(ctx, pctx, tt, num)
+		} else if generatedAlways(tt, num) {
+			return nil, fmt.Errorf("evaluate: update: %s: column is generated always: %s",
+				stmt.Table, cu.Column)
+		} else {
+			e, err = compileExpr(ctx, pctx, cols, cu.Expr)
+		}
+		if err != nil {
+			return nil, err
+		}
+		nums = append(nums, num)
+		exprs = append(exprs, e)
+	}
+
+	rc, err := newRowChecker(ctx, pctx, stmt.Table, tt)
+	if err != nil {
+		return nil, err
+	}
+	rt, err := newReturning(ctx, pctx, cols, stmt.Returning)
+	if err != nil {
+		return nil, err
+	}
+
+	// jrow is row followed by the row of from, if any, that it is joined with.
+	update := func(ref storage.RowRef, row, jrow types.Row) error {
+		nrow := append(make(types.Row, 0, len(row)), row...)
+		for edx, e := range exprs {
+			val, err := e.eval(ctx, jrow)
+			if err != nil {
+				return err
+			}
+			nrow[nums[edx]] = val
+		}
+		err := updateRow(ctx, pctx, tbl, rc, ref, row, nrow, nums)
+		if err != nil {
+			return err
+		}
+		return rt.addRow(ctx, append(nrow, jrow[len(row):]...))
+	}
+
+	if from == nil {
+		err = modifyRows(ctx, tbl, cond,
+			func(ref storage.RowRef, row types.Row) error {
+				return update(ref, row, row)
+			})
+	} else {
+		err = modifyJoinRows(ctx, tbl, from, cond,
+			func(ref storage.RowRef, row types.Row, jrows []types.Row) error {
+				if len(jrows) > 1 {
+					return fmt.Errorf("evaluate: update: %s: row matches more than one row: %s",
+						stmt.Table, row)
+				}
+				return update(ref, row, jrows[0])
+			})
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = pctx.checkForeignKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return rt.resultRows(), nil
+}
+
+func evaluateDelete(ctx context.Context, pctx *planContext, stmt *sql.Delete) (Rows, error) {
+	tbl, err := pctx.tx.OpenTable(ctx, stmt.Table)
+	if err != nil {
+		return nil, err
+	}
+	tt := tbl.Type()
+	cols := tableColumns(stmt.Table, tt)
+
+	var using plan
+	if stmt.Using != nil {
+		using, err = planFrom(ctx, pctx, stmt.Using)
+		if err != nil {
+			return nil, err
+		}
+		cols = append(cols, using.columns()...)
+	}
+
+	var cond expr
+	if stmt.Where != nil {
+		cond, err = compileExpr(ctx, pctx, cols, stmt.Where)
+		if err != nil {
+			return nil, err
+		}
+	} else if len(tt.ForeignRefs) == 0 && using == nil && stmt.Returning == nil {
+		// Every row is deleted and no other tables need to know which rows.
+		_, err = tbl.DeleteRange(ctx, nil, nil)
+		return nil, err
+	}
+
+	rt, err := newReturning(ctx, pctx, cols, stmt.Returning)
+	if err != nil {
+		return nil, err
+	}
+
+	if using == nil {
+		err = modifyRows(ctx, tbl, cond,
+			func(ref storage.RowRef, row types.Row) error {
+				err := deleteRow(ctx, pctx, tbl, ref, row)
+				if err != nil {
+					return err
+				}
+				return rt.addRow(ctx, row)
+			})
+	} else {
+		err = modifyJoinRows(ctx, tbl, using, cond,
+			func(ref storage.RowRef, row types.Row, jrows []types.Row) error {
+				if len(jrows) > 1 {
+					return fmt.Errorf("evaluate: delete: %s: row matches more than one row: %s",
+						stmt.Table, row)
+				}
+				err := deleteRow(ctx, pctx, tbl, ref, row)
+				if err != nil {
+					return err
+				}
+				return rt.addRow(ctx, jrows[0])
+			})
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = pctx.checkForeignKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return rt.resultRows(), nil
+}
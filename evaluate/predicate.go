@@ -0,0 +1,278 @@
+package evaluate
+
+import (
+	"github.com/leftmike/maho/engine"
+	"github.com/leftmike/maho/parser/sql"
+	"github.com/leftmike/maho/storage"
+	"github.com/leftmike/maho/types"
+)
+
+var (
+	compareOps = map[sql.Op]storage.CompareOp{
+		sql.EqualOp:        storage.EqualOp,
+		sql.NotEqualOp:     storage.NotEqualOp,
+		sql.LessThanOp:     storage.LessOp,
+		sql.LessEqualOp:    storage.LessEqualOp,
+		sql.GreaterThanOp:  storage.GreaterOp,
+		sql.GreaterEqualOp: storage.GreaterEqualOp,
+	}
+
+	// The op to use when the operands are swapped: 1 < c1 is the same as c1 > 1.
+	swappedOps = map[storage.CompareOp]storage.CompareOp{
+		storage.EqualOp:        storage.EqualOp,
+		storage.NotEqualOp:     storage.NotEqualOp,
+		storage.LessOp:         storage.GreaterOp,
+		storage.LessEqualOp:    storage.GreaterEqualOp,
+		storage.GreaterOp:      storage.LessOp,
+		storage.GreaterEqualOp: storage.LessEqualOp,
+	}
+)
+
+// conjuncts splits e into the expressions which are ANDed together.
+func conjuncts(e sql.Expr) []sql.Expr {
+	switch be := e.(type) {
+	case *sql.BinaryExpr:
+		if be.Op == sql.AndOp {
+			return append(conjuncts(be.Left), conjuncts(be.Right)...)
+		}
+	case *sql.UnaryExpr:
+		if be.Op == sql.NoOp {
+			return conjuncts(be.Expr)
+		}
+	}
+	return []sql.Expr{e}
+}
+
+func literalValue(e sql.Expr) (types.Value, bool) {
+	switch e := e.(type) {
+	case sql.Literal:
+		return e.Value, true
+	case *sql.Literal:
+		return e.Value, true
+	case *sql.UnaryExpr:
+		if e.Op == sql.NoOp {
+			return literalValue(e.Expr)
+		}
+	}
+	return nil, false
+}
+
+// comparableTypes returns whether a value of type t1 can be compared with a value of type t2; the
+// comparison would be an error when evaluated, so it must not be pushed into storage.
+func comparableTypes(t1, t2 types.ValueType) bool {
+	if t1 == t2 {
+		return true
+	}
+	return (t1 == types.Int64Type || t1 == types.Float64Type) &&
+		(t2 == types.Int64Type || t2 == types.Float64Type)
+}
+
+func valueType(val types.Value) types.ValueType {
+	switch val.(type) {
+	case types.BoolValue:
+		return types.BoolType
+	case types.StringValue:
+		return types.StringType
+	case types.BytesValue:
+		return types.BytesType
+	case types.Float64Value:
+		return types.Float64Type
+	case types.Int64Value:
+		return types.Int64Type
+	}
+	return types.UnknownType
+}
+
+type predicateColumns struct {
+	cols []column
+	tt   *engine.TableType
+}
+
+func (pc predicateColumns) column(e sql.Expr) (types.ColumnNum, bool) {
+	ref, ok := e.(sql.Ref)
+	if !ok {
+		return 0, false
+	}
+	idx, err := findColumn(pc.cols, ref)
+	if err != nil {
+		return 0, false
+	}
+	return types.ColumnNum(idx), true
+}
+
+func (pc predicateColumns) valueOf(col types.ColumnNum, e sql.Expr) (types.Value, bool) {
+	val, ok := literalValue(e)
+	if !ok || (val != nil && !comparableTypes(pc.tt.ColumnTypes[col].Type, valueType(val))) {
+		return nil, false
+	}
+	return val, true
+}
+
+// storagePredicate converts e, which is a condition on the rows of the table with columns cols,
+// into a storage predicate; ok is false if e can not be converted.
+func (pc predicateColumns) storagePredicate(e sql.Expr) (storage.Predicate, bool) {
+	switch e := e.(type) {
+	case *sql.UnaryExpr:
+		switch e.Op {
+		case sql.NoOp:
+			return pc.storagePredicate(e.Expr)
+		case sql.NotOp:
+			pred, ok := pc.storagePredicate(e.Expr)
+			if !ok {
+				return nil, false
+			}
+			return storage.NotPredicate{Predicate: pred}, true
+		}
+	case *sql.BinaryExpr:
+		if e.Op == sql.AndOp || e.Op == sql.OrOp {
+			left, ok := pc.storagePredicate(e.Left)
+			if !ok {
+				return nil, false
+			}
+			right, ok := pc.storagePredicate(e.Right)
+			if !ok {
+				return nil, false
+			}
+			if e.Op == sql.AndOp {
+				return storage.AndPredicate{left, right}, true
+			}
+			return storage.OrPredicate{left, right}, true
+		}
+
+		op, ok := compareOps[e.Op]
+		if !ok {
+			return nil, false
+		}
+		if col, ok := pc.column(e.Left); ok {
+			if rcol, ok := pc.column(e.Right); ok {
+				if !comparableTypes(pc.tt.ColumnTypes[col].Type, pc.tt.ColumnTypes[rcol].Type) {
+					return nil, false
+				}
+				return storage.ColumnsPredicate{Op: op, Left: col, Right: rcol}, true
+			}
+			val, ok := pc.valueOf(col, e.Right)
+			if !ok {
+				return nil, false
+			}
+			return storage.ComparePredicate{Op: op, Column: col, Value: val}, true
+		} else if col, ok := pc.column(e.Right); ok {
+			val, ok := pc.valueOf(col, e.Left)
+			if !ok {
+				return nil, false
+			}
+			return storage.ComparePredicate{Op: swappedOps[op], Column: col, Value: val}, true
+		}
+	case *sql.SExpr:
+		if e.Name == types.ID("is_null", false) && len(e.Args) == 1 {
+			col, ok := pc.column(e.Args[0])
+			if !ok {
+				return nil, false
+			}
+			return storage.NullPredicate{Column: col}, true
+		}
+	case *sql.Subquery:
+		// column IN (VALUES ...) and column NOT IN (VALUES ...)
+		if !(e.Op == sql.Any && e.ExprOp == sql.EqualOp) &&
+			!(e.Op == sql.All && e.ExprOp == sql.NotEqualOp) {
+
+			return nil, false
+		}
+		col, ok := pc.column(e.Expr)
+		if !ok {
+			return nil, false
+		}
+		values, ok := e.Stmt.(*sql.Values)
+		if !ok {
+			return nil, false
+		}
+
+		in := storage.InPredicate{Column: col}
+		for _, row := range values.Expressions {
+			if len(row) != 1 {
+				return nil, false
+			}
+			val, ok := pc.valueOf(col, row[0])
+			if !ok {
+				return nil, false
+			}
+			in.Values = append(in.Values, val)
+		}
+		if e.Op == sql.All {
+			return storage.NotPredicate{Predicate: in}, true
+		}
+		return in, true
+	}
+
+	return nil, false
+}
+
+// pushPredicate moves the conjuncts of where which tbl can evaluate into the scan; the rest of
+// where, if any, is returned to be evaluated by a filter.
+func (sp *scanPlan) pushPredicate(where sql.Expr) sql.Expr {
+	pc := predicateColumns{cols: sp.cols, tt: sp.tbl.Type()}
+
+	var preds storage.AndPredicate
+	var rest sql.Expr
+	for _, e := range conjuncts(where) {
+		pred, ok := pc.storagePredicate(e)
+		if ok && sp.tbl.SupportsPredicate(pred) {
+			preds = append(preds, pred)
+		} else if rest == nil {
+			rest = e
+		} else {
+			rest = &sql.BinaryExpr{Op: sql.AndOp, Left: rest, Right: e}
+		}
+	}
+
+	if len(preds) == 1 {
+		sp.pred = preds[0]
+	} else if len(preds) > 1 {
+		sp.pred = preds
+	}
+	sp.scanRange(preds)
+	return rest
+}
+
+// scanRange bounds the scan using comparisons of the first column of the primary key with a
+// value; the comparisons are still evaluated as part of the predicate.
+func (sp *scanPlan) scanRange(preds []storage.Predicate) {
+	tt := sp.tbl.Type()
+	if len(tt.Key) == 0 || tt.Key[0].Reverse() {
+		return
+	}
+
+	col := tt.Key[0].Column()
+	for _, pred := range preds {
+		cp, ok := pred.(storage.ComparePredicate)
+		if !ok || cp.Column != col || cp.Value == nil ||
+			valueType(cp.Value) != tt.ColumnTypes[col].Type {
+
+			continue
+		}
+
+		row := make(types.Row, len(tt.ColumnNames))
+		row[col] = cp.Value
+		// XXX: only the first bound in each direction is used
+		switch cp.Op {
+		case storage.EqualOp:
+			if sp.spec.MinRow == nil && sp.spec.MaxRow == nil {
+				sp.spec.MinRow = row
+				sp.spec.MaxRow = row
+			}
+		case storage.GreaterOp, storage.GreaterEqualOp:
+			if sp.spec.MinRow == nil {
+				sp.spec.MinRow = row
+				sp.spec.MinExclusive = cp.Op == storage.GreaterOp
+			}
+		case storage.LessOp, storage.LessEqualOp:
+			if sp.spec.MaxRow == nil {
+				sp.spec.MaxRow = row
+				sp.spec.MaxExclusive = cp.Op == storage.LessOp
+			}
+		}
+	}
+	if sp.spec.MinRow != nil || sp.spec.MaxRow != nil {
+		sp.spec.Prefix = 1
+		sp.scan = true
+	}
+}
@@ -0,0 +1,561 @@
+package evaluate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/leftmike/maho/engine"
+	"github.com/leftmike/maho/parser/sql"
+	"github.com/leftmike/maho/storage"
+	"github.com/leftmike/maho/types"
+)
+
+// Rows is the result set of a query.
+type Rows interface {
+	Columns() []types.Identifier
+	Next(ctx context.Context) (types.Row, error)
+	Close(ctx context.Context) error
+}
+
+type rows interface {
+	Next(ctx context.Context) (types.Row, error)
+	Close(ctx context.Context) error
+}
+
+type planContext struct {
+	ses    *Session
+	tx     engine.Transaction
+	checks []foreignKeyCheck
+}
+
+type plan interface {
+	columns() []column
+	rows(ctx context.Context) (rows, error)
+}
+
+type scanPlan struct {
+	tbl  engine.Table
+	cols []column
+	pred storage.Predicate
+	spec storage.ScanSpec
+	scan bool // use spec to scan the table in primary key order
+}
+
+type valuesPlan struct {
+	cols   []column
+	values [][]expr
+}
+
+type filterPlan struct {
+	plan plan
+	cond expr
+}
+
+type projectPlan struct {
+	plan  plan
+	cols  []column
+	exprs []expr
+}
+
+type sortKey struct {
+	idx     int
+	reverse bool
+}
+
+type sortPlan struct {
+	plan plan
+	keys []sortKey
+}
+
+type aliasPlan struct {
+	plan plan
+	cols []column
+}
+
+type valuesRows struct {
+	values [][]expr
+	next   int
+}
+
+type filterRows struct {
+	rows rows
+	cond expr
+}
+
+type projectRows struct {
+	rows  rows
+	exprs []expr
+}
+
+type resultRows struct {
+	cols []types.Identifier
+	rows []types.Row
+	next int
+}
+
+func planQuery(ctx context.Context, pctx *planContext, stmt sql.Stmt) (plan, error) {
+	switch stmt := stmt.(type) {
+	case *sql.Select:
+		return planSelect(ctx, pctx, stmt)
+	case *sql.Values:
+		return planValues(ctx, pctx, stmt)
+	case *sql.Show:
+		return planShow(ctx, pctx, stmt)
+	}
+
+	return nil, fmt.Errorf("evaluate: expected a query: %s", stmt)
+}
+
+func planShow(ctx context.Context, pctx *planContext, stmt *sql.Show) (plan, error) {
+	if pctx.ses == nil {
+		return nil, fmt.Errorf("evaluate: %s: requires a session", stmt)
+	}
+
+	var val string
+	switch stmt.Variable {
+	case types.DATABASE:
+		val = pctx.ses.defaultDatabase.String()
+	case types.SCHEMA:
+		val = pctx.ses.defaultSchema.String()
+	default:
+		var ok bool
+		if cfg := pctx.ses.eng.Config(); cfg != nil {
+			val, ok = cfg.Get(stmt.Variable.String())
+		}
+		if !ok {
+			return nil, fmt.Errorf("evaluate: show: %s not found", stmt.Variable)
+		}
+	}
+
+	return &valuesPlan{
+		cols:   []column{{name: stmt.Variable}},
+		values: [][]expr{{literal{types.StringValue(val)}}},
+	}, nil
+}
+
+func valueColumns(n int) []column {
+	cols := make([]column, n)
+	for cdx := range cols {
+		cols[cdx] = column{name: types.ID(fmt.Sprintf("column%d", cdx+1), false)}
+	}
+	return cols
+}
+
+func planValues(ctx context.Context, pctx *planContext, stmt *sql.Values) (plan, error) {
+	var values [][]expr
+	for _, row := range stmt.Expressions {
+		if len(row) != len(stmt.Expressions[0]) {
+			return nil, fmt.Errorf("evaluate: values: all rows must have the same length")
+		}
+
+		var vals []expr
+		for _, e := range row {
+			ce, err := compileExpr(ctx, pctx, nil, e)
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, ce)
+		}
+		values = append(values, vals)
+	}
+
+	return &valuesPlan{
+		cols:   valueColumns(len(stmt.Expressions[0])),
+		values: values,
+	}, nil
+}
+
+func planFrom(ctx context.Context, pctx *planContext, fi sql.FromItem) (plan, error) {
+	switch fi := fi.(type) {
+	case nil:
+		return &valuesPlan{values: [][]expr{{}}}, nil
+	case *sql.FromTableAlias:
+		return planTable(ctx, pctx, fi.TableName, fi.Alias)
+	case *sql.FromIndexAlias:
+		// XXX: use the index
+		return planTable(ctx, pctx, fi.TableName, fi.Alias)
+	case sql.FromStmt:
+		p, err := planQuery(ctx, pctx, fi.Stmt)
+		if err != nil {
+			return nil, err
+		}
+
+		pcols := p.columns()
+		if fi.ColumnAliases != nil && len(fi.ColumnAliases) != len(pcols) {
+			return nil, fmt.Errorf("evaluate: %s: wrong number of column aliases", fi.Alias)
+		}
+		cols := make([]column, len(pcols))
+		for cdx, col := range pcols {
+			cols[cdx] = column{table: fi.Alias, name: col.name}
+			if fi.ColumnAliases != nil {
+				cols[cdx].name = fi.ColumnAliases[cdx]
+			}
+		}
+		return &aliasPlan{plan: p, cols: cols}, nil
+	case sql.FromJoin:
+		return planJoin(ctx, pctx, fi)
+	}
+
+	panic(fmt.Sprintf("evaluate: unexpected from item: %#v", fi))
+}
+
+func planTable(ctx context.Context, pctx *planContext, tn types.TableName,
+	alias types.Identifier) (plan, error) {
+
+	tbl, err := pctx.tx.OpenTable(ctx, tn)
+	if err != nil {
+		return nil, err
+	}
+
+	if alias == 0 {
+		alias = tn.Table
+	}
+	var cols []column
+	for _, col := range tbl.Type().ColumnNames {
+		cols = append(cols, column{table: alias, name: col})
+	}
+
+	return &scanPlan{tbl: tbl, cols: cols}, nil
+}
+
+func resultName(er sql.ExprResult, cdx int) types.Identifier {
+	if er.Alias != 0 {
+		return er.Alias
+	} else if ref, ok := er.Expr.(sql.Ref); ok {
+		return ref[len(ref)-1]
+	}
+	return types.ID(fmt.Sprintf("expr%d", cdx+1), false)
+}
+
+func planSelect(ctx context.Context, pctx *planContext, stmt *sql.Select) (plan, error) {
+	if stmt.GroupBy != nil || stmt.Having != nil {
+		return nil, fmt.Errorf("evaluate: group by not implemented: %s", stmt)
+	}
+
+	p, err := planFrom(ctx, pctx, stmt.From)
+	if err != nil {
+		return nil, err
+	}
+	fromCols := p.columns()
+
+	where := stmt.Where
+	if sp, ok := p.(*scanPlan); ok && where != nil {
+		where = sp.pushPredicate(where)
+	}
+	if where != nil {
+		cond, err := compileExpr(ctx, pctx, fromCols, where)
+		if err != nil {
+			return nil, err
+		}
+		p = &filterPlan{plan: p, cond: cond}
+	}
+
+	if stmt.Results != nil {
+		pp := &projectPlan{plan: p}
+		for _, sr := range stmt.Results {
+			switch sr := sr.(type) {
+			case sql.TableResult:
+				var found bool
+				for cdx, col := range fromCols {
+					if col.table == sr.Table {
+						found = true
+						pp.cols = append(pp.cols, col)
+						pp.exprs = append(pp.exprs,
+							columnRef{idx: cdx, ref: sql.Ref{col.table, col.name}})
+					}
+				}
+				if !found {
+					return nil, fmt.Errorf("evaluate: table not found: %s", sr.Table)
+				}
+			case sql.ExprResult:
+				ce, err := compileExpr(ctx, pctx, fromCols, sr.Expr)
+				if err != nil {
+					return nil, err
+				}
+				pp.cols = append(pp.cols, column{name: resultName(sr, len(pp.cols))})
+				pp.exprs = append(pp.exprs, ce)
+			default:
+				panic(fmt.Sprintf("evaluate: unexpected select result: %#v", sr))
+			}
+		}
+		p = pp
+	}
+
+	if stmt.OrderBy != nil {
+		sp := &sortPlan{plan: p}
+		cols := p.columns()
+		for _, by := range stmt.OrderBy {
+			ref, ok := by.Expr.(sql.Ref)
+			if !ok {
+				return nil, fmt.Errorf("evaluate: order by: expected a column: %s", by.Expr)
+			}
+			idx, err := findColumn(cols, ref)
+			if err != nil {
+				return nil, err
+			}
+			sp.keys = append(sp.keys, sortKey{idx: idx, reverse: by.Reverse})
+		}
+		if !scanOrder(p, sp.keys) {
+			p = sp
+		}
+	}
+
+	return p, nil
+}
+
+// scanOrder returns whether the rows of p are already in the order of keys because p scans a
+// table in the order, or the reverse order, of a prefix of its primary key; if necessary, the
+// scan is reversed.
+func scanOrder(p plan, keys []sortKey) bool {
+	switch p := p.(type) {
+	case *projectPlan:
+		pkeys := make([]sortKey, 0, len(keys))
+		for _, key := range keys {
+			ref, ok := p.exprs[key.idx].(columnRef)
+			if !ok {
+				return false
+			}
+			pkeys = append(pkeys, sortKey{idx: ref.idx, reverse: key.reverse})
+		}
+		return scanOrder(p.plan, pkeys)
+	case *filterPlan:
+		return scanOrder(p.plan, keys)
+	case *scanPlan:
+		key := p.tbl.Type().Key
+		if len(keys) > len(key) {
+			return false
+		}
+
+		var reverse bool
+		for kdx, sk := range keys {
+			if sk.idx != int(key[kdx].Column()) {
+				return false
+			}
+			if kdx == 0 {
+				reverse = sk.reverse != key[kdx].Reverse()
+			} else if reverse != (sk.reverse != key[kdx].Reverse()) {
+				return false
+			}
+		}
+		p.spec.Reverse = reverse
+		p.scan = true
+		return true
+	}
+
+	return false
+}
+
+func (sp *scanPlan) columns() []column {
+	return sp.cols
+}
+
+func (sp *scanPlan) rows(ctx context.Context) (rows, error) {
+	if sp.scan {
+		return sp.tbl.Scan(ctx, nil, sp.spec, sp.pred)
+	}
+	return sp.tbl.Rows(ctx, nil, nil, nil, sp.pred)
+}
+
+func (vp *valuesPlan) columns() []column {
+	return vp.cols
+}
+
+func (vp *valuesPlan) rows(ctx context.Context) (rows, error) {
+	return &valuesRows{values: vp.values}, nil
+}
+
+func (vr *valuesRows) Next(ctx context.Context) (types.Row, error) {
+	if vr.next == len(vr.values) {
+		return nil, io.EOF
+	}
+
+	vals := vr.values[vr.next]
+	vr.next += 1
+
+	row := make(types.Row, len(vals))
+	for vdx, e := range vals {
+		var err error
+		row[vdx], err = e.eval(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return row, nil
+}
+
+func (vr *valuesRows) Close(ctx context.Context) error {
+	vr.next = len(vr.values)
+	return nil
+}
+
+func (fp *filterPlan) columns() []column {
+	return fp.plan.columns()
+}
+
+func (fp *filterPlan) rows(ctx context.Context) (rows, error) {
+	r, err := fp.plan.rows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &filterRows{rows: r, cond: fp.cond}, nil
+}
+
+func (fr *filterRows) Next(ctx context.Context) (types.Row, error) {
+	for {
+		row, err := fr.rows.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		val, err := evalBool(ctx, fr.cond, row)
+		if err != nil {
+			return nil, err
+		}
+		if val == types.BoolValue(true) {
+			return row, nil
+		}
+	}
+}
+
+func (fr *filterRows) Close(ctx context.Context) error {
+	return fr.rows.Close(ctx)
+}
+
+func (pp *projectPlan) columns() []column {
+	return pp.cols
+}
+
+func (pp *projectPlan) rows(ctx context.Context) (rows, error) {
+	r, err := pp.plan.rows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &projectRows{rows: r, exprs: pp.exprs}, nil
+}
+
+func (pr *projectRows) Next(ctx context.Context) (types.Row, error) {
+	row, err := pr.rows.Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	prow := make(types.Row, len(pr.exprs))
+	for edx, e := range pr.exprs {
+		prow[edx], err = e.eval(ctx, row)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return prow, nil
+}
+
+func (pr *projectRows) Close(ctx context.Context) error {
+	return pr.rows.Close(ctx)
+}
+
+func (sp *sortPlan) columns() []column {
+	return sp.plan.columns()
+}
+
+func allRows(ctx context.Context, r rows) ([]types.Row, error) {
+	var all []types.Row
+	for {
+		row, err := r.Next(ctx)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			r.Close(ctx)
+			return nil, err
+		}
+		all = append(all, row)
+	}
+
+	err := r.Close(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+func (sp *sortPlan) rows(ctx context.Context) (rows, error) {
+	r, err := sp.plan.rows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	all, err := allRows(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(all,
+		func(i, j int) bool {
+			for _, key := range sp.keys {
+				cmp := types.Compare(all[i][key.idx], all[j][key.idx])
+				if cmp == 0 {
+					continue
+				}
+				if key.reverse {
+					return cmp > 0
+				}
+				return cmp < 0
+			}
+			return false
+		})
+	return &resultRows{rows: all}, nil
+}
+
+func (ap *aliasPlan) columns() []column {
+	return ap.cols
+}
+
+func (ap *aliasPlan) rows(ctx context.Context) (rows, error) {
+	return ap.plan.rows(ctx)
+}
+
+func (rr *resultRows) Columns() []types.Identifier {
+	return rr.cols
+}
+
+func (rr *resultRows) Next(ctx context.Context) (types.Row, error) {
+	if rr.next >= len(rr.rows) {
+		return nil, io.EOF
+	}
+
+	rr.next += 1
+	return rr.rows[rr.next-1], nil
+}
+
+func (rr *resultRows) Close(ctx context.Context) error {
+	rr.next = len(rr.rows)
+	return nil
+}
+
+func query(ctx context.Context, pctx *planContext, stmt sql.Stmt) (Rows, error) {
+	p, err := planQuery(ctx, pctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := p.rows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	all, err := allRows(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	var cols []types.Identifier
+	for _, col := range p.columns() {
+		cols = append(cols, col.name)
+	}
+	return &resultRows{cols: cols, rows: all}, nil
+}
+
+// Query evaluates stmt, which must be a SELECT or VALUES, using tx. The rows are read before
+// returning so that they remain valid after tx is committed.
+func Query(ctx context.Context, tx engine.Transaction, stmt sql.Stmt) (Rows, error) {
+	return query(ctx, &planContext{tx: tx}, stmt)
+}
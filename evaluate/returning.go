@@ -0,0 +1,100 @@
+package evaluate
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/leftmike/maho/parser/sql"
+	"github.com/leftmike/maho/types"
+)
+
+// returning collects the rows for RETURNING; a nil returning collects nothing.
+type returning struct {
+	cols  []types.Identifier
+	exprs []expr
+	rows  []types.Row
+}
+
+// newReturning compiles results against cols, which are the columns of the table being modified
+// followed by the columns of any tables it is joined with.
+func newReturning(ctx context.Context, pctx *planContext, cols []column,
+	results []sql.SelectResult) (*returning, error) {
+
+	if results == nil {
+		return nil, nil
+	}
+
+	var rt returning
+	for _, sr := range results {
+		switch sr := sr.(type) {
+		case sql.StarResult:
+			for cdx, col := range cols {
+				rt.cols = append(rt.cols, col.name)
+				rt.exprs = append(rt.exprs,
+					columnRef{idx: cdx, ref: sql.Ref{col.table, col.name}})
+			}
+		case sql.TableResult:
+			var found bool
+			for cdx, col := range cols {
+				if col.table == sr.Table {
+					found = true
+					rt.cols = append(rt.cols, col.name)
+					rt.exprs = append(rt.exprs,
+						columnRef{idx: cdx, ref: sql.Ref{col.table, col.name}})
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("evaluate: table not found: %s", sr.Table)
+			}
+		case sql.ExprResult:
+			ce, err := compileExpr(ctx, pctx, cols, sr.Expr)
+			if err != nil {
+				return nil, err
+			}
+			rt.cols = append(rt.cols, resultName(sr, len(rt.cols)))
+			rt.exprs = append(rt.exprs, ce)
+		default:
+			panic(fmt.Sprintf("evaluate: unexpected returning result: %#v", sr))
+		}
+	}
+	return &rt, nil
+}
+
+func (rt *returning) addRow(ctx context.Context, row types.Row) error {
+	if rt == nil {
+		return nil
+	}
+
+	rrow := make(types.Row, len(rt.exprs))
+	for edx, e := range rt.exprs {
+		val, err := e.eval(ctx, row)
+		if err != nil {
+			return err
+		}
+		rrow[edx] = val
+	}
+	rt.rows = append(rt.rows, rrow)
+	return nil
+}
+
+func (rt *returning) resultRows() Rows {
+	if rt == nil {
+		return nil
+	}
+	return &resultRows{cols: rt.cols, rows: rt.rows}
+}
+
+// evaluateModify evaluates an INSERT, UPDATE, or DELETE and returns the rows for RETURNING;
+// the rows are nil if stmt does not have RETURNING.
+func evaluateModify(ctx context.Context, pctx *planContext, stmt sql.Stmt) (Rows, error) {
+	switch stmt := stmt.(type) {
+	case *sql.Delete:
+		return evaluateDelete(ctx, pctx, stmt)
+	case *sql.InsertValues:
+		return evaluateInsert(ctx, pctx, stmt)
+	case *sql.Update:
+		return evaluateUpdate(ctx, pctx, stmt)
+	}
+
+	panic(fmt.Sprintf("evaluate: unexpected statement: %s", stmt))
+}
@@ -0,0 +1,166 @@
+package evaluate
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/leftmike/maho/engine"
+	"github.com/leftmike/maho/parser/sql"
+	"github.com/leftmike/maho/types"
+)
+
+// setSequenceOptions changes seq using so; missing minimum and maximum values default based on
+// the direction of the sequence.
+func setSequenceOptions(sqn types.TableName, seq *engine.Sequence, so sql.SequenceOptions,
+	create bool) error {
+
+	if so.Increment != nil {
+		if *so.Increment == 0 {
+			return fmt.Errorf("evaluate: sequence %s: increment must not be zero", sqn)
+		}
+		seq.Increment = *so.Increment
+	}
+
+	if so.MinValue != nil {
+		seq.MinValue = *so.MinValue
+	} else if create || so.NoMinValue {
+		if seq.Increment > 0 {
+			seq.MinValue = 1
+		} else {
+			seq.MinValue = math.MinInt64
+		}
+	}
+
+	if so.MaxValue != nil {
+		seq.MaxValue = *so.MaxValue
+	} else if create || so.NoMaxValue {
+		if seq.Increment > 0 {
+			seq.MaxValue = math.MaxInt64
+		} else {
+			seq.MaxValue = -1
+		}
+	}
+
+	if so.Start != nil {
+		seq.Start = *so.Start
+	} else if create {
+		if seq.Increment > 0 {
+			seq.Start = seq.MinValue
+		} else {
+			seq.Start = seq.MaxValue
+		}
+	}
+
+	if so.Cycle != nil {
+		seq.Cycle = *so.Cycle
+	}
+
+	if seq.MinValue >= seq.MaxValue {
+		return fmt.Errorf("evaluate: sequence %s: minimum value (%d) must be less than maximum "+
+			"value (%d)", sqn, seq.MinValue, seq.MaxValue)
+	} else if seq.Start < seq.MinValue || seq.Start > seq.MaxValue {
+		return fmt.Errorf("evaluate: sequence %s: start value (%d) must be between %d and %d",
+			sqn, seq.Start, seq.MinValue, seq.MaxValue)
+	}
+	return nil
+}
+
+// createIdentitySequence creates the sequence owned by an identity column of a new table; by
+// default, the values of the sequence are limited by the size of the column.
+func createIdentitySequence(ctx context.Context, tx engine.Transaction, tn types.TableName,
+	col types.Identifier, ct types.ColumnType, so sql.SequenceOptions) (types.TableName, error) {
+
+	sqn := types.TableName{
+		Database: tn.Database,
+		Schema:   tn.Schema,
+		Table:    types.ID(fmt.Sprintf("%s_%s_seq", tn.Table, col), false),
+	}
+	if _, err := tx.LookupSequence(ctx, sqn); err == nil {
+		return types.TableName{}, fmt.Errorf("evaluate: sequence already exists: %s", sqn)
+	}
+
+	if ct.Size < 8 {
+		maxVal := int64(1)<<(ct.Size*8-1) - 1
+		minVal := -maxVal - 1
+		if so.Increment == nil || *so.Increment > 0 {
+			if so.MaxValue == nil {
+				so.MaxValue = &maxVal
+			}
+		} else if so.MinValue == nil {
+			so.MinValue = &minVal
+		}
+	}
+
+	seq := engine.Sequence{
+		Increment: 1,
+	}
+	err := setSequenceOptions(sqn, &seq, so, true)
+	if err != nil {
+		return types.TableName{}, err
+	}
+	return sqn, tx.CreateSequence(ctx, sqn, seq)
+}
+
+func EvaluateCreateSequence(ctx context.Context, tx engine.Transaction,
+	stmt *sql.CreateSequence) error {
+
+	if _, err := tx.LookupSequence(ctx, stmt.Sequence); err == nil {
+		if stmt.IfNotExists {
+			return nil
+		}
+		return fmt.Errorf("evaluate: create sequence: sequence already exists: %s",
+			stmt.Sequence)
+	}
+
+	seq := engine.Sequence{
+		Increment: 1,
+	}
+	err := setSequenceOptions(stmt.Sequence, &seq, stmt.Options, true)
+	if err != nil {
+		return err
+	}
+	return tx.CreateSequence(ctx, stmt.Sequence, seq)
+}
+
+func EvaluateAlterSequence(ctx context.Context, tx engine.Transaction,
+	stmt *sql.AlterSequence) error {
+
+	seq, err := tx.LookupSequence(ctx, stmt.Sequence)
+	if err != nil {
+		if stmt.IfExists {
+			return nil
+		}
+		return err
+	}
+
+	err = setSequenceOptions(stmt.Sequence, &seq, stmt.Options, false)
+	if err != nil {
+		return err
+	}
+	err = tx.AlterSequence(ctx, stmt.Sequence, seq)
+	if err != nil {
+		return err
+	}
+
+	if stmt.Restart {
+		val := seq.Start
+		if stmt.RestartWith != nil {
+			val = *stmt.RestartWith
+		}
+		return tx.RestartSequence(ctx, stmt.Sequence, val)
+	}
+	return nil
+}
+
+func EvaluateDropSequence(ctx context.Context, tx engine.Transaction,
+	stmt *sql.DropSequence) error {
+
+	for _, sqn := range stmt.Sequences {
+		err := tx.DropSequence(ctx, sqn, stmt.IfExists)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,25 +3,82 @@ package evaluate
 import (
 	"context"
 	"fmt"
+	"io"
+	"slices"
+	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/leftmike/maho/engine"
 	"github.com/leftmike/maho/parser/sql"
+	"github.com/leftmike/maho/storage"
 	"github.com/leftmike/maho/types"
 )
 
 var (
 	sessionId atomic.Uint64
+
+	// Read uncommitted is the same as read committed, and repeatable read is the same as
+	// snapshot.
+	isolationLevels = map[sql.IsolationLevel]storage.IsolationLevel{
+		sql.ReadUncommitted: storage.ReadCommitted,
+		sql.ReadCommitted:   storage.ReadCommitted,
+		sql.RepeatableRead:  storage.Snapshot,
+		sql.Snapshot:        storage.Snapshot,
+		sql.Serializable:    storage.Serializable,
+	}
 )
 
 type Session struct {
 	eng             engine.Engine
 	tx              engine.Transaction
+	deferred        []foreignKeyCheck
+	constraints     constraintModes // set by SET CONSTRAINTS in the active transaction
+	savepoints      []savepoint
+	stmts           int                       // statements evaluated in the active transaction
+	readOnly        bool                      // the active transaction is read only
+	currentValues   map[types.TableName]int64 // sequence values returned by nextval
 	defaultDatabase types.Identifier
 	defaultSchema   types.Identifier
+	copyOutput      io.Writer // output for COPY ... TO STDOUT
 	id              uint64
 }
 
+// constraintModes are the modes of foreign keys set by SET CONSTRAINTS: names takes precedence
+// over all, which takes precedence over the mode of the foreign key.
+type constraintModes struct {
+	all      bool // the mode of all foreign keys has been set
+	deferred bool // the mode of all foreign keys, if all is set
+	names    map[types.Identifier]bool
+}
+
+func (cm constraintModes) isDeferred(fk engine.ForeignKey) bool {
+	if deferred, ok := cm.names[fk.Name]; ok {
+		return deferred
+	} else if cm.all {
+		return cm.deferred
+	}
+	return fk.Deferred
+}
+
+type savepoint struct {
+	name        types.Identifier
+	sp          storage.Savepoint
+	deferred    int             // number of deferred foreign key checks
+	constraints constraintModes // modes of foreign keys when the savepoint was set
+}
+
+func (cm constraintModes) copy() constraintModes {
+	if cm.names != nil {
+		names := map[types.Identifier]bool{}
+		for nam, deferred := range cm.names {
+			names[nam] = deferred
+		}
+		cm.names = names
+	}
+	return cm
+}
+
 func NewSession(eng engine.Engine, defaultDatabase, defaultSchema types.Identifier) *Session {
 	return &Session{
 		eng:             eng,
@@ -31,6 +88,163 @@ func NewSession(eng engine.Engine, defaultDatabase, defaultSchema types.Identifi
 	}
 }
 
+// SetCopyOutput sets where COPY ... TO STDOUT writes its rows.
+func (ses *Session) SetCopyOutput(w io.Writer) {
+	ses.copyOutput = w
+}
+
+func (ses *Session) begin(stmt *sql.Begin) (engine.Transaction, error) {
+	var tx engine.Transaction
+	if stmt.Modes.ReadOnly || stmt.AsOf != "" {
+		if stmt.Modes.ReadWrite {
+			return nil, fmt.Errorf(
+				"execute: begin: session %d: as of system time requires a read only transaction",
+				ses.id)
+		}
+
+		var asOf time.Time
+		if stmt.AsOf != "" {
+			var err error
+			asOf, err = parseAsOf(stmt.AsOf, time.Now())
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		var err error
+		tx, err = ses.eng.BeginReadOnly(asOf)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		tx = ses.eng.Begin()
+	}
+
+	if stmt.Modes.Isolation != 0 {
+		err := tx.SetIsolation(isolationLevels[stmt.Modes.Isolation])
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	return tx, nil
+}
+
+// parseAsOf parses the timestamp of AS OF SYSTEM TIME: either a negative duration relative to
+// now, such as '-10s', or a time in UTC.
+func parseAsOf(s string, now time.Time) (time.Time, error) {
+	if strings.HasPrefix(s, "-") {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("execute: as of system time: %s", err)
+		}
+		return now.Add(d), nil
+	}
+
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999"} {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("execute: as of system time: unable to parse timestamp: %s",
+		s)
+}
+
+func (ses *Session) setTransaction(tm sql.TransactionModes) error {
+	if tm.ReadWrite && ses.readOnly {
+		return fmt.Errorf("execute: set transaction: session %d: transaction is read only",
+			ses.id)
+	}
+
+	if tm.Isolation != 0 {
+		err := ses.tx.SetIsolation(isolationLevels[tm.Isolation])
+		if err != nil {
+			return err
+		}
+	}
+	if tm.ReadOnly {
+		err := ses.tx.SetReadOnly()
+		if err != nil {
+			return err
+		}
+		ses.readOnly = true
+	}
+	return nil
+}
+
+// setConstraints changes the modes of foreign keys. Deferred checks of foreign keys which are
+// changed to immediate are done now, as well as when the transaction commits.
+func (ses *Session) setConstraints(ctx context.Context, stmt *sql.SetConstraints) error {
+	if !stmt.All {
+		err := ses.checkConstraintNames(ctx, stmt.Names)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !stmt.Deferred {
+		var checks []foreignKeyCheck
+		for _, chk := range ses.deferred {
+			if stmt.All || slices.Contains(stmt.Names, chk.fk) {
+				checks = append(checks, chk)
+			}
+		}
+		err := checkForeignKeys(ctx, ses.tx, checks)
+		if err != nil {
+			return err
+		}
+	}
+
+	if stmt.All {
+		ses.constraints = constraintModes{all: true, deferred: stmt.Deferred}
+	} else {
+		if ses.constraints.names == nil {
+			ses.constraints.names = map[types.Identifier]bool{}
+		}
+		for _, nam := range stmt.Names {
+			ses.constraints.names[nam] = stmt.Deferred
+		}
+	}
+	return nil
+}
+
+// checkConstraintNames returns an error unless each name is a foreign key of a table in the
+// default schema.
+func (ses *Session) checkConstraintNames(ctx context.Context, names []types.Identifier) error {
+	sn := types.SchemaName{Database: ses.defaultDatabase, Schema: ses.defaultSchema}
+	tblnames, err := ses.tx.ListTables(ctx, sn)
+	if err != nil {
+		return err
+	}
+
+	fks := map[types.Identifier]struct{}{}
+	for _, tblname := range tblnames {
+		tn := types.TableName{Database: sn.Database, Schema: sn.Schema, Table: tblname}
+		tbl, err := ses.tx.OpenTable(ctx, tn)
+		if err != nil {
+			return err
+		}
+		for _, fk := range tbl.Type().ForeignKeys {
+			fks[fk.Name] = struct{}{}
+		}
+	}
+
+	for _, nam := range names {
+		if _, ok := fks[nam]; !ok {
+			return fmt.Errorf("evaluate: set constraints: constraint not found: %s", nam)
+		}
+	}
+	return nil
+}
+
+func (ses *Session) setCurrentValue(sqn types.TableName, val int64) {
+	if ses.currentValues == nil {
+		ses.currentValues = map[types.TableName]int64{}
+	}
+	ses.currentValues[sqn] = val
+}
+
 func (ses *Session) ResolveTable(tn types.TableName) types.TableName {
 	if tn.Database == 0 {
 		tn.Database = ses.defaultDatabase
@@ -48,61 +262,188 @@ func (ses *Session) ResolveSchema(sn types.SchemaName) types.SchemaName {
 	return sn
 }
 
-func (ses *Session) Evaluate(ctx context.Context, stmt sql.Stmt) error {
+func (ses *Session) Evaluate(ctx context.Context, stmt sql.Stmt) (Rows, error) {
 	stmt.Resolve(ses)
 
 	switch stmt := stmt.(type) {
 	case *sql.Begin:
 		if ses.tx != nil {
-			return fmt.Errorf("execute: begin: session %d already has active transaction", ses.id)
+			return nil, fmt.Errorf("execute: begin: session %d already has active transaction",
+				ses.id)
+		}
+		tx, err := ses.begin(stmt)
+		if err != nil {
+			return nil, err
 		}
-		ses.tx = ses.eng.Begin()
-		return nil
+		ses.tx = tx
+		ses.readOnly = stmt.Modes.ReadOnly || stmt.AsOf != ""
+		ses.stmts = 0
+		ses.savepoints = nil
+		ses.constraints = constraintModes{}
+		return nil, nil
 	case *sql.Commit:
 		if ses.tx == nil {
-			return fmt.Errorf("execute: commit: session %d does not have active transaction",
+			return nil, fmt.Errorf("execute: commit: session %d does not have active transaction",
 				ses.id)
 		}
-		err := ses.tx.Commit(ctx)
+		checks := ses.deferred
+		ses.deferred = nil
+		ses.savepoints = nil
+		ses.constraints = constraintModes{}
+		err := checkForeignKeys(ctx, ses.tx, checks)
+		if err != nil {
+			ses.tx.Rollback()
+			ses.tx = nil
+			return nil, err
+		}
+		err = ses.tx.Commit(ctx)
 		ses.tx = nil
-		return err
+		return nil, err
 	case *sql.CreateDatabase:
 		if ses.tx != nil {
-			return fmt.Errorf(
+			return nil, fmt.Errorf(
 				"execute: create database: session %d must not have active transaction", ses.id)
 		}
 
-		return ses.eng.CreateDatabase(stmt.Database, stmt.Options)
+		return nil, ses.eng.CreateDatabase(stmt.Database, stmt.Options)
 	case *sql.DropDatabase:
 		if ses.tx != nil {
-			return fmt.Errorf(
+			return nil, fmt.Errorf(
 				"execute: drop database: session %d must not have active transaction", ses.id)
 		}
 
-		return ses.eng.DropDatabase(stmt.Database, stmt.IfExists)
+		return nil, ses.eng.DropDatabase(stmt.Database, stmt.IfExists)
 	case *sql.Rollback:
 		if ses.tx == nil {
-			return fmt.Errorf("execute: rollback: session %d does not have active transaction",
-				ses.id)
+			return nil, fmt.Errorf(
+				"execute: rollback: session %d does not have active transaction", ses.id)
 		}
 		err := ses.tx.Rollback()
 		ses.tx = nil
-		return err
+		ses.deferred = nil
+		ses.savepoints = nil
+		ses.constraints = constraintModes{}
+		return nil, err
+	case *sql.Savepoint:
+		if ses.tx == nil {
+			return nil, fmt.Errorf(
+				"execute: savepoint: session %d does not have active transaction", ses.id)
+		}
+		ses.savepoints = append(ses.savepoints,
+			savepoint{
+				name:        stmt.Name,
+				sp:          ses.tx.Savepoint(),
+				deferred:    len(ses.deferred),
+				constraints: ses.constraints.copy(),
+			})
+		return nil, nil
+	case *sql.RollbackTo:
+		if ses.tx == nil {
+			return nil, fmt.Errorf(
+				"execute: rollback to savepoint: session %d does not have active transaction",
+				ses.id)
+		}
+		idx := ses.findSavepoint(stmt.Savepoint)
+		if idx < 0 {
+			return nil, fmt.Errorf("execute: rollback to savepoint: savepoint not found: %s",
+				stmt.Savepoint)
+		}
+
+		// The savepoint is kept, but later savepoints are released.
+		sp := ses.savepoints[idx]
+		ses.savepoints = ses.savepoints[:idx+1]
+		ses.deferred = ses.deferred[:sp.deferred]
+		ses.constraints = sp.constraints.copy()
+		return nil, ses.tx.RollbackTo(sp.sp)
+	case *sql.Release:
+		if ses.tx == nil {
+			return nil, fmt.Errorf(
+				"execute: release savepoint: session %d does not have active transaction", ses.id)
+		}
+		idx := ses.findSavepoint(stmt.Savepoint)
+		if idx < 0 {
+			return nil, fmt.Errorf("execute: release savepoint: savepoint not found: %s",
+				stmt.Savepoint)
+		}
+		ses.savepoints = ses.savepoints[:idx]
+		return nil, nil
 	case *sql.Set:
-		return ses.set(stmt.Variable, stmt.Value)
+		return nil, ses.set(stmt.Variable, stmt.Value)
+	case *sql.SetTransaction:
+		if ses.tx == nil {
+			return nil, fmt.Errorf(
+				"execute: set transaction: session %d does not have active transaction", ses.id)
+		} else if ses.stmts > 0 {
+			return nil, fmt.Errorf("execute: set transaction: session %d: must be before any query",
+				ses.id)
+		}
+		return nil, ses.setTransaction(stmt.Modes)
+	case *sql.SetConstraints:
+		if ses.tx == nil {
+			return nil, fmt.Errorf(
+				"execute: set constraints: session %d does not have active transaction", ses.id)
+		}
+		return nil, ses.setConstraints(ctx, stmt)
 	}
 
-	if ses.tx == nil {
-		tx := ses.eng.Begin()
-		err := Evaluate(ctx, tx, stmt)
+	var rows Rows
+	err := ses.withTransaction(ctx,
+		func(tx engine.Transaction) error {
+			switch stmt.(type) {
+			case *sql.Select, *sql.Show, *sql.Values:
+				var err error
+				rows, err = query(ctx, &planContext{ses: ses, tx: tx}, stmt)
+				return err
+			case *sql.InsertValues, *sql.Update, *sql.Delete:
+				var err error
+				rows, err = evaluateModify(ctx, &planContext{ses: ses, tx: tx}, stmt)
+				return err
+			}
+
+			return evaluate(ctx, &planContext{ses: ses, tx: tx}, stmt)
+		})
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (ses *Session) withTransaction(ctx context.Context,
+	fn func(tx engine.Transaction) error) error {
+
+	if ses.tx != nil {
+		ses.tx.NextStmt()
+		ses.stmts += 1
+
+		// Statements are atomic: if a statement fails, any changes it made are undone, but the
+		// transaction continues.
+		sp := ses.tx.Savepoint()
+		deferred := len(ses.deferred)
+		err := fn(ses.tx)
 		if err != nil {
-			tx.Rollback()
-			return err
+			ses.tx.RollbackTo(sp)
+			ses.deferred = ses.deferred[:deferred]
 		}
-		return tx.Commit(ctx)
+		return err
 	}
 
-	return Evaluate(ctx, ses.tx, stmt)
+	tx := ses.eng.Begin()
+	err := fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// findSavepoint returns the index of the most recent savepoint with name, or -1.
+func (ses *Session) findSavepoint(name types.Identifier) int {
+	for idx := len(ses.savepoints) - 1; idx >= 0; idx -= 1 {
+		if ses.savepoints[idx].name == name {
+			return idx
+		}
+	}
+	return -1
 }
 
 func (ses *Session) set(id types.Identifier, val string) error {
@@ -110,6 +451,8 @@ func (ses *Session) set(id types.Identifier, val string) error {
 		ses.defaultDatabase = types.ID(val, false)
 	} else if id == types.SCHEMA {
 		ses.defaultSchema = types.ID(val, false)
+	} else if cfg := ses.eng.Config(); cfg != nil {
+		return cfg.Set(id.String(), val)
 	} else {
 		return fmt.Errorf("evaluate: set: %s not found", id)
 	}
@@ -6,13 +6,19 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/leftmike/maho/config"
 	"github.com/leftmike/maho/engine"
 	"github.com/leftmike/maho/evaluate"
+	"github.com/leftmike/maho/parser"
 	"github.com/leftmike/maho/parser/sql"
 	"github.com/leftmike/maho/storage"
+	"github.com/leftmike/maho/storage/basic"
 	"github.com/leftmike/maho/testutil"
 	"github.com/leftmike/maho/types"
 )
@@ -68,13 +74,13 @@ func TestSessionResolve(t *testing.T) {
 	ctx := context.Background()
 
 	stmt := mustParse("set database = 'db'")
-	err := ses.Evaluate(ctx, stmt)
+	_, err := ses.Evaluate(ctx, stmt)
 	if err != nil {
 		t.Errorf("Evaluate(%s) failed with %s", stmt, err)
 	}
 
 	stmt = mustParse("set schema = 'test'")
-	err = ses.Evaluate(ctx, stmt)
+	_, err = ses.Evaluate(ctx, stmt)
 	if err != nil {
 		t.Errorf("Evaluate(%s) failed with %s", stmt, err)
 	}
@@ -120,6 +126,57 @@ func TestSessionBegin(t *testing.T) {
 			stmt:  mustParse("rollback"),
 			trace: "Rollback()",
 		},
+		{
+			stmt: mustParse("set transaction isolation level serializable"),
+			fail: true,
+		},
+		{
+			stmt:  mustParse("begin"),
+			trace: "Begin()",
+		},
+		{
+			stmt:  mustParse("set transaction isolation level repeatable read"),
+			trace: "SetIsolation(snapshot)",
+		},
+		{
+			stmt: mustParse("create schema sn1"),
+			trace: `Savepoint()
+CreateSchema(maho.sn1)`,
+		},
+		{
+			stmt: mustParse("set transaction isolation level serializable"),
+			fail: true,
+		},
+		{
+			stmt:  mustParse("savepoint sp1"),
+			trace: "Savepoint()",
+		},
+		{
+			stmt: mustParse("drop schema sn1"),
+			fail: true,
+			trace: `Savepoint()
+DropSchema(maho.sn1, false)
+RollbackTo()`,
+		},
+		{
+			stmt:  mustParse("rollback to savepoint sp1"),
+			trace: "RollbackTo()",
+		},
+		{
+			stmt: mustParse("release savepoint sp1"),
+		},
+		{
+			stmt: mustParse("rollback to savepoint sp1"),
+			fail: true,
+		},
+		{
+			stmt:  mustParse("commit"),
+			trace: "Commit()",
+		},
+		{
+			stmt: mustParse("savepoint sp1"),
+			fail: true,
+		},
 		{
 			stmt: mustParse("set database = 'db'"),
 		},
@@ -136,6 +193,47 @@ Commit()`,
 DropSchema(db.sn2, false)
 Rollback()`,
 		},
+		{
+			stmt: mustParse("begin read only isolation level read committed"),
+			trace: `BeginReadOnly()
+SetIsolation(read committed)`,
+		},
+		{
+			stmt: mustParse("set transaction read write"),
+			fail: true,
+		},
+		{
+			stmt:  mustParse("rollback"),
+			trace: "Rollback()",
+		},
+		{
+			stmt:  mustParse("begin transaction read write"),
+			trace: "Begin()",
+		},
+		{
+			stmt:  mustParse("set transaction read only"),
+			trace: "SetReadOnly()",
+		},
+		{
+			stmt:  mustParse("commit"),
+			trace: "Commit()",
+		},
+		{
+			stmt:  mustParse("begin as of system time '-10s'"),
+			trace: "BeginReadOnly(as of)",
+		},
+		{
+			stmt:  mustParse("commit"),
+			trace: "Commit()",
+		},
+		{
+			stmt: mustParse("begin read write as of system time '-10s'"),
+			fail: true,
+		},
+		{
+			stmt: mustParse("begin as of system time 'yesterday'"),
+			fail: true,
+		},
 	}
 
 	var buf bytes.Buffer
@@ -147,7 +245,8 @@ Rollback()`,
 	ctx := context.Background()
 	for _, c := range cases {
 		err, panicked := testutil.ErrorPanicked(func() error {
-			return ses.Evaluate(ctx, c.stmt)
+			_, err := ses.Evaluate(ctx, c.stmt)
+			return err
 		})
 		if panicked {
 			if !c.panicked {
@@ -204,7 +303,8 @@ func TestSessionEvaluate(t *testing.T) {
 
 	for _, c := range cases {
 		err, panicked := testutil.ErrorPanicked(func() error {
-			return ses.Evaluate(ctx, c.stmt)
+			_, err := ses.Evaluate(ctx, c.stmt)
+			return err
 		})
 		if panicked {
 			if !c.panicked {
@@ -251,6 +351,10 @@ func (eng sesEngine) ListDatabases() ([]types.Identifier, error) {
 	return nil, nil
 }
 
+func (eng sesEngine) Config() *config.Config {
+	return nil
+}
+
 func (eng sesEngine) Begin() engine.Transaction {
 	fmt.Fprintln(eng.trace, "Begin()")
 
@@ -259,6 +363,18 @@ func (eng sesEngine) Begin() engine.Transaction {
 	}
 }
 
+func (eng sesEngine) BeginReadOnly(asOf time.Time) (engine.Transaction, error) {
+	if asOf.IsZero() {
+		fmt.Fprintln(eng.trace, "BeginReadOnly()")
+	} else {
+		fmt.Fprintln(eng.trace, "BeginReadOnly(as of)")
+	}
+
+	return sesTx{
+		trace: eng.trace,
+	}, nil
+}
+
 func (tx sesTx) Commit(ctx context.Context) error {
 	fmt.Fprintln(tx.trace, "Commit()")
 	return nil
@@ -269,6 +385,28 @@ func (tx sesTx) Rollback() error {
 	return nil
 }
 
+func (tx sesTx) NextStmt() {}
+
+func (tx sesTx) SetIsolation(level storage.IsolationLevel) error {
+	fmt.Fprintf(tx.trace, "SetIsolation(%s)\n", level)
+	return nil
+}
+
+func (tx sesTx) SetReadOnly() error {
+	fmt.Fprintln(tx.trace, "SetReadOnly()")
+	return nil
+}
+
+func (tx sesTx) Savepoint() storage.Savepoint {
+	fmt.Fprintln(tx.trace, "Savepoint()")
+	return nil
+}
+
+func (tx sesTx) RollbackTo(sp storage.Savepoint) error {
+	fmt.Fprintln(tx.trace, "RollbackTo()")
+	return nil
+}
+
 func (tx sesTx) CreateSchema(ctx context.Context, sn types.SchemaName) error {
 	fmt.Fprintf(tx.trace, "CreateSchema(%s)\n", sn)
 	return nil
@@ -290,7 +428,8 @@ func (tx sesTx) OpenTable(ctx context.Context, tn types.TableName) (engine.Table
 }
 
 func (tx sesTx) CreateTable(ctx context.Context, tn types.TableName, colNames []types.Identifier,
-	colTypes []types.ColumnType, primary []types.ColumnKey) error {
+	colTypes []types.ColumnType, primary []types.ColumnKey, colDefaults []sql.Expr,
+	constraints []engine.Constraint, identities []engine.IdentityColumn) error {
 
 	fmt.Fprintf(tx.trace, "CreateTable(%s, %v, %v, %v)\n", tn, colNames, colTypes, primary)
 	return nil
@@ -306,10 +445,55 @@ func (tx sesTx) ListTables(ctx context.Context, sn types.SchemaName) ([]types.Id
 	return nil, nil
 }
 
+func (tx sesTx) RenameTable(ctx context.Context, tn, ntn types.TableName) error {
+	fmt.Fprintf(tx.trace, "RenameTable(%s, %s)\n", tn, ntn)
+	return nil
+}
+
+func (tx sesTx) AddColumn(ctx context.Context, tn types.TableName, col types.Identifier,
+	ct types.ColumnType, dflt sql.Expr, val types.Value) error {
+
+	fmt.Fprintf(tx.trace, "AddColumn(%s, %s)\n", tn, col)
+	return nil
+}
+
+func (tx sesTx) DropColumn(ctx context.Context, tn types.TableName, col types.Identifier) error {
+	fmt.Fprintf(tx.trace, "DropColumn(%s, %s)\n", tn, col)
+	return nil
+}
+
+func (tx sesTx) AlterColumn(ctx context.Context, tn types.TableName, col, nam types.Identifier,
+	ct types.ColumnType, dflt sql.Expr) error {
+
+	fmt.Fprintf(tx.trace, "AlterColumn(%s, %s)\n", tn, col)
+	return nil
+}
+
+func (tx sesTx) AddConstraint(ctx context.Context, tn types.TableName,
+	con engine.Constraint) error {
+
+	fmt.Fprintf(tx.trace, "AddConstraint(%s, %s)\n", tn, con.Name)
+	return nil
+}
+
+func (tx sesTx) DropConstraint(ctx context.Context, tn types.TableName,
+	cn types.Identifier) error {
+
+	fmt.Fprintf(tx.trace, "DropConstraint(%s, %s)\n", tn, cn)
+	return nil
+}
+
+func (tx sesTx) AddForeignKey(ctx context.Context, tn types.TableName,
+	fk engine.ForeignKey) error {
+
+	fmt.Fprintf(tx.trace, "AddForeignKey(%s, %s)\n", tn, fk.Name)
+	return nil
+}
+
 func (tx sesTx) CreateIndex(ctx context.Context, tn types.TableName, in types.Identifier,
-	key []types.ColumnKey) error {
+	key []types.ColumnKey, unique bool) error {
 
-	fmt.Fprintf(tx.trace, "CreateIndex(%s, %s, %v)\n", tn, in, key)
+	fmt.Fprintf(tx.trace, "CreateIndex(%s, %s, %v, %v)\n", tn, in, key, unique)
 	return nil
 }
 
@@ -317,3 +501,2213 @@ func (tx sesTx) DropIndex(ctx context.Context, tn types.TableName, in types.Iden
 	fmt.Fprintf(tx.trace, "DropIndex(%s, %s)\n", tn, in)
 	return nil
 }
+
+func (tx sesTx) CreateSequence(ctx context.Context, sqn types.TableName,
+	seq engine.Sequence) error {
+
+	fmt.Fprintf(tx.trace, "CreateSequence(%s)\n", sqn)
+	return nil
+}
+
+func (tx sesTx) DropSequence(ctx context.Context, sqn types.TableName, ifExists bool) error {
+	fmt.Fprintf(tx.trace, "DropSequence(%s)\n", sqn)
+	return nil
+}
+
+func (tx sesTx) LookupSequence(ctx context.Context, sqn types.TableName) (engine.Sequence,
+	error) {
+
+	fmt.Fprintf(tx.trace, "LookupSequence(%s)\n", sqn)
+	return engine.Sequence{}, nil
+}
+
+func (tx sesTx) AlterSequence(ctx context.Context, sqn types.TableName,
+	seq engine.Sequence) error {
+
+	fmt.Fprintf(tx.trace, "AlterSequence(%s)\n", sqn)
+	return nil
+}
+
+func (tx sesTx) SetValue(ctx context.Context, sqn types.TableName, val int64,
+	called bool) error {
+
+	fmt.Fprintf(tx.trace, "SetValue(%s, %d)\n", sqn, val)
+	return nil
+}
+
+func (tx sesTx) RestartSequence(ctx context.Context, sqn types.TableName, val int64) error {
+	fmt.Fprintf(tx.trace, "RestartSequence(%s, %d)\n", sqn, val)
+	return nil
+}
+
+func (tx sesTx) NextValue(ctx context.Context, sqn types.TableName) (int64, error) {
+	fmt.Fprintf(tx.trace, "NextValue(%s)\n", sqn)
+	return 0, nil
+}
+
+func newSession(t *testing.T) *evaluate.Session {
+	t.Helper()
+
+	return newConfigSession(t, nil)
+}
+
+func newConfigSession(t *testing.T, cfg *config.Config) *evaluate.Session {
+	t.Helper()
+
+	s := t.TempDir()
+	store, err := basic.NewStore(s)
+	if err != nil {
+		t.Fatalf("NewStore(%s) failed with %s", s, err)
+	}
+	err = engine.Init(store)
+	if err != nil {
+		t.Fatalf("Init() failed with %s", err)
+	}
+
+	return evaluate.NewSession(engine.NewEngine(store, cfg), types.MAHO, types.PUBLIC)
+}
+
+func allRows(t *testing.T, rows evaluate.Rows) []types.Row {
+	t.Helper()
+
+	ctx := context.Background()
+	var all []types.Row
+	for {
+		row, err := rows.Next(ctx)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Next() failed with %s", err)
+		}
+		all = append(all, row)
+	}
+	err := rows.Close(ctx)
+	if err != nil {
+		t.Fatalf("Close() failed with %s", err)
+	}
+	return all
+}
+
+type queryCase struct {
+	sql  string
+	cols []types.Identifier
+	rows []types.Row
+	fail bool
+}
+
+func testQuery(t *testing.T, ses *evaluate.Session, cases []queryCase) {
+	t.Helper()
+
+	ctx := context.Background()
+	for _, c := range cases {
+		rows, err := ses.Evaluate(ctx, mustParse(c.sql))
+		if c.fail {
+			if err == nil {
+				t.Errorf("Evaluate(%s) did not fail", c.sql)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("Evaluate(%s) failed with %s", c.sql, err)
+			continue
+		}
+
+		if rows == nil {
+			if c.rows != nil {
+				t.Errorf("Evaluate(%s) did not return rows", c.sql)
+			}
+			continue
+		}
+		if c.cols != nil && !reflect.DeepEqual(rows.Columns(), c.cols) {
+			t.Errorf("Evaluate(%s).Columns() got %v want %v", c.sql, rows.Columns(), c.cols)
+		}
+		got := allRows(t, rows)
+		if !reflect.DeepEqual(got, c.rows) {
+			t.Errorf("Evaluate(%s) got %v want %v", c.sql, got, c.rows)
+		}
+	}
+}
+
+func TestSessionShow(t *testing.T) {
+	ses := newSession(t)
+	testQuery(t, ses,
+		[]queryCase{
+			{sql: "create schema test"},
+			{sql: "create table t1 (c1 int primary key, c2 text default 'abc')"},
+			{sql: "create table test.t2 (c3 bool)"},
+			{
+				sql:  "show database",
+				cols: []types.Identifier{types.DATABASE},
+				rows: []types.Row{{types.StringValue("maho")}},
+			},
+			{
+				sql:  "show schema",
+				rows: []types.Row{{types.StringValue("public")}},
+			},
+			{
+				sql: "show databases",
+				rows: []types.Row{
+					{types.StringValue("maho")},
+					{types.StringValue("system")},
+				},
+			},
+			{
+				sql: "show schemas",
+				rows: []types.Row{
+					{types.StringValue("public")},
+					{types.StringValue("test")},
+				},
+			},
+			{
+				sql:  "show schemas from system",
+				rows: []types.Row{{types.StringValue("info")}},
+			},
+			{
+				sql: "show tables",
+				cols: []types.Identifier{
+					types.ID("schema_name", false),
+					types.ID("table_name", false),
+				},
+				rows: []types.Row{{types.StringValue("public"), types.StringValue("t1")}},
+			},
+			{
+				sql:  "show tables from test",
+				rows: []types.Row{{types.StringValue("test"), types.StringValue("t2")}},
+			},
+			{
+				sql: "show columns from test.t2",
+				rows: []types.Row{
+					{types.StringValue("test"), types.StringValue("t2"), types.Int64Value(1),
+						types.StringValue("c3"), types.StringValue("BOOL"),
+						types.BoolValue(false), nil},
+				},
+			},
+			{
+				sql: "show constraints from t1",
+				rows: []types.Row{
+					{types.StringValue("public"), types.StringValue("t1"),
+						types.StringValue("primary"), types.StringValue("PRIMARY KEY"),
+						types.StringValue("(c1)")},
+				},
+			},
+			{
+				sql:  "show constraints from test.t2",
+				rows: nil,
+			},
+			{
+				sql:  "show tables from not_schema",
+				rows: nil,
+			},
+			{
+				sql:  "show tables from not_db.public",
+				fail: true,
+			},
+			{
+				sql:  "show not_a_variable",
+				fail: true,
+			},
+		})
+}
+
+func TestSessionSelect(t *testing.T) {
+	ses := newSession(t)
+	testQuery(t, ses,
+		[]queryCase{
+			{
+				sql: "select 1 + 2 as three, 'abc' || 'def', 5 / 2, -(2.5 * 2)",
+				cols: []types.Identifier{types.ID("three", false), types.ID("expr2", false),
+					types.ID("expr3", false), types.ID("expr4", false)},
+				rows: []types.Row{
+					{types.Int64Value(3), types.StringValue("abcdef"), types.Int64Value(2),
+						types.Float64Value(-5)},
+				},
+			},
+			{
+				sql: "select null is null, true and null, false and null, true or null, " +
+					"coalesce(null, 3), abs(-4)",
+				rows: []types.Row{
+					{types.BoolValue(true), nil, types.BoolValue(false), types.BoolValue(true),
+						types.Int64Value(3), types.Int64Value(4)},
+				},
+			},
+			{
+				sql: "values (1, 'one'), (2, 'two')",
+				cols: []types.Identifier{types.ID("column1", false),
+					types.ID("column2", false)},
+				rows: []types.Row{
+					{types.Int64Value(1), types.StringValue("one")},
+					{types.Int64Value(2), types.StringValue("two")},
+				},
+			},
+			{
+				sql: "select * from (values (1, 'one'), (3, 'three'), (2, 'two')) as v (n, s) " +
+					"where n > 1 order by n desc",
+				cols: []types.Identifier{types.ID("n", false), types.ID("s", false)},
+				rows: []types.Row{
+					{types.Int64Value(3), types.StringValue("three")},
+					{types.Int64Value(2), types.StringValue("two")},
+				},
+			},
+			{
+				sql: "select v.n from (values (1), (2), (3)) as v (n) " +
+					"where n in (select column1 from (values (2), (3)) as w)",
+				rows: []types.Row{
+					{types.Int64Value(2)},
+					{types.Int64Value(3)},
+				},
+			},
+			{
+				sql:  "select 1 / 0",
+				fail: true,
+			},
+			{
+				sql:  "select c1",
+				fail: true,
+			},
+			{
+				sql:  "select (values (1), (2))",
+				fail: true,
+			},
+		})
+}
+
+func TestSessionModify(t *testing.T) {
+	ses := newSession(t)
+	testQuery(t, ses,
+		[]queryCase{
+			{
+				sql: "create table t1 (c1 int primary key, c2 text default 'abc', " +
+					"c3 int default 1 + 2)",
+			},
+			{sql: "insert into t1 values (1, 'one', 1), (2, default, 2)"},
+			{sql: "insert into t1 (c1) values (3)"},
+			{sql: "insert into t1 (c3, c1) values (4, 4)"},
+			{
+				sql: "select * from t1",
+				rows: []types.Row{
+					{types.Int64Value(1), types.StringValue("one"), types.Int64Value(1)},
+					{types.Int64Value(2), types.StringValue("abc"), types.Int64Value(2)},
+					{types.Int64Value(3), types.StringValue("abc"), types.Int64Value(3)},
+					{types.Int64Value(4), types.StringValue("abc"), types.Int64Value(4)},
+				},
+			},
+			{sql: "update t1 set c2 = 'def', c3 = c3 * 10 where c1 > 2"},
+			{sql: "update t1 set c2 = default, c3 = default where c1 = 1"},
+			{
+				sql: "select * from t1",
+				rows: []types.Row{
+					{types.Int64Value(1), types.StringValue("abc"), types.Int64Value(3)},
+					{types.Int64Value(2), types.StringValue("abc"), types.Int64Value(2)},
+					{types.Int64Value(3), types.StringValue("def"), types.Int64Value(30)},
+					{types.Int64Value(4), types.StringValue("def"), types.Int64Value(40)},
+				},
+			},
+			{sql: "create table t4 (c1 int primary key)"},
+			{sql: "insert into t4 values (3)"},
+			{sql: "delete from t1 where c2 = 'def' and c1 in (select c1 from t4)"},
+			{
+				sql: "select c1 from t1",
+				rows: []types.Row{
+					{types.Int64Value(1)},
+					{types.Int64Value(2)},
+					{types.Int64Value(4)},
+				},
+			},
+			{sql: "delete from t1 where c2 = 'def'"},
+			{
+				sql: "select c1 from t1",
+				rows: []types.Row{
+					{types.Int64Value(1)},
+					{types.Int64Value(2)},
+				},
+			},
+			{sql: "alter table t1 alter column c2 set default 'ghi'"},
+			{sql: "insert into t1 (c1) values (5)"},
+			{
+				sql:  "select c2 from t1 where c1 = 5",
+				rows: []types.Row{{types.StringValue("ghi")}},
+			},
+			{sql: "delete from t1"},
+			{
+				sql:  "select * from t1",
+				rows: nil,
+			},
+			{
+				sql:  "insert into t1 (c1, c4) values (6, 6)",
+				fail: true,
+			},
+			{
+				sql:  "insert into t1 (c1, c2) values (6)",
+				fail: true,
+			},
+			{
+				sql:  "insert into t1 (c1, c2) select c1 from t4",
+				fail: true,
+			},
+			{
+				sql:  "update t1 set c4 = 1",
+				fail: true,
+			},
+			{
+				sql:  "update t1 set c2 = 'a', c2 = 'b'",
+				fail: true,
+			},
+			{
+				sql:  "delete from t1 where c4 = 1",
+				fail: true,
+			},
+			{sql: "create table t2 (c1 int primary key, c2 text default now())"},
+			{sql: "insert into t2 (c1) values (1)"},
+			{
+				sql:  "select c2 is null from t2",
+				rows: []types.Row{{types.BoolValue(false)}},
+			},
+			{sql: "create table t3 (c1 int primary key, c2 int default nextval('seq'))"},
+			{
+				sql:  "insert into t3 (c1) values (1)",
+				fail: true,
+			},
+			{sql: "create table t5 (c1 int, c2 text)"},
+			{sql: "insert into t5 values (1, 'one'), (2, 'two'), (1, 'one')"},
+			{sql: "delete from t5"},
+			{sql: "select * from t5"},
+			{sql: "insert into t5 values (3, 'three')"},
+			{
+				sql:  "select * from t5",
+				rows: []types.Row{{types.Int64Value(3), types.StringValue("three")}},
+			},
+		})
+}
+
+func TestSessionConstraints(t *testing.T) {
+	ses := newSession(t)
+	testQuery(t, ses,
+		[]queryCase{
+			{
+				sql: "create table t1 (c1 int primary key check (c1 > 0), " +
+					"c2 text constraint c2_nn not null, c3 int, check (c3 < 100))",
+			},
+			{sql: "insert into t1 values (1, 'one', 10), (2, 'two', null)"},
+			{
+				sql:  "insert into t1 values (0, 'zero', 0)",
+				fail: true,
+			},
+			{
+				sql:  "insert into t1 values (3, null, 30)",
+				fail: true,
+			},
+			{
+				sql:  "insert into t1 values (4, 'four', 100)",
+				fail: true,
+			},
+			{
+				sql:  "update t1 set c3 = c3 * 10",
+				fail: true,
+			},
+			{
+				sql:  "update t1 set c2 = null where c1 = 1",
+				fail: true,
+			},
+			{sql: "update t1 set c3 = c3 + 1"},
+			{
+				sql: "select * from t1",
+				rows: []types.Row{
+					{types.Int64Value(1), types.StringValue("one"), types.Int64Value(11)},
+					{types.Int64Value(2), types.StringValue("two"), nil},
+				},
+			},
+			{
+				sql: "show constraints from t1",
+				rows: []types.Row{
+					{types.StringValue("public"), types.StringValue("t1"),
+						types.StringValue("primary"), types.StringValue("PRIMARY KEY"),
+						types.StringValue("(c1)")},
+					{types.StringValue("public"), types.StringValue("t1"),
+						types.StringValue("c1_check"), types.StringValue("CHECK"),
+						types.StringValue("(c1 > 0)")},
+					{types.StringValue("public"), types.StringValue("t1"),
+						types.StringValue("c2_nn"), types.StringValue("NOT NULL"),
+						types.StringValue("(c2)")},
+					{types.StringValue("public"), types.StringValue("t1"),
+						types.StringValue("t1_check"), types.StringValue("CHECK"),
+						types.StringValue("(c3 < 100)")},
+				},
+			},
+			{
+				sql:  "alter table t1 add constraint c3_small check (c3 < 10)",
+				fail: true,
+			},
+			{
+				sql:  "alter table t1 add constraint c1_check check (c1 < 10)",
+				fail: true,
+			},
+			{
+				sql:  "alter table t1 add check (c1 in (select c3 from t1))",
+				fail: true,
+			},
+			{sql: "alter table t1 add constraint c1_small check (c1 < 10)"},
+			{
+				sql:  "insert into t1 values (10, 'ten', 10)",
+				fail: true,
+			},
+			{sql: "alter table t1 drop constraint c1_small"},
+			{sql: "insert into t1 values (10, 'ten', 10)"},
+			{
+				sql:  "alter table t1 drop constraint c1_small",
+				fail: true,
+			},
+			{sql: "alter table t1 drop constraint if exists c1_small"},
+			{sql: "alter table t1 drop constraint c2_nn"},
+			{sql: "insert into t1 values (11, null, 11)"},
+			{sql: "alter table t1 rename column c3 to c4"},
+			{
+				sql:  "insert into t1 values (12, 'twelve', 120)",
+				fail: true,
+			},
+			{
+				sql: "show constraints from t1",
+				rows: []types.Row{
+					{types.StringValue("public"), types.StringValue("t1"),
+						types.StringValue("primary"), types.StringValue("PRIMARY KEY"),
+						types.StringValue("(c1)")},
+					{types.StringValue("public"), types.StringValue("t1"),
+						types.StringValue("c1_check"), types.StringValue("CHECK"),
+						types.StringValue("(c1 > 0)")},
+					{types.StringValue("public"), types.StringValue("t1"),
+						types.StringValue("t1_check"), types.StringValue("CHECK"),
+						types.StringValue("(c4 < 100)")},
+				},
+			},
+			{sql: "alter table t1 drop column c4"},
+			{
+				sql: "show constraints from t1",
+				rows: []types.Row{
+					{types.StringValue("public"), types.StringValue("t1"),
+						types.StringValue("primary"), types.StringValue("PRIMARY KEY"),
+						types.StringValue("(c1)")},
+					{types.StringValue("public"), types.StringValue("t1"),
+						types.StringValue("c1_check"), types.StringValue("CHECK"),
+						types.StringValue("(c1 > 0)")},
+				},
+			},
+			{
+				sql:  "insert into t1 values (null, 'null')",
+				fail: true,
+			},
+			{
+				sql:  "alter table t1 alter c1 drop not null",
+				fail: true,
+			},
+			{sql: "create table t5 (c1 int, c2 int, c3 int, primary key (c1, c2))"},
+			{
+				sql:  "insert into t5 values (3, null, 3)",
+				fail: true,
+			},
+			{sql: "insert into t5 values (1, 2, null)"},
+			{
+				sql:  "update t5 set c2 = null where c1 = 1",
+				fail: true,
+			},
+			{
+				sql: "select column_name, not_null from metadata.columns " +
+					"where table_name = 't5'",
+				rows: []types.Row{
+					{types.StringValue("c1"), types.BoolValue(true)},
+					{types.StringValue("c2"), types.BoolValue(true)},
+					{types.StringValue("c3"), types.BoolValue(false)},
+				},
+			},
+		})
+}
+
+func TestSessionForeignKeys(t *testing.T) {
+	ses := newSession(t)
+	testQuery(t, ses,
+		[]queryCase{
+			{sql: "create table p1 (c1 int primary key, c2 text)"},
+			{sql: "insert into p1 values (1, 'one'), (2, 'two'), (3, 'three'), (4, 'four')"},
+			{
+				sql: "create table c1 (c1 int primary key, c2 int references p1, " +
+					"c3 int, constraint c3_fk foreign key (c3) references p1 (c1) " +
+					"on delete cascade on update cascade)",
+			},
+			{sql: "insert into c1 values (10, 1, 1), (20, 2, 2), (30, null, 3)"},
+			{
+				sql:  "insert into c1 values (40, 5, 1)",
+				fail: true,
+			},
+			{
+				sql:  "insert into c1 values (40, 1, 5)",
+				fail: true,
+			},
+			{
+				sql:  "update c1 set c2 = 5 where c1 = 10",
+				fail: true,
+			},
+			{
+				sql:  "update p1 set c1 = 100 where c1 = 1",
+				fail: true,
+			},
+			{sql: "delete from p1 where c1 = 4"},
+			{sql: "update p1 set c1 = 30 where c1 = 3"},
+			{sql: "delete from p1 where c1 = 2"},
+			{
+				sql: "select * from c1",
+				rows: []types.Row{
+					{types.Int64Value(10), types.Int64Value(1), types.Int64Value(1)},
+					{types.Int64Value(30), nil, types.Int64Value(30)},
+				},
+			},
+			{
+				sql: "show constraints from c1",
+				rows: []types.Row{
+					{types.StringValue("public"), types.StringValue("c1"),
+						types.StringValue("primary"), types.StringValue("PRIMARY KEY"),
+						types.StringValue("(c1)")},
+					{types.StringValue("public"), types.StringValue("c1"),
+						types.StringValue("c1_c2_fkey"), types.StringValue("FOREIGN KEY"),
+						types.StringValue("(c2) REFERENCES maho.public.p1")},
+					{types.StringValue("public"), types.StringValue("c1"),
+						types.StringValue("c3_fk"), types.StringValue("FOREIGN KEY"),
+						types.StringValue("(c3) REFERENCES maho.public.p1")},
+				},
+			},
+			{
+				sql: "create table c2 (c1 int primary key, c2 int default 1 references p1 " +
+					"on delete set default, c3 int references p1 on delete set null)",
+			},
+			{sql: "insert into p1 values (5, 'five')"},
+			{sql: "insert into c2 values (1, 5, 5), (2, 30, 30)"},
+			{sql: "delete from c1 where c1 = 30"},
+			{sql: "delete from p1 where c1 = 5"},
+			{
+				sql: "select * from c2",
+				rows: []types.Row{
+					{types.Int64Value(1), types.Int64Value(1), nil},
+					{types.Int64Value(2), types.Int64Value(30), types.Int64Value(30)},
+				},
+			},
+			{
+				sql: "create table c3 (c1 int primary key, c2 int references p1 " +
+					"on delete restrict)",
+			},
+			{sql: "insert into c3 values (1, 30)"},
+			{
+				sql:  "delete from p1 where c1 = 30",
+				fail: true,
+			},
+			{sql: "alter table c3 drop constraint c3_c2_fkey"},
+			{sql: "insert into c3 values (2, 100)"},
+			{
+				sql:  "alter table c3 add foreign key (c2) references p1",
+				fail: true,
+			},
+			{sql: "delete from c3 where c1 = 2"},
+			{sql: "alter table c3 add foreign key (c2) references p1"},
+			{
+				sql:  "insert into c3 values (2, 100)",
+				fail: true,
+			},
+			{
+				sql:  "drop index c3_c2_fkey on c3",
+				fail: true,
+			},
+			{
+				sql: "create table t1 (c1 int primary key, c2 int references t1 " +
+					"on delete cascade)",
+			},
+			{sql: "insert into t1 values (1, null), (2, 1), (3, 2), (4, null)"},
+			{
+				sql:  "insert into t1 values (5, 6)",
+				fail: true,
+			},
+			{sql: "delete from t1 where c1 < 3"},
+			{
+				sql: "select * from t1",
+				rows: []types.Row{
+					{types.Int64Value(4), nil},
+				},
+			},
+			{
+				sql:  "create table c4 (c1 int references p1 (c2))",
+				fail: true,
+			},
+			{
+				sql:  "create table c4 (c1 int, c2 int, foreign key (c1, c2) references p1)",
+				fail: true,
+			},
+			{
+				sql:  "create table c4 (c1 text references p1)",
+				fail: true,
+			},
+		})
+}
+
+func TestSessionDeferredForeignKeys(t *testing.T) {
+	ses := newSession(t)
+	testQuery(t, ses,
+		[]queryCase{
+			{sql: "create table p1 (c1 int primary key)"},
+			{
+				sql: "create table c1 (c1 int primary key, c2 int references p1 " +
+					"deferrable initially deferred)",
+			},
+			{sql: "begin"},
+			{sql: "insert into c1 values (1, 10)"},
+			{sql: "insert into p1 values (10)"},
+			{sql: "commit"},
+			{sql: "begin"},
+			{sql: "insert into c1 values (2, 20)"},
+			{
+				sql:  "commit",
+				fail: true,
+			},
+			{
+				sql: "select * from c1",
+				rows: []types.Row{
+					{types.Int64Value(1), types.Int64Value(10)},
+				},
+			},
+			{
+				sql:  "insert into c1 values (2, 20)",
+				fail: true,
+			},
+			{sql: "alter table p1 rename to p2"},
+			{sql: "begin"},
+			{sql: "delete from p2"},
+			{sql: "rollback"},
+			{
+				sql:  "delete from p2",
+				fail: true,
+			},
+			{
+				sql: "show constraints from c1",
+				rows: []types.Row{
+					{types.StringValue("public"), types.StringValue("c1"),
+						types.StringValue("primary"), types.StringValue("PRIMARY KEY"),
+						types.StringValue("(c1)")},
+					{types.StringValue("public"), types.StringValue("c1"),
+						types.StringValue("c1_c2_fkey"), types.StringValue("FOREIGN KEY"),
+						types.StringValue("(c2) REFERENCES maho.public.p2")},
+				},
+			},
+			{sql: "create table c2 (c1 int primary key, c2 int references p2)"},
+			{sql: "set constraints all deferred", fail: true},
+			{sql: "begin"},
+			{sql: "insert into c2 values (1, 30)", fail: true},
+			{sql: "set constraints all deferred"},
+			{sql: "insert into c2 values (1, 30)"},
+			{sql: "insert into p2 values (30)"},
+			{sql: "insert into c2 values (2, 40)"},
+			{sql: "set constraints c2_c2_fkey immediate", fail: true},
+			{sql: "delete from c2 where c1 = 2"},
+			{sql: "set constraints c2_c2_fkey immediate"},
+			{sql: "insert into c2 values (3, 50)", fail: true},
+			{sql: "commit"},
+			{
+				sql: "select * from c2",
+				rows: []types.Row{
+					{types.Int64Value(1), types.Int64Value(30)},
+				},
+			},
+			{sql: "begin"},
+			{sql: "insert into c2 values (4, 60)", fail: true},
+			{sql: "set constraints c2_c2_fkey deferred"},
+			{sql: "insert into c2 values (4, 60)"},
+			{sql: "commit", fail: true},
+			{sql: "begin"},
+			{sql: "set constraints all immediate"},
+			{sql: "insert into c1 values (5, 70)", fail: true},
+			{sql: "rollback"},
+			{sql: "begin"},
+			{sql: "set constraints all deferred"},
+			{sql: "rollback"},
+			{sql: "begin"},
+			{sql: "insert into c2 values (6, 90)", fail: true},
+			{sql: "set constraints c2_missing_fkey deferred", fail: true},
+			{sql: "insert into c2 values (6, 90)", fail: true},
+			{sql: "savepoint sp1"},
+			{sql: "set constraints c2_c2_fkey deferred"},
+			{sql: "insert into c2 values (6, 90)"},
+			{sql: "rollback to savepoint sp1"},
+			{sql: "insert into c2 values (6, 90)", fail: true},
+			{sql: "set constraints all deferred"},
+			{sql: "savepoint sp2"},
+			{sql: "set constraints c2_c2_fkey immediate"},
+			{sql: "rollback to sp2"},
+			{sql: "insert into c2 values (7, 100)"},
+			{sql: "rollback to sp1"},
+			{sql: "commit"},
+			{
+				sql: "select * from c2",
+				rows: []types.Row{
+					{types.Int64Value(1), types.Int64Value(30)},
+				},
+			},
+		})
+}
+
+func TestSessionSequences(t *testing.T) {
+	ses := newSession(t)
+	testQuery(t, ses,
+		[]queryCase{
+			{
+				sql:  "select nextval('seq')",
+				fail: true,
+			},
+			{sql: "create sequence seq start 10 increment by 10 maxvalue 40"},
+			{
+				sql:  "create sequence seq",
+				fail: true,
+			},
+			{sql: "create sequence if not exists seq"},
+			{
+				sql:  "select currval('seq')",
+				fail: true,
+			},
+			{
+				sql:  "select nextval('seq')",
+				rows: []types.Row{{types.Int64Value(10)}},
+			},
+			{
+				sql:  "select currval('seq')",
+				rows: []types.Row{{types.Int64Value(10)}},
+			},
+			{sql: "begin"},
+			{
+				sql:  "select nextval('maho.public.seq')",
+				rows: []types.Row{{types.Int64Value(20)}},
+			},
+			{sql: "rollback"},
+			{
+				sql:  "select nextval('public.seq')",
+				rows: []types.Row{{types.Int64Value(30)}},
+			},
+			{
+				sql:  "select nextval('seq')",
+				rows: []types.Row{{types.Int64Value(40)}},
+			},
+			{
+				sql:  "select nextval('seq')",
+				fail: true,
+			},
+			{sql: "alter sequence seq cycle minvalue 5"},
+			{
+				sql:  "select nextval('seq')",
+				rows: []types.Row{{types.Int64Value(5)}},
+			},
+			{
+				sql:  "select setval('seq', 20)",
+				rows: []types.Row{{types.Int64Value(20)}},
+			},
+			{
+				sql:  "select currval('seq'), nextval('seq')",
+				rows: []types.Row{{types.Int64Value(20), types.Int64Value(30)}},
+			},
+			{
+				sql:  "select setval('seq', 25, false)",
+				rows: []types.Row{{types.Int64Value(25)}},
+			},
+			{
+				sql:  "select currval('seq'), nextval('seq')",
+				rows: []types.Row{{types.Int64Value(30), types.Int64Value(25)}},
+			},
+			{
+				sql:  "select setval('seq', 100)",
+				fail: true,
+			},
+			{sql: "alter sequence seq restart"},
+			{
+				sql:  "select nextval('seq')",
+				rows: []types.Row{{types.Int64Value(10)}},
+			},
+			{sql: "alter sequence seq restart with 6"},
+			{
+				sql:  "select nextval('seq')",
+				rows: []types.Row{{types.Int64Value(6)}},
+			},
+			{
+				sql:  "alter sequence seq minvalue 50",
+				fail: true,
+			},
+			{
+				sql:  "alter sequence seq increment 0",
+				fail: true,
+			},
+			{
+				sql:  "alter sequence seq2 restart",
+				fail: true,
+			},
+			{sql: "alter sequence if exists seq2 restart"},
+			{sql: "create sequence seq2 increment by -1"},
+			{
+				sql:  "select nextval('seq2'), nextval('seq2')",
+				rows: []types.Row{{types.Int64Value(-1), types.Int64Value(-2)}},
+			},
+			{
+				sql:  "create sequence seq3 start 0",
+				fail: true,
+			},
+			{sql: "create table t1 (c1 int primary key default nextval('seq2'), c2 int)"},
+			{sql: "insert into t1 (c2) values (1), (2)"},
+			{
+				sql: "select * from t1",
+				rows: []types.Row{
+					{types.Int64Value(-4), types.Int64Value(2)},
+					{types.Int64Value(-3), types.Int64Value(1)},
+				},
+			},
+			{sql: "create schema s2"},
+			{sql: "set schema = 's2'"},
+			{sql: "insert into public.t1 (c2) values (3)"},
+			{sql: "alter table public.t1 alter column c2 set default nextval('seq2')"},
+			{sql: "insert into public.t1 (c1) values (4)"},
+			{sql: "set schema = 'public'"},
+			{
+				sql: "select * from t1 where c2 >= 3 or c1 = 4",
+				rows: []types.Row{
+					{types.Int64Value(-5), types.Int64Value(3)},
+					{types.Int64Value(4), types.Int64Value(-6)},
+				},
+			},
+			{
+				sql:  "alter table t1 add c3 int default nextval('seq2')",
+				fail: true,
+			},
+			{sql: "create table t6 (c1 int primary key)"},
+			{sql: "alter table t6 add c2 int default nextval('seq2')"},
+			{sql: "insert into t6 (c1) values (1), (2)"},
+			{
+				sql: "select * from t6",
+				rows: []types.Row{
+					{types.Int64Value(1), types.Int64Value(-7)},
+					{types.Int64Value(2), types.Int64Value(-8)},
+				},
+			},
+			{sql: "drop sequence seq, seq2"},
+			{
+				sql:  "select nextval('seq')",
+				fail: true,
+			},
+			{
+				sql:  "drop sequence seq",
+				fail: true,
+			},
+			{sql: "drop sequence if exists seq"},
+			{sql: "create sequence seq"},
+			{
+				sql:  "select nextval('seq')",
+				rows: []types.Row{{types.Int64Value(1)}},
+			},
+		})
+}
+
+func TestSessionConfig(t *testing.T) {
+	cfg := config.NewConfig(nil)
+	var b bool
+	var i int64
+	var s string
+	cfg.Bool(&b, "test_bool", true, "usage")
+	cfg.Int(&i, "test_int", 123, "usage").Hide()
+	cfg.String(&s, "test_string", "abc", "usage").NoSet()
+
+	ses := newConfigSession(t, cfg)
+	testQuery(t, ses,
+		[]queryCase{
+			{
+				sql: "show config",
+				cols: []types.Identifier{types.ID("name", false), types.ID("value", false),
+					types.BY},
+				rows: []types.Row{
+					{types.StringValue("test_bool"), types.StringValue("true"),
+						types.StringValue("default")},
+					{types.StringValue("test_string"), types.StringValue("abc"),
+						types.StringValue("default")},
+				},
+			},
+			{sql: "set test_bool = false"},
+			{sql: "set test_int to 456"},
+			{
+				sql:  "set test_string = 'def'",
+				fail: true,
+			},
+			{
+				sql:  "set test_int = 'abc'",
+				fail: true,
+			},
+			{
+				sql:  "set test_unknown = 'abc'",
+				fail: true,
+			},
+			{
+				sql:  "show test_int",
+				rows: []types.Row{{types.StringValue("456")}},
+			},
+			{
+				sql: "select name, value, hidden from system.info.config " +
+					"where name != 'test_string'",
+				rows: []types.Row{
+					{types.StringValue("test_bool"), types.StringValue("false"),
+						types.BoolValue(false)},
+					{types.StringValue("test_int"), types.StringValue("456"),
+						types.BoolValue(true)},
+				},
+			},
+			{
+				sql: "show config",
+				rows: []types.Row{
+					{types.StringValue("test_bool"), types.StringValue("false"),
+						types.StringValue("set")},
+					{types.StringValue("test_string"), types.StringValue("abc"),
+						types.StringValue("default")},
+				},
+			},
+		})
+
+	if b || i != 456 || s != "abc" {
+		t.Errorf("config got %v, %d, %s", b, i, s)
+	}
+}
+
+func TestSessionIdentityColumns(t *testing.T) {
+	ses := newSession(t)
+	testQuery(t, ses,
+		[]queryCase{
+			{sql: "create table t1 (c1 int, c2 text)"},
+			{sql: "insert into t1 values (1, 'one'), (2, 'two')"},
+			{sql: "insert into t1 values (3, 'three')"},
+			{
+				sql: "select * from t1",
+				rows: []types.Row{
+					{types.Int64Value(1), types.StringValue("one")},
+					{types.Int64Value(2), types.StringValue("two")},
+					{types.Int64Value(3), types.StringValue("three")},
+				},
+			},
+			{sql: "create table t2 (c1 serial primary key, c2 text)"},
+			{sql: "insert into t2 (c2) values ('one'), ('two')"},
+			{sql: "insert into t2 values (default, 'three')"},
+			{sql: "insert into t2 values (10, 'ten')"},
+			{
+				sql:  "insert into t2 (c1) values (null)",
+				fail: true,
+			},
+			{
+				sql: "select * from t2",
+				rows: []types.Row{
+					{types.Int64Value(1), types.StringValue("one")},
+					{types.Int64Value(2), types.StringValue("two")},
+					{types.Int64Value(3), types.StringValue("three")},
+					{types.Int64Value(10), types.StringValue("ten")},
+				},
+			},
+			{
+				sql:  "select currval('t2_c1_seq')",
+				rows: []types.Row{{types.Int64Value(3)}},
+			},
+			{sql: "create sequence t3_c1_seq"},
+			{
+				sql:  "create table t3 (c1 serial, c2 int)",
+				fail: true,
+			},
+			{sql: "drop sequence t3_c1_seq"},
+			{
+				sql: `create table t3 (c1 smallint generated always as identity (start with 32766),
+c2 int)`,
+			},
+			{sql: "insert into t3 (c2) values (1), (2)"},
+			{
+				sql:  "insert into t3 (c2) values (3)",
+				fail: true,
+			},
+			{
+				sql:  "insert into t3 values (1, 4)",
+				fail: true,
+			},
+			{
+				sql:  "update t3 set c1 = 5",
+				fail: true,
+			},
+			{
+				sql: "select * from t3",
+				rows: []types.Row{
+					{types.Int64Value(32766), types.Int64Value(1)},
+					{types.Int64Value(32767), types.Int64Value(2)},
+				},
+			},
+			{
+				sql: `create table t4 (c1 bigint generated by default as identity
+(increment by -1), c2 int)`,
+			},
+			{sql: "insert into t4 (c2) values (1), (2)"},
+			{sql: "insert into t4 values (100, 3)"},
+			{sql: "update t4 set c1 = default where c2 = 3"},
+			{
+				sql: "select * from t4",
+				rows: []types.Row{
+					{types.Int64Value(-1), types.Int64Value(1)},
+					{types.Int64Value(-2), types.Int64Value(2)},
+					{types.Int64Value(-3), types.Int64Value(3)},
+				},
+			},
+			{sql: "alter table t4 drop column c1"},
+			{
+				sql:  "select nextval('t4_c1_seq')",
+				fail: true,
+			},
+		})
+}
+
+func TestSessionIsolation(t *testing.T) {
+	s := t.TempDir()
+	store, err := basic.NewMVCCStore(s)
+	if err != nil {
+		t.Fatalf("NewMVCCStore(%s) failed with %s", s, err)
+	}
+	err = engine.Init(store)
+	if err != nil {
+		t.Fatalf("Init() failed with %s", err)
+	}
+	eng := engine.NewEngine(store, nil)
+	ses1 := evaluate.NewSession(eng, types.MAHO, types.PUBLIC)
+	ses2 := evaluate.NewSession(eng, types.MAHO, types.PUBLIC)
+
+	testQuery(t, ses1,
+		[]queryCase{
+			{sql: "create table doctors (name text primary key, oncall bool)"},
+			{sql: "insert into doctors values ('alice', true), ('bob', true)"},
+		})
+
+	// Each doctor goes off call if another doctor is on call.
+	for _, ses := range []*evaluate.Session{ses1, ses2} {
+		testQuery(t, ses,
+			[]queryCase{
+				{sql: "begin"},
+				{sql: "set transaction isolation level serializable"},
+				{
+					sql:  "select name from doctors where oncall",
+					rows: []types.Row{{types.StringValue("alice")}, {types.StringValue("bob")}},
+				},
+			})
+	}
+	testQuery(t, ses1,
+		[]queryCase{
+			{sql: "update doctors set oncall = false where name = 'alice'"},
+			{sql: "commit"},
+		})
+	testQuery(t, ses2,
+		[]queryCase{
+			{sql: "update doctors set oncall = false where name = 'bob'"},
+			{sql: "commit", fail: true},
+		})
+
+	testQuery(t, ses1,
+		[]queryCase{
+			{
+				sql: "select * from doctors",
+				rows: []types.Row{
+					{types.StringValue("alice"), types.BoolValue(false)},
+					{types.StringValue("bob"), types.BoolValue(true)},
+				},
+			},
+			{sql: "begin"},
+			{sql: "set transaction isolation level read committed"},
+			{
+				sql:  "select name from doctors where oncall",
+				rows: []types.Row{{types.StringValue("bob")}},
+			},
+		})
+	testQuery(t, ses2,
+		[]queryCase{
+			{sql: "update doctors set oncall = true where name = 'alice'"},
+		})
+	testQuery(t, ses1,
+		[]queryCase{
+			{
+				sql:  "select name from doctors where oncall",
+				rows: []types.Row{{types.StringValue("alice")}, {types.StringValue("bob")}},
+			},
+			{sql: "set transaction isolation level serializable", fail: true},
+			{sql: "commit"},
+		})
+}
+
+func TestSessionPredicates(t *testing.T) {
+	ses := newSession(t)
+	testQuery(t, ses,
+		[]queryCase{
+			{sql: "create table t (c1 int primary key, c2 int, c3 text, c4 int)"},
+			{
+				sql: `insert into t values (1, 10, 'one', 10), (2, 20, NULL, 30),
+(3, NULL, 'three', 30), (4, 40, 'four', NULL), (5, 50, 'five', 40)`,
+			},
+			{
+				sql:  "select c1 from t where c2 > 20",
+				rows: testutil.MustParseRows("(4), (5)"),
+			},
+			{
+				sql:  "select c1 from t where 20 >= c2",
+				rows: testutil.MustParseRows("(1), (2)"),
+			},
+			{
+				sql:  "select c1 from t where not c2 > 20",
+				rows: testutil.MustParseRows("(1), (2)"),
+			},
+			{
+				sql:  "select c1 from t where c2 < c4",
+				rows: testutil.MustParseRows("(2)"),
+			},
+			{
+				sql:  "select c1 from t where c2 = c4 or c3 = 'five'",
+				rows: testutil.MustParseRows("(1), (5)"),
+			},
+			{
+				sql:  "select c1 from t where c3 is null",
+				rows: testutil.MustParseRows("(2)"),
+			},
+			{
+				sql:  "select c1 from t where (c2 is not null) and (c4 is not null)",
+				rows: testutil.MustParseRows("(1), (2), (5)"),
+			},
+			{
+				sql:  "select c1 from t where c4 in (values (30), (40))",
+				rows: testutil.MustParseRows("(2), (3), (5)"),
+			},
+			{
+				sql:  "select c1 from t where c4 not in (values (30))",
+				rows: testutil.MustParseRows("(1), (5)"),
+			},
+			{
+				sql: "select c1 from t where c4 not in (values (30), (NULL))",
+			},
+			{
+				sql:  "select c1 from t where c1 > 1 and c2 + 10 = c4",
+				rows: testutil.MustParseRows("(2)"),
+			},
+			{
+				sql:  "select c1 from t where c1 >= 3 and abs(c2) < 45",
+				rows: testutil.MustParseRows("(4)"),
+			},
+			{sql: "select c1 from t where c3 > 10", fail: true},
+		})
+}
+
+// scanEngine records the specs of the scans of the tables opened by its transactions.
+type scanEngine struct {
+	engine.Engine
+	scans *[]storage.ScanSpec
+}
+
+type scanTx struct {
+	engine.Transaction
+	scans *[]storage.ScanSpec
+}
+
+type scanTable struct {
+	engine.Table
+	scans *[]storage.ScanSpec
+}
+
+func (se scanEngine) Begin() engine.Transaction {
+	return scanTx{Transaction: se.Engine.Begin(), scans: se.scans}
+}
+
+func (se scanEngine) BeginReadOnly(asOf time.Time) (engine.Transaction, error) {
+	tx, err := se.Engine.BeginReadOnly(asOf)
+	if err != nil {
+		return nil, err
+	}
+	return scanTx{Transaction: tx, scans: se.scans}, nil
+}
+
+func (stx scanTx) OpenTable(ctx context.Context, tn types.TableName) (engine.Table, error) {
+	tbl, err := stx.Transaction.OpenTable(ctx, tn)
+	if err != nil {
+		return nil, err
+	}
+	return scanTable{Table: tbl, scans: stx.scans}, nil
+}
+
+func (st scanTable) Scan(ctx context.Context, cols []types.ColumnNum, spec storage.ScanSpec,
+	pred storage.Predicate) (storage.Rows, error) {
+
+	*st.scans = append(*st.scans, spec)
+	return st.Table.Scan(ctx, cols, spec, pred)
+}
+
+func TestSessionScan(t *testing.T) {
+	s := t.TempDir()
+	store, err := basic.NewStore(s)
+	if err != nil {
+		t.Fatalf("NewStore(%s) failed with %s", s, err)
+	}
+	err = engine.Init(store)
+	if err != nil {
+		t.Fatalf("Init() failed with %s", err)
+	}
+	var scans []storage.ScanSpec
+	ses := evaluate.NewSession(scanEngine{Engine: engine.NewEngine(store, nil), scans: &scans},
+		types.MAHO, types.PUBLIC)
+
+	testQuery(t, ses,
+		[]queryCase{
+			{sql: "create table t1 (c1 int primary key, c2 text)"},
+			{sql: "create table t2 (c1 int, c2 int, primary key (c1 desc))"},
+			{
+				sql: `insert into t1 values (1, 'one'), (2, 'two'), (3, 'three'), (4, 'four'),
+(5, 'five'), (6, 'six')`,
+			},
+			{sql: "insert into t2 values (1, 10), (2, 20), (3, 30)"},
+		})
+
+	key := func(n int64) types.Row {
+		return types.Row{types.Int64Value(n), nil}
+	}
+	cases := []struct {
+		sql   string
+		rows  []types.Row
+		scans []storage.ScanSpec
+	}{
+		{
+			sql:   "select c1 from t1 where c1 > 4",
+			rows:  testutil.MustParseRows("(5), (6)"),
+			scans: []storage.ScanSpec{{MinRow: key(4), MinExclusive: true, Prefix: 1}},
+		},
+		{
+			sql:   "select c1 from t1 where 2 >= c1",
+			rows:  testutil.MustParseRows("(1), (2)"),
+			scans: []storage.ScanSpec{{MaxRow: key(2), Prefix: 1}},
+		},
+		{
+			sql:   "select c2 from t1 where c1 = 3",
+			rows:  testutil.MustParseRows("('three')"),
+			scans: []storage.ScanSpec{{MinRow: key(3), MaxRow: key(3), Prefix: 1}},
+		},
+		{
+			sql:   "select c1 from t1 order by c1 desc",
+			rows:  testutil.MustParseRows("(6), (5), (4), (3), (2), (1)"),
+			scans: []storage.ScanSpec{{Reverse: true}},
+		},
+		{
+			sql:  "select * from t1 where c1 >= 2 and c1 < 5 and c2 <> 'three' order by c1 desc",
+			rows: testutil.MustParseRows("(4, 'four'), (2, 'two')"),
+			scans: []storage.ScanSpec{
+				{
+					MinRow:       key(2),
+					MaxRow:       key(5),
+					MaxExclusive: true,
+					Prefix:       1,
+					Reverse:      true,
+				},
+			},
+		},
+		{
+			sql:   "select c1 from t1 where c1 > 4 order by c1",
+			rows:  testutil.MustParseRows("(5), (6)"),
+			scans: []storage.ScanSpec{{MinRow: key(4), MinExclusive: true, Prefix: 1}},
+		},
+		{
+			sql:  "select c1 from t1 where c2 > 'six'",
+			rows: testutil.MustParseRows("(2), (3)"),
+		},
+		{
+			sql: "select c1, c2 from t1 order by c2 desc",
+			rows: testutil.MustParseRows(
+				"(2, 'two'), (3, 'three'), (6, 'six'), (1, 'one'), (4, 'four'), (5, 'five')"),
+		},
+		{
+			sql:   "select c1 from t2 order by c1",
+			rows:  testutil.MustParseRows("(1), (2), (3)"),
+			scans: []storage.ScanSpec{{Reverse: true}},
+		},
+		{
+			sql:   "select c1 from t2 order by c1 desc",
+			rows:  testutil.MustParseRows("(3), (2), (1)"),
+			scans: []storage.ScanSpec{{}},
+		},
+		{
+			sql:  "select c1 from t2 where c1 > 1",
+			rows: testutil.MustParseRows("(3), (2)"),
+		},
+		{
+			sql:   "select * from metadata.tables order by schema_name desc, table_name desc",
+			rows:  testutil.MustParseRows("('public', 't2'), ('public', 't1')"),
+			scans: []storage.ScanSpec{{Reverse: true}},
+		},
+	}
+
+	for _, c := range cases {
+		scans = nil
+		testQuery(t, ses, []queryCase{{sql: c.sql, rows: c.rows}})
+		if !reflect.DeepEqual(scans, c.scans) {
+			t.Errorf("Evaluate(%s) scans got %v want %v", c.sql, scans, c.scans)
+		}
+	}
+}
+
+func TestSessionInsertSelect(t *testing.T) {
+	ses := newSession(t)
+	testQuery(t, ses,
+		[]queryCase{
+			{sql: "create table t1 (c1 int primary key, c2 text, c3 int default 3)"},
+			{sql: "insert into t1 values (1, 'one', 10), (2, 'two', 20)"},
+			{sql: "create table t2 (c1 serial primary key, c2 int, c3 text)"},
+			{sql: "insert into t2 (c2, c3) select c1, c2 from t1"},
+			{
+				sql:  "select * from t2",
+				rows: testutil.MustParseRows("(1, 1, 'one'), (2, 2, 'two')"),
+			},
+			{sql: "insert into t1 (c1, c2) select c1 + 10, c3 from t2 where c1 > 1"},
+			{
+				sql: "select * from t1",
+				rows: testutil.MustParseRows(
+					"(1, 'one', 10), (2, 'two', 20), (12, 'two', 3)"),
+			},
+			{sql: "insert into t1 select c1 + 100 from t1"},
+			{
+				sql: "select * from t1",
+				rows: testutil.MustParseRows(`(1, 'one', 10), (2, 'two', 20), (12, 'two', 3),
+(101, NULL, 3), (102, NULL, 3), (112, NULL, 3)`),
+			},
+			{sql: "insert into t1 (c1) select c1, c2 from t2", fail: true},
+			{sql: "insert into t1 select c1, c3 from t2", fail: true},
+			{sql: "insert into t1 (c1) select c2 from t2 where c1 = 1", fail: true},
+			{sql: "create table t3 (c1 int generated always as identity, c2 int)"},
+			{sql: "insert into t3 (c1) select c1 from t1", fail: true},
+			{sql: "insert into t3 (c2) select c1 from t1 where c1 < 10"},
+			{sql: "select * from t3", rows: testutil.MustParseRows("(1, 1), (2, 2)")},
+		})
+}
+
+func TestSessionOnConflict(t *testing.T) {
+	ses := newSession(t)
+	testQuery(t, ses,
+		[]queryCase{
+			{sql: "create table t1 (c1 int primary key, c2 int, c3 text)"},
+			{sql: "create unique index t1_c2 on t1 (c2)"},
+			{sql: "insert into t1 values (1, 10, 'one'), (2, 20, 'two')"},
+			{sql: "insert into t1 values (3, 10, 'three')", fail: true},
+			{sql: "insert into t1 values (3, 30, 'three'), (4, 30, 'four')", fail: true},
+			{sql: "insert into t1 values (3, NULL, 'three'), (4, NULL, 'four')"},
+			{sql: "update t1 set c2 = 20 where c1 = 3", fail: true},
+			{sql: "update t1 set c2 = 30 where c1 = 3"},
+			{
+				sql: "select * from t1",
+				rows: testutil.MustParseRows(
+					"(1, 10, 'one'), (2, 20, 'two'), (3, 30, 'three'), (4, NULL, 'four')"),
+			},
+			{sql: "insert into t1 values (1, 40, 'ONE') on conflict do nothing"},
+			{sql: "insert into t1 values (5, 20, 'five') on conflict do nothing"},
+			{
+				sql: "insert into t1 values (5, 50, 'five'), (1, 11, 'ONE') " +
+					"on conflict (c1) do nothing",
+			},
+			{sql: "insert into t1 values (6, 10, 'six') on conflict (c1) do nothing", fail: true},
+			{sql: "insert into t1 values (6, 60, 'six') on conflict (c3) do nothing", fail: true},
+			{sql: "insert into t1 values (6, 60, 'six') on conflict (c4) do nothing", fail: true},
+			{
+				sql:  "insert into t1 values (6, 60, 'six') on conflict do update set c3 = 'x'",
+				fail: true,
+			},
+			{
+				sql: "select * from t1",
+				rows: testutil.MustParseRows(`(1, 10, 'one'), (2, 20, 'two'), (3, 30, 'three'),
+(4, NULL, 'four'), (5, 50, 'five')`),
+			},
+			{
+				sql: "insert into t1 values (1, 11, 'ONE'), (6, 60, 'six') " +
+					"on conflict (c1) do update set c2 = excluded.c2, c3 = t1.c3 || excluded.c3",
+			},
+			{
+				sql: "insert into t1 values (7, 20, 'seven') " +
+					"on conflict (c2) do update set c3 = excluded.c3 where t1.c1 > 2",
+			},
+			{
+				sql: "insert into t1 values (7, 30, 'seven') " +
+					"on conflict (c2) do update set c3 = excluded.c3 where t1.c1 > 2",
+			},
+			{
+				sql: "insert into t1 values (8, 11, 'eight') " +
+					"on conflict (c1) do update set c2 = 0",
+				fail: true,
+			},
+			{
+				sql: "insert into t1 values (5, 0, 'five') " +
+					"on conflict (c1) do update set c2 = 60",
+				fail: true,
+			},
+			{
+				sql: "insert into t1 values (5, 0, 'five') " +
+					"on conflict (c1) do update set c2 = c2 + 1",
+				fail: true,
+			},
+			{
+				sql: "select * from t1",
+				rows: testutil.MustParseRows(`(1, 11, 'oneONE'), (2, 20, 'two'),
+(3, 30, 'seven'), (4, NULL, 'four'), (5, 50, 'five'), (6, 60, 'six')`),
+			},
+			{sql: "create table t2 (c1 int, c2 int)"},
+			{sql: "insert into t2 values (1, 1), (1, 1)"},
+			{sql: "create unique index t2_c1 on t2 (c1)", fail: true},
+			{sql: "create unique index t2_c2 on t2 (c2, c1)", fail: true},
+			{sql: "delete from t2"},
+			{sql: "create unique index t2_c1 on t2 (c1)"},
+			{sql: "insert into t2 values (1, 1), (2, 2)"},
+			{sql: "insert into t2 select c1, c2 + 10 from t1 where c1 < 4 on conflict do nothing"},
+			{
+				sql: "insert into t2 values (2, 20) " +
+					"on conflict (c1) do update set c2 = excluded.c2 + t2.c2",
+			},
+			{
+				sql:  "select * from t2",
+				rows: testutil.MustParseRows("(1, 1), (2, 22), (3, 40)"),
+			},
+			{sql: "create table t3 (id int primary key, c2 text unique, c3 int)"},
+			{sql: "insert into t3 values (1, 'a', 1), (2, 'a', 2)", fail: true},
+			{sql: "insert into t3 values (1, 'a', 1), (2, 'b', 2)"},
+			{sql: "insert into t3 values (3, 'b', 3)", fail: true},
+			{sql: "update t3 set c2 = 'a' where id = 2", fail: true},
+			{
+				sql: "insert into t3 values (6, 'q', 1), (6, 'r', 2) " +
+					"on conflict (id) do update set c3 = excluded.c3",
+				fail: true,
+			},
+			{
+				sql: "insert into t3 values (1, 'x', 10), (1, 'y', 20) " +
+					"on conflict (id) do update set c3 = excluded.c3",
+				fail: true,
+			},
+			{
+				sql: "insert into t3 values (4, 'b', 20), (5, 'c', 30) " +
+					"on conflict (c2) do update set c3 = excluded.c3",
+			},
+			{
+				sql: "insert into t3 values (6, 'd', 40), (7, 'd', 50) " +
+					"on conflict (c2) do nothing",
+			},
+			{
+				sql: "select * from t3",
+				rows: testutil.MustParseRows(
+					"(1, 'a', 1), (2, 'b', 20), (5, 'c', 30), (6, 'd', 40)"),
+			},
+			{sql: "create table t4 (c1 int, c2 int, unique (c1, c2))"},
+			{sql: "insert into t4 values (1, 1), (1, 2), (1, NULL), (1, NULL)"},
+			{sql: "insert into t4 values (1, 2)", fail: true},
+			{sql: "create table t5 (c1 int, unique (c2))", fail: true},
+		})
+}
+
+func TestSessionReturning(t *testing.T) {
+	ses := newSession(t)
+	testQuery(t, ses,
+		[]queryCase{
+			{sql: "create table t1 (c1 serial primary key, c2 int, c3 text default 'abc')"},
+			{
+				sql:  "insert into t1 (c2) values (10), (20) returning c1, c3",
+				cols: []types.Identifier{types.ID("c1", false), types.ID("c3", false)},
+				rows: testutil.MustParseRows("(1, 'abc'), (2, 'abc')"),
+			},
+			{
+				sql: "insert into t1 (c2, c3) values (30, 'def') returning *",
+				cols: []types.Identifier{
+					types.ID("c1", false), types.ID("c2", false), types.ID("c3", false),
+				},
+				rows: testutil.MustParseRows("(3, 30, 'def')"),
+			},
+			{sql: "insert into t1 (c2) values (40)"},
+			{
+				sql:  "update t1 set c2 = c2 + 1 where c1 > 2 returning c1 as id, c2 * 2",
+				cols: []types.Identifier{types.ID("id", false), types.ID("expr2", false)},
+				rows: testutil.MustParseRows("(3, 62), (4, 82)"),
+			},
+			{sql: "update t1 set c2 = 0 where c1 > 10 returning c1"},
+			{
+				sql:  "delete from t1 where c2 < 30 returning t1.*",
+				rows: testutil.MustParseRows("(1, 10, 'abc'), (2, 20, 'abc')"),
+			},
+			{
+				sql:  "select * from t1",
+				rows: testutil.MustParseRows("(3, 31, 'def'), (4, 41, 'abc')"),
+			},
+			{
+				sql: "insert into t1 values (3, 30, 'ghi'), (5, 50, 'ghi') " +
+					"on conflict (c1) do update set c3 = excluded.c3 returning c1, c2, c3",
+				rows: testutil.MustParseRows("(3, 31, 'ghi'), (5, 50, 'ghi')"),
+			},
+			{
+				sql: "insert into t1 values (3, 30, 'jkl'), (6, 60, 'jkl') " +
+					"on conflict do nothing returning c1",
+				rows: testutil.MustParseRows("(6)"),
+			},
+			{sql: "insert into t1 (c2) values (70) returning c4", fail: true},
+			{sql: "update t1 set c2 = 0 returning t2.*", fail: true},
+			{sql: "delete from t1 where c1 = 6 returning t2.c1", fail: true},
+			{
+				sql: "select * from t1",
+				rows: testutil.MustParseRows(
+					"(3, 31, 'ghi'), (4, 41, 'abc'), (5, 50, 'ghi'), (6, 60, 'jkl')"),
+			},
+			{
+				sql:  "delete from t1 returning c1",
+				rows: testutil.MustParseRows("(3), (4), (5), (6)"),
+			},
+			{sql: "select * from t1"},
+		})
+}
+
+func TestSessionJoin(t *testing.T) {
+	ses := newSession(t)
+	testQuery(t, ses,
+		[]queryCase{
+			{sql: "create table t1 (c1 int primary key, c2 text)"},
+			{sql: "insert into t1 values (1, 'one'), (2, 'two'), (3, 'three')"},
+			{sql: "create table t2 (c1 int primary key, c3 int)"},
+			{sql: "insert into t2 values (2, 20), (3, 30), (4, 40)"},
+			{
+				sql: "select * from t1, t2 where t1.c1 = 1",
+				rows: testutil.MustParseRows(
+					"(1, 'one', 2, 20), (1, 'one', 3, 30), (1, 'one', 4, 40)"),
+			},
+			{
+				sql:  "select t1.c1, c3 from t1 join t2 on t1.c1 = t2.c1",
+				rows: testutil.MustParseRows("(2, 20), (3, 30)"),
+			},
+			{
+				sql:  "select t1.c1, c3 from t1 left join t2 on t1.c1 = t2.c1",
+				rows: testutil.MustParseRows("(1, NULL), (2, 20), (3, 30)"),
+			},
+			{
+				sql:  "select t1.c1, c3 from t1 right join t2 on t1.c1 = t2.c1",
+				rows: testutil.MustParseRows("(2, 20), (3, 30), (NULL, 40)"),
+			},
+			{
+				sql:  "select t1.c1, t2.c1 from t1 full join t2 on t1.c1 = t2.c1",
+				rows: testutil.MustParseRows("(1, NULL), (2, 2), (3, 3), (NULL, 4)"),
+			},
+			{
+				sql:  "select a.c1, b.c1 from t1 as a cross join t1 as b where a.c1 > b.c1",
+				rows: testutil.MustParseRows("(2, 1), (3, 1), (3, 2)"),
+			},
+			{sql: "select c1 from t1, t2", fail: true},
+			{sql: "select * from t1 join t2 using (c1)", fail: true},
+		})
+}
+
+func TestSessionUpdateFrom(t *testing.T) {
+	ses := newSession(t)
+	testQuery(t, ses,
+		[]queryCase{
+			{sql: "create table t1 (c1 int primary key, c2 int, c3 text)"},
+			{sql: "insert into t1 values (1, 10, 'one'), (2, 20, 'two'), (3, 30, 'three')"},
+			{sql: "create table t2 (c1 int primary key, c2 int)"},
+			{sql: "insert into t2 values (1, 100), (3, 300), (4, 400)"},
+			{sql: "update t1 set c2 = t2.c2 from t2 where t1.c1 = t2.c1"},
+			{
+				sql: "select * from t1",
+				rows: testutil.MustParseRows(
+					"(1, 100, 'one'), (2, 20, 'two'), (3, 300, 'three')"),
+			},
+			{
+				sql: "update t1 set c3 = 'x' || t2.c2 from t2 where t1.c1 = t2.c1 - 1 " +
+					"returning t1.c1, t1.c3, t2.*",
+				rows: testutil.MustParseRows("(2, 'x300', 3, 300), (3, 'x400', 4, 400)"),
+			},
+			{sql: "update t1 set c2 = 0 from t2 where t1.c1 = 1", fail: true},
+			{sql: "update t1 set c2 = 0 from t2 where c1 = 1", fail: true},
+			{sql: "update t1 set c4 = 0 from t2 where t1.c1 = t2.c1", fail: true},
+			{
+				sql: "update t1 set c2 = a.c2 + b.c2 from t1 as a, t2 as b " +
+					"where t1.c1 = a.c1 + 1 and b.c1 = a.c1",
+			},
+			{
+				sql: "select * from t1",
+				rows: testutil.MustParseRows(
+					"(1, 100, 'one'), (2, 200, 'x300'), (3, 300, 'x400')"),
+			},
+			{
+				sql: "update t1 set c2 = 0 from t2 where t1.c1 = t2.c1 and t2.c1 > 10",
+			},
+		})
+}
+
+func TestSessionDeleteUsing(t *testing.T) {
+	ses := newSession(t)
+	testQuery(t, ses,
+		[]queryCase{
+			{sql: "create table t1 (c1 int primary key, c2 text)"},
+			{sql: "insert into t1 values (1, 'one'), (2, 'two'), (3, 'three'), (4, 'four')"},
+			{sql: "create table t2 (c1 int primary key, c2 int)"},
+			{sql: "insert into t2 values (1, 10), (3, 30), (5, 50)"},
+			{
+				sql:  "delete from t1 using t2 where t1.c1 = t2.c1 returning t1.c1, t2.c2",
+				rows: testutil.MustParseRows("(1, 10), (3, 30)"),
+			},
+			{sql: "select * from t1", rows: testutil.MustParseRows("(2, 'two'), (4, 'four')")},
+			{sql: "delete from t1 using t2 where t1.c1 = 2", fail: true},
+			{sql: "delete from t1 using t2 where t1.c1 = t2.c1 + 10"},
+			{
+				sql: "delete from t1 using t2 as a, t2 as b " +
+					"where t1.c1 = a.c1 + 1 and a.c1 = 3 and b.c1 = 5",
+			},
+			{sql: "select * from t1", rows: testutil.MustParseRows("(2, 'two')")},
+			{sql: "delete from t1 using t2 returning *", fail: true},
+			{sql: "delete from t1 using (values (2)) as v (c1) where t1.c1 = v.c1"},
+			{sql: "select * from t1"},
+		})
+}
+
+func TestSessionCopy(t *testing.T) {
+	ses := newSession(t)
+	testQuery(t, ses,
+		[]queryCase{
+			{
+				sql: "create table t1 (c1 int primary key, c2 text, c3 bool default true, " +
+					"c4 double precision, check (c1 > 0))",
+			},
+			{sql: "copy t1 from stdin;\n1\tone\tfalse\t1.5\n2\t\\N\tt\t\\N\n\\.\n"},
+			{sql: "copy t1 (c2, c1) from stdin;\na\\tb\\\\c\t3\n\\x41\\102\\n\t4\n"},
+			{sql: "copy t1 (c1, c4) from stdin delimiter ',';\n5,2\n6,\\N\n\\.\n"},
+			{
+				sql: "select * from t1",
+				rows: testutil.MustParseRows(`(1, 'one', false, 1.5), (2, NULL, true, NULL),
+(3, 'a	b\c', true, NULL), (4, 'AB
+', true, NULL), (5, NULL, true, 2.0), (6, NULL, true, NULL)`),
+			},
+			{sql: "copy t1 from stdin;\n7\tseven\n", fail: true},
+			{sql: "copy t1 (c1) from stdin;\n7\n8\tx\n", fail: true},
+			{sql: "copy t1 (c1) from stdin;\nseven\n", fail: true},
+			{sql: "copy t1 (c1) from stdin;\n-7\n", fail: true},
+			{sql: "copy t1 (c1) from stdin;\n7\n1\n", fail: true},
+			{sql: "copy t1 (c5) from stdin;\n7\n", fail: true},
+			{sql: "copy t2 from stdin;\n7\n", fail: true},
+			{
+				sql:  "select c1 from t1",
+				rows: testutil.MustParseRows("(1), (2), (3), (4), (5), (6)"),
+			},
+			{
+				sql: "copy t1 (c1, c2, c4) from stdin with (format csv, header);\n" +
+					"c1,c2,c4\n7,\"se,\"\"ven\"\"\",\n8,\"\",3\n9,\"two\nlines\",\n\\.\n",
+			},
+			{
+				sql: "copy t1 (c1, c2) from stdin (format csv, null 'NULL', quote '''', " +
+					"escape '\\');\n10,NULL\n11,'NULL'\n12,'it\\'s\\\\'\n",
+			},
+			{
+				sql: "select c1, c2, c4 from t1 where c1 > 6",
+				rows: []types.Row{
+					{types.Int64Value(7), types.StringValue(`se,"ven"`), nil},
+					{types.Int64Value(8), types.StringValue(""), types.Float64Value(3)},
+					{types.Int64Value(9), types.StringValue("two\nlines"), nil},
+					{types.Int64Value(10), nil, nil},
+					{types.Int64Value(11), types.StringValue("NULL"), nil},
+					{types.Int64Value(12), types.StringValue(`it's\`), nil},
+				},
+			},
+			{sql: "copy t1 (c1, c2) from stdin (format csv);\n13,\"abc\n", fail: true},
+			{sql: "copy t1 from stdin (format binary);\n\\.\n", fail: true},
+		})
+}
+
+func TestSessionCopyErrors(t *testing.T) {
+	ses := newSession(t)
+	testQuery(t, ses,
+		[]queryCase{
+			{sql: "create table t1 (c1 int primary key, c2 text not null)"},
+			{sql: "create unique index t1_c2 on t1 (c2)"},
+		})
+
+	// The duplicate row is in the second batch of rows.
+	var dup strings.Builder
+	dup.WriteString("copy t1 from stdin;\n")
+	for n := 100; n < 400; n += 1 {
+		if n == 380 {
+			dup.WriteString("8\tseven\n")
+		} else {
+			fmt.Fprintf(&dup, "%d\t%d\n", n, n)
+		}
+	}
+	dup.WriteString("\\.\n")
+
+	ctx := context.Background()
+	p := parser.NewParser(strings.NewReader(`copy t1 from stdin;
+1	one
+2	two
+three	3
+4	four
+\.
+copy t1 from stdin;
+5	five
+5	FIVE
+\.
+copy t1 from stdin;
+6	\N
+\.
+copy t1 from stdin;
+7	seven
+\.
+`+dup.String()+`copy t1 from stdin;
+\N	eight
+\.
+`), "test")
+
+	cases := []string{
+		"line 4: ",
+		"line 9: ",
+		"line 12: ",
+		"",
+		"line 298: ",
+		"line 320: ",
+	}
+	for _, c := range cases {
+		stmt, err := p.Parse()
+		if err != nil {
+			t.Fatalf("Parse() failed with %s", err)
+		}
+		_, err = ses.Evaluate(ctx, stmt)
+		if c == "" {
+			if err != nil {
+				t.Errorf("Evaluate(%s) failed with %s", stmt, err)
+			}
+		} else if err == nil {
+			t.Errorf("Evaluate(%s) did not fail", stmt)
+		} else if !strings.Contains(err.Error(), c) {
+			t.Errorf("Evaluate(%s) failed with %s; want %s", stmt, err, c)
+		}
+	}
+	_, err := p.Parse()
+	if err != io.EOF {
+		t.Errorf("Parse() got %v want io.EOF", err)
+	}
+
+	testQuery(t, ses,
+		[]queryCase{
+			{sql: "select * from t1", rows: testutil.MustParseRows("(7, 'seven')")},
+		})
+}
+
+func TestSessionCopyTo(t *testing.T) {
+	ses := newSession(t)
+	testQuery(t, ses,
+		[]queryCase{
+			{sql: "copy (values (1)) to stdout", fail: true},
+			{
+				sql: "create table t1 (c1 int primary key, c2 text, c3 bool, " +
+					"c4 double precision, c5 smallint, c6 real, c7 bytea)",
+			},
+			{
+				sql: "insert into t1 values (1, 'one', true, 1.5, 10, 0.5, 'AB'), " +
+					"(2, NULL, false, NULL, NULL, NULL, NULL), " +
+					"(3, 'a\tb\\c,d\"e', NULL, -2, -3, 4, '')",
+			},
+		})
+
+	var buf bytes.Buffer
+	ses.SetCopyOutput(&buf)
+
+	cases := []struct {
+		sql  string
+		out  string
+		fail bool
+	}{
+		{
+			sql: "copy t1 to stdout",
+			out: "1\tone\tt\t1.5\t10\t0.5\t\\\\x4142\n" +
+				"2\t\\N\tf\t\\N\t\\N\t\\N\t\\N\n" +
+				"3\ta\\tb\\\\c,d\"e\t\\N\t-2\t-3\t4\t\\\\x\n",
+		},
+		{
+			sql: "copy t1 (c2, c1) to stdout with (delimiter ',', null 'NULL', header)",
+			out: "c2,c1\none,1\nNULL,2\na\\tb\\\\c\\,d\"e,3\n",
+		},
+		{
+			sql: "copy t1 (c1, c2, c3) to stdout with (format csv, header)",
+			out: "c1,c2,c3\n1,one,t\n2,,f\n3,\"a\tb\\c,d\"\"e\",\n",
+		},
+		{
+			sql: "copy (select c2, c1 from t1 where c1 < 3 order by c1 desc) to stdout " +
+				"(format csv, delimiter '|', null 'NULL', force_quote (c1))",
+			out: "NULL|\"2\"\none|\"1\"\n",
+		},
+		{
+			sql: "copy (select c1, 'NULL' as c2 from t1 where c1 = 1) to stdout " +
+				"(format csv, null 'NULL', quote '''', escape '\\', force_quote (c1))",
+			out: "'1','NULL'\n",
+		},
+		{
+			sql: "copy t1 (c1, c5, c6) to stdout (format binary)",
+			out: "PGCOPY\n\xff\r\n\x00" + "\x00\x00\x00\x00" + "\x00\x00\x00\x00" +
+				"\x00\x03" + "\x00\x00\x00\x04\x00\x00\x00\x01" +
+				"\x00\x00\x00\x02\x00\x0a" + "\x00\x00\x00\x04\x3f\x00\x00\x00" +
+				"\x00\x03" + "\x00\x00\x00\x04\x00\x00\x00\x02" +
+				"\xff\xff\xff\xff" + "\xff\xff\xff\xff" +
+				"\x00\x03" + "\x00\x00\x00\x04\x00\x00\x00\x03" +
+				"\x00\x00\x00\x02\xff\xfd" + "\x00\x00\x00\x04\x40\x80\x00\x00" +
+				"\xff\xff",
+		},
+		{sql: "copy t1 (c8) to stdout", fail: true},
+		{sql: "copy t1 to stdout (format csv, force_quote (c8))", fail: true},
+		{sql: "copy t2 to stdout", fail: true},
+	}
+
+	for _, c := range cases {
+		buf.Reset()
+		testQuery(t, ses, []queryCase{{sql: c.sql, fail: c.fail}})
+		if !c.fail && buf.String() != c.out {
+			t.Errorf("%s: got %q want %q", c.sql, buf.String(), c.out)
+		}
+	}
+
+	testQuery(t, ses,
+		[]queryCase{
+			{sql: "create table t2 (c1 int primary key, c2 bytea)"},
+			{sql: "copy t2 from stdin;\n1\t\\\\x4142\n2\t\\\\xZZ\n", fail: true},
+			{sql: "copy t2 from stdin;\n1\t\\\\x4142\n"},
+			{
+				sql:  "select * from t2",
+				rows: []types.Row{{types.Int64Value(1), types.BytesValue("AB")}},
+			},
+		})
+}
+
+func TestSessionCopyBinary(t *testing.T) {
+	ses := newSession(t)
+	testQuery(t, ses,
+		[]queryCase{
+			{
+				sql: "create table t1 (c1 int primary key, c2 text, c3 bool, " +
+					"c4 double precision, c5 smallint, c6 real, c7 bytea, c8 bigint)",
+			},
+			{
+				sql: "insert into t1 values (1, 'one', true, 1.5, 10, 0.5, 'AB', 1), " +
+					"(2, NULL, false, NULL, NULL, NULL, NULL, NULL), " +
+					"(3, 'a\tb\nc', NULL, -2, -3, 4, '', -9223372036854775807)",
+			},
+			{sql: "create table t2 (c1 int primary key, c2 text, c3 bool, " +
+				"c4 double precision, c5 smallint, c6 real, c7 bytea, c8 bigint)"},
+			{sql: "create table t3 (c1 int primary key, c5 smallint)"},
+		})
+
+	var buf bytes.Buffer
+	ses.SetCopyOutput(&buf)
+	copyTo := func(sql string) string {
+		t.Helper()
+
+		buf.Reset()
+		testQuery(t, ses, []queryCase{{sql: sql}})
+		return buf.String()
+	}
+	all := copyTo("copy t1 to stdout (format binary)")
+	some := copyTo("copy t1 (c1, c5) to stdout (format binary)")
+
+	ctx := context.Background()
+	copyFrom := func(sql, data, fail string) {
+		t.Helper()
+
+		// The statement following the data must be parsed, even if the copy fails.
+		p := parser.NewParser(strings.NewReader(sql+";\n"+data+"show database;\n"), "test")
+		stmt, err := p.Parse()
+		if err != nil {
+			t.Fatalf("Parse() failed with %s", err)
+		}
+		_, err = ses.Evaluate(ctx, stmt)
+		if fail == "" {
+			if err != nil {
+				t.Errorf("Evaluate(%s) failed with %s", stmt, err)
+			}
+		} else if err == nil {
+			t.Errorf("Evaluate(%s) did not fail", stmt)
+		} else if !strings.Contains(err.Error(), fail) {
+			t.Errorf("Evaluate(%s) failed with %s; want %s", stmt, err, fail)
+		}
+
+		stmt, err = p.Parse()
+		if err != nil {
+			t.Fatalf("Parse() failed with %s", err)
+		} else if stmt.String() != "SHOW DATABASE" {
+			t.Errorf("Parse() got %s want SHOW DATABASE", stmt)
+		}
+	}
+
+	copyFrom("copy t2 from stdin (format binary)", all, "")
+	copyFrom("copy t3 from stdin (format binary)", some, "")
+	copyFrom("copy t3 from stdin (format binary)", all, "line 1: expected 2 values, got 8")
+	copyFrom("copy t2 (c1, c3) from stdin (format binary)",
+		copyTo("copy t1 (c1, c6) to stdout (format binary)"),
+		"line 1: invalid binary BOOL value of 4 bytes")
+	copyFrom("copy t2 (c1, c2) from stdin (format binary)",
+		copyTo("copy t1 (c1, c6) to stdout (format binary)"), "line 3: invalid UTF-8")
+	copyFrom("copy t2 from stdin (format binary)", "PGCOPY\n\xff\r\n\x01",
+		"line 1: invalid binary signature")
+
+	// The data is truncated, so the rest of the input is read as data.
+	p := parser.NewParser(
+		strings.NewReader("copy t3 from stdin (format binary);\n"+some[:len(some)-5]), "test")
+	stmt, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed with %s", err)
+	}
+	_, err = ses.Evaluate(ctx, stmt)
+	if err == nil || !strings.Contains(err.Error(), "line 3: unexpected EOF") {
+		t.Errorf("Evaluate(%s) got %v want line 3: unexpected EOF", stmt, err)
+	}
+
+	testQuery(t, ses,
+		[]queryCase{
+			{
+				sql: "select * from t2",
+				rows: []types.Row{
+					{types.Int64Value(1), types.StringValue("one"), types.BoolValue(true),
+						types.Float64Value(1.5), types.Int64Value(10), types.Float64Value(0.5),
+						types.BytesValue("AB"), types.Int64Value(1)},
+					{types.Int64Value(2), nil, types.BoolValue(false), nil, nil, nil, nil, nil},
+					{types.Int64Value(3), types.StringValue("a\tb\nc"), nil,
+						types.Float64Value(-2), types.Int64Value(-3), types.Float64Value(4),
+						types.BytesValue(""), types.Int64Value(-math.MaxInt64)},
+				},
+			},
+			{
+				sql: "select * from t3",
+				rows: []types.Row{
+					{types.Int64Value(1), types.Int64Value(10)},
+					{types.Int64Value(2), nil},
+					{types.Int64Value(3), types.Int64Value(-3)},
+				},
+			},
+		})
+}
+
+func TestSessionTruncate(t *testing.T) {
+	ses := newSession(t)
+	testQuery(t, ses,
+		[]queryCase{
+			{sql: "create table t1 (c1 serial primary key, c2 text)"},
+			{sql: "create index t1_c2 on t1 (c2)"},
+			{sql: "insert into t1 (c2) values ('one'), ('two'), ('three')"},
+			{sql: "create table t2 (c1 int, c2 int)"},
+			{sql: "insert into t2 values (1, 10), (2, 20)"},
+			{sql: "begin"},
+			{sql: "truncate t1, t2"},
+			{sql: "select * from t1"},
+			{sql: "select * from t2"},
+			{sql: "rollback"},
+			{sql: "select c1 from t1", rows: testutil.MustParseRows("(1), (2), (3)")},
+			{sql: "select c1 from t2", rows: testutil.MustParseRows("(1), (2)")},
+			{sql: "truncate table t1 continue identity restrict"},
+			{sql: "insert into t1 (c2) values ('four')"},
+			{sql: "select * from t1", rows: testutil.MustParseRows("(4, 'four')")},
+			{sql: "truncate t1 restart identity"},
+			{sql: "insert into t1 (c2) values ('one')"},
+			{sql: "select * from t1", rows: testutil.MustParseRows("(1, 'one')")},
+			{sql: "begin"},
+			{sql: "insert into t1 (c2) values ('two')"},
+			{sql: "truncate t1 restart identity"},
+			{sql: "insert into t1 (c2) values ('three')"},
+			{sql: "select * from t1", rows: testutil.MustParseRows("(1, 'three')")},
+			{sql: "rollback"},
+			{sql: "insert into t1 (c2) values ('d')"},
+			{sql: "select * from t1", rows: testutil.MustParseRows("(1, 'one'), (3, 'd')")},
+			{sql: "begin"},
+			{sql: "savepoint s1"},
+			{sql: "truncate t1 restart identity"},
+			{sql: "rollback to savepoint s1"},
+			{sql: "insert into t1 (c2) values ('e')"},
+			{sql: "commit"},
+			{sql: "select * from t1",
+				rows: testutil.MustParseRows("(1, 'one'), (3, 'd'), (4, 'e')")},
+			{sql: "begin"},
+			{sql: "truncate t1 restart identity"},
+			{sql: "insert into t1 (c2) values ('f')"},
+			{sql: "commit"},
+			{sql: "insert into t1 (c2) values ('g')"},
+			{sql: "select * from t1", rows: testutil.MustParseRows("(1, 'f'), (2, 'g')")},
+			{sql: "create table p1 (c1 int primary key)"},
+			{sql: "insert into p1 values (1), (2)"},
+			{sql: "create table c1 (c1 int primary key, c2 int references p1)"},
+			{sql: "insert into c1 values (10, 1), (20, 2)"},
+			{sql: "truncate p1", fail: true},
+			{sql: "truncate c1, p1"},
+			{sql: "select * from p1"},
+			{sql: "insert into p1 values (1), (2)"},
+			{sql: "insert into c1 values (10, 1), (20, 2)"},
+			{sql: "truncate p1 cascade"},
+			{sql: "select * from p1"},
+			{sql: "select * from c1"},
+			{sql: "truncate t3", fail: true},
+		})
+}
+
+func TestSessionDropTable(t *testing.T) {
+	ses := newSession(t)
+	showTables := func(tables ...string) queryCase {
+		qc := queryCase{sql: "show tables"}
+		for _, tbl := range tables {
+			qc.rows = append(qc.rows,
+				types.Row{types.StringValue("public"), types.StringValue(tbl)})
+		}
+		return qc
+	}
+
+	testQuery(t, ses,
+		[]queryCase{
+			{sql: "create table t1 (c1 serial primary key, c2 text)"},
+			{sql: "insert into t1 (c2) values ('one'), ('two'), ('three')"},
+			{sql: "create table t2 (c1 int, c2 int)"},
+			{sql: "insert into t2 values (1, 10), (2, 20)"},
+			showTables("t1", "t2"),
+			{sql: "begin"},
+			{sql: "drop table t1"},
+			{sql: "select * from t1", fail: true},
+			{sql: "rollback"},
+			{sql: "select c1 from t1", rows: testutil.MustParseRows("(1), (2), (3)")},
+			{sql: "drop table t1, t2"},
+			{sql: "select * from t1", fail: true},
+			{sql: "select * from t2", fail: true},
+			showTables(),
+			{sql: "drop table t1", fail: true},
+			{sql: "create table t1 (c1 serial primary key, c2 text)"},
+			{sql: "create table t2 (c1 int, c2 int)"},
+			{sql: "select * from t1"},
+			{sql: "select * from t2"},
+			{sql: "insert into t1 (c2) values ('four')"},
+			{sql: "select * from t1", rows: testutil.MustParseRows("(1, 'four')")},
+			showTables("t1", "t2"),
+			{sql: "create table p1 (c1 int primary key)"},
+			{sql: "insert into p1 values (1), (2)"},
+			{sql: "create table c1 (c1 int primary key, c2 int references p1)"},
+			{sql: "insert into c1 values (10, 1), (20, 2)"},
+			{sql: "drop table p1", fail: true},
+			{sql: "drop table p1 cascade"},
+			{sql: "select * from c1", rows: testutil.MustParseRows("(10, 1), (20, 2)")},
+			{sql: "insert into c1 values (30, 3)"},
+			{sql: "create table p2 (c1 int primary key)"},
+			{sql: "create table c2 (c1 int primary key, c2 int references p2)"},
+			{sql: "drop table p2, c2"},
+			{sql: "create table p3 (c1 int primary key, c2 int references p3)"},
+			{sql: "insert into p3 values (1, 1), (2, 1)"},
+			{sql: "drop table p3"},
+			showTables("c1", "t1", "t2"),
+		})
+}
+
+func TestSessionReadOnly(t *testing.T) {
+	ses := newSession(t)
+	testQuery(t, ses,
+		[]queryCase{
+			{sql: "create sequence seq"},
+			{sql: "create table t (c1 int primary key, c2 int default nextval('seq'))"},
+			{sql: "insert into t values (1)"},
+			{sql: "begin read only"},
+			{sql: "select * from t", rows: []types.Row{{types.Int64Value(1), types.Int64Value(1)}}},
+			{sql: "insert into t values (2, 20)", fail: true},
+			{sql: "insert into t values (3)", fail: true},
+			{sql: "update t set c2 = 10", fail: true},
+			{sql: "delete from t", fail: true},
+			{sql: "create table t2 (c1 int primary key)", fail: true},
+			{sql: "truncate t", fail: true},
+			{sql: "commit"},
+			{sql: "begin as of system time '-1s'", fail: true},
+			{sql: "begin"},
+			{sql: "set transaction read only"},
+			{sql: "delete from t", fail: true},
+			{sql: "rollback"},
+			{sql: "select * from t", rows: []types.Row{{types.Int64Value(1), types.Int64Value(1)}}},
+		})
+
+	s := t.TempDir()
+	store, err := basic.NewMVCCStore(s)
+	if err != nil {
+		t.Fatalf("NewMVCCStore(%s) failed with %s", s, err)
+	}
+	err = engine.Init(store)
+	if err != nil {
+		t.Fatalf("Init() failed with %s", err)
+	}
+	ses = evaluate.NewSession(engine.NewEngine(store, nil), types.MAHO, types.PUBLIC)
+
+	testQuery(t, ses,
+		[]queryCase{
+			{sql: "create table t (c1 int primary key, c2 int)"},
+			{sql: "insert into t values (1, 10)"},
+		})
+	time.Sleep(time.Millisecond)
+	asOf := time.Now().UTC().Format(time.RFC3339Nano)
+	time.Sleep(time.Millisecond)
+	testQuery(t, ses,
+		[]queryCase{
+			{sql: "update t set c2 = 20"},
+			{sql: "insert into t values (2, 20)"},
+			{sql: fmt.Sprintf("begin as of system time '%s'", asOf)},
+			{
+				sql:  "select * from t",
+				rows: []types.Row{{types.Int64Value(1), types.Int64Value(10)}},
+			},
+			{sql: "update t set c2 = 30", fail: true},
+			{sql: "commit"},
+			{sql: "begin read only"},
+			{
+				sql: "select * from t",
+				rows: []types.Row{
+					{types.Int64Value(1), types.Int64Value(20)},
+					{types.Int64Value(2), types.Int64Value(20)},
+				},
+			},
+			{sql: "commit"},
+			{sql: "begin as of system time '-1h'", fail: true},
+			{sql: "begin as of system time '2200-01-01 00:00:00'", fail: true},
+		})
+}
+
+func TestSessionSavepoints(t *testing.T) {
+	ses := newSession(t)
+	testQuery(t, ses,
+		[]queryCase{
+			{sql: "create table t (c1 int primary key, c2 int not null)"},
+			{sql: "begin"},
+			{sql: "insert into t values (1, 10)"},
+			{sql: "savepoint sp1"},
+			{sql: "insert into t values (2, 20)"},
+			{sql: "savepoint sp2"},
+			{sql: "update t set c2 = c2 + 1"},
+			{
+				sql: "select * from t",
+				rows: []types.Row{
+					{types.Int64Value(1), types.Int64Value(11)},
+					{types.Int64Value(2), types.Int64Value(21)},
+				},
+			},
+			{sql: "rollback to savepoint sp1"},
+			{
+				sql:  "select * from t",
+				rows: []types.Row{{types.Int64Value(1), types.Int64Value(10)}},
+			},
+			{sql: "rollback to sp2", fail: true},
+			{sql: "insert into t values (3, 30)"},
+
+			// A failed statement does not leave any of its changes.
+			{sql: "insert into t values (4, 40), (5, 50), (3, 300)", fail: true},
+			{sql: "insert into t values (6, 60), (7, null)", fail: true},
+			{
+				sql: "select * from t",
+				rows: []types.Row{
+					{types.Int64Value(1), types.Int64Value(10)},
+					{types.Int64Value(3), types.Int64Value(30)},
+				},
+			},
+			{sql: "release savepoint sp1"},
+			{sql: "rollback to savepoint sp1", fail: true},
+			{sql: "savepoint sp1"},
+			{sql: "savepoint sp1"},
+			{sql: "delete from t where c1 = 1"},
+			{sql: "release sp1"},
+			{sql: "delete from t where c1 = 3"},
+			{sql: "rollback to sp1"},
+			{sql: "commit"},
+			{
+				sql: "select * from t",
+				rows: []types.Row{
+					{types.Int64Value(1), types.Int64Value(10)},
+					{types.Int64Value(3), types.Int64Value(30)},
+				},
+			},
+			{sql: "savepoint sp1", fail: true},
+		})
+}
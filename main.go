@@ -2,11 +2,19 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"flag"
 	"fmt"
 	"io"
 	"os"
 
+	"github.com/leftmike/maho/config"
+	"github.com/leftmike/maho/engine"
+	"github.com/leftmike/maho/evaluate"
 	"github.com/leftmike/maho/parser"
+	"github.com/leftmike/maho/storage"
+	"github.com/leftmike/maho/storage/durable"
+	"github.com/leftmike/maho/types"
 )
 
 /*
@@ -26,7 +34,81 @@ import (
 -- physical: storage
 */
 
+var (
+	cfg = config.NewConfig(flag.CommandLine)
+
+	configFile      string
+	dataDir         string
+	defaultDatabase string
+	storeName       string
+)
+
+func init() {
+	cfg.String(&configFile, "config_file", "", "`file` to load config from").NoSet().Hide()
+	cfg.String(&dataDir, "data_dir", "testdata", "`directory` containing databases").NoSet()
+	cfg.String(&defaultDatabase, "default_database", "maho", "default `database`").NoSet()
+	cfg.String(&storeName, "store", "basic", "`store` to use: basic or mvcc").NoSet()
+}
+
+func printRows(ctx context.Context, rows evaluate.Rows) error {
+	for cdx, col := range rows.Columns() {
+		if cdx > 0 {
+			fmt.Print("\t")
+		}
+		fmt.Print(col)
+	}
+	fmt.Println()
+
+	for {
+		row, err := rows.Next(ctx)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			rows.Close(ctx)
+			return err
+		}
+		fmt.Println(row)
+	}
+	return rows.Close(ctx)
+}
+
 func main() {
+	flag.Parse()
+	if configFile != "" {
+		err := cfg.Load(configFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "maho: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var store storage.Store
+	var created bool
+	var err error
+	switch storeName {
+	case "basic":
+		store, created, err = durable.OpenStore(dataDir)
+	case "mvcc":
+		store, created, err = durable.OpenMVCCStore(dataDir)
+	default:
+		err = fmt.Errorf("store: want basic or mvcc: %s", storeName)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "maho: %s\n", err)
+		os.Exit(1)
+	}
+	if created {
+		err = engine.Init(store)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "maho: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	ses := evaluate.NewSession(engine.NewEngine(store, cfg), types.ID(defaultDatabase, false),
+		types.PUBLIC)
+	ses.SetCopyOutput(os.Stdout)
+
+	ctx := context.Background()
 	p := parser.NewParser(bufio.NewReader(os.Stdin), "console")
 	for {
 		fmt.Print("maho: ")
@@ -38,6 +120,12 @@ func main() {
 			continue
 		}
 
-		fmt.Println(stmt)
+		rows, err := ses.Evaluate(ctx, stmt)
+		if err == nil && rows != nil {
+			err = printRows(ctx, rows)
+		}
+		if err != nil {
+			fmt.Printf("maho: %s\n", err)
+		}
 	}
 }
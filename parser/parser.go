@@ -3,6 +3,7 @@ package parser
 import (
 	"fmt"
 	"io"
+	"math"
 	"runtime"
 	"strconv"
 
@@ -261,22 +262,31 @@ func (p *Parser) parseStmt() sql.Stmt {
 		types.EXPLAIN,
 		types.INSERT,
 		types.PREPARE,
+		types.RELEASE,
 		types.ROLLBACK,
+		types.SAVEPOINT,
 		types.SELECT,
 		types.SET,
 		types.SHOW,
 		types.START,
+		types.TRUNCATE,
 		types.UPDATE,
 		types.USE,
 		types.VALUES,
 	) {
 	case types.ALTER:
+		if p.maybeIdentifier(types.SEQUENCE) {
+			// ALTER SEQUENCE ...
+			return p.parseAlterSequence()
+		}
+
 		// ALTER TABLE ...
 		p.expectReserved(types.TABLE)
 		return p.parseAlterTable()
 	case types.BEGIN:
-		// BEGIN
-		return &sql.Begin{}
+		// BEGIN [TRANSACTION] [transaction_mode [[','] ...]]
+		p.optionalReserved(types.TRANSACTION)
+		return p.parseBegin()
 	case types.COMMIT:
 		// COMMIT
 		return &sql.Commit{}
@@ -284,6 +294,11 @@ func (p *Parser) parseStmt() sql.Stmt {
 		// COPY
 		return p.parseCopy()
 	case types.CREATE:
+		if p.maybeIdentifier(types.SEQUENCE) {
+			// CREATE SEQUENCE ...
+			return p.parseCreateSequence()
+		}
+
 		switch p.expectReserved(types.DATABASE, types.INDEX, types.SCHEMA, types.TABLE,
 			types.UNIQUE) {
 		case types.DATABASE:
@@ -308,6 +323,11 @@ func (p *Parser) parseStmt() sql.Stmt {
 		p.expectReserved(types.FROM)
 		return p.parseDelete()
 	case types.DROP:
+		if p.maybeIdentifier(types.SEQUENCE) {
+			// DROP SEQUENCE ...
+			return p.parseDropSequence()
+		}
+
 		switch p.expectReserved(types.DATABASE, types.INDEX, types.SCHEMA, types.TABLE) {
 		case types.DATABASE:
 			// DROP DATABASE ...
@@ -336,9 +356,22 @@ func (p *Parser) parseStmt() sql.Stmt {
 			case types.PREPARE:
 				return p.parsePrepare()
 		*/
+	case types.RELEASE:
+		// RELEASE [SAVEPOINT] name
+		p.optionalReserved(types.SAVEPOINT)
+		return &sql.Release{Savepoint: p.expectIdentifier("expected a savepoint")}
 	case types.ROLLBACK:
+		if p.optionalReserved(types.TO) {
+			// ROLLBACK TO [SAVEPOINT] name
+			p.optionalReserved(types.SAVEPOINT)
+			return &sql.RollbackTo{Savepoint: p.expectIdentifier("expected a savepoint")}
+		}
+
 		// ROLLBACK
 		return &sql.Rollback{}
+	case types.SAVEPOINT:
+		// SAVEPOINT name
+		return &sql.Savepoint{Name: p.expectIdentifier("expected a savepoint")}
 	case types.SELECT:
 		// SELECT ...
 		return p.parseSelect()
@@ -349,9 +382,13 @@ func (p *Parser) parseStmt() sql.Stmt {
 		// SHOW ...
 		return p.parseShow()
 	case types.START:
-		// START TRANSACTION
+		// START TRANSACTION [transaction_mode [[','] ...]]
 		p.expectReserved(types.TRANSACTION)
-		return &sql.Begin{}
+		return p.parseBegin()
+	case types.TRUNCATE:
+		// TRUNCATE [TABLE] ...
+		p.optionalReserved(types.TABLE)
+		return p.parseTruncate()
 	case types.UPDATE:
 		// UPDATE ...
 		return p.parseUpdate()
@@ -521,6 +558,17 @@ func (p *Parser) parseOnActions(fk *sql.ForeignKey) *sql.ForeignKey {
 		}
 	}
 
+	// All foreign keys are deferrable: SET CONSTRAINTS can change when any of them are checked.
+	p.maybeIdentifier(types.DEFERRABLE)
+	if p.maybeIdentifier(types.INITIALLY) {
+		if p.maybeIdentifier(types.DEFERRED) {
+			fk.Deferred = true
+		} else if !p.maybeIdentifier(types.IMMEDIATE) {
+			p.scan()
+			p.error(fmt.Sprintf("expected DEFERRED or IMMEDIATE; got %s", p.got()))
+		}
+	}
+
 	return fk
 }
 
@@ -569,6 +617,7 @@ func (p *Parser) parseCreateDetails(s *sql.CreateTable) {
 			| CHECK '(' expr ')'
 			| FOREIGN KEY columns REFERENCES [[database '.'] schema '.'] table [columns]
 			  [ON DELETE referential_action] [ON UPDATE referential_action]
+			  [DEFERRABLE] [INITIALLY DEFERRED | INITIALLY IMMEDIATE]
 		key_columns = '(' column [ASC | DESC] [',' ...] ')'
 		columns = '(' column [',' ...] ')'
 		referential_action = NO ACTION | RESTRICT | CASCADE | SET NULL | SET DEFAULT
@@ -638,9 +687,15 @@ var (
 		types.INT8:      {Type: types.Int64Type, Size: 8},
 		types.BIGINT:    {Type: types.Int64Type, Size: 8},
 	}
+
+	SerialTypes = map[types.Identifier]types.ColumnType{
+		types.SMALLSERIAL: {Type: types.Int64Type, Size: 2},
+		types.SERIAL:      {Type: types.Int64Type, Size: 4},
+		types.BIGSERIAL:   {Type: types.Int64Type, Size: 8},
+	}
 )
 
-func (p *Parser) parseColumnType() types.ColumnType {
+func (p *Parser) parseColumnType(serial bool) (types.ColumnType, bool) {
 	/*
 		data_type =
 			  BINARY ['(' length ')']
@@ -664,9 +719,17 @@ func (p *Parser) parseColumnType() types.ColumnType {
 			| INTEGER
 			| BIGINT
 			| INT8
+			| SMALLSERIAL
+			| SERIAL
+			| BIGSERIAL
 	*/
 
 	typ := p.expectIdentifier("expected a data type")
+	if serial {
+		if ct, found := SerialTypes[typ]; found {
+			return ct, true
+		}
+	}
 	ct, found := ColumnTypes[typ]
 	if !found {
 		p.error(fmt.Sprintf("expected a data type, got %s", typ))
@@ -683,7 +746,7 @@ func (p *Parser) parseColumnType() types.ColumnType {
 		}
 	}
 
-	return ct
+	return ct, false
 }
 
 func makeKeyConstraintName(cn types.Identifier, key sql.IndexKey, suffix string) types.Identifier {
@@ -756,6 +819,8 @@ func (p *Parser) parseColumn(s *sql.CreateTable) {
 			| CHECK '(' expr ')'
 			| REFERENCES [[database '.'] schema '.'] table ['(' column ')']
 			  [ON DELETE referential_action] [ON UPDATE referential_action]
+			  [DEFERRABLE] [INITIALLY DEFERRED | INITIALLY IMMEDIATE]
+			| GENERATED {ALWAYS | BY DEFAULT} AS IDENTITY ['(' sequence_option ... ')']
 		referential_action = NO ACTION | RESTRICT | CASCADE | SET NULL | SET DEFAULT
 	*/
 
@@ -767,7 +832,15 @@ func (p *Parser) parseColumn(s *sql.CreateTable) {
 	}
 	s.Columns = append(s.Columns, nam)
 
-	ct := p.parseColumnType()
+	ct, serial := p.parseColumnType(true)
+	identity := serial
+	if serial {
+		s.Identities = append(s.Identities,
+			sql.ColumnIdentity{
+				ColNum: len(s.Columns) - 1,
+				Serial: true,
+			})
+	}
 
 	var dflt sql.Expr
 	for {
@@ -779,6 +852,8 @@ func (p *Parser) parseColumn(s *sql.CreateTable) {
 		if p.optionalReserved(types.DEFAULT) {
 			if dflt != nil {
 				p.error("DEFAULT specified more than once per column")
+			} else if identity {
+				p.error(fmt.Sprintf("DEFAULT not allowed on identity column: %s", nam))
 			}
 			if cn != 0 {
 				p.addColumnConstraint(s, sql.DefaultConstraint, cn, len(s.Columns)-1)
@@ -842,6 +917,34 @@ func (p *Parser) parseColumn(s *sql.CreateTable) {
 						RefTable: rtn,
 						RefCols:  refCols,
 					}))
+		} else if p.maybeIdentifier(types.GENERATED) {
+			if identity {
+				p.error(fmt.Sprintf("identity specified more than once for column: %s", nam))
+			} else if dflt != nil {
+				p.error(fmt.Sprintf("DEFAULT not allowed on identity column: %s", nam))
+			} else if ct.Type != types.Int64Type {
+				p.error(fmt.Sprintf("identity column must be an integer type: %s", nam))
+			}
+			identity = true
+
+			ci := sql.ColumnIdentity{
+				ColNum: len(s.Columns) - 1,
+			}
+			if p.maybeIdentifier(types.ALWAYS) {
+				ci.Always = true
+			} else {
+				p.expectReserved(types.BY)
+				p.expectReserved(types.DEFAULT)
+			}
+			p.expectReserved(types.AS)
+			p.expectKeyword(types.IDENTITY)
+
+			if p.maybeToken(token.LParen) {
+				for p.parseSequenceOption(&ci.Options) {
+				}
+				p.expectTokens(token.RParen)
+			}
+			s.Identities = append(s.Identities, ci)
 		} else if cn != 0 {
 			p.error("CONSTRAINT name specified without a constraint")
 		} else {
@@ -856,13 +959,24 @@ func (p *Parser) parseColumn(s *sql.CreateTable) {
 func (p *Parser) parseAlterTable() sql.Stmt {
 	// ALTER TABLE table action [',' ...]
 	// action =
-	//      ADD [CONSTRAINT constraint] table_constraint
+	//      ADD [COLUMN] column data_type [column_constraint ...]
+	//    | ADD [CONSTRAINT constraint] table_constraint
+	//    | DROP COLUMN [IF EXISTS] column
 	//    | DROP CONSTRAINT [IF EXISTS] constraint
 	//    | ALTER [COLUMN] column DROP DEFAULT
 	//    | ALTER [COLUMN] column DROP NOT NULL
-	// table_constraint = FOREIGN KEY columns
-	//    REFERENCES [[database '.'] schema '.'] table [columns]
-	//    [ON DELETE referential_action] [ON UPDATE referential_action]
+	//    | ALTER [COLUMN] column SET DEFAULT expr
+	//    | ALTER [COLUMN] column SET NOT NULL
+	//    | ALTER [COLUMN] column [SET DATA] TYPE data_type
+	//    | RENAME [COLUMN] column TO column
+	//    | RENAME TO table
+	//    | SET SCHEMA schema
+	// column_constraint = DEFAULT expr | NOT NULL
+	// table_constraint =
+	//      CHECK '(' expr ')'
+	//    | FOREIGN KEY columns REFERENCES [[database '.'] schema '.'] table [columns]
+	//      [ON DELETE referential_action] [ON UPDATE referential_action]
+	//      [DEFERRABLE] [INITIALLY DEFERRED | INITIALLY IMMEDIATE]
 	// referential_action = NO ACTION | RESTRICT | CASCADE | SET NULL | SET DEFAULT
 	// columns = '(' column [',' ...] ')'
 	var s sql.AlterTable
@@ -870,59 +984,190 @@ func (p *Parser) parseAlterTable() sql.Stmt {
 	s.Table = p.parseTableName()
 
 	for {
-		switch p.expectReserved(types.ADD, types.DROP, types.ALTER) {
-		case types.ADD:
-			var cn types.Identifier
-			if p.optionalReserved(types.CONSTRAINT) {
-				cn = p.expectIdentifier("expected a constraint name")
+		if p.maybeIdentifier(types.RENAME) {
+			if p.optionalReserved(types.TO) {
+				s.Actions = append(s.Actions,
+					&sql.RenameTable{
+						NewName: p.expectIdentifier("expected a table name"),
+					})
+				break
 			}
 
-			p.expectReserved(types.FOREIGN)
-			p.expectReserved(types.KEY)
+			p.optionalReserved(types.COLUMN)
+			col := p.expectIdentifier("expected a column name")
+			p.expectReserved(types.TO)
+			s.Actions = append(s.Actions,
+				&sql.RenameColumn{
+					Column:  col,
+					NewName: p.expectIdentifier("expected a column name"),
+				})
+		} else if p.optionalReserved(types.SET) {
+			p.expectReserved(types.SCHEMA)
+			s.Actions = append(s.Actions,
+				&sql.SetSchema{
+					Schema: p.expectIdentifier("expected a schema name"),
+				})
+			break
+		} else {
+			p.parseAlterAction(&s)
+		}
 
-			fk := p.parseForeignKey(cn)
-			s.Actions = append(s.Actions, &sql.AddForeignKey{*fk})
-		case types.DROP:
-			p.expectReserved(types.CONSTRAINT)
+		if !p.maybeToken(token.Comma) {
+			break
+		}
+	}
 
-			var ifExists bool
-			if p.optionalReserved(types.IF) {
-				p.expectReserved(types.EXISTS)
-				ifExists = true
-			}
+	if len(s.Actions) > 1 {
+		switch s.Actions[len(s.Actions)-1].(type) {
+		case *sql.RenameTable, *sql.SetSchema:
+			p.error(fmt.Sprintf("%s must be the only action", s.Actions[len(s.Actions)-1]))
+		}
+	}
 
+	return &s
+}
+
+func (p *Parser) parseAlterAction(s *sql.AlterTable) {
+	switch p.expectReserved(types.ADD, types.DROP, types.ALTER) {
+	case types.ADD:
+		var cn types.Identifier
+		var con types.Identifier
+		if p.optionalReserved(types.CONSTRAINT) {
+			cn = p.expectIdentifier("expected a constraint name")
+			con = p.expectReserved(types.FOREIGN, types.CHECK)
+		} else if p.optionalReserved(types.CHECK) {
+			con = types.CHECK
+		} else if !p.optionalReserved(types.FOREIGN) {
+			p.optionalReserved(types.COLUMN)
+			s.Actions = append(s.Actions, p.parseAddColumn())
+			break
+		}
+
+		if con == types.CHECK {
+			p.expectTokens(token.LParen)
+			s.Actions = append(s.Actions,
+				&sql.AddConstraint{
+					Constraint: sql.Constraint{
+						Type:   sql.CheckConstraint,
+						Name:   cn,
+						ColNum: -1,
+						Check:  p.parseExpr(),
+					},
+				})
+			p.expectTokens(token.RParen)
+			break
+		}
+
+		p.expectReserved(types.KEY)
+
+		fk := p.parseForeignKey(cn)
+		s.Actions = append(s.Actions, &sql.AddForeignKey{*fk})
+	case types.DROP:
+		isColumn := p.expectReserved(types.COLUMN, types.CONSTRAINT) == types.COLUMN
+
+		var ifExists bool
+		if p.optionalReserved(types.IF) {
+			p.expectReserved(types.EXISTS)
+			ifExists = true
+		}
+
+		if isColumn {
+			s.Actions = append(s.Actions,
+				&sql.DropColumn{
+					Column:   p.expectIdentifier("expected a column name"),
+					IfExists: ifExists,
+				})
+		} else {
 			s.Actions = append(s.Actions,
 				&sql.DropConstraint{
 					Name:     p.expectIdentifier("expected a constraint name"),
 					IfExists: ifExists,
 				})
-		case types.ALTER:
-			p.optionalReserved(types.COLUMN)
-			nam := p.expectIdentifier("expected a column name")
-			p.expectReserved(types.DROP)
-
-			var ct sql.ConstraintType
-			switch p.expectReserved(types.DEFAULT, types.NOT) {
-			case types.DEFAULT:
-				ct = sql.DefaultConstraint
-			case types.NOT:
-				p.expectReserved(types.NULL)
-				ct = sql.NotNullConstraint
-			}
+		}
+	case types.ALTER:
+		p.optionalReserved(types.COLUMN)
+		nam := p.expectIdentifier("expected a column name")
 
+		if p.maybeIdentifier(types.TYPE) {
+			ct, _ := p.parseColumnType(false)
 			s.Actions = append(s.Actions,
-				&sql.DropConstraint{
+				&sql.AlterColumn{
 					Column: nam,
+					Action: sql.SetColumnType,
 					Type:   ct,
 				})
+			break
 		}
 
-		if !p.maybeToken(token.Comma) {
+		if p.expectReserved(types.DROP, types.SET) == types.SET {
+			if p.maybeIdentifier(types.DATA) {
+				if p.expectIdentifier("expected TYPE") != types.TYPE {
+					p.error(fmt.Sprintf("expected TYPE, got %s", p.got()))
+				}
+				ct, _ := p.parseColumnType(false)
+				s.Actions = append(s.Actions,
+					&sql.AlterColumn{
+						Column: nam,
+						Action: sql.SetColumnType,
+						Type:   ct,
+					})
+			} else if p.expectReserved(types.DEFAULT, types.NOT) == types.DEFAULT {
+				s.Actions = append(s.Actions,
+					&sql.AlterColumn{
+						Column:  nam,
+						Action:  sql.SetColumnDefault,
+						Default: p.parseExpr(),
+					})
+			} else {
+				p.expectReserved(types.NULL)
+				s.Actions = append(s.Actions,
+					&sql.AlterColumn{
+						Column: nam,
+						Action: sql.SetColumnNotNull,
+					})
+			}
 			break
 		}
+
+		var ct sql.ConstraintType
+		switch p.expectReserved(types.DEFAULT, types.NOT) {
+		case types.DEFAULT:
+			ct = sql.DefaultConstraint
+		case types.NOT:
+			p.expectReserved(types.NULL)
+			ct = sql.NotNullConstraint
+		}
+
+		s.Actions = append(s.Actions,
+			&sql.DropConstraint{
+				Column: nam,
+				Type:   ct,
+			})
 	}
+}
 
-	return &s
+func (p *Parser) parseAddColumn() *sql.AddColumn {
+	var s sql.CreateTable
+	p.parseColumn(&s)
+
+	for _, c := range s.Constraints {
+		if c.Type != sql.DefaultConstraint && c.Type != sql.NotNullConstraint {
+			p.error(fmt.Sprintf("ADD COLUMN: %s constraint not supported", c.Type))
+		}
+	}
+	if len(s.ForeignKeys) > 0 {
+		p.error("ADD COLUMN: REFERENCES constraint not supported")
+	}
+	if len(s.Identities) > 0 {
+		// XXX: identity columns
+		p.error("ADD COLUMN: identity columns not supported")
+	}
+
+	return &sql.AddColumn{
+		Column:  s.Columns[0],
+		Type:    s.ColumnTypes[0],
+		Default: s.ColumnDefaults[0],
+	}
 }
 
 func (p *Parser) parseCreateIndex(unique bool) sql.Stmt {
@@ -951,12 +1196,19 @@ func (p *Parser) parseCreateIndex(unique bool) sql.Stmt {
 }
 
 func (p *Parser) parseDelete() sql.Stmt {
-	// DELETE FROM [database '.'] table [WHERE expr]
+	/*
+		DELETE FROM [database '.'] table [USING from-item [',' ...]] [WHERE expr]
+			[RETURNING select-list]
+	*/
 	var s sql.Delete
 	s.Table = p.parseTableName()
+	if p.optionalReserved(types.USING) {
+		s.Using = p.parseFromList()
+	}
 	if p.optionalReserved(types.WHERE) {
 		s.Where = p.parseExpr()
 	}
+	s.Returning = p.parseReturning()
 
 	return &s
 }
@@ -983,6 +1235,31 @@ func (p *Parser) parseDropTable() sql.Stmt {
 	return &s
 }
 
+func (p *Parser) parseTruncate() sql.Stmt {
+	// TRUNCATE [TABLE] [database '.' ] table [',' ...] [RESTART IDENTITY | CONTINUE IDENTITY]
+	//     [CASCADE | RESTRICT]
+	var s sql.Truncate
+	s.Tables = []types.TableName{p.parseTableName()}
+	for p.maybeToken(token.Comma) {
+		s.Tables = append(s.Tables, p.parseTableName())
+	}
+
+	if p.maybeIdentifier(types.RESTART) {
+		p.expectKeyword(types.IDENTITY)
+		s.RestartIdentity = true
+	} else if p.maybeIdentifier(types.CONTINUE) {
+		p.expectKeyword(types.IDENTITY)
+	}
+
+	if p.optionalReserved(types.CASCADE) {
+		s.Cascade = true
+	} else {
+		p.optionalReserved(types.RESTRICT)
+	}
+
+	return &s
+}
+
 func (p *Parser) parseDropIndex() sql.Stmt {
 	// DROP INDEX [IF EXISTS] index ON table
 	var s sql.DropIndex
@@ -996,6 +1273,143 @@ func (p *Parser) parseDropIndex() sql.Stmt {
 	return &s
 }
 
+func (p *Parser) parseSequenceValue() *int64 {
+	n := p.expectInteger(math.MinInt64, math.MaxInt64)
+	return &n
+}
+
+func (p *Parser) parseSequenceOption(so *sql.SequenceOptions) bool {
+	if p.maybeIdentifier(types.INCREMENT) {
+		// INCREMENT [BY] increment
+		if so.Increment != nil {
+			p.error("increment specified more than once")
+		}
+		p.optionalReserved(types.BY)
+		so.Increment = p.parseSequenceValue()
+	} else if p.maybeIdentifier(types.MINVALUE) {
+		// MINVALUE minvalue
+		if so.MinValue != nil || so.NoMinValue {
+			p.error("minvalue specified more than once")
+		}
+		so.MinValue = p.parseSequenceValue()
+	} else if p.maybeIdentifier(types.MAXVALUE) {
+		// MAXVALUE maxvalue
+		if so.MaxValue != nil || so.NoMaxValue {
+			p.error("maxvalue specified more than once")
+		}
+		so.MaxValue = p.parseSequenceValue()
+	} else if p.optionalReserved(types.START) {
+		// START [WITH] start
+		if so.Start != nil {
+			p.error("start specified more than once")
+		}
+		p.optionalReserved(types.WITH)
+		so.Start = p.parseSequenceValue()
+	} else if p.maybeIdentifier(types.CYCLE) {
+		// CYCLE
+		if so.Cycle != nil {
+			p.error("cycle specified more than once")
+		}
+		cycle := true
+		so.Cycle = &cycle
+	} else if p.optionalReserved(types.NO) {
+		if p.maybeIdentifier(types.MINVALUE) {
+			// NO MINVALUE
+			if so.MinValue != nil || so.NoMinValue {
+				p.error("minvalue specified more than once")
+			}
+			so.NoMinValue = true
+		} else if p.maybeIdentifier(types.MAXVALUE) {
+			// NO MAXVALUE
+			if so.MaxValue != nil || so.NoMaxValue {
+				p.error("maxvalue specified more than once")
+			}
+			so.NoMaxValue = true
+		} else if p.maybeIdentifier(types.CYCLE) {
+			// NO CYCLE
+			if so.Cycle != nil {
+				p.error("cycle specified more than once")
+			}
+			cycle := false
+			so.Cycle = &cycle
+		} else {
+			p.scan()
+			p.error(fmt.Sprintf("expected MINVALUE, MAXVALUE, or CYCLE, got %s", p.got()))
+		}
+	} else {
+		return false
+	}
+
+	return true
+}
+
+func (p *Parser) parseCreateSequence() sql.Stmt {
+	// CREATE SEQUENCE [IF NOT EXISTS] [[database '.'] schema '.'] sequence
+	//     [INCREMENT [BY] increment]
+	//     [MINVALUE minvalue | NO MINVALUE] [MAXVALUE maxvalue | NO MAXVALUE]
+	//     [START [WITH] start] [[NO] CYCLE]
+	var s sql.CreateSequence
+
+	if p.optionalReserved(types.IF) {
+		p.expectReserved(types.NOT)
+		p.expectReserved(types.EXISTS)
+		s.IfNotExists = true
+	}
+
+	s.Sequence = p.parseTableName()
+	for p.parseSequenceOption(&s.Options) {
+	}
+	return &s
+}
+
+func (p *Parser) parseAlterSequence() sql.Stmt {
+	// ALTER SEQUENCE [IF EXISTS] [[database '.'] schema '.'] sequence
+	//     [INCREMENT [BY] increment]
+	//     [MINVALUE minvalue | NO MINVALUE] [MAXVALUE maxvalue | NO MAXVALUE]
+	//     [START [WITH] start] [RESTART [[WITH] restart]] [[NO] CYCLE]
+	var s sql.AlterSequence
+
+	if p.optionalReserved(types.IF) {
+		p.expectReserved(types.EXISTS)
+		s.IfExists = true
+	}
+
+	s.Sequence = p.parseTableName()
+	for {
+		if p.maybeIdentifier(types.RESTART) {
+			if s.Restart {
+				p.error("restart specified more than once")
+			}
+			s.Restart = true
+
+			if p.optionalReserved(types.WITH) {
+				s.RestartWith = p.parseSequenceValue()
+			} else if p.maybeToken(token.Integer) {
+				p.unscan()
+				s.RestartWith = p.parseSequenceValue()
+			}
+		} else if !p.parseSequenceOption(&s.Options) {
+			break
+		}
+	}
+	return &s
+}
+
+func (p *Parser) parseDropSequence() sql.Stmt {
+	// DROP SEQUENCE [IF EXISTS] [[database '.'] schema '.'] sequence [',' ...]
+	var s sql.DropSequence
+	if p.optionalReserved(types.IF) {
+		p.expectReserved(types.EXISTS)
+		s.IfExists = true
+	}
+
+	s.Sequences = []types.TableName{p.parseTableName()}
+	for p.maybeToken(token.Comma) {
+		s.Sequences = append(s.Sequences, p.parseTableName())
+	}
+	return &s
+}
+
 func (p *Parser) optionalSubquery() (sql.Stmt, bool) {
 	if p.optionalReserved(types.SELECT) {
 		// ( select )
@@ -1300,7 +1714,11 @@ func (p *Parser) parseSubquery() sql.Stmt {
 func (p *Parser) parseInsert() sql.Stmt {
 	/*
 		INSERT INTO [database '.'] table ['(' column [',' ...] ')']
-			VALUES '(' (expr | DEFAULT) [',' ...] ')' [',' ...]
+			( VALUES '(' (expr | DEFAULT) [',' ...] ')' [',' ...] | SELECT ... )
+			[ON CONFLICT ['(' column [',' ...] ')'] conflict_action]
+			[RETURNING select-list]
+		conflict_action = DO NOTHING
+			| DO UPDATE SET column '=' (expr | DEFAULT) [',' ...] [WHERE expr]
 	*/
 
 	var s sql.InsertValues
@@ -1322,8 +1740,23 @@ func (p *Parser) parseInsert() sql.Stmt {
 		}
 	}
 
-	p.expectReserved(types.VALUES)
+	if p.expectReserved(types.VALUES, types.SELECT) == types.SELECT {
+		s.Query = p.parseSelect()
+	} else {
+		s.Rows = p.parseInsertRows()
+	}
 
+	if p.optionalReserved(types.ON) {
+		p.expectKeyword(types.CONFLICT)
+		s.OnConflict = p.parseOnConflict()
+	}
+	s.Returning = p.parseReturning()
+
+	return &s
+}
+
+func (p *Parser) parseInsertRows() [][]sql.Expr {
+	var rows [][]sql.Expr
 	for {
 		var row []sql.Expr
 
@@ -1342,61 +1775,245 @@ func (p *Parser) parseInsert() sql.Stmt {
 			}
 		}
 
-		s.Rows = append(s.Rows, row)
+		rows = append(rows, row)
 
 		if !p.maybeToken(token.Comma) {
 			break
 		}
 	}
 
-	return &s
+	return rows
 }
 
-func (p *Parser) parseCopy() sql.Stmt {
-	/*
-		COPY [[database '.'] schema '.'] table '(' column [',' ...] ')' FROM STDIN
-			[DELIMITER delimiter]
-	*/
-
-	var s sql.Copy
-	s.Table = p.parseTableName()
-
+func (p *Parser) parseOnConflict() *sql.OnConflict {
+	var oc sql.OnConflict
 	if p.maybeToken(token.LParen) {
 		for {
 			nam := p.expectIdentifier("expected a column name")
-			for _, c := range s.Columns {
+			for _, c := range oc.Columns {
 				if c == nam {
 					p.error(fmt.Sprintf("duplicate column name %s", nam))
 				}
 			}
-			s.Columns = append(s.Columns, nam)
-			r := p.expectTokens(token.Comma, token.RParen)
-			if r == token.RParen {
+			oc.Columns = append(oc.Columns, nam)
+			if p.expectTokens(token.Comma, token.RParen) == token.RParen {
 				break
 			}
 		}
 	}
 
-	p.expectReserved(types.FROM)
-	if p.expectIdentifier("expected STDIN") != types.STDIN {
-		p.error("expected STDIN")
+	p.expectKeyword(types.DO)
+	if p.maybeIdentifier(types.NOTHING) {
+		oc.DoNothing = true
+		return &oc
+	}
+
+	p.expectReserved(types.UPDATE)
+	p.expectReserved(types.SET)
+	oc.ColumnUpdates = p.parseColumnUpdates()
+	if p.optionalReserved(types.WHERE) {
+		oc.Where = p.parseExpr()
+	}
+	return &oc
+}
+
+func (p *Parser) parseCopy() sql.Stmt {
+	/*
+		COPY [[database '.'] schema '.'] table ['(' column [',' ...] ')'] FROM STDIN
+			[[WITH] '(' copy-option [',' ...] ')'] [DELIMITER delimiter]
+		COPY ([[database '.'] schema '.'] table ['(' column [',' ...] ')'] | '(' query ')')
+			TO STDOUT [[WITH] '(' copy-option [',' ...] ')'] [DELIMITER delimiter]
+		copy-option = FORMAT (TEXT | CSV | BINARY)
+			| DELIMITER delimiter
+			| NULL null
+			| HEADER [TRUE | FALSE]
+			| QUOTE quote
+			| ESCAPE escape
+			| FORCE_QUOTE ('*' | '(' column [',' ...] ')')
+	*/
+
+	var s sql.Copy
+	if p.maybeToken(token.LParen) {
+		var ok bool
+		s.Query, ok = p.optionalSubquery()
+		if !ok {
+			p.error("expected a query")
+		}
+		p.expectTokens(token.RParen)
+	} else {
+		s.Table = p.parseTableName()
+		if p.maybeToken(token.LParen) {
+			s.Columns = p.parseColumnList()
+		}
+	}
+
+	if p.expectReserved(types.FROM, types.TO) == types.TO {
+		if !p.maybeIdentifier(types.STDOUT) {
+			p.error("expected STDOUT")
+		}
+		s.To = true
+	} else {
+		if s.Query != nil {
+			p.error("expected TO with a query")
+		}
+		if !p.maybeIdentifier(types.STDIN) {
+			p.error("expected STDIN")
+		}
+	}
+
+	var delimiter, quote, escape rune
+	var null *string
+	var format, header, forceQuote bool
+	options := p.optionalReserved(types.WITH)
+	if options {
+		p.expectTokens(token.LParen)
+	} else {
+		options = p.maybeToken(token.LParen)
+	}
+
+	for options {
+		var dup bool
+		if p.optionalReserved(types.DELIMITER) {
+			dup = delimiter != 0
+			delimiter = p.parseCopyRune()
+		} else if p.optionalReserved(types.NULL) {
+			dup = null != nil
+			if p.scan() != token.String {
+				p.error("expected a string")
+			}
+			str := p.sctx.String
+			null = &str
+		} else if p.maybeIdentifier(types.FORMAT) {
+			dup = format
+			format = true
+			if p.maybeIdentifier(types.TEXT) {
+				s.Format = sql.TextFormat
+			} else if p.maybeIdentifier(types.CSV) {
+				s.Format = sql.CSVFormat
+			} else if p.maybeIdentifier(types.BINARY) {
+				s.Format = sql.BinaryFormat
+			} else {
+				p.error("expected TEXT, CSV, or BINARY")
+			}
+		} else if p.maybeIdentifier(types.HEADER) {
+			dup = header
+			header = true
+			if p.optionalReserved(types.FALSE) {
+				s.Header = false
+			} else {
+				p.optionalReserved(types.TRUE)
+				s.Header = true
+			}
+		} else if p.maybeIdentifier(types.QUOTE) {
+			dup = quote != 0
+			quote = p.parseCopyRune()
+		} else if p.maybeIdentifier(types.ESCAPE) {
+			dup = escape != 0
+			escape = p.parseCopyRune()
+		} else if p.maybeIdentifier(types.FORCE_QUOTE) {
+			dup = forceQuote
+			forceQuote = true
+			if p.maybeToken(token.Star) {
+				s.ForceQuoteAll = true
+			} else {
+				p.expectTokens(token.LParen)
+				s.ForceQuote = p.parseColumnList()
+			}
+		} else {
+			p.error("expected a copy option")
+		}
+		if dup {
+			p.error("duplicate copy option")
+		}
+
+		if p.expectTokens(token.Comma, token.RParen) == token.RParen {
+			break
+		}
 	}
 
-	s.Delimiter = '\t'
 	if p.optionalReserved(types.DELIMITER) {
-		if p.scan() != token.String || len(p.sctx.String) != 1 {
-			p.error("expected a one character string")
+		if delimiter != 0 {
+			p.error("duplicate copy option")
 		}
-		s.Delimiter = rune(p.sctx.String[0])
+		delimiter = p.parseCopyRune()
 	}
 
-	// Must be last because the scanner will skip to the end of the line before returning
-	// the reader.
-	s.From, s.FromLine = p.scanner.RuneReader()
+	if s.Format == sql.BinaryFormat {
+		if delimiter != 0 || null != nil || header {
+			p.error("DELIMITER, NULL, and HEADER are not allowed with FORMAT BINARY")
+		}
+	} else if s.Format == sql.TextFormat {
+		if quote != 0 || escape != 0 || forceQuote {
+			p.error("QUOTE, ESCAPE, and FORCE_QUOTE require FORMAT CSV")
+		}
+	}
+	if forceQuote && !s.To {
+		p.error("FORCE_QUOTE requires TO")
+	}
+
+	if delimiter != 0 {
+		s.Delimiter = delimiter
+	} else if s.Format == sql.CSVFormat {
+		s.Delimiter = ','
+	} else {
+		s.Delimiter = '\t'
+	}
+	if null != nil {
+		s.Null = *null
+	} else if s.Format == sql.TextFormat {
+		s.Null = `\N`
+	}
+	if s.Format == sql.CSVFormat {
+		s.Quote = '"'
+		if quote != 0 {
+			s.Quote = quote
+		}
+		s.Escape = s.Quote
+		if escape != 0 {
+			s.Escape = escape
+		}
+		if s.Delimiter == s.Quote {
+			p.error("DELIMITER and QUOTE must be different")
+		}
+	}
+	if s.Delimiter == '\n' || s.Delimiter == '\r' || s.Delimiter == '\\' {
+		p.error("DELIMITER must not be newline, carriage return, or backslash")
+	}
+
+	if !s.To {
+		// Must be last because the scanner will skip to the end of the line before returning
+		// the reader.
+		s.From, s.FromLine = p.scanner.DataReader()
+	}
 
 	return &s
 }
 
+func (p *Parser) parseCopyRune() rune {
+	if p.scan() != token.String || len(p.sctx.String) != 1 {
+		p.error("expected a one character string")
+	}
+	return rune(p.sctx.String[0])
+}
+
+func (p *Parser) parseColumnList() []types.Identifier {
+	var cols []types.Identifier
+	for {
+		nam := p.expectIdentifier("expected a column name")
+		for _, c := range cols {
+			if c == nam {
+				p.error(fmt.Sprintf("duplicate column name %s", nam))
+			}
+		}
+		cols = append(cols, nam)
+		r := p.expectTokens(token.Comma, token.RParen)
+		if r == token.RParen {
+			break
+		}
+	}
+	return cols
+}
+
 func (p *Parser) parseValues() *sql.Values {
 	/*
 	   values = VALUES '(' expr [',' ...] ')' [',' ...]
@@ -1428,6 +2045,51 @@ func (p *Parser) parseValues() *sql.Values {
 	return &s
 }
 
+func (p *Parser) parseSelectItems() []sql.SelectResult {
+	var results []sql.SelectResult
+	for {
+		t := p.scan()
+		if t == token.Identifier {
+			tbl := p.sctx.Identifier
+			if p.maybeToken(token.Dot) {
+				if p.maybeToken(token.Star) {
+					// table '.' *
+					results = append(results, sql.TableResult{Table: tbl})
+
+					if !p.maybeToken(token.Comma) {
+						break
+					}
+					continue
+				}
+				p.unscan()
+			}
+		}
+		p.unscan()
+
+		// expr [[ AS ] column-alias]
+		results = append(results, sql.ExprResult{
+			Expr:  p.parseExpr(),
+			Alias: p.parseAlias(false),
+		})
+
+		if !p.maybeToken(token.Comma) {
+			break
+		}
+	}
+
+	return results
+}
+
+func (p *Parser) parseReturning() []sql.SelectResult {
+	if !p.optionalReserved(types.RETURNING) {
+		return nil
+	}
+	if p.maybeToken(token.Star) {
+		return []sql.SelectResult{sql.StarResult{}}
+	}
+	return p.parseSelectItems()
+}
+
 /*
 select =
     SELECT select-list
@@ -1446,35 +2108,7 @@ select-item = table '.' '*'
 func (p *Parser) parseSelect() *sql.Select {
 	var s sql.Select
 	if !p.maybeToken(token.Star) {
-		for {
-			t := p.scan()
-			if t == token.Identifier {
-				tbl := p.sctx.Identifier
-				if p.maybeToken(token.Dot) {
-					if p.maybeToken(token.Star) {
-						// table '.' *
-						s.Results = append(s.Results, sql.TableResult{Table: tbl})
-
-						if !p.maybeToken(token.Comma) {
-							break
-						}
-						continue
-					}
-					p.unscan()
-				}
-			}
-			p.unscan()
-
-			// expr [[ AS ] column-alias]
-			s.Results = append(s.Results, sql.ExprResult{
-				Expr:  p.parseExpr(),
-				Alias: p.parseAlias(false),
-			})
-
-			if !p.maybeToken(token.Comma) {
-				break
-			}
-		}
+		s.Results = p.parseSelectItems()
 	}
 
 	if p.optionalReserved(types.FROM) {
@@ -1623,11 +2257,30 @@ func (p *Parser) parseFromStmt(s sql.Stmt) sql.FromItem {
 }
 
 func (p *Parser) parseUpdate() sql.Stmt {
-	// UPDATE [database '.'] table SET column '=' (expr | DEFAULT) [',' ...] [WHERE expr]
+	/*
+		UPDATE [database '.'] table SET column '=' (expr | DEFAULT) [',' ...]
+			[FROM from-item [',' ...]] [WHERE expr] [RETURNING select-list]
+	*/
 	var s sql.Update
 	s.Table = p.parseTableName()
 	p.expectReserved(types.SET)
+	s.ColumnUpdates = p.parseColumnUpdates()
+
+	if p.optionalReserved(types.FROM) {
+		s.From = p.parseFromList()
+	}
+
+	if p.optionalReserved(types.WHERE) {
+		s.Where = p.parseExpr()
+	}
+	s.Returning = p.parseReturning()
+
+	return &s
+}
 
+func (p *Parser) parseColumnUpdates() []sql.ColumnUpdate {
+	// column '=' (expr | DEFAULT) [',' ...]
+	var cus []sql.ColumnUpdate
 	for {
 		var cu sql.ColumnUpdate
 		cu.Column = p.expectIdentifier("expected a column name")
@@ -1639,24 +2292,26 @@ func (p *Parser) parseUpdate() sql.Stmt {
 			p.unscan()
 			cu.Expr = p.parseExpr()
 		}
-		s.ColumnUpdates = append(s.ColumnUpdates, cu)
+		cus = append(cus, cu)
 		if !p.maybeToken(token.Comma) {
 			break
 		}
 	}
 
-	if p.optionalReserved(types.WHERE) {
-		s.Where = p.parseExpr()
-	}
-
-	return &s
+	return cus
 }
 
 func (p *Parser) parseSet() sql.Stmt {
 	// SET variable ( TO | '=' ) literal
+	// SET TRANSACTION transaction_mode [[','] ...]
+	// SET CONSTRAINTS ( ALL | constraint [',' ...] ) ( DEFERRED | IMMEDIATE )
 	var s sql.Set
 
-	if p.optionalReserved(types.DATABASE) {
+	if p.optionalReserved(types.TRANSACTION) {
+		return p.parseSetTransaction()
+	} else if p.maybeIdentifier(types.CONSTRAINTS) {
+		return p.parseSetConstraints()
+	} else if p.optionalReserved(types.DATABASE) {
 		s.Variable = types.DATABASE
 	} else if p.optionalReserved(types.SCHEMA) {
 		s.Variable = types.SCHEMA
@@ -1680,6 +2335,125 @@ func (p *Parser) parseSet() sql.Stmt {
 	return &s
 }
 
+func (p *Parser) expectKeyword(id types.Identifier) {
+	if !p.maybeIdentifier(id) {
+		p.scan()
+		p.error(fmt.Sprintf("expected %s, got %s", id, p.got()))
+	}
+}
+
+func (p *Parser) parseBegin() sql.Stmt {
+	var s sql.Begin
+	s.Modes, s.AsOf = p.parseTransactionModes(true)
+	return &s
+}
+
+func (p *Parser) parseSetTransaction() sql.Stmt {
+	var s sql.SetTransaction
+	s.Modes, _ = p.parseTransactionModes(false)
+	return &s
+}
+
+func (p *Parser) parseSetConstraints() sql.Stmt {
+	var s sql.SetConstraints
+	if p.optionalReserved(types.ALL) {
+		s.All = true
+	} else {
+		for {
+			s.Names = append(s.Names, p.expectIdentifier("expected a constraint name"))
+			if !p.maybeToken(token.Comma) {
+				break
+			}
+		}
+	}
+
+	if p.maybeIdentifier(types.DEFERRED) {
+		s.Deferred = true
+	} else {
+		p.expectKeyword(types.IMMEDIATE)
+	}
+	return &s
+}
+
+func (p *Parser) parseTransactionModes(begin bool) (sql.TransactionModes, string) {
+	/*
+		transaction_mode =
+			  ISOLATION LEVEL isolation_level
+			| READ ONLY
+			| READ WRITE
+			| AS OF SYSTEM TIME 'timestamp'
+		isolation_level = SERIALIZABLE | SNAPSHOT | REPEATABLE READ | READ COMMITTED
+			| READ UNCOMMITTED
+
+		AS OF SYSTEM TIME is only allowed with BEGIN, and SET TRANSACTION requires at least one
+		mode.
+	*/
+
+	var tm sql.TransactionModes
+	var asOf string
+	first := true
+	for {
+		comma := !first && p.maybeToken(token.Comma)
+		if p.maybeIdentifier(types.ISOLATION) {
+			if tm.Isolation != 0 {
+				p.error("isolation level specified more than once")
+			}
+			p.expectKeyword(types.LEVEL)
+			tm.Isolation = p.parseIsolationLevel()
+		} else if p.maybeIdentifier(types.READ) {
+			if tm.ReadOnly || tm.ReadWrite {
+				p.error("read only or read write specified more than once")
+			}
+			if p.maybeIdentifier(types.ONLY) {
+				tm.ReadOnly = true
+			} else {
+				p.expectKeyword(types.WRITE)
+				tm.ReadWrite = true
+			}
+		} else if begin && p.optionalReserved(types.AS) {
+			if asOf != "" {
+				p.error("as of system time specified more than once")
+			}
+			p.expectKeyword(types.OF)
+			p.expectKeyword(types.SYSTEM)
+			p.expectKeyword(types.TIME)
+			if p.scan() != token.String || p.sctx.String == "" {
+				p.error(fmt.Sprintf("expected a timestamp, got %s", p.got()))
+			}
+			asOf = p.sctx.String
+		} else if comma || (first && !begin) {
+			p.scan()
+			p.error(fmt.Sprintf("expected a transaction mode, got %s", p.got()))
+		} else {
+			break
+		}
+		first = false
+	}
+
+	return tm, asOf
+}
+
+func (p *Parser) parseIsolationLevel() sql.IsolationLevel {
+	if p.maybeIdentifier(types.SERIALIZABLE) {
+		return sql.Serializable
+	} else if p.maybeIdentifier(types.SNAPSHOT) {
+		return sql.Snapshot
+	} else if p.maybeIdentifier(types.REPEATABLE) {
+		p.expectKeyword(types.READ)
+		return sql.RepeatableRead
+	} else if p.maybeIdentifier(types.READ) {
+		if p.maybeIdentifier(types.COMMITTED) {
+			return sql.ReadCommitted
+		}
+		p.expectKeyword(types.UNCOMMITTED)
+		return sql.ReadUncommitted
+	}
+
+	p.scan()
+	p.error(fmt.Sprintf("expected an isolation level, got %s", p.got()))
+	return 0
+}
+
 func (p *Parser) parseShowFromTable() (types.TableName, sql.Expr) {
 	tn := p.parseTableName()
 
@@ -714,6 +714,44 @@ constraint fkey foreign key (c3, c4, c2) references t3 (p1, p2, p3) on update no
 				},
 			},
 		},
+		{
+			s: `create table t (c1 int references t2 on delete cascade initially deferred,
+c2 int references t3 deferrable initially immediate not null,
+foreign key (c2) references t4 deferrable)`,
+			stmt: sql.CreateTable{
+				Table:   types.TableName{Table: types.ID("t", false)},
+				Columns: []types.Identifier{types.ID("c1", false), types.ID("c2", false)},
+				ColumnTypes: []types.ColumnType{
+					{Type: types.Int64Type, Size: 4},
+					{Type: types.Int64Type, Size: 4, NotNull: true},
+				},
+				ColumnDefaults: []sql.Expr{nil, nil},
+				ForeignKeys: []*sql.ForeignKey{
+					&sql.ForeignKey{
+						FKCols:   []types.Identifier{types.ID("c1", false)},
+						RefTable: types.TableName{Table: types.ID("t2", false)},
+						OnDelete: sql.Cascade,
+						Deferred: true,
+					},
+					&sql.ForeignKey{
+						FKCols:   []types.Identifier{types.ID("c2", false)},
+						RefTable: types.TableName{Table: types.ID("t3", false)},
+					},
+					&sql.ForeignKey{
+						FKCols:   []types.Identifier{types.ID("c2", false)},
+						RefTable: types.TableName{Table: types.ID("t4", false)},
+					},
+				},
+			},
+		},
+		{
+			s:    "create table t (c1 int references t2 initially, c2 int)",
+			fail: true,
+		},
+		{
+			s:    "create table t (c1 int references t2 initially never, c2 int)",
+			fail: true,
+		},
 		{
 			s:    "create table t (c1 int, c2 int, c3 int, foreign key c1 references t2)",
 			fail: true,
@@ -783,6 +821,64 @@ foreign key (c1) references t2 on delete restrict on update restrict on delete n
 foreign key (c1) references t2 on delete set on update cascade)`,
 			fail: true,
 		},
+		{
+			s: "create table t (c1 smallserial, c2 serial, c3 bigserial)",
+			stmt: sql.CreateTable{
+				Table: types.TableName{Table: types.ID("t", false)},
+				Columns: []types.Identifier{
+					types.ID("c1", false),
+					types.ID("c2", false),
+					types.ID("c3", false),
+				},
+				ColumnTypes: []types.ColumnType{
+					{Type: types.Int64Type, Size: 2},
+					{Type: types.Int64Type, Size: 4},
+					{Type: types.Int64Type, Size: 8},
+				},
+				ColumnDefaults: []sql.Expr{nil, nil, nil},
+				Identities: []sql.ColumnIdentity{
+					{ColNum: 0, Serial: true},
+					{ColNum: 1, Serial: true},
+					{ColNum: 2, Serial: true},
+				},
+			},
+		},
+		{
+			s: `create table t (c1 int generated always as identity,
+c2 bigint not null generated by default as identity (start with 10 increment by 5 cycle))`,
+			stmt: sql.CreateTable{
+				Table: types.TableName{Table: types.ID("t", false)},
+				Columns: []types.Identifier{
+					types.ID("c1", false),
+					types.ID("c2", false),
+				},
+				ColumnTypes: []types.ColumnType{
+					{Type: types.Int64Type, Size: 4},
+					{Type: types.Int64Type, Size: 8, NotNull: true},
+				},
+				ColumnDefaults: []sql.Expr{nil, nil},
+				Identities: []sql.ColumnIdentity{
+					{ColNum: 0, Always: true},
+					{
+						ColNum: 1,
+						Options: sql.SequenceOptions{
+							Increment: int64Ptr(5),
+							Start:     int64Ptr(10),
+							Cycle:     boolPtr(true),
+						},
+					},
+				},
+			},
+		},
+		{s: "create table t (c1 serial default 1)", fail: true},
+		{s: "create table t (c1 int default 1 generated always as identity)", fail: true},
+		{s: "create table t (c1 serial generated always as identity)", fail: true},
+		{s: "create table t (c1 text generated always as identity)", fail: true},
+		{s: "create table t (c1 int generated as identity)", fail: true},
+		{s: "create table t (c1 int generated always identity)", fail: true},
+		{s: "create table t (c1 int generated always as)", fail: true},
+		{s: "create table t (c1 int generated always as identity (restart))", fail: true},
+		{s: "create table t (c1 int generated always as identity (start 1 start 2))", fail: true},
 	}
 
 	for i, c := range cases {
@@ -905,6 +1001,103 @@ func TestInsertValues(t *testing.T) {
 				},
 			},
 		},
+		{s: "insert into t select", fail: true},
+		{s: "insert into t values (1) on conflict", fail: true},
+		{s: "insert into t values (1) on conflict do", fail: true},
+		{s: "insert into t values (1) on conflict () do nothing", fail: true},
+		{s: "insert into t values (1) on conflict (a, a) do nothing", fail: true},
+		{s: "insert into t values (1) on conflict (a) do update", fail: true},
+		{s: "insert into t values (1) on conflict (a) do update set", fail: true},
+		{s: "insert into t values (1) on conflict do nothing where a > 1", fail: true},
+		{s: "insert into t values (1) returning", fail: true},
+		{s: "insert into t values (1) returning *, a", fail: true},
+		{s: "insert into t values (1) returning a,", fail: true},
+		{
+			s: "insert into t (a, b) select * from u",
+			stmt: sql.InsertValues{
+				Table:   types.TableName{Table: types.ID("t", false)},
+				Columns: []types.Identifier{types.ID("a", false), types.ID("b", false)},
+				Query: &sql.Select{
+					From: &sql.FromTableAlias{
+						TableName: types.TableName{Table: types.ID("u", false)},
+					},
+				},
+			},
+		},
+		{
+			s: "insert into t select * from u on conflict do nothing",
+			stmt: sql.InsertValues{
+				Table: types.TableName{Table: types.ID("t", false)},
+				Query: &sql.Select{
+					From: &sql.FromTableAlias{
+						TableName: types.TableName{Table: types.ID("u", false)},
+					},
+				},
+				OnConflict: &sql.OnConflict{DoNothing: true},
+			},
+		},
+		{
+			s: "insert into t values (1, 2) on conflict (a, b) do nothing",
+			stmt: sql.InsertValues{
+				Table: types.TableName{Table: types.ID("t", false)},
+				Rows:  [][]sql.Expr{{int64Literal(1), int64Literal(2)}},
+				OnConflict: &sql.OnConflict{
+					Columns:   []types.Identifier{types.ID("a", false), types.ID("b", false)},
+					DoNothing: true,
+				},
+			},
+		},
+		{
+			s: "insert into t values (1, 2) on conflict (a) do update set b = excluded.b, " +
+				"c = default where t.b > 1",
+			stmt: sql.InsertValues{
+				Table: types.TableName{Table: types.ID("t", false)},
+				Rows:  [][]sql.Expr{{int64Literal(1), int64Literal(2)}},
+				OnConflict: &sql.OnConflict{
+					Columns: []types.Identifier{types.ID("a", false)},
+					ColumnUpdates: []sql.ColumnUpdate{
+						{
+							Column: types.ID("b", false),
+							Expr:   sql.Ref{types.ID("excluded", false), types.ID("b", false)},
+						},
+						{Column: types.ID("c", false)},
+					},
+					Where: &sql.BinaryExpr{
+						Op:    sql.GreaterThanOp,
+						Left:  sql.Ref{types.ID("t", false), types.ID("b", false)},
+						Right: int64Literal(1),
+					},
+				},
+			},
+		},
+		{
+			s: "insert into t values (1) returning *",
+			stmt: sql.InsertValues{
+				Table:     types.TableName{Table: types.ID("t", false)},
+				Rows:      [][]sql.Expr{{int64Literal(1)}},
+				Returning: []sql.SelectResult{sql.StarResult{}},
+			},
+		},
+		{
+			s: "insert into t select * from u on conflict do nothing returning a, b + 1 as c",
+			stmt: sql.InsertValues{
+				Table: types.TableName{Table: types.ID("t", false)},
+				Query: &sql.Select{
+					From: &sql.FromTableAlias{
+						TableName: types.TableName{Table: types.ID("u", false)},
+					},
+				},
+				OnConflict: &sql.OnConflict{DoNothing: true},
+				Returning: []sql.SelectResult{
+					sql.ExprResult{Expr: sql.Ref{types.ID("a", false)}},
+					sql.ExprResult{
+						Expr: &sql.BinaryExpr{Op: sql.AddOp, Left: sql.Ref{types.ID("b", false)},
+							Right: int64Literal(1)},
+						Alias: types.ID("c", false),
+					},
+				},
+			},
+		},
 	}
 
 	for i, c := range cases {
@@ -1715,6 +1908,9 @@ func TestDelete(t *testing.T) {
 		{s: "delete from", fail: true},
 		{s: "delete from t1, t2", fail: true},
 		{s: "delete from t where", fail: true},
+		{s: "delete from t returning", fail: true},
+		{s: "delete from t using", fail: true},
+		{s: "delete from t using u,", fail: true},
 		{
 			s: "delete from t",
 			stmt: sql.Delete{
@@ -1729,6 +1925,39 @@ func TestDelete(t *testing.T) {
 					Right: int64Literal(1)},
 			},
 		},
+		{
+			s: "delete from t where c > 1 returning t.*, c d",
+			stmt: sql.Delete{
+				Table: types.TableName{Table: types.ID("t", false)},
+				Where: &sql.BinaryExpr{Op: sql.GreaterThanOp, Left: sql.Ref{types.ID("c", false)},
+					Right: int64Literal(1)},
+				Returning: []sql.SelectResult{
+					sql.TableResult{Table: types.ID("t", false)},
+					sql.ExprResult{Expr: sql.Ref{types.ID("c", false)},
+						Alias: types.ID("d", false)},
+				},
+			},
+		},
+		{
+			s: "delete from t using u, v where t.c = u.c",
+			stmt: sql.Delete{
+				Table: types.TableName{Table: types.ID("t", false)},
+				Using: sql.FromJoin{
+					Left: &sql.FromTableAlias{
+						TableName: types.TableName{Table: types.ID("u", false)},
+					},
+					Right: &sql.FromTableAlias{
+						TableName: types.TableName{Table: types.ID("v", false)},
+					},
+					Type: sql.CrossJoin,
+				},
+				Where: &sql.BinaryExpr{
+					Op:    sql.EqualOp,
+					Left:  sql.Ref{types.ID("t", false), types.ID("c", false)},
+					Right: sql.Ref{types.ID("u", false), types.ID("c", false)},
+				},
+			},
+		},
 	}
 
 	for i, c := range cases {
@@ -1748,6 +1977,181 @@ func TestDelete(t *testing.T) {
 	}
 }
 
+func TestTruncate(t *testing.T) {
+	cases := []struct {
+		s    string
+		stmt sql.Truncate
+		fail bool
+	}{
+		{s: "truncate", fail: true},
+		{s: "truncate table", fail: true},
+		{s: "truncate t restart", fail: true},
+		{s: "truncate t continue", fail: true},
+		{s: "truncate t cascade restart identity", fail: true},
+		{
+			s: "truncate t",
+			stmt: sql.Truncate{
+				Tables: []types.TableName{{Table: types.ID("t", false)}},
+			},
+		},
+		{
+			s: "truncate table t1, s.t2 restrict",
+			stmt: sql.Truncate{
+				Tables: []types.TableName{
+					{Table: types.ID("t1", false)},
+					{Schema: types.ID("s", false), Table: types.ID("t2", false)},
+				},
+			},
+		},
+		{
+			s: "truncate t continue identity",
+			stmt: sql.Truncate{
+				Tables: []types.TableName{{Table: types.ID("t", false)}},
+			},
+		},
+		{
+			s: "truncate table t restart identity cascade",
+			stmt: sql.Truncate{
+				Tables:          []types.TableName{{Table: types.ID("t", false)}},
+				RestartIdentity: true,
+				Cascade:         true,
+			},
+		},
+	}
+
+	for i, c := range cases {
+		p := NewParser(strings.NewReader(c.s), fmt.Sprintf("tests[%d]", i))
+		stmt, err := p.Parse()
+		if c.fail {
+			if err == nil {
+				t.Errorf("Parse(%s) did not fail", c.s)
+			}
+		} else {
+			if err != nil {
+				t.Errorf("Parse(%s) failed with %s", c.s, err)
+			} else if stmt, ok := stmt.(*sql.Truncate); !ok || !reflect.DeepEqual(&c.stmt, stmt) {
+				t.Errorf("Parse(%s) got %s want %s", c.s, stmt.String(), c.stmt.String())
+			}
+		}
+	}
+}
+
+func TestCopy(t *testing.T) {
+	tn := types.TableName{Table: types.ID("t", false)}
+
+	cases := []struct {
+		s    string
+		stmt sql.Copy
+		fail bool
+	}{
+		{s: "copy t", fail: true},
+		{s: "copy t to stdin", fail: true},
+		{s: "copy t from stdout", fail: true},
+		{s: "copy (select * from t) from stdin", fail: true},
+		{s: "copy t to stdout with (format json)", fail: true},
+		{s: "copy t to stdout with (format csv, format text)", fail: true},
+		{s: "copy t to stdout (delimiter 'ab')", fail: true},
+		{s: "copy t to stdout (format binary, header)", fail: true},
+		{s: "copy t to stdout (format text, quote '''')", fail: true},
+		{s: "copy t to stdout (force_quote *)", fail: true},
+		{s: "copy t from stdin (format csv, force_quote *)", fail: true},
+		{s: "copy t to stdout (format csv, delimiter '\"')", fail: true},
+		{s: `copy t to stdout (delimiter '\')`, fail: true},
+		{
+			s: "copy t to stdout",
+			stmt: sql.Copy{
+				Table:     tn,
+				To:        true,
+				Delimiter: '\t',
+				Null:      `\N`,
+			},
+		},
+		{
+			s: "copy t (c1, c2) from stdin delimiter ','",
+			stmt: sql.Copy{
+				Table:     tn,
+				Columns:   []types.Identifier{types.ID("c1", false), types.ID("c2", false)},
+				Delimiter: ',',
+				Null:      `\N`,
+			},
+		},
+		{
+			s: "copy t from stdin with (format csv, header, null 'NULL', quote '''', escape '\\')",
+			stmt: sql.Copy{
+				Table:     tn,
+				Format:    sql.CSVFormat,
+				Delimiter: ',',
+				Header:    true,
+				Null:      "NULL",
+				Quote:     '\'',
+				Escape:    '\\',
+			},
+		},
+		{
+			s: "copy (select c1 from t) to stdout (format csv, header false, force_quote *)",
+			stmt: sql.Copy{
+				Query: &sql.Select{
+					Results: []sql.SelectResult{
+						sql.ExprResult{Expr: sql.Ref{types.ID("c1", false)}},
+					},
+					From: &sql.FromTableAlias{TableName: tn},
+				},
+				To:            true,
+				Format:        sql.CSVFormat,
+				Delimiter:     ',',
+				Quote:         '"',
+				Escape:        '"',
+				ForceQuoteAll: true,
+			},
+		},
+		{
+			s: "copy t to stdout (format csv, delimiter '|', force_quote (c1, c2))",
+			stmt: sql.Copy{
+				Table:      tn,
+				To:         true,
+				Format:     sql.CSVFormat,
+				Delimiter:  '|',
+				Quote:      '"',
+				Escape:     '"',
+				ForceQuote: []types.Identifier{types.ID("c1", false), types.ID("c2", false)},
+			},
+		},
+		{
+			s: "copy t to stdout with (format binary)",
+			stmt: sql.Copy{
+				Table:     tn,
+				To:        true,
+				Format:    sql.BinaryFormat,
+				Delimiter: '\t',
+			},
+		},
+	}
+
+	for i, c := range cases {
+		p := NewParser(strings.NewReader(c.s), fmt.Sprintf("tests[%d]", i))
+		stmt, err := p.Parse()
+		if c.fail {
+			if err == nil {
+				t.Errorf("Parse(%s) did not fail", c.s)
+			}
+		} else {
+			if err != nil {
+				t.Errorf("Parse(%s) failed with %s", c.s, err)
+				continue
+			}
+
+			stmt, ok := stmt.(*sql.Copy)
+			if ok {
+				stmt.From = nil
+				stmt.FromLine = 0
+			}
+			if !ok || !reflect.DeepEqual(&c.stmt, stmt) {
+				t.Errorf("Parse(%s) got %#v want %#v", c.s, stmt, c.stmt)
+			}
+		}
+	}
+}
+
 func TestUpdate(t *testing.T) {
 	cases := []struct {
 		s    string
@@ -1763,6 +2167,9 @@ func TestUpdate(t *testing.T) {
 		{s: "update t set c = 5, where", fail: true},
 		{s: "update t set c = 5 where", fail: true},
 		{s: "update t set where c = 6", fail: true},
+		{s: "update t set c = 5 returning", fail: true},
+		{s: "update t set c = 5 from", fail: true},
+		{s: "update t set c = 5 from u where", fail: true},
 		{
 			s: "update t set c = 5",
 			stmt: sql.Update{
@@ -1805,6 +2212,37 @@ func TestUpdate(t *testing.T) {
 				},
 			},
 		},
+		{
+			s: "update t set c = 5 returning *",
+			stmt: sql.Update{
+				Table: types.TableName{Table: types.ID("t", false)},
+				ColumnUpdates: []sql.ColumnUpdate{
+					{Column: types.ID("c", false), Expr: int64Literal(5)},
+				},
+				Returning: []sql.SelectResult{sql.StarResult{}},
+			},
+		},
+		{
+			s: "update t set c = u.c from u as v where t.d = v.d",
+			stmt: sql.Update{
+				Table: types.TableName{Table: types.ID("t", false)},
+				ColumnUpdates: []sql.ColumnUpdate{
+					{
+						Column: types.ID("c", false),
+						Expr:   sql.Ref{types.ID("u", false), types.ID("c", false)},
+					},
+				},
+				From: &sql.FromTableAlias{
+					TableName: types.TableName{Table: types.ID("u", false)},
+					Alias:     types.ID("v", false),
+				},
+				Where: &sql.BinaryExpr{
+					Op:    sql.EqualOp,
+					Left:  sql.Ref{types.ID("t", false), types.ID("d", false)},
+					Right: sql.Ref{types.ID("v", false), types.ID("d", false)},
+				},
+			},
+		},
 	}
 
 	for i, c := range cases {
@@ -1942,6 +2380,45 @@ add constraint con2 foreign key (c2) references tbl2`,
 add constraint con2 foreign key (c2) references tbl2, fail`,
 			fail: true,
 		},
+		{
+			s: "alter table tbl add constraint con check (c1 > 0), add check (c2 != 'abc')",
+			stmt: &sql.AlterTable{
+				Table: types.TableName{Table: types.ID("tbl", false)},
+				Actions: []sql.AlterAction{
+					&sql.AddConstraint{
+						Constraint: sql.Constraint{
+							Type:   sql.CheckConstraint,
+							Name:   types.ID("con", false),
+							ColNum: -1,
+							Check: &sql.BinaryExpr{
+								Op:    sql.GreaterThanOp,
+								Left:  sql.Ref{types.ID("c1", false)},
+								Right: sql.Literal{Value: types.Int64Value(0)},
+							},
+						},
+					},
+					&sql.AddConstraint{
+						Constraint: sql.Constraint{
+							Type:   sql.CheckConstraint,
+							ColNum: -1,
+							Check: &sql.BinaryExpr{
+								Op:    sql.NotEqualOp,
+								Left:  sql.Ref{types.ID("c2", false)},
+								Right: sql.Literal{Value: types.StringValue("abc")},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			s:    "alter table tbl add constraint con check c1 > 0",
+			fail: true,
+		},
+		{
+			s:    "alter table tbl add constraint con primary key (c1)",
+			fail: true,
+		},
 		{
 			s: "alter table tbl drop constraint if exists con",
 			stmt: &sql.AlterTable{
@@ -2005,6 +2482,405 @@ alter column c1 drop default, alter c2 drop not null, drop constraint con`,
 			s:    "alter table tbl alter c1 drop null",
 			fail: true,
 		},
+		{
+			s:    "alter table tbl add c1 serial",
+			fail: true,
+		},
+		{
+			s:    "alter table tbl add c1 int generated by default as identity",
+			fail: true,
+		},
+		{
+			s:    "alter table tbl alter c1 type serial",
+			fail: true,
+		},
+		{
+			s: `alter table tbl add c1 int not null default 123, add column c2 text,
+drop column c3, drop column if exists c4`,
+			stmt: &sql.AlterTable{
+				Table: types.TableName{Table: types.ID("tbl", false)},
+				Actions: []sql.AlterAction{
+					&sql.AddColumn{
+						Column:  types.ID("c1", false),
+						Type:    types.ColumnType{Type: types.Int64Type, Size: 4, NotNull: true},
+						Default: sql.Literal{Value: types.Int64Value(123)},
+					},
+					&sql.AddColumn{
+						Column: types.ID("c2", false),
+						Type: types.ColumnType{Type: types.StringType,
+							Size: types.MaxColumnSize},
+					},
+					&sql.DropColumn{
+						Column: types.ID("c3", false),
+					},
+					&sql.DropColumn{
+						Column:   types.ID("c4", false),
+						IfExists: true,
+					},
+				},
+			},
+		},
+		{
+			s: `alter table tbl alter c1 set default 'abc', alter column c2 set not null,
+alter c3 type bigint, alter column c4 set data type varchar(16), rename c5 to c6,
+rename column c7 to c8`,
+			stmt: &sql.AlterTable{
+				Table: types.TableName{Table: types.ID("tbl", false)},
+				Actions: []sql.AlterAction{
+					&sql.AlterColumn{
+						Column:  types.ID("c1", false),
+						Action:  sql.SetColumnDefault,
+						Default: sql.Literal{Value: types.StringValue("abc")},
+					},
+					&sql.AlterColumn{
+						Column: types.ID("c2", false),
+						Action: sql.SetColumnNotNull,
+					},
+					&sql.AlterColumn{
+						Column: types.ID("c3", false),
+						Action: sql.SetColumnType,
+						Type:   types.ColumnType{Type: types.Int64Type, Size: 8},
+					},
+					&sql.AlterColumn{
+						Column: types.ID("c4", false),
+						Action: sql.SetColumnType,
+						Type:   types.ColumnType{Type: types.StringType, Size: 16},
+					},
+					&sql.RenameColumn{
+						Column:  types.ID("c5", false),
+						NewName: types.ID("c6", false),
+					},
+					&sql.RenameColumn{
+						Column:  types.ID("c7", false),
+						NewName: types.ID("c8", false),
+					},
+				},
+			},
+		},
+		{
+			s:    "alter table tbl add column c1 int primary key",
+			fail: true,
+		},
+		{
+			s:    "alter table tbl add column c1 int references tbl2",
+			fail: true,
+		},
+		{
+			s:    "alter table tbl drop column",
+			fail: true,
+		},
+		{
+			s:    "alter table tbl alter c1 set data bigint",
+			fail: true,
+		},
+		{
+			s:    "alter table tbl alter c1 set null",
+			fail: true,
+		},
+		{
+			s:    "alter table tbl rename c1 c2",
+			fail: true,
+		},
+		{
+			s: "alter table tbl rename to tbl2",
+			stmt: &sql.AlterTable{
+				Table: types.TableName{Table: types.ID("tbl", false)},
+				Actions: []sql.AlterAction{
+					&sql.RenameTable{
+						NewName: types.ID("tbl2", false),
+					},
+				},
+			},
+		},
+		{
+			s: "alter table sn.tbl set schema sn2",
+			stmt: &sql.AlterTable{
+				Table: types.TableName{
+					Schema: types.ID("sn", false),
+					Table:  types.ID("tbl", false),
+				},
+				Actions: []sql.AlterAction{
+					&sql.SetSchema{
+						Schema: types.ID("sn2", false),
+					},
+				},
+			},
+		},
+		{
+			s:    "alter table tbl rename to tbl2, drop column c1",
+			fail: true,
+		},
+		{
+			s:    "alter table tbl drop column c1, rename to tbl2",
+			fail: true,
+		},
+		{
+			s:    "alter table tbl drop column c1, set schema sn2",
+			fail: true,
+		},
+		{
+			s:    "alter table tbl set schema",
+			fail: true,
+		},
+	}
+
+	for i, c := range cases {
+		p := NewParser(strings.NewReader(c.s), fmt.Sprintf("tests[%d]", i))
+		stmt, err := p.Parse()
+		if c.fail {
+			if err == nil {
+				t.Errorf("Parse(%s) did not fail", c.s)
+			}
+		} else {
+			if err != nil {
+				t.Errorf("Parse(%s) failed with %s", c.s, err)
+			} else {
+				if !reflect.DeepEqual(c.stmt, stmt) {
+					t.Errorf("Parse(%s) got %s want %s", c.s, stmt.String(), c.stmt.String())
+				}
+			}
+		}
+	}
+}
+
+func int64Ptr(n int64) *int64 {
+	return &n
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestSequences(t *testing.T) {
+	cases := []struct {
+		s    string
+		stmt sql.Stmt
+		fail bool
+	}{
+		{s: "create sequence", fail: true},
+		{
+			s: "create sequence seq",
+			stmt: &sql.CreateSequence{
+				Sequence: types.TableName{Table: types.ID("seq", false)},
+			},
+		},
+		{
+			s: "create sequence if not exists sn.seq increment by -2 minvalue -100 " +
+				"no maxvalue start with 10 cycle",
+			stmt: &sql.CreateSequence{
+				Sequence: types.TableName{
+					Schema: types.ID("sn", false),
+					Table:  types.ID("seq", false),
+				},
+				IfNotExists: true,
+				Options: sql.SequenceOptions{
+					Increment:  int64Ptr(-2),
+					MinValue:   int64Ptr(-100),
+					Start:      int64Ptr(10),
+					Cycle:      boolPtr(true),
+					NoMaxValue: true,
+				},
+			},
+		},
+		{
+			s: "create sequence seq increment 5 maxvalue 100 no minvalue start 1 no cycle",
+			stmt: &sql.CreateSequence{
+				Sequence: types.TableName{Table: types.ID("seq", false)},
+				Options: sql.SequenceOptions{
+					Increment:  int64Ptr(5),
+					MaxValue:   int64Ptr(100),
+					Start:      int64Ptr(1),
+					Cycle:      boolPtr(false),
+					NoMinValue: true,
+				},
+			},
+		},
+		{s: "create sequence seq increment by 1 increment by 2", fail: true},
+		{s: "create sequence seq minvalue 1 no minvalue", fail: true},
+		{s: "create sequence seq cycle no cycle", fail: true},
+		{s: "create sequence seq no start", fail: true},
+		{s: "create sequence seq restart", fail: true},
+		{s: "create sequence seq start with", fail: true},
+		{
+			s: "alter sequence seq restart",
+			stmt: &sql.AlterSequence{
+				Sequence: types.TableName{Table: types.ID("seq", false)},
+				Restart:  true,
+			},
+		},
+		{
+			s: "alter sequence if exists seq restart -5 increment by 3",
+			stmt: &sql.AlterSequence{
+				Sequence:    types.TableName{Table: types.ID("seq", false)},
+				IfExists:    true,
+				Restart:     true,
+				RestartWith: int64Ptr(-5),
+				Options: sql.SequenceOptions{
+					Increment: int64Ptr(3),
+				},
+			},
+		},
+		{
+			s: "alter sequence seq no maxvalue restart with 7",
+			stmt: &sql.AlterSequence{
+				Sequence:    types.TableName{Table: types.ID("seq", false)},
+				Restart:     true,
+				RestartWith: int64Ptr(7),
+				Options: sql.SequenceOptions{
+					NoMaxValue: true,
+				},
+			},
+		},
+		{s: "alter sequence seq restart restart", fail: true},
+		{
+			s: "drop sequence seq",
+			stmt: &sql.DropSequence{
+				Sequences: []types.TableName{{Table: types.ID("seq", false)}},
+			},
+		},
+		{
+			s: "drop sequence if exists seq1, db.sn.seq2",
+			stmt: &sql.DropSequence{
+				IfExists: true,
+				Sequences: []types.TableName{
+					{Table: types.ID("seq1", false)},
+					{
+						Database: types.ID("db", false),
+						Schema:   types.ID("sn", false),
+						Table:    types.ID("seq2", false),
+					},
+				},
+			},
+		},
+		{s: "drop sequence", fail: true},
+	}
+
+	for i, c := range cases {
+		p := NewParser(strings.NewReader(c.s), fmt.Sprintf("tests[%d]", i))
+		stmt, err := p.Parse()
+		if c.fail {
+			if err == nil {
+				t.Errorf("Parse(%s) did not fail", c.s)
+			}
+		} else {
+			if err != nil {
+				t.Errorf("Parse(%s) failed with %s", c.s, err)
+			} else {
+				if !reflect.DeepEqual(c.stmt, stmt) {
+					t.Errorf("Parse(%s) got %s want %s", c.s, stmt.String(), c.stmt.String())
+				}
+			}
+		}
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	cases := []struct {
+		s    string
+		stmt sql.Stmt
+		fail bool
+	}{
+		{s: "begin", stmt: &sql.Begin{}},
+		{s: "start transaction", stmt: &sql.Begin{}},
+		{s: "commit", stmt: &sql.Commit{}},
+		{s: "rollback", stmt: &sql.Rollback{}},
+		{s: "savepoint sp1", stmt: &sql.Savepoint{Name: types.ID("sp1", false)}},
+		{s: "savepoint", fail: true},
+		{s: "rollback to sp1", stmt: &sql.RollbackTo{Savepoint: types.ID("sp1", false)}},
+		{
+			s:    "rollback to savepoint sp1",
+			stmt: &sql.RollbackTo{Savepoint: types.ID("sp1", false)},
+		},
+		{s: "rollback to", fail: true},
+		{s: "release sp1", stmt: &sql.Release{Savepoint: types.ID("sp1", false)}},
+		{s: "release savepoint sp1", stmt: &sql.Release{Savepoint: types.ID("sp1", false)}},
+		{s: "release savepoint", fail: true},
+		{
+			s:    "set transaction isolation level serializable",
+			stmt: &sql.SetTransaction{Modes: sql.TransactionModes{Isolation: sql.Serializable}},
+		},
+		{
+			s:    "set transaction isolation level snapshot",
+			stmt: &sql.SetTransaction{Modes: sql.TransactionModes{Isolation: sql.Snapshot}},
+		},
+		{
+			s:    "set transaction isolation level repeatable read",
+			stmt: &sql.SetTransaction{Modes: sql.TransactionModes{Isolation: sql.RepeatableRead}},
+		},
+		{
+			s:    "set transaction isolation level read committed",
+			stmt: &sql.SetTransaction{Modes: sql.TransactionModes{Isolation: sql.ReadCommitted}},
+		},
+		{
+			s:    "set transaction isolation level read uncommitted",
+			stmt: &sql.SetTransaction{Modes: sql.TransactionModes{Isolation: sql.ReadUncommitted}},
+		},
+		{s: "set transaction", fail: true},
+		{s: "set transaction isolation serializable", fail: true},
+		{s: "set transaction isolation level", fail: true},
+		{s: "set transaction isolation level repeatable", fail: true},
+		{s: "set transaction isolation level read", fail: true},
+		{s: "set transaction isolation level committed", fail: true},
+		{
+			s:    "set transaction read only",
+			stmt: &sql.SetTransaction{Modes: sql.TransactionModes{ReadOnly: true}},
+		},
+		{
+			s:    "set transaction read write",
+			stmt: &sql.SetTransaction{Modes: sql.TransactionModes{ReadWrite: true}},
+		},
+		{
+			s: "set transaction read only, isolation level snapshot",
+			stmt: &sql.SetTransaction{
+				Modes: sql.TransactionModes{Isolation: sql.Snapshot, ReadOnly: true},
+			},
+		},
+		{s: "set transaction read", fail: true},
+		{s: "set transaction read only,", fail: true},
+		{s: "set transaction read only read write", fail: true},
+		{s: "set transaction as of system time '-10s'", fail: true},
+		{s: "set constraints all deferred", stmt: &sql.SetConstraints{All: true, Deferred: true}},
+		{s: "set constraints all immediate", stmt: &sql.SetConstraints{All: true}},
+		{
+			s: "set constraints fk1, fk2 deferred",
+			stmt: &sql.SetConstraints{
+				Names:    []types.Identifier{types.ID("fk1", false), types.ID("fk2", false)},
+				Deferred: true,
+			},
+		},
+		{s: "set constraints", fail: true},
+		{s: "set constraints all", fail: true},
+		{s: "set constraints fk1, deferred", fail: true},
+		{s: "set constraints all later", fail: true},
+		{s: "begin transaction", stmt: &sql.Begin{}},
+		{s: "begin read only", stmt: &sql.Begin{Modes: sql.TransactionModes{ReadOnly: true}}},
+		{s: "begin read write", stmt: &sql.Begin{Modes: sql.TransactionModes{ReadWrite: true}}},
+		{
+			s: "begin transaction isolation level read committed read write",
+			stmt: &sql.Begin{
+				Modes: sql.TransactionModes{Isolation: sql.ReadCommitted, ReadWrite: true},
+			},
+		},
+		{
+			s: "start transaction isolation level serializable, read only",
+			stmt: &sql.Begin{
+				Modes: sql.TransactionModes{Isolation: sql.Serializable, ReadOnly: true},
+			},
+		},
+		{s: "begin as of system time '-10s'", stmt: &sql.Begin{AsOf: "-10s"}},
+		{
+			s: "begin read only, as of system time '2024-01-02 03:04:05'",
+			stmt: &sql.Begin{
+				Modes: sql.TransactionModes{ReadOnly: true},
+				AsOf:  "2024-01-02 03:04:05",
+			},
+		},
+		{s: "begin as of system time", fail: true},
+		{s: "begin as of time '-10s'", fail: true},
+		{s: "begin as of system time ''", fail: true},
+		{s: "begin isolation level snapshot isolation level serializable", fail: true},
+		{s: "begin read only read only", fail: true},
+		{s: "begin read only,", fail: true},
 	}
 
 	for i, c := range cases {
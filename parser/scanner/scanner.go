@@ -72,11 +72,20 @@ func (s *Scanner) Scan(sctx *ScanCtx) {
 	sctx.Token = s.scan(sctx)
 }
 
-type runeReader struct {
+// DataReader reads data embedded in the input, such as the rows of COPY ... FROM STDIN, as
+// runes or as bytes.
+type DataReader interface {
+	io.RuneReader
+	io.ByteReader
+}
+
+type dataReader struct {
 	s *Scanner
 }
 
-func (s *Scanner) RuneReader() (io.RuneReader, int) {
+// DataReader skips to the end of the current line and returns a reader for the data which
+// follows it, along with the line number of the data.
+func (s *Scanner) DataReader() (DataReader, int) {
 	for {
 		r, _, err := s.rr.ReadRune()
 		if err != nil {
@@ -89,26 +98,46 @@ func (s *Scanner) RuneReader() (io.RuneReader, int) {
 		}
 	}
 
-	return runeReader{
+	return dataReader{
 		s: s,
 	}, s.line
 }
 
-func (rr runeReader) ReadRune() (rune, int, error) {
-	r, size, err := rr.s.rr.ReadRune()
+func (dr dataReader) ReadRune() (rune, int, error) {
+	r, size, err := dr.s.rr.ReadRune()
 	if err != nil {
 		return 0, 0, err
 	}
 
 	if r == '\n' {
-		rr.s.line += 1
-		rr.s.column = 0
+		dr.s.line += 1
+		dr.s.column = 0
 	} else {
-		rr.s.column += 1
+		dr.s.column += 1
 	}
 	return r, size, nil
 }
 
+// ReadByte requires that the input, passed to Init, is also an io.ByteReader.
+func (dr dataReader) ReadByte() (byte, error) {
+	br, ok := dr.s.rr.(io.ByteReader)
+	if !ok {
+		return 0, errors.New("scanner: unable to read bytes")
+	}
+	b, err := br.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	if b == '\n' {
+		dr.s.line += 1
+		dr.s.column = 0
+	} else {
+		dr.s.column += 1
+	}
+	return b, nil
+}
+
 func (s *Scanner) scan(sctx *ScanCtx) rune {
 SkipWhitespace:
 	r := s.readRune(sctx)
@@ -114,6 +114,7 @@ type ForeignKey struct {
 	RefCols  []types.Identifier
 	OnDelete RefAction
 	OnUpdate RefAction
+	Deferred bool
 }
 
 func (fk ForeignKey) String() string {
@@ -140,6 +141,9 @@ func (fk ForeignKey) String() string {
 		}
 		buf.WriteRune(')')
 	}
+	if fk.Deferred {
+		buf.WriteString(" INITIALLY DEFERRED")
+	}
 	return buf.String()
 }
 
@@ -151,6 +155,24 @@ type CreateTable struct {
 	IfNotExists    bool
 	Constraints    []Constraint
 	ForeignKeys    []*ForeignKey
+	Identities     []ColumnIdentity
+}
+
+// ColumnIdentity is a SERIAL column or a column GENERATED {ALWAYS | BY DEFAULT} AS IDENTITY.
+type ColumnIdentity struct {
+	ColNum  int
+	Always  bool
+	Serial  bool
+	Options SequenceOptions
+}
+
+func (stmt *CreateTable) identity(colNum int) (ColumnIdentity, bool) {
+	for _, ci := range stmt.Identities {
+		if ci.ColNum == colNum {
+			return ci, true
+		}
+	}
+	return ColumnIdentity{}, false
 }
 
 func (stmt *CreateTable) String() string {
@@ -165,10 +187,32 @@ func (stmt *CreateTable) String() string {
 		if i > 0 {
 			buf.WriteString(", ")
 		}
-		fmt.Fprintf(&buf, "%s %s", stmt.Columns[i], ct.Type)
+		ci, identity := stmt.identity(i)
+		if identity && ci.Serial {
+			switch ct.Size {
+			case 2:
+				fmt.Fprintf(&buf, "%s SMALLSERIAL", stmt.Columns[i])
+			case 4:
+				fmt.Fprintf(&buf, "%s SERIAL", stmt.Columns[i])
+			default:
+				fmt.Fprintf(&buf, "%s BIGSERIAL", stmt.Columns[i])
+			}
+		} else {
+			fmt.Fprintf(&buf, "%s %s", stmt.Columns[i], ct.Type)
+		}
 		if ct.NotNull {
 			buf.WriteString(" NOT NULL")
 		}
+		if identity && !ci.Serial {
+			if ci.Always {
+				buf.WriteString(" GENERATED ALWAYS AS IDENTITY")
+			} else {
+				buf.WriteString(" GENERATED BY DEFAULT AS IDENTITY")
+			}
+			if opts := ci.Options.String(); opts != "" {
+				fmt.Fprintf(&buf, " (%s)", opts[1:])
+			}
+		}
 		cd := stmt.ColumnDefaults[i]
 		if cd != nil {
 			fmt.Fprintf(&buf, " DEFAULT %s", cd)
@@ -187,6 +231,9 @@ func (stmt *CreateTable) String() string {
 
 func (stmt *CreateTable) Resolve(r Resolver) {
 	stmt.Table = r.ResolveTable(stmt.Table)
+	for _, fk := range stmt.ForeignKeys {
+		fk.RefTable = r.ResolveTable(fk.RefTable)
+	}
 }
 
 type CreateIndex struct {
@@ -253,6 +300,10 @@ type AddForeignKey struct {
 	ForeignKey
 }
 
+type AddConstraint struct {
+	Constraint
+}
+
 type DropConstraint struct {
 	Name     types.Identifier
 	IfExists bool
@@ -260,6 +311,45 @@ type DropConstraint struct {
 	Type     ConstraintType
 }
 
+type AddColumn struct {
+	Column  types.Identifier
+	Type    types.ColumnType
+	Default Expr
+}
+
+type DropColumn struct {
+	Column   types.Identifier
+	IfExists bool
+}
+
+type AlterColumnAction int
+
+const (
+	SetColumnDefault AlterColumnAction = iota + 1
+	SetColumnNotNull
+	SetColumnType
+)
+
+type AlterColumn struct {
+	Column  types.Identifier
+	Action  AlterColumnAction
+	Default Expr             // SetColumnDefault
+	Type    types.ColumnType // SetColumnType
+}
+
+type RenameColumn struct {
+	Column  types.Identifier
+	NewName types.Identifier
+}
+
+type RenameTable struct {
+	NewName types.Identifier
+}
+
+type SetSchema struct {
+	Schema types.Identifier
+}
+
 type AlterTable struct {
 	Table   types.TableName
 	Actions []AlterAction
@@ -269,6 +359,13 @@ func (afk AddForeignKey) String() string {
 	return fmt.Sprintf("ADD %s", afk.ForeignKey)
 }
 
+func (ac AddConstraint) String() string {
+	if ac.Name == 0 {
+		return fmt.Sprintf("ADD CHECK (%s)", ac.Check)
+	}
+	return fmt.Sprintf("ADD CONSTRAINT %s CHECK (%s)", ac.Name, ac.Check)
+}
+
 func (dc DropConstraint) String() string {
 	if dc.Name != 0 {
 		if dc.IfExists {
@@ -280,6 +377,50 @@ func (dc DropConstraint) String() string {
 	return fmt.Sprintf("ALTER COLUMN %s DROP %s", dc.Column, dc.Type)
 }
 
+func (ac AddColumn) String() string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "ADD COLUMN %s %s", ac.Column, ac.Type.Type)
+	if ac.Type.NotNull {
+		buf.WriteString(" NOT NULL")
+	}
+	if ac.Default != nil {
+		fmt.Fprintf(&buf, " DEFAULT %s", ac.Default)
+	}
+	return buf.String()
+}
+
+func (dc DropColumn) String() string {
+	if dc.IfExists {
+		return fmt.Sprintf("DROP COLUMN IF EXISTS %s", dc.Column)
+	}
+	return fmt.Sprintf("DROP COLUMN %s", dc.Column)
+}
+
+func (ac AlterColumn) String() string {
+	switch ac.Action {
+	case SetColumnDefault:
+		return fmt.Sprintf("ALTER COLUMN %s SET DEFAULT %s", ac.Column, ac.Default)
+	case SetColumnNotNull:
+		return fmt.Sprintf("ALTER COLUMN %s SET NOT NULL", ac.Column)
+	case SetColumnType:
+		return fmt.Sprintf("ALTER COLUMN %s TYPE %s", ac.Column, ac.Type)
+	default:
+		panic(fmt.Sprintf("unexpected alter column action: %d", ac.Action))
+	}
+}
+
+func (rc RenameColumn) String() string {
+	return fmt.Sprintf("RENAME COLUMN %s TO %s", rc.Column, rc.NewName)
+}
+
+func (rt RenameTable) String() string {
+	return fmt.Sprintf("RENAME TO %s", rt.NewName)
+}
+
+func (ss SetSchema) String() string {
+	return fmt.Sprintf("SET SCHEMA %s", ss.Schema)
+}
+
 func (stmt *AlterTable) String() string {
 	var buf strings.Builder
 	fmt.Fprintf(&buf, "ALTER TABLE %s ", stmt.Table)
@@ -294,6 +435,11 @@ func (stmt *AlterTable) String() string {
 
 func (stmt *AlterTable) Resolve(r Resolver) {
 	stmt.Table = r.ResolveTable(stmt.Table)
+	for _, act := range stmt.Actions {
+		if afk, ok := act.(*AddForeignKey); ok {
+			afk.RefTable = r.ResolveTable(afk.RefTable)
+		}
+	}
 }
 
 type DropTable struct {
@@ -326,6 +472,36 @@ func (stmt *DropTable) Resolve(r Resolver) {
 	}
 }
 
+type Truncate struct {
+	Tables          []types.TableName
+	RestartIdentity bool
+	Cascade         bool
+}
+
+func (stmt *Truncate) String() string {
+	var buf strings.Builder
+	buf.WriteString("TRUNCATE TABLE ")
+	for i, tbl := range stmt.Tables {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(tbl.String())
+	}
+	if stmt.RestartIdentity {
+		buf.WriteString(" RESTART IDENTITY")
+	}
+	if stmt.Cascade {
+		buf.WriteString(" CASCADE")
+	}
+	return buf.String()
+}
+
+func (stmt *Truncate) Resolve(r Resolver) {
+	for idx := range stmt.Tables {
+		stmt.Tables[idx] = r.ResolveTable(stmt.Tables[idx])
+	}
+}
+
 type DropIndex struct {
 	Table    types.TableName
 	Index    types.Identifier
@@ -382,3 +558,112 @@ func (stmt *DropSchema) String() string {
 func (stmt *DropSchema) Resolve(r Resolver) {
 	stmt.Schema = r.ResolveSchema(stmt.Schema)
 }
+
+type SequenceOptions struct {
+	Increment  *int64
+	MinValue   *int64
+	MaxValue   *int64
+	Start      *int64
+	Cycle      *bool
+	NoMinValue bool
+	NoMaxValue bool
+}
+
+func (so SequenceOptions) String() string {
+	var buf strings.Builder
+	if so.Increment != nil {
+		fmt.Fprintf(&buf, " INCREMENT BY %d", *so.Increment)
+	}
+	if so.MinValue != nil {
+		fmt.Fprintf(&buf, " MINVALUE %d", *so.MinValue)
+	} else if so.NoMinValue {
+		buf.WriteString(" NO MINVALUE")
+	}
+	if so.MaxValue != nil {
+		fmt.Fprintf(&buf, " MAXVALUE %d", *so.MaxValue)
+	} else if so.NoMaxValue {
+		buf.WriteString(" NO MAXVALUE")
+	}
+	if so.Start != nil {
+		fmt.Fprintf(&buf, " START WITH %d", *so.Start)
+	}
+	if so.Cycle != nil {
+		if *so.Cycle {
+			buf.WriteString(" CYCLE")
+		} else {
+			buf.WriteString(" NO CYCLE")
+		}
+	}
+	return buf.String()
+}
+
+type CreateSequence struct {
+	Sequence    types.TableName
+	IfNotExists bool
+	Options     SequenceOptions
+}
+
+func (stmt *CreateSequence) String() string {
+	if stmt.IfNotExists {
+		return fmt.Sprintf("CREATE SEQUENCE IF NOT EXISTS %s%s", stmt.Sequence, stmt.Options)
+	}
+	return fmt.Sprintf("CREATE SEQUENCE %s%s", stmt.Sequence, stmt.Options)
+}
+
+func (stmt *CreateSequence) Resolve(r Resolver) {
+	stmt.Sequence = r.ResolveTable(stmt.Sequence)
+}
+
+type AlterSequence struct {
+	Sequence    types.TableName
+	IfExists    bool
+	Options     SequenceOptions
+	Restart     bool
+	RestartWith *int64
+}
+
+func (stmt *AlterSequence) String() string {
+	var buf strings.Builder
+	buf.WriteString("ALTER SEQUENCE ")
+	if stmt.IfExists {
+		buf.WriteString("IF EXISTS ")
+	}
+	buf.WriteString(stmt.Sequence.String())
+	buf.WriteString(stmt.Options.String())
+	if stmt.RestartWith != nil {
+		fmt.Fprintf(&buf, " RESTART WITH %d", *stmt.RestartWith)
+	} else if stmt.Restart {
+		buf.WriteString(" RESTART")
+	}
+	return buf.String()
+}
+
+func (stmt *AlterSequence) Resolve(r Resolver) {
+	stmt.Sequence = r.ResolveTable(stmt.Sequence)
+}
+
+type DropSequence struct {
+	IfExists  bool
+	Sequences []types.TableName
+}
+
+func (stmt *DropSequence) String() string {
+	var buf strings.Builder
+	buf.WriteString("DROP SEQUENCE ")
+	if stmt.IfExists {
+		buf.WriteString("IF EXISTS ")
+	}
+	for i, sqn := range stmt.Sequences {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(sqn.String())
+	}
+	return buf.String()
+}
+
+func (stmt *DropSequence) Resolve(r Resolver) {
+	for idx := range stmt.Sequences {
+		stmt.Sequences[idx] = r.ResolveTable(stmt.Sequences[idx])
+	}
+}
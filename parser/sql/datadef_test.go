@@ -8,6 +8,8 @@ import (
 )
 
 func TestCreateTable(t *testing.T) {
+	start := int64(10)
+
 	cases := []struct {
 		stmt sql.CreateTable
 		s    string
@@ -69,6 +71,71 @@ func TestCreateTable(t *testing.T) {
 			},
 			s: "CREATE TABLE t (c1 INT, c2 INT, c3 INT, c4 INT NOT NULL, CONSTRAINT foreign_2 FOREIGN KEY (c1, c2) REFERENCES t2, CONSTRAINT fkey FOREIGN KEY (c3, c4, c2) REFERENCES t3 (p1, p2, p3))",
 		},
+		{
+			stmt: sql.CreateTable{
+				Table: types.TableName{Table: types.ID("t", false)},
+				Columns: []types.Identifier{
+					types.ID("c1", false),
+					types.ID("c2", false),
+					types.ID("c3", false),
+				},
+				ColumnTypes: []types.ColumnType{
+					{Type: types.Int64Type, Size: 4},
+					{Type: types.Int64Type, Size: 8},
+					{Type: types.Int64Type, Size: 2},
+				},
+				ColumnDefaults: make([]sql.Expr, 3),
+				Identities: []sql.ColumnIdentity{
+					{ColNum: 0, Serial: true},
+					{ColNum: 1, Always: true},
+					{
+						ColNum: 2,
+						Options: sql.SequenceOptions{
+							Start: &start,
+						},
+					},
+				},
+			},
+			s: "CREATE TABLE t (c1 SERIAL, c2 INT GENERATED ALWAYS AS IDENTITY, c3 INT GENERATED BY DEFAULT AS IDENTITY (START WITH 10))",
+		},
+	}
+
+	for _, c := range cases {
+		s := c.stmt.String()
+		if s != c.s {
+			t.Errorf("%#v.String() got %s want %s", c.stmt, s, c.s)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	cases := []struct {
+		stmt sql.Truncate
+		s    string
+	}{
+		{
+			stmt: sql.Truncate{
+				Tables: []types.TableName{
+					{
+						Database: types.ID("abc", false),
+						Schema:   types.ID("def", false),
+						Table:    types.ID("ghijk", false),
+					},
+				},
+			},
+			s: "TRUNCATE TABLE abc.def.ghijk",
+		},
+		{
+			stmt: sql.Truncate{
+				Tables: []types.TableName{
+					{Table: types.ID("abc", false)},
+					{Table: types.ID("def", false)},
+				},
+				RestartIdentity: true,
+				Cascade:         true,
+			},
+			s: "TRUNCATE TABLE abc, def RESTART IDENTITY CASCADE",
+		},
 	}
 
 	for _, c := range cases {
@@ -163,6 +230,16 @@ func TestForeignKey(t *testing.T) {
 			},
 			s: "CONSTRAINT fk_1 FOREIGN KEY (c1) REFERENCES db.sc.tbl2 (d2)",
 		},
+		{
+			fk: sql.ForeignKey{
+				Name:     types.ID("fk_1", false),
+				FKTable:  tn1,
+				FKCols:   []types.Identifier{types.ID("c1", false)},
+				RefTable: tn2,
+				Deferred: true,
+			},
+			s: "CONSTRAINT fk_1 FOREIGN KEY (c1) REFERENCES db.sc.tbl2 INITIALLY DEFERRED",
+		},
 		{
 			fk: sql.ForeignKey{
 				Name:     types.ID("fk_1", false),
@@ -464,6 +541,88 @@ func TestAlterTable(t *testing.T) {
 			},
 			s: "ALTER TABLE tbl ADD CONSTRAINT con FOREIGN KEY (c1) REFERENCES rtbl, ALTER COLUMN c1 DROP DEFAULT, ALTER COLUMN c2 DROP NOT NULL, DROP CONSTRAINT con",
 		},
+		{
+			stmt: sql.AlterTable{
+				Table: types.TableName{Table: types.ID("tbl", false)},
+				Actions: []sql.AlterAction{
+					&sql.AddColumn{
+						Column:  types.ID("c1", false),
+						Type:    types.ColumnType{Type: types.Int64Type, Size: 4, NotNull: true},
+						Default: sql.Literal{Value: types.Int64Value(123)},
+					},
+					&sql.DropColumn{
+						Column:   types.ID("c2", false),
+						IfExists: true,
+					},
+					&sql.AlterColumn{
+						Column:  types.ID("c3", false),
+						Action:  sql.SetColumnDefault,
+						Default: sql.Literal{Value: types.StringValue("abc")},
+					},
+					&sql.AlterColumn{
+						Column: types.ID("c4", false),
+						Action: sql.SetColumnNotNull,
+					},
+					&sql.AlterColumn{
+						Column: types.ID("c5", false),
+						Action: sql.SetColumnType,
+						Type:   types.ColumnType{Type: types.StringType, Size: 16},
+					},
+					&sql.RenameColumn{
+						Column:  types.ID("c6", false),
+						NewName: types.ID("c7", false),
+					},
+				},
+			},
+			s: "ALTER TABLE tbl ADD COLUMN c1 INT NOT NULL DEFAULT 123, DROP COLUMN IF EXISTS c2, ALTER COLUMN c3 SET DEFAULT 'abc', ALTER COLUMN c4 SET NOT NULL, ALTER COLUMN c5 TYPE VARCHAR(16), RENAME COLUMN c6 TO c7",
+		},
+		{
+			stmt: sql.AlterTable{
+				Table: types.TableName{Table: types.ID("tbl", false)},
+				Actions: []sql.AlterAction{
+					&sql.RenameTable{
+						NewName: types.ID("tbl2", false),
+					},
+				},
+			},
+			s: "ALTER TABLE tbl RENAME TO tbl2",
+		},
+		{
+			stmt: sql.AlterTable{
+				Table: types.TableName{Table: types.ID("tbl", false)},
+				Actions: []sql.AlterAction{
+					&sql.AddConstraint{
+						Constraint: sql.Constraint{
+							Type: sql.CheckConstraint,
+							Name: types.ID("con", false),
+							Check: &sql.BinaryExpr{
+								Op:    sql.GreaterThanOp,
+								Left:  sql.Ref{types.ID("c1", false)},
+								Right: sql.Literal{Value: types.Int64Value(0)},
+							},
+						},
+					},
+					&sql.AddConstraint{
+						Constraint: sql.Constraint{
+							Type:  sql.CheckConstraint,
+							Check: sql.Ref{types.ID("c2", false)},
+						},
+					},
+				},
+			},
+			s: "ALTER TABLE tbl ADD CONSTRAINT con CHECK ((c1 > 0)), ADD CHECK (c2)",
+		},
+		{
+			stmt: sql.AlterTable{
+				Table: types.TableName{Table: types.ID("tbl", false)},
+				Actions: []sql.AlterAction{
+					&sql.SetSchema{
+						Schema: types.ID("sn2", false),
+					},
+				},
+			},
+			s: "ALTER TABLE tbl SET SCHEMA sn2",
+		},
 	}
 
 	for _, c := range cases {
@@ -571,3 +730,71 @@ func TestDropSchema(t *testing.T) {
 		}
 	}
 }
+
+func TestSequences(t *testing.T) {
+	incr := int64(-2)
+	minVal := int64(-100)
+	start := int64(10)
+	cycle := true
+
+	cases := []struct {
+		stmt sql.Stmt
+		s    string
+	}{
+		{
+			stmt: &sql.CreateSequence{
+				Sequence: types.TableName{Table: types.ID("seq", false)},
+			},
+			s: "CREATE SEQUENCE seq",
+		},
+		{
+			stmt: &sql.CreateSequence{
+				Sequence:    types.TableName{Table: types.ID("seq", false)},
+				IfNotExists: true,
+				Options: sql.SequenceOptions{
+					Increment:  &incr,
+					MinValue:   &minVal,
+					Start:      &start,
+					Cycle:      &cycle,
+					NoMaxValue: true,
+				},
+			},
+			s: "CREATE SEQUENCE IF NOT EXISTS seq INCREMENT BY -2 MINVALUE -100 NO MAXVALUE " +
+				"START WITH 10 CYCLE",
+		},
+		{
+			stmt: &sql.AlterSequence{
+				Sequence: types.TableName{Table: types.ID("seq", false)},
+				IfExists: true,
+				Restart:  true,
+			},
+			s: "ALTER SEQUENCE IF EXISTS seq RESTART",
+		},
+		{
+			stmt: &sql.AlterSequence{
+				Sequence:    types.TableName{Table: types.ID("seq", false)},
+				Options:     sql.SequenceOptions{NoMinValue: true},
+				Restart:     true,
+				RestartWith: &start,
+			},
+			s: "ALTER SEQUENCE seq NO MINVALUE RESTART WITH 10",
+		},
+		{
+			stmt: &sql.DropSequence{
+				IfExists: true,
+				Sequences: []types.TableName{
+					{Table: types.ID("seq1", false)},
+					{Schema: types.ID("sn", false), Table: types.ID("seq2", false)},
+				},
+			},
+			s: "DROP SEQUENCE IF EXISTS seq1, sn.seq2",
+		},
+	}
+
+	for _, c := range cases {
+		s := c.stmt.String()
+		if s != c.s {
+			t.Errorf("%#v.String() got %s want %s", c.stmt, s, c.s)
+		}
+	}
+}
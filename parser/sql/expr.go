@@ -1,6 +1,7 @@
 package sql
 
 import (
+	"encoding/gob"
 	"fmt"
 	"strings"
 
@@ -75,6 +76,10 @@ var (
 	}
 )
 
+func (op Op) String() string {
+	return opNames[op]
+}
+
 type UnaryExpr struct {
 	Op   Op
 	Expr Expr
@@ -86,6 +91,15 @@ type BinaryExpr struct {
 	Right Expr
 }
 
+func init() {
+	// Column defaults are stored as part of gob encoded table types.
+	gob.Register(Literal{})
+	gob.Register(Ref{})
+	gob.Register(&SExpr{})
+	gob.Register(&UnaryExpr{})
+	gob.Register(&BinaryExpr{})
+}
+
 type SubqueryOp int
 
 const (
@@ -167,3 +181,47 @@ func (sq *Subquery) String() string {
 }
 
 func (_ *Subquery) isExpr() {}
+
+// ResolveExpr resolves the statements of any subqueries in the expression.
+func ResolveExpr(e Expr, r Resolver) {
+	switch e := e.(type) {
+	case *SExpr:
+		for _, arg := range e.Args {
+			ResolveExpr(arg, r)
+		}
+	case *UnaryExpr:
+		ResolveExpr(e.Expr, r)
+	case *BinaryExpr:
+		ResolveExpr(e.Left, r)
+		ResolveExpr(e.Right, r)
+	case *Subquery:
+		ResolveExpr(e.Expr, r)
+		e.Stmt.Resolve(r)
+	}
+}
+
+// WalkExpr calls fn for the expression and each of its subexpressions, but not for the
+// statements of any subqueries. If fn returns false, the walk stops and WalkExpr returns false.
+func WalkExpr(e Expr, fn func(e Expr) bool) bool {
+	if e == nil {
+		return true
+	} else if !fn(e) {
+		return false
+	}
+
+	switch e := e.(type) {
+	case *SExpr:
+		for _, arg := range e.Args {
+			if !WalkExpr(arg, fn) {
+				return false
+			}
+		}
+	case *UnaryExpr:
+		return WalkExpr(e.Expr, fn)
+	case *BinaryExpr:
+		return WalkExpr(e.Left, fn) && WalkExpr(e.Right, fn)
+	case *Subquery:
+		return WalkExpr(e.Expr, fn)
+	}
+	return true
+}
@@ -59,55 +59,176 @@ func (fs FromStmt) String() string {
 	return buf.String()
 }
 
+type CopyFormat int
+
+const (
+	TextFormat CopyFormat = iota
+	CSVFormat
+	BinaryFormat
+)
+
+var copyFormats = map[CopyFormat]string{
+	TextFormat:   "text",
+	CSVFormat:    "csv",
+	BinaryFormat: "binary",
+}
+
+func (cf CopyFormat) String() string {
+	return copyFormats[cf]
+}
+
+// CopyReader reads the data of COPY ... FROM STDIN: the text and CSV formats are read as runes,
+// and the binary format is read as bytes.
+type CopyReader interface {
+	io.RuneReader
+	io.ByteReader
+}
+
+// Copy copies the rows read from From into Table or, if To is true, writes the rows of Table or
+// of Query to STDOUT.
 type Copy struct {
-	Table     types.TableName
-	Columns   []types.Identifier
-	From      io.RuneReader
-	FromLine  int
-	Delimiter rune
+	Table         types.TableName
+	Columns       []types.Identifier
+	Query         Stmt
+	To            bool
+	From          CopyReader
+	FromLine      int
+	Format        CopyFormat
+	Delimiter     rune
+	Header        bool
+	Null          string
+	Quote         rune
+	Escape        rune
+	ForceQuote    []types.Identifier
+	ForceQuoteAll bool
 }
 
 func (stmt *Copy) String() string {
 	var buf strings.Builder
-	fmt.Fprintf(&buf, "COPY %s (", stmt.Table)
-	for i, col := range stmt.Columns {
-		if i > 0 {
-			buf.WriteString(", ")
+	buf.WriteString("COPY ")
+	if stmt.Query != nil {
+		fmt.Fprintf(&buf, "(%s) ", stmt.Query)
+	} else {
+		fmt.Fprintf(&buf, "%s ", stmt.Table)
+		if stmt.Columns != nil {
+			buf.WriteRune('(')
+			for i, col := range stmt.Columns {
+				if i > 0 {
+					buf.WriteString(", ")
+				}
+				buf.WriteString(col.String())
+			}
+			buf.WriteString(") ")
+		}
+	}
+	if stmt.To {
+		buf.WriteString("TO STDOUT")
+	} else {
+		buf.WriteString("FROM STDIN")
+	}
+
+	var opts []string
+	if stmt.Format != TextFormat {
+		opts = append(opts, fmt.Sprintf("FORMAT %s", stmt.Format))
+	}
+	if (stmt.Format == TextFormat && stmt.Delimiter != '\t') ||
+		(stmt.Format == CSVFormat && stmt.Delimiter != ',') {
+
+		opts = append(opts, fmt.Sprintf("DELIMITER %s", types.StringValue(stmt.Delimiter)))
+	}
+	if (stmt.Format == TextFormat && stmt.Null != `\N`) ||
+		(stmt.Format == CSVFormat && stmt.Null != "") {
+
+		opts = append(opts, fmt.Sprintf("NULL %s", types.StringValue(stmt.Null)))
+	}
+	if stmt.Header {
+		opts = append(opts, "HEADER")
+	}
+	if stmt.Format == CSVFormat {
+		if stmt.Quote != '"' {
+			opts = append(opts, fmt.Sprintf("QUOTE %s", types.StringValue(stmt.Quote)))
+		}
+		if stmt.Escape != stmt.Quote {
+			opts = append(opts, fmt.Sprintf("ESCAPE %s", types.StringValue(stmt.Escape)))
+		}
+		if stmt.ForceQuoteAll {
+			opts = append(opts, "FORCE_QUOTE *")
+		} else if stmt.ForceQuote != nil {
+			var fq strings.Builder
+			fq.WriteString("FORCE_QUOTE (")
+			for i, col := range stmt.ForceQuote {
+				if i > 0 {
+					fq.WriteString(", ")
+				}
+				fq.WriteString(col.String())
+			}
+			fq.WriteRune(')')
+			opts = append(opts, fq.String())
 		}
-		buf.WriteString(col.String())
 	}
-	buf.WriteString(") FROM STDIN")
 
-	if stmt.Delimiter != '\t' {
-		fmt.Fprintf(&buf, " DELIMITER '%c'", stmt.Delimiter)
+	if opts != nil {
+		fmt.Fprintf(&buf, " WITH (%s)", strings.Join(opts, ", "))
 	}
 	return buf.String()
 }
 
 func (stmt *Copy) Resolve(r Resolver) {
-	stmt.Table = r.ResolveTable(stmt.Table)
+	if stmt.Query != nil {
+		stmt.Query.Resolve(r)
+	} else {
+		stmt.Table = r.ResolveTable(stmt.Table)
+	}
 }
 
+// Delete deletes the rows of Table which, when joined with the rows of Using, match Where.
 type Delete struct {
-	Table types.TableName
-	Where Expr
+	Table     types.TableName
+	Using     FromItem
+	Where     Expr
+	Returning []SelectResult
 }
 
 func (stmt *Delete) String() string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "DELETE FROM %s", stmt.Table)
+	if stmt.Using != nil {
+		fmt.Fprintf(&buf, " USING %s", stmt.Using)
+	}
 	if stmt.Where != nil {
-		return fmt.Sprintf("DELETE FROM %s WHERE %s", stmt.Table, stmt.Where)
+		fmt.Fprintf(&buf, " WHERE %s", stmt.Where)
 	}
-	return fmt.Sprintf("DELETE FROM %s", stmt.Table)
+	writeReturning(&buf, stmt.Returning)
+	return buf.String()
 }
 
 func (stmt *Delete) Resolve(r Resolver) {
 	stmt.Table = r.ResolveTable(stmt.Table)
+	if stmt.Using != nil {
+		stmt.Using = resolveFromItem(stmt.Using, r)
+	}
+	ResolveExpr(stmt.Where, r)
+	resolveResults(stmt.Returning, r)
 }
 
+// InsertValues inserts either Rows or the rows returned by Query.
 type InsertValues struct {
-	Table   types.TableName
-	Columns []types.Identifier
-	Rows    [][]Expr
+	Table      types.TableName
+	Columns    []types.Identifier
+	Rows       [][]Expr
+	Query      Stmt
+	OnConflict *OnConflict
+	Returning  []SelectResult
+}
+
+// OnConflict is the action to take when an inserted row conflicts with an existing row on the
+// primary key or a unique index; if Columns is not nil, only conflicts on the primary key or
+// unique index with exactly those columns are handled.
+type OnConflict struct {
+	Columns       []types.Identifier
+	DoNothing     bool
+	ColumnUpdates []ColumnUpdate
+	Where         Expr
 }
 
 func (stmt *InsertValues) String() string {
@@ -125,30 +246,93 @@ func (stmt *InsertValues) String() string {
 		buf.WriteString(") ")
 	}
 
-	buf.WriteString("VALUES")
+	if stmt.Query != nil {
+		buf.WriteString(stmt.Query.String())
+	} else {
+		buf.WriteString("VALUES")
 
-	for i, r := range stmt.Rows {
-		if i > 0 {
-			buf.WriteString(", (")
-		} else {
-			buf.WriteString(" (")
-		}
+		for i, r := range stmt.Rows {
+			if i > 0 {
+				buf.WriteString(", (")
+			} else {
+				buf.WriteString(" (")
+			}
 
-		for j, v := range r {
-			if j > 0 {
-				buf.WriteString(", ")
+			for j, v := range r {
+				if j > 0 {
+					buf.WriteString(", ")
+				}
+				if v == nil {
+					buf.WriteString("DEFAULT")
+				} else {
+					buf.WriteString(v.String())
+				}
 			}
-			buf.WriteString(v.String())
-		}
 
-		buf.WriteRune(')')
+			buf.WriteRune(')')
+		}
 	}
 
+	if stmt.OnConflict != nil {
+		buf.WriteString(stmt.OnConflict.String())
+	}
+	writeReturning(&buf, stmt.Returning)
 	return buf.String()
 }
 
 func (stmt *InsertValues) Resolve(r Resolver) {
 	stmt.Table = r.ResolveTable(stmt.Table)
+	for _, row := range stmt.Rows {
+		for _, e := range row {
+			ResolveExpr(e, r)
+		}
+	}
+	if stmt.Query != nil {
+		stmt.Query.Resolve(r)
+	}
+	if stmt.OnConflict != nil {
+		for _, cu := range stmt.OnConflict.ColumnUpdates {
+			ResolveExpr(cu.Expr, r)
+		}
+		ResolveExpr(stmt.OnConflict.Where, r)
+	}
+	resolveResults(stmt.Returning, r)
+}
+
+func (oc *OnConflict) String() string {
+	var buf strings.Builder
+	buf.WriteString(" ON CONFLICT")
+	if oc.Columns != nil {
+		buf.WriteString(" (")
+		for i, col := range oc.Columns {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(col.String())
+		}
+		buf.WriteRune(')')
+	}
+
+	if oc.DoNothing {
+		buf.WriteString(" DO NOTHING")
+		return buf.String()
+	}
+
+	buf.WriteString(" DO UPDATE SET ")
+	for i, cu := range oc.ColumnUpdates {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		if cu.Expr == nil {
+			fmt.Fprintf(&buf, "%s = DEFAULT", cu.Column)
+		} else {
+			fmt.Fprintf(&buf, "%s = %s", cu.Column, cu.Expr)
+		}
+	}
+	if oc.Where != nil {
+		fmt.Fprintf(&buf, " WHERE %s", oc.Where)
+	}
+	return buf.String()
 }
 
 type ColumnUpdate struct {
@@ -156,10 +340,13 @@ type ColumnUpdate struct {
 	Expr   Expr
 }
 
+// Update updates the rows of Table which, when joined with the rows of From, match Where.
 type Update struct {
 	Table         types.TableName
 	ColumnUpdates []ColumnUpdate
+	From          FromItem
 	Where         Expr
+	Returning     []SelectResult
 }
 
 func (stmt *Update) String() string {
@@ -171,14 +358,26 @@ func (stmt *Update) String() string {
 		}
 		fmt.Fprintf(&buf, "%s = %s", cu.Column, cu.Expr)
 	}
+	if stmt.From != nil {
+		fmt.Fprintf(&buf, " FROM %s", stmt.From)
+	}
 	if stmt.Where != nil {
 		fmt.Fprintf(&buf, " WHERE %s", stmt.Where)
 	}
+	writeReturning(&buf, stmt.Returning)
 	return buf.String()
 }
 
 func (stmt *Update) Resolve(r Resolver) {
 	stmt.Table = r.ResolveTable(stmt.Table)
+	for _, cu := range stmt.ColumnUpdates {
+		ResolveExpr(cu.Expr, r)
+	}
+	if stmt.From != nil {
+		stmt.From = resolveFromItem(stmt.From, r)
+	}
+	ResolveExpr(stmt.Where, r)
+	resolveResults(stmt.Returning, r)
 }
 
 type Values struct {
@@ -207,7 +406,13 @@ func (stmt *Values) String() string {
 	return buf.String()
 }
 
-func (_ *Values) Resolve(r Resolver) {}
+func (stmt *Values) Resolve(r Resolver) {
+	for _, row := range stmt.Expressions {
+		for _, e := range row {
+			ResolveExpr(e, r)
+		}
+	}
+}
 
 type SelectResult interface {
 	String() string
@@ -222,6 +427,9 @@ type ExprResult struct {
 	Alias types.Identifier
 }
 
+// StarResult is all of the columns of the table; it is only used by RETURNING.
+type StarResult struct{}
+
 type OrderBy struct {
 	Expr    Expr
 	Reverse bool
@@ -240,6 +448,10 @@ func (tr TableResult) String() string {
 	return fmt.Sprintf("%s.*", tr.Table)
 }
 
+func (_ StarResult) String() string {
+	return "*"
+}
+
 func (er ExprResult) String() string {
 	if er.Alias != 0 {
 		return fmt.Sprintf("%s AS %s", er.Expr, er.Alias)
@@ -293,7 +505,56 @@ func (stmt *Select) String() string {
 	return buf.String()
 }
 
-func (_ *Select) Resolve(r Resolver) {}
+func writeReturning(buf *strings.Builder, results []SelectResult) {
+	if results == nil {
+		return
+	}
+
+	buf.WriteString(" RETURNING ")
+	for i, sr := range results {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(sr.String())
+	}
+}
+
+func resolveResults(results []SelectResult, r Resolver) {
+	for _, sr := range results {
+		if er, ok := sr.(ExprResult); ok {
+			ResolveExpr(er.Expr, r)
+		}
+	}
+}
+
+func (stmt *Select) Resolve(r Resolver) {
+	resolveResults(stmt.Results, r)
+	if stmt.From != nil {
+		stmt.From = resolveFromItem(stmt.From, r)
+	}
+	ResolveExpr(stmt.Where, r)
+	for _, e := range stmt.GroupBy {
+		ResolveExpr(e, r)
+	}
+	ResolveExpr(stmt.Having, r)
+}
+
+func resolveFromItem(fi FromItem, r Resolver) FromItem {
+	switch fi := fi.(type) {
+	case *FromTableAlias:
+		fi.TableName = r.ResolveTable(fi.TableName)
+	case *FromIndexAlias:
+		fi.TableName = r.ResolveTable(fi.TableName)
+	case FromStmt:
+		fi.Stmt.Resolve(r)
+	case FromJoin:
+		fi.Left = resolveFromItem(fi.Left, r)
+		fi.Right = resolveFromItem(fi.Right, r)
+		ResolveExpr(fi.On, r)
+		return fi
+	}
+	return fi
+}
 
 type JoinType int
 
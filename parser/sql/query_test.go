@@ -125,6 +125,53 @@ func TestInsert(t *testing.T) {
 			},
 			s: "INSERT INTO t VALUES (true, false)",
 		},
+		{
+			stmt: sql.InsertValues{
+				Table: tn,
+				Rows: [][]sql.Expr{
+					{sql.Literal{types.BoolValue(true)}, nil},
+				},
+				OnConflict: &sql.OnConflict{DoNothing: true},
+			},
+			s: "INSERT INTO t VALUES (true, DEFAULT) ON CONFLICT DO NOTHING",
+		},
+		{
+			stmt: sql.InsertValues{
+				Table:   tn,
+				Columns: cols2,
+				Query: &sql.Values{
+					Expressions: [][]sql.Expr{
+						{sql.Literal{types.Int64Value(1)}, sql.Literal{types.Int64Value(2)}},
+					},
+				},
+				OnConflict: &sql.OnConflict{
+					Columns: cols1,
+					ColumnUpdates: []sql.ColumnUpdate{
+						{
+							Column: types.ID("c2", false),
+							Expr:   sql.Ref{types.ID("excluded", false), types.ID("c2", false)},
+						},
+						{Column: types.ID("c3", false)},
+					},
+					Where: sql.Literal{types.BoolValue(true)},
+				},
+			},
+			s: "INSERT INTO t (c1, c2) VALUES (1, 2) ON CONFLICT (c1) DO UPDATE SET " +
+				"c2 = excluded.c2, c3 = DEFAULT WHERE true",
+		},
+		{
+			stmt: sql.InsertValues{
+				Table:   tn,
+				Columns: cols1,
+				Rows:    [][]sql.Expr{{sql.Literal{types.Int64Value(1)}}},
+				Returning: []sql.SelectResult{
+					sql.ExprResult{Expr: sql.Ref{types.ID("c1", false)}},
+					sql.ExprResult{Expr: sql.Ref{types.ID("c2", false)},
+						Alias: types.ID("a2", false)},
+				},
+			},
+			s: "INSERT INTO t (c1) VALUES (1) RETURNING c1, c2 AS a2",
+		},
 	}
 
 	for _, c := range cases {
@@ -478,6 +525,97 @@ func TestSelect(t *testing.T) {
 	}
 }
 
+func TestCopy(t *testing.T) {
+	cases := []struct {
+		stmt sql.Copy
+		s    string
+	}{
+		{
+			stmt: sql.Copy{
+				Table:     types.TableName{Table: types.ID("t", false)},
+				Delimiter: '\t',
+				Null:      `\N`,
+			},
+			s: "COPY t FROM STDIN",
+		},
+		{
+			stmt: sql.Copy{
+				Table:     types.TableName{Table: types.ID("t", false)},
+				Columns:   []types.Identifier{types.ID("c1", false), types.ID("c2", false)},
+				Delimiter: ',',
+				Null:      `\N`,
+			},
+			s: "COPY t (c1, c2) FROM STDIN WITH (DELIMITER ',')",
+		},
+		{
+			stmt: sql.Copy{
+				Table:     types.TableName{Table: types.ID("t", false)},
+				To:        true,
+				Delimiter: '\t',
+				Header:    true,
+			},
+			s: "COPY t TO STDOUT WITH (NULL '', HEADER)",
+		},
+		{
+			stmt: sql.Copy{
+				Query: &sql.Select{
+					Results: []sql.SelectResult{sql.StarResult{}},
+					From: sql.FromTableAlias{
+						TableName: types.TableName{Table: types.ID("t", false)},
+					},
+				},
+				To:        true,
+				Format:    sql.CSVFormat,
+				Delimiter: ',',
+				Quote:     '"',
+				Escape:    '"',
+			},
+			s: "COPY (SELECT * FROM t) TO STDOUT WITH (FORMAT csv)",
+		},
+		{
+			stmt: sql.Copy{
+				Table:      types.TableName{Table: types.ID("t", false)},
+				To:         true,
+				Format:     sql.CSVFormat,
+				Delimiter:  '|',
+				Null:       "NULL",
+				Quote:      '%',
+				Escape:     '\\',
+				ForceQuote: []types.Identifier{types.ID("c1", false)},
+			},
+			s: `COPY t TO STDOUT WITH (FORMAT csv, DELIMITER '|', NULL 'NULL', QUOTE '%', ` +
+				`ESCAPE '\', FORCE_QUOTE (c1))`,
+		},
+		{
+			stmt: sql.Copy{
+				Table:         types.TableName{Table: types.ID("t", false)},
+				To:            true,
+				Format:        sql.CSVFormat,
+				Delimiter:     ',',
+				Quote:         '"',
+				Escape:        '"',
+				ForceQuoteAll: true,
+			},
+			s: "COPY t TO STDOUT WITH (FORMAT csv, FORCE_QUOTE *)",
+		},
+		{
+			stmt: sql.Copy{
+				Table:  types.TableName{Table: types.ID("t", false)},
+				To:     true,
+				Format: sql.BinaryFormat,
+			},
+			s: "COPY t TO STDOUT WITH (FORMAT binary)",
+		},
+	}
+
+	for _, c := range cases {
+		s := c.stmt.String()
+		if s != c.s {
+			t.Errorf("%#v.String() got %s want %s", c.stmt, s, c.s)
+		}
+	}
+}
+
 func TestDelete(t *testing.T) {
 	cases := []struct {
 		stmt sql.Delete
@@ -496,6 +634,24 @@ func TestDelete(t *testing.T) {
 			},
 			s: "DELETE FROM t WHERE true",
 		},
+		{
+			stmt: sql.Delete{
+				Table:     types.TableName{Table: types.ID("t", false)},
+				Where:     sql.Literal{types.BoolValue(true)},
+				Returning: []sql.SelectResult{sql.StarResult{}},
+			},
+			s: "DELETE FROM t WHERE true RETURNING *",
+		},
+		{
+			stmt: sql.Delete{
+				Table: types.TableName{Table: types.ID("t", false)},
+				Using: &sql.FromTableAlias{
+					TableName: types.TableName{Table: types.ID("u", false)},
+				},
+				Where: sql.Literal{types.BoolValue(true)},
+			},
+			s: "DELETE FROM t USING u WHERE true",
+		},
 	}
 
 	for _, c := range cases {
@@ -532,6 +688,32 @@ func TestUpdate(t *testing.T) {
 			},
 			s: "UPDATE t SET c1 = 1 WHERE true",
 		},
+		{
+			stmt: sql.Update{
+				Table: types.TableName{Table: types.ID("t", false)},
+				ColumnUpdates: []sql.ColumnUpdate{
+					{types.ID("c1", false), sql.Literal{types.Int64Value(1)}},
+				},
+				Returning: []sql.SelectResult{
+					sql.TableResult{Table: types.ID("t", false)},
+					sql.ExprResult{Expr: sql.Ref{types.ID("c1", false)}},
+				},
+			},
+			s: "UPDATE t SET c1 = 1 RETURNING t.*, c1",
+		},
+		{
+			stmt: sql.Update{
+				Table: types.TableName{Table: types.ID("t", false)},
+				ColumnUpdates: []sql.ColumnUpdate{
+					{types.ID("c1", false), sql.Ref{types.ID("u", false), types.ID("c1", false)}},
+				},
+				From: &sql.FromTableAlias{
+					TableName: types.TableName{Table: types.ID("u", false)},
+				},
+				Where: sql.Literal{types.BoolValue(true)},
+			},
+			s: "UPDATE t SET c1 = u.c1 FROM u WHERE true",
+		},
 	}
 
 	for _, c := range cases {
@@ -2,6 +2,7 @@ package sql
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/leftmike/maho/types"
 )
@@ -16,10 +17,20 @@ type Stmt interface {
 	Resolve(r Resolver)
 }
 
-type Begin struct{}
+type Begin struct {
+	Modes TransactionModes
+	AsOf  string
+}
 
-func (_ *Begin) String() string {
-	return "BEGIN"
+func (stmt *Begin) String() string {
+	s := "BEGIN"
+	if modes := stmt.Modes.String(); modes != "" {
+		s += " " + modes
+	}
+	if stmt.AsOf != "" {
+		s += fmt.Sprintf(" AS OF SYSTEM TIME '%s'", stmt.AsOf)
+	}
+	return s
 }
 
 func (_ *Begin) Resolve(r Resolver) {}
@@ -40,6 +51,36 @@ func (_ *Rollback) String() string {
 
 func (_ *Rollback) Resolve(r Resolver) {}
 
+type Savepoint struct {
+	Name types.Identifier
+}
+
+func (stmt *Savepoint) String() string {
+	return fmt.Sprintf("SAVEPOINT %s", stmt.Name)
+}
+
+func (_ *Savepoint) Resolve(r Resolver) {}
+
+type RollbackTo struct {
+	Savepoint types.Identifier
+}
+
+func (stmt *RollbackTo) String() string {
+	return fmt.Sprintf("ROLLBACK TO SAVEPOINT %s", stmt.Savepoint)
+}
+
+func (_ *RollbackTo) Resolve(r Resolver) {}
+
+type Release struct {
+	Savepoint types.Identifier
+}
+
+func (stmt *Release) String() string {
+	return fmt.Sprintf("RELEASE SAVEPOINT %s", stmt.Savepoint)
+}
+
+func (_ *Release) Resolve(r Resolver) {}
+
 type Set struct {
 	Variable types.Identifier
 	Value    string
@@ -51,6 +92,89 @@ func (stmt *Set) String() string {
 
 func (_ *Set) Resolve(r Resolver) {}
 
+type IsolationLevel int
+
+const (
+	ReadUncommitted IsolationLevel = iota + 1
+	ReadCommitted
+	RepeatableRead
+	Snapshot
+	Serializable
+)
+
+var isolationLevels = map[IsolationLevel]string{
+	ReadUncommitted: "READ UNCOMMITTED",
+	ReadCommitted:   "READ COMMITTED",
+	RepeatableRead:  "REPEATABLE READ",
+	Snapshot:        "SNAPSHOT",
+	Serializable:    "SERIALIZABLE",
+}
+
+func (il IsolationLevel) String() string {
+	return isolationLevels[il]
+}
+
+// TransactionModes are the modes of BEGIN and SET TRANSACTION; zero values are not specified.
+type TransactionModes struct {
+	Isolation IsolationLevel
+	ReadOnly  bool
+	ReadWrite bool
+}
+
+func (tm TransactionModes) String() string {
+	var modes []string
+	if tm.Isolation != 0 {
+		modes = append(modes, fmt.Sprintf("ISOLATION LEVEL %s", tm.Isolation))
+	}
+	if tm.ReadOnly {
+		modes = append(modes, "READ ONLY")
+	} else if tm.ReadWrite {
+		modes = append(modes, "READ WRITE")
+	}
+	return strings.Join(modes, ", ")
+}
+
+type SetTransaction struct {
+	Modes TransactionModes
+}
+
+func (stmt *SetTransaction) String() string {
+	return fmt.Sprintf("SET TRANSACTION %s", stmt.Modes)
+}
+
+func (_ *SetTransaction) Resolve(r Resolver) {}
+
+// SetConstraints changes when foreign keys are checked in the active transaction: at the end of
+// each statement (immediate) or when the transaction commits (deferred).
+type SetConstraints struct {
+	All      bool
+	Names    []types.Identifier
+	Deferred bool
+}
+
+func (stmt *SetConstraints) String() string {
+	var buf strings.Builder
+	buf.WriteString("SET CONSTRAINTS ")
+	if stmt.All {
+		buf.WriteString("ALL")
+	} else {
+		for ndx, nam := range stmt.Names {
+			if ndx > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(nam.String())
+		}
+	}
+	if stmt.Deferred {
+		buf.WriteString(" DEFERRED")
+	} else {
+		buf.WriteString(" IMMEDIATE")
+	}
+	return buf.String()
+}
+
+func (_ *SetConstraints) Resolve(r Resolver) {}
+
 type Show struct {
 	Variable types.Identifier
 }
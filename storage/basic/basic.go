@@ -9,8 +9,10 @@ import (
 	"io"
 	"slices"
 	"sync"
+	"time"
 
 	"github.com/google/btree"
+	"github.com/leftmike/maho/encode"
 	"github.com/leftmike/maho/storage"
 	"github.com/leftmike/maho/types"
 )
@@ -20,14 +22,57 @@ const (
 )
 
 type store struct {
-	mutex sync.Mutex
+	mutex sync.RWMutex
 	tree  *btree.BTreeG[item]
+	name  string
+	log   Log
+	mvcc  *mvccState // if nil, a transaction holds mutex until it completes
+}
+
+// Item is an item of the store as it is persisted by a Log; Row is nil when the item has been
+// deleted.
+type Item struct {
+	Relation uint64
+	Key      []byte
+	Row      types.Row
+	Version  uint32
+	Clear    bool // every item of the relation is deleted; Key, Row, and Version are not used
+}
+
+// Log persists the items changed by each transaction as it is committed; all iterates over
+// every item in the store, including the committed items, so that the log can be checkpointed.
+type Log interface {
+	Commit(items []Item, all func(fn func(it Item) bool)) error
+}
+
+type itemKey struct {
+	rel relationId
+	key string
 }
 
 type transaction struct {
 	st        *store
 	tree      *btree.BTreeG[item]
+	private   bool                // tree has been cloned for writing
+	base      *btree.BTreeG[item] // committed tree of the snapshot; only with mvcc
+	start     uint64
+	isolation storage.IsolationLevel
+	readOnly  bool
+	shared    bool // holds a read lock on the store, rather than a write lock
+	asOf      bool // reads a snapshot from the history of the store
 	rowsCount int
+	changed   map[itemKey]uint64 // timestamp of the snapshot when each item was first changed
+	changes   []itemKey          // changed items in the order they were first changed
+	cleared   []relationId       // relations all of whose items were deleted
+	reads     []readRange
+}
+
+type savepoint struct {
+	tree    *btree.BTreeG[item]
+	private bool
+	start   uint64
+	changes int
+	cleared int
 }
 
 type tableType struct {
@@ -36,6 +81,32 @@ type tableType struct {
 	ColumnNames []types.Identifier
 	ColumnTypes []types.ColumnType
 	Key         []types.ColumnKey
+	Changes     []columnChange
+	Indexes     []indexType
+}
+
+type indexType struct {
+	Id     storage.IndexId
+	Key    []types.ColumnKey
+	Unique bool
+}
+
+type changeType int
+
+const (
+	addColumn changeType = iota + 1
+	dropColumn
+	convertColumn
+)
+
+// Rows are not rewritten when columns are added, dropped, or converted. Instead, each row
+// records the version of the table type it was written with, and is upgraded when it is read.
+type columnChange struct {
+	Version    uint32
+	Type       changeType
+	Column     types.ColumnNum  // dropColumn and convertColumn
+	ColumnType types.ColumnType // convertColumn
+	Default    types.Value      // addColumn
 }
 
 type table struct {
@@ -44,11 +115,23 @@ type table struct {
 	tt  *tableType
 }
 
+// rows iterates lazily over a snapshot of the tree of the transaction, taken when the rows
+// are opened, so changes made through RowRef do not change which rows are returned.
 type rows struct {
-	tbl   *table
-	cols  []types.ColumnNum
-	items []item
-	next  int
+	tbl     *table
+	tt      *tableType
+	cols    []types.ColumnNum
+	tree    *btree.BTreeG[item]
+	rel     relationId
+	reverse bool
+	// Continue with the first key greater or equal to seek, or if reverse, with the last key
+	// less than seek; if reverse and seek is nil, continue with the last key.
+	seek    []byte
+	past    func(it item) bool         // it is beyond the end of the range
+	fetch   func(it item) (item, bool) // returns the row for it, or false to skip it
+	current *item
+	done    bool
+	closed  bool
 }
 
 type rowRef struct {
@@ -57,13 +140,62 @@ type rowRef struct {
 }
 
 func NewStore(dataDir string) (storage.Store, error) {
-	return &store{
-		tree: newBTree(),
-	}, nil
+	return newStore(newBTree(), "basic", nil, false), nil
+}
+
+// NewMVCCStore is like NewStore, except that transactions run concurrently, each reading a
+// snapshot of the store.
+func NewMVCCStore(dataDir string) (storage.Store, error) {
+	return newStore(newBTree(), "mvcc", nil, true), nil
+}
+
+// NewLogStore returns a store which uses log to persist committed transactions; load is called
+// to restore the items of the store, in the order in which they were committed.
+func NewLogStore(name string, log Log, load func(fn func(it Item)) error,
+	mvcc bool) (storage.Store, error) {
+
+	tree := newBTree()
+	err := load(
+		func(it Item) {
+			bit := item{
+				rel: relationId(it.Relation),
+				key: it.Key,
+				row: it.Row,
+				ver: it.Version,
+			}
+			if it.Clear {
+				clearRelation(tree, bit.rel)
+			} else if it.Row == nil {
+				tree.Delete(bit)
+			} else {
+				tree.ReplaceOrInsert(bit)
+			}
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	return newStore(tree, name, log, mvcc), nil
 }
 
-func (_ *store) Name() string {
-	return "basic"
+func newStore(tree *btree.BTreeG[item], name string, log Log, mvcc bool) *store {
+	st := &store{
+		tree: tree,
+		name: name,
+		log:  log,
+	}
+	if mvcc {
+		st.mvcc = &mvccState{
+			versions: map[itemKey]uint64{},
+			active:   map[*transaction]uint64{},
+		}
+		st.mvcc.addHistory(tree)
+	}
+	return st
+}
+
+func (st *store) Name() string {
+	return st.name
 }
 
 func (_ *store) SetupColumns(colNames []types.Identifier, colTypes []types.ColumnType,
@@ -82,10 +214,59 @@ func (_ *store) SetupColumns(colNames []types.Identifier, colTypes []types.Colum
 
 func (st *store) Begin() storage.Transaction {
 	st.mutex.Lock()
-	return &transaction{
-		st:   st,
-		tree: st.tree,
+	tx := &transaction{
+		st:        st,
+		tree:      st.tree,
+		isolation: storage.Serializable,
+	}
+	if st.mvcc != nil {
+		tx.base = st.tree
+		tx.start = st.mvcc.begin(tx)
+		tx.isolation = storage.Snapshot
+		st.mutex.Unlock()
 	}
+	return tx
+}
+
+// BeginReadOnly begins a transaction which can not make changes. Without mvcc, read only
+// transactions share the store mutex, so they can run concurrently with each other. With mvcc,
+// if asOf is not zero, the transaction reads the store as it was at asOf.
+func (st *store) BeginReadOnly(asOf time.Time) (storage.Transaction, error) {
+	if st.mvcc == nil {
+		if !asOf.IsZero() {
+			return nil, errors.New("basic: as of system time requires an mvcc store")
+		}
+
+		st.mutex.RLock()
+		return &transaction{
+			st:        st,
+			tree:      st.tree,
+			isolation: storage.Serializable,
+			readOnly:  true,
+			shared:    true,
+		}, nil
+	}
+
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	tx := &transaction{
+		st:        st,
+		tree:      st.tree,
+		isolation: storage.Snapshot,
+		readOnly:  true,
+	}
+	if !asOf.IsZero() {
+		tree, err := st.mvcc.snapshotAt(asOf)
+		if err != nil {
+			return nil, err
+		}
+		tx.tree = tree
+		tx.asOf = true
+	}
+	tx.base = tx.tree
+	tx.start = st.mvcc.begin(tx)
+	return tx, nil
 }
 
 var (
@@ -99,6 +280,7 @@ func (tx *transaction) Store() storage.Store {
 
 func (tx *transaction) getTableType(tid storage.TableId) *tableType {
 	it := rowToItem(tableTypesRelation, tableTypesKey, types.Row{types.Int64Value(tid)})
+	tx.readKey(it.rel, it.key)
 	it, ok := tx.tree.Get(it)
 	if !ok {
 		return nil
@@ -127,12 +309,12 @@ func (tx *transaction) setTableType(tid storage.TableId, tt *tableType) {
 
 	it := rowToItem(tableTypesRelation, tableTypesKey,
 		types.Row{types.Int64Value(tid), types.BytesValue(buf.Bytes())})
-	tx.tree.ReplaceOrInsert(it)
+	tx.put(it)
 }
 
 func (tx *transaction) deleteTableType(tid storage.TableId) bool {
 	it := rowToItem(tableTypesRelation, tableTypesKey, types.Row{types.Int64Value(tid)})
-	_, ok := tx.tree.Delete(it)
+	_, ok := tx.delete(it)
 	return ok
 }
 
@@ -146,9 +328,7 @@ func (tx *transaction) OpenTable(ctx context.Context, tid storage.TableId, tn ty
 		panic(fmt.Sprintf("basic: table not found: %d", tid))
 	}
 
-	if tn != tt.Name {
-		panic(fmt.Sprintf("basic: wrong table name: %d: %s %s", tid, tn, tt.Name))
-	}
+	// Tables are identified by tid; the name is only used for error messages.
 	if !slices.Equal(colNames, tt.ColumnNames) {
 		panic(fmt.Sprintf("basic: wrong column names: %d: %v %v", tid, colNames,
 			tt.ColumnNames))
@@ -171,6 +351,11 @@ func (tx *transaction) OpenTable(ctx context.Context, tid storage.TableId, tn ty
 func (tx *transaction) CreateTable(ctx context.Context, tid storage.TableId, tn types.TableName,
 	colNames []types.Identifier, colTypes []types.ColumnType, primary []types.ColumnKey) error {
 
+	err := tx.checkWrite()
+	if err != nil {
+		return err
+	}
+
 	if tid < storage.EngineTableId {
 		panic(fmt.Sprintf("basic: tid too small: %d", tid))
 	} else if tx.getTableType(tid) != nil {
@@ -211,7 +396,13 @@ func (tx *transaction) CreateTable(ctx context.Context, tid storage.TableId, tn
 }
 
 func (tx *transaction) DropTable(ctx context.Context, tid storage.TableId) error {
-	if tx.getTableType(tid) == nil {
+	err := tx.checkWrite()
+	if err != nil {
+		return err
+	}
+
+	tt := tx.getTableType(tid)
+	if tt == nil {
 		panic(fmt.Sprintf("basic: table not found: %d", tid))
 	}
 
@@ -220,7 +411,29 @@ func (tx *transaction) DropTable(ctx context.Context, tid storage.TableId) error
 		panic(fmt.Sprintf("basic: unable to delete table type: %d", tid))
 	}
 
-	// XXX: delete all rows in the table
+	tx.deleteRange(toRelationId(tid, primaryIndexId), nil, nil, nil)
+	for _, idx := range tt.Indexes {
+		tx.deleteRange(toRelationId(tid, idx.Id), nil, nil, nil)
+	}
+	return nil
+}
+
+func (tx *transaction) RenameTable(ctx context.Context, tid storage.TableId,
+	tn types.TableName) error {
+
+	err := tx.checkWrite()
+	if err != nil {
+		return err
+	}
+
+	tt := tx.getTableType(tid)
+	if tt == nil {
+		panic(fmt.Sprintf("basic: table not found: %d", tid))
+	}
+
+	tx.forWrite()
+	tt.Name = tn
+	tx.setTableType(tid, tt)
 	return nil
 }
 
@@ -231,6 +444,30 @@ func (tx *transaction) Commit(ctx context.Context) error {
 		panic(fmt.Sprintf("basic: commit transaction has open rows: %d", tx.rowsCount))
 	}
 
+	if tx.st.mvcc != nil {
+		err := tx.st.commitMVCC(tx)
+		tx.st = nil
+		tx.tree = nil
+		return err
+	}
+
+	if tx.shared {
+		tx.st.mutex.RUnlock()
+		tx.st = nil
+		tx.tree = nil
+		return nil
+	}
+
+	if len(tx.changed) > 0 {
+		err := tx.st.logCommit(tx.changedItems(), tx.cleared, tx.tree)
+		if err != nil {
+			tx.st.mutex.Unlock()
+			tx.st = nil
+			tx.tree = nil
+			return err
+		}
+	}
+
 	tx.st.tree = tx.tree
 	tx.st.mutex.Unlock()
 	tx.st = nil
@@ -238,6 +475,76 @@ func (tx *transaction) Commit(ctx context.Context) error {
 	return nil
 }
 
+// changedItems returns the items changed by the transaction; deleted items have a nil row.
+func (tx *transaction) changedItems() []item {
+	items := make([]item, 0, len(tx.changed))
+	for ik := range tx.changed {
+		it, ok := tx.tree.Get(keyToItem(ik.rel, []byte(ik.key)))
+		if !ok {
+			it = keyToItem(ik.rel, []byte(ik.key))
+		}
+		items = append(items, it)
+	}
+	return items
+}
+
+// logCommit persists items using the log of the store, if it has one; tree contains all of the
+// items in the store, including these items. A relation in cleared is logged as a single item,
+// rather than logging each of its deleted items, if every one of its items in tree was changed
+// by the commit; otherwise, a concurrent commit added items to the relation.
+func (st *store) logCommit(items []item, cleared []relationId,
+	tree *btree.BTreeG[item]) error {
+
+	if st.log == nil {
+		return nil
+	}
+
+	var lits []Item
+	clear := map[relationId]bool{}
+	if len(cleared) > 0 {
+		present := map[relationId]int{}
+		for _, it := range items {
+			if it.row != nil {
+				present[it.rel] += 1
+			}
+		}
+
+		for _, rel := range cleared {
+			if clear[rel] || countRelation(tree, rel) != present[rel] {
+				continue
+			}
+			clear[rel] = true
+			lits = append(lits,
+				Item{
+					Relation: uint64(rel),
+					Clear:    true,
+				})
+		}
+	}
+
+	for _, it := range items {
+		if it.row != nil || !clear[it.rel] {
+			lits = append(lits, it.toItem())
+		}
+	}
+	return st.log.Commit(lits,
+		func(fn func(it Item) bool) {
+			tree.Ascend(
+				func(it item) bool {
+					return fn(it.toItem())
+				})
+		})
+}
+
+func (it item) toItem() Item {
+	return Item{
+		Relation: uint64(it.rel),
+		Key:      it.key,
+		Row:      it.row,
+		Version:  it.ver,
+	}
+}
+
 func (tx *transaction) Rollback() error {
 	if tx.st == nil {
 		return errors.New("basic: transaction already completed")
@@ -245,20 +552,188 @@ func (tx *transaction) Rollback() error {
 		panic(fmt.Sprintf("basic: rollback transaction has open rows: %d", tx.rowsCount))
 	}
 
-	tx.st.mutex.Unlock()
+	if tx.shared {
+		tx.st.mutex.RUnlock()
+	} else {
+		if tx.st.mvcc != nil {
+			tx.st.mutex.Lock()
+			tx.st.mvcc.end(tx)
+		}
+		tx.st.mutex.Unlock()
+	}
 	tx.st = nil
 	tx.tree = nil
 	return nil
 }
 
 func (tx *transaction) NextStmt() {
-	// Nothing.
+	if tx.st.mvcc != nil && tx.isolation == storage.ReadCommitted {
+		tx.st.refreshMVCC(tx)
+	}
+}
+
+// SetIsolation only changes the isolation level of mvcc transactions: without mvcc, transactions
+// run one at a time, so they are always serializable.
+func (tx *transaction) SetIsolation(level storage.IsolationLevel) error {
+	if tx.st == nil {
+		return errors.New("basic: transaction already completed")
+	}
+	switch level {
+	case storage.ReadCommitted, storage.Snapshot, storage.Serializable:
+	default:
+		return fmt.Errorf("basic: unsupported %s", level)
+	}
+
+	// The snapshot of an as of transaction never changes.
+	if tx.st.mvcc != nil && !tx.asOf {
+		tx.isolation = level
+	}
+	return nil
+}
+
+func (tx *transaction) SetReadOnly() error {
+	if tx.st == nil {
+		return errors.New("basic: transaction already completed")
+	}
+	tx.readOnly = true
+	return nil
+}
+
+func (tx *transaction) checkWrite() error {
+	if tx.readOnly {
+		return errors.New("basic: unable to make changes in a read only transaction")
+	}
+	return nil
+}
+
+// Savepoint and RollbackTo use clones of the tree, which are cheap because the tree is
+// copy-on-write.
+func (tx *transaction) Savepoint() storage.Savepoint {
+	if tx.st == nil {
+		panic("basic: savepoint on completed transaction")
+	}
+
+	return &savepoint{
+		tree:    tx.snapshot(),
+		private: tx.private,
+		start:   tx.start,
+		changes: len(tx.changes),
+		cleared: len(tx.cleared),
+	}
+}
+
+// snapshot returns a tree which will not be changed by the transaction.
+func (tx *transaction) snapshot() *btree.BTreeG[item] {
+	if tx.private {
+		return tx.tree.Clone()
+	}
+	return tx.tree
+}
+
+func (tx *transaction) RollbackTo(sp storage.Savepoint) error {
+	if tx.st == nil {
+		return errors.New("basic: transaction already completed")
+	}
+
+	s := sp.(*savepoint)
+	if s.changes > len(tx.changes) {
+		panic(fmt.Sprintf("basic: rollback to released savepoint: %d %d", s.changes,
+			len(tx.changes)))
+	}
+
+	for _, ik := range tx.changes[s.changes:] {
+		delete(tx.changed, ik)
+	}
+	tx.changes = tx.changes[:s.changes]
+	tx.cleared = tx.cleared[:s.cleared]
+
+	if s.start == tx.start {
+		tx.tree = s.tree
+		tx.private = s.private
+		if s.private {
+			tx.tree = s.tree.Clone()
+		}
+		return nil
+	}
+
+	// The snapshot of a read committed transaction has moved since the savepoint: the changes
+	// made before the savepoint are applied to the current snapshot, which does not move.
+	tx.tree = tx.base
+	tx.private = false
+	if len(tx.changes) > 0 {
+		tx.forWrite()
+		for _, ik := range tx.changes {
+			it, ok := s.tree.Get(keyToItem(ik.rel, []byte(ik.key)))
+			if ok {
+				tx.tree.ReplaceOrInsert(it)
+			} else {
+				tx.tree.Delete(keyToItem(ik.rel, []byte(ik.key)))
+			}
+		}
+	}
+	return nil
 }
 
 func (tx *transaction) forWrite() {
-	if tx.tree == tx.st.tree {
-		tx.tree = tx.st.tree.Clone()
+	if tx.private {
+		return
+	}
+
+	// With mvcc, other transactions might be cloning the same tree.
+	if tx.st.mvcc != nil {
+		tx.st.mutex.Lock()
+		defer tx.st.mutex.Unlock()
+	}
+	tx.tree = tx.tree.Clone()
+	tx.private = true
+}
+
+func (tx *transaction) changeItem(it item) {
+	if tx.changed == nil {
+		tx.changed = map[itemKey]uint64{}
+	}
+	ik := itemKey{rel: it.rel, key: string(it.key)}
+	if _, ok := tx.changed[ik]; !ok {
+		tx.changed[ik] = tx.start
+		tx.changes = append(tx.changes, ik)
+	}
+}
+
+func (tx *transaction) put(it item) {
+	tx.changeItem(it)
+	tx.tree.ReplaceOrInsert(it)
+}
+
+func (tx *transaction) delete(it item) (item, bool) {
+	tx.changeItem(it)
+	return tx.tree.Delete(it)
+}
+
+// deleteRange deletes the items of rel with a key between minKey and maxKey, inclusive, calling
+// fn, if not nil, with each item deleted; the number of items deleted is returned. A nil minKey
+// or maxKey is unbounded.
+func (tx *transaction) deleteRange(rel relationId, minKey, maxKey []byte,
+	fn func(it item)) int64 {
+
+	var its []item
+	tx.tree.AscendGreaterOrEqual(keyToItem(rel, minKey),
+		func(it item) bool {
+			if it.rel != rel || (maxKey != nil && comparePrefix(it.key, maxKey) > 0) {
+				return false
+			}
+			its = append(its, it)
+			return true
+		})
+	for _, it := range its {
+		tx.delete(it)
+		if fn != nil {
+			fn(it)
+		}
 	}
+	if minKey == nil && maxKey == nil {
+		tx.cleared = append(tx.cleared, rel)
+	}
+	return int64(len(its))
 }
 
 func (tbl *table) TID() storage.TableId {
@@ -285,86 +760,544 @@ func (tbl *table) Key() []types.ColumnKey {
 	return tbl.tt.Key
 }
 
-func predicateFunction(pred storage.Predicate, ct types.ColumnType) func(types.Value) bool {
-	switch ct.Type {
-	case types.UnknownType:
-		panic("unexpected column type: unknown")
-	case types.BoolType:
-		boolPred := pred.(storage.BoolPredicate)
-		return func(val types.Value) bool {
-			return boolPred.BoolPred(val.(types.BoolValue))
+func (tt *tableType) upgradeRow(ver uint32, row types.Row) types.Row {
+	if ver == tt.Version {
+		return row
+	}
+
+	row = append(make(types.Row, 0, len(row)+1), row...)
+	for _, chg := range tt.Changes {
+		if chg.Version <= ver {
+			continue
 		}
-	case types.StringType:
-		stringPred := pred.(storage.StringPredicate)
-		return func(val types.Value) bool {
-			return stringPred.StringPred(val.(types.StringValue))
+
+		switch chg.Type {
+		case addColumn:
+			row = append(row, chg.Default)
+		case dropColumn:
+			row = slices.Delete(row, int(chg.Column), int(chg.Column)+1)
+		case convertColumn:
+			val, err := types.ConvertValue(chg.ColumnType, row[chg.Column])
+			if err != nil {
+				panic(fmt.Sprintf("basic: %s: unable to convert column %d: %s", tt.Name,
+					chg.Column, err))
+			}
+			row[chg.Column] = val
+		default:
+			panic(fmt.Sprintf("basic: %s: unexpected column change: %d", tt.Name, chg.Type))
 		}
-	case types.BytesType:
-		bytesPred := pred.(storage.BytesPredicate)
-		return func(val types.Value) bool {
-			return bytesPred.BytesPred(val.(types.BytesValue))
+	}
+
+	return row
+}
+
+func (tbl *table) scanRows(fn func(row types.Row) error) error {
+	rel := toRelationId(tbl.tid, primaryIndexId)
+
+	var err error
+	tbl.tx.tree.AscendGreaterOrEqual(keyToItem(rel, nil),
+		func(it item) bool {
+			if it.rel != rel {
+				return false
+			}
+
+			err = fn(tbl.tt.upgradeRow(it.ver, it.row))
+			return err == nil
+		})
+	return err
+}
+
+func keyColumn(key []types.ColumnKey, col types.ColumnNum) bool {
+	for _, ck := range key {
+		if ck.Column() == col {
+			return true
 		}
-	case types.Float64Type:
-		float64Pred := pred.(storage.Float64Predicate)
-		return func(val types.Value) bool {
-			return float64Pred.Float64Pred(val.(types.Float64Value))
+	}
+	return false
+}
+
+func (tbl *table) isKeyColumn(col types.ColumnNum) bool {
+	return keyColumn(tbl.tt.Key, col)
+}
+
+func (tbl *table) changeColumns(chg columnChange, colNames []types.Identifier,
+	colTypes []types.ColumnType, key []types.ColumnKey) {
+
+	tbl.tx.forWrite()
+
+	tt := *tbl.tt
+	tt.Version += 1
+	tt.ColumnNames = colNames
+	tt.ColumnTypes = colTypes
+	tt.Key = key
+	if chg.Type != 0 {
+		chg.Version = tt.Version
+		tt.Changes = append(slices.Clone(tt.Changes), chg)
+	}
+
+	tbl.tx.setTableType(tbl.tid, &tt)
+	tbl.tt = &tt
+}
+
+func (tbl *table) AddColumn(ctx context.Context, nam types.Identifier, ct types.ColumnType,
+	dflt types.Value) error {
+
+	err := tbl.tx.checkWrite()
+	if err != nil {
+		return err
+	}
+
+	if slices.Contains(tbl.tt.ColumnNames, nam) {
+		return fmt.Errorf("basic: table %s: column already exists: %s", tbl.tt.Name, nam)
+	} else if nam == types.ROWID {
+		return fmt.Errorf("basic: table %s: reserved column name used: %s", tbl.tt.Name,
+			types.ROWID)
+	}
+
+	dflt, err = types.ConvertValue(ct, dflt)
+	if err == nil && dflt == nil && ct.NotNull {
+		err = tbl.scanRows(
+			func(row types.Row) error {
+				return fmt.Errorf("not null column requires a default")
+			})
+	}
+	if err != nil {
+		return fmt.Errorf("basic: table %s: column %s: %s", tbl.tt.Name, nam, err)
+	}
+
+	tbl.changeColumns(
+		columnChange{
+			Type:    addColumn,
+			Default: dflt,
+		},
+		append(slices.Clone(tbl.tt.ColumnNames), nam),
+		append(slices.Clone(tbl.tt.ColumnTypes), ct),
+		tbl.tt.Key)
+	return nil
+}
+
+func (tbl *table) DropColumn(ctx context.Context, col types.ColumnNum) error {
+	err := tbl.tx.checkWrite()
+	if err != nil {
+		return err
+	}
+
+	if int(col) >= len(tbl.tt.ColumnNames) {
+		panic(fmt.Sprintf("basic: table %s: column out of range: %d", tbl.tt.Name, col))
+	} else if tbl.isKeyColumn(col) {
+		return fmt.Errorf("basic: table %s: unable to drop primary key column: %s",
+			tbl.tt.Name, tbl.tt.ColumnNames[col])
+	}
+
+	for _, idx := range tbl.tt.Indexes {
+		if keyColumn(idx.Key, col) {
+			return fmt.Errorf("basic: table %s: unable to drop index column: %s", tbl.tt.Name,
+				tbl.tt.ColumnNames[col])
 		}
-	case types.Int64Type:
-		int64Pred := pred.(storage.Int64Predicate)
-		return func(val types.Value) bool {
-			return int64Pred.Int64Pred(val.(types.Int64Value))
+	}
+
+	indexes := make([]indexType, 0, len(tbl.tt.Indexes))
+	for _, idx := range tbl.tt.Indexes {
+		indexes = append(indexes,
+			indexType{
+				Id:     idx.Id,
+				Key:    dropKeyColumn(idx.Key, col),
+				Unique: idx.Unique,
+			})
+	}
+
+	tbl.changeColumns(
+		columnChange{
+			Type:   dropColumn,
+			Column: col,
+		},
+		slices.Delete(slices.Clone(tbl.tt.ColumnNames), int(col), int(col)+1),
+		slices.Delete(slices.Clone(tbl.tt.ColumnTypes), int(col), int(col)+1),
+		dropKeyColumn(tbl.tt.Key, col))
+	tbl.setIndexes(indexes)
+	return nil
+}
+
+func dropKeyColumn(key []types.ColumnKey, col types.ColumnNum) []types.ColumnKey {
+	nkey := make([]types.ColumnKey, 0, len(key))
+	for _, ck := range key {
+		if ck.Column() > col {
+			ck = types.MakeColumnKey(ck.Column()-1, ck.Reverse())
+		}
+		nkey = append(nkey, ck)
+	}
+	return nkey
+}
+
+func (tbl *table) UpdateColumn(ctx context.Context, col types.ColumnNum, nam types.Identifier,
+	ct types.ColumnType) error {
+
+	err := tbl.tx.checkWrite()
+	if err != nil {
+		return err
+	}
+
+	if int(col) >= len(tbl.tt.ColumnNames) {
+		panic(fmt.Sprintf("basic: table %s: column out of range: %d", tbl.tt.Name, col))
+	}
+
+	cn := tbl.tt.ColumnNames[col]
+	if cn == types.ROWID || nam == types.ROWID {
+		return fmt.Errorf("basic: table %s: reserved column name used: %s", tbl.tt.Name,
+			types.ROWID)
+	} else if nam != cn && slices.Contains(tbl.tt.ColumnNames, nam) {
+		return fmt.Errorf("basic: table %s: column already exists: %s", tbl.tt.Name, nam)
+	}
+
+	var chg columnChange
+	if ct != tbl.tt.ColumnTypes[col] {
+		if tbl.isKeyColumn(col) && ct.Type != tbl.tt.ColumnTypes[col].Type {
+			return fmt.Errorf("basic: table %s: unable to change type of primary key column: %s",
+				tbl.tt.Name, cn)
+		}
+
+		err := tbl.scanRows(
+			func(row types.Row) error {
+				_, err := types.ConvertValue(ct, row[col])
+				return err
+			})
+		if err != nil {
+			return fmt.Errorf("basic: table %s: column %s: %s", tbl.tt.Name, cn, err)
+		}
+
+		chg = columnChange{
+			Type:       convertColumn,
+			Column:     col,
+			ColumnType: ct,
+		}
+	}
+
+	colNames := slices.Clone(tbl.tt.ColumnNames)
+	colNames[col] = nam
+	colTypes := slices.Clone(tbl.tt.ColumnTypes)
+	colTypes[col] = ct
+	tbl.changeColumns(chg, colNames, colTypes, tbl.tt.Key)
+
+	// The encoding of the index keys changes when the type of the column changes.
+	if chg.Type == convertColumn {
+		for _, idx := range tbl.tt.Indexes {
+			if keyColumn(idx.Key, col) {
+				tbl.clearIndex(idx.Id)
+				err := tbl.buildIndex(idx)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (tbl *table) findIndex(iid storage.IndexId) (int, bool) {
+	for idx := range tbl.tt.Indexes {
+		if tbl.tt.Indexes[idx].Id == iid {
+			return idx, true
+		}
+	}
+	return 0, false
+}
+
+func (tbl *table) setIndexes(indexes []indexType) {
+	tbl.tx.forWrite()
+
+	tt := *tbl.tt
+	tt.Indexes = indexes
+	tbl.tx.setTableType(tbl.tid, &tt)
+	tbl.tt = &tt
+}
+
+func (tbl *table) insertIndexes(row types.Row) error {
+	for _, idx := range tbl.tt.Indexes {
+		err := tbl.insertIndex(idx, row)
+		if err != nil {
+			return err
 		}
-	default:
-		panic(fmt.Sprintf("unexpected column type: %#v %d", ct, ct.Type))
 	}
+	return nil
+}
 
+// insertIndex puts the index item for row; it only already exists when the index is unique and
+// another row has the same key.
+func (tbl *table) insertIndex(idx indexType, row types.Row) error {
+	it := rowToIndexItem(toRelationId(tbl.tid, idx.Id), idx, tbl.tt.Key, row)
+	if idx.Unique && tbl.tx.tree.Has(it) {
+		return fmt.Errorf("basic: %s: unique index %d: existing row with duplicate key: %s",
+			tbl.tt.Name, idx.Id, row)
+	}
+	tbl.tx.put(it)
 	return nil
 }
 
+func (tbl *table) deleteIndexes(row types.Row) {
+	for _, idx := range tbl.tt.Indexes {
+		it := rowToIndexItem(toRelationId(tbl.tid, idx.Id), idx, tbl.tt.Key, row)
+		if _, ok := tbl.tx.delete(it); !ok {
+			panic(fmt.Sprintf("basic: table %s: index %d: missing item to delete: %v",
+				tbl.tt.Name, idx.Id, it.key))
+		}
+	}
+}
+
+func (tbl *table) buildIndex(idx indexType) error {
+	var rows []types.Row
+	tbl.scanRows(
+		func(row types.Row) error {
+			rows = append(rows, row)
+			return nil
+		})
+	for _, row := range rows {
+		err := tbl.insertIndex(idx, row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tbl *table) clearIndex(iid storage.IndexId) {
+	tbl.tx.deleteRange(toRelationId(tbl.tid, iid), nil, nil, nil)
+}
+
+func (tbl *table) CreateIndex(ctx context.Context, iid storage.IndexId,
+	key []types.ColumnKey, unique bool) error {
+
+	err := tbl.tx.checkWrite()
+	if err != nil {
+		return err
+	}
+
+	if iid == primaryIndexId {
+		panic(fmt.Sprintf("basic: table %s: index id reserved for primary index", tbl.tt.Name))
+	} else if _, ok := tbl.findIndex(iid); ok {
+		panic(fmt.Sprintf("basic: table %s: index already exists: %d", tbl.tt.Name, iid))
+	}
+	for _, ck := range key {
+		if int(ck.Column()) >= len(tbl.tt.ColumnNames) {
+			panic(fmt.Sprintf("basic: table %s: index key out of range: %d", tbl.tt.Name,
+				ck.Column()))
+		}
+	}
+
+	idx := indexType{
+		Id:     iid,
+		Key:    key,
+		Unique: unique,
+	}
+	tbl.setIndexes(append(slices.Clone(tbl.tt.Indexes), idx))
+	return tbl.buildIndex(idx)
+}
+
+func (tbl *table) DropIndex(ctx context.Context, iid storage.IndexId) error {
+	err := tbl.tx.checkWrite()
+	if err != nil {
+		return err
+	}
+
+	idx, ok := tbl.findIndex(iid)
+	if !ok {
+		panic(fmt.Sprintf("basic: table %s: index not found: %d", tbl.tt.Name, iid))
+	}
+
+	tbl.setIndexes(slices.Delete(slices.Clone(tbl.tt.Indexes), idx, idx+1))
+	tbl.clearIndex(iid)
+	return nil
+}
+
+func (tbl *table) SupportsPredicate(pred storage.Predicate) bool {
+	return storage.CheckPredicate(pred, tbl.tt.ColumnTypes) == nil
+}
+
 func (tbl *table) Rows(ctx context.Context, cols []types.ColumnNum, minRow, maxRow types.Row,
 	pred storage.Predicate) (storage.Rows, error) {
 
-	rel := toRelationId(tbl.tid, primaryIndexId)
+	return tbl.Scan(ctx, cols,
+		storage.ScanSpec{
+			MinRow: minRow,
+			MaxRow: maxRow,
+		}, pred)
+}
 
-	var maxItem item
-	if maxRow != nil {
-		maxItem = rowToItem(rel, tbl.tt.Key, maxRow)
+// comparePrefix compares key with bound, only up to the length of bound.
+func comparePrefix(key, bound []byte) int {
+	if len(key) > len(bound) {
+		key = key[:len(bound)]
+	}
+	return bytes.Compare(key, bound)
+}
+
+// prefixEnd returns the smallest key which is greater than every key starting with prefix; nil
+// if there is no such key.
+func prefixEnd(prefix []byte) []byte {
+	end := slices.Clone(prefix)
+	for idx := len(end) - 1; idx >= 0; idx -= 1 {
+		if end[idx] < 0xFF {
+			end[idx] += 1
+			return end[:idx+1]
+		}
+	}
+	return nil
+}
+
+func (tbl *table) Scan(ctx context.Context, cols []types.ColumnNum, spec storage.ScanSpec,
+	pred storage.Predicate) (storage.Rows, error) {
+
+	key := tbl.tt.Key
+	if spec.Prefix > 0 {
+		if spec.Prefix > len(key) {
+			panic(fmt.Sprintf("basic: table %s: scan prefix longer than primary key: %d",
+				tbl.tt.Name, spec.Prefix))
+		}
+		key = key[:spec.Prefix]
+	}
+
+	var minKey, maxKey []byte
+	if spec.MinRow != nil {
+		minKey = encode.MakeKey(key, spec.MinRow)
+	}
+	if spec.MaxRow != nil {
+		maxKey = encode.MakeKey(key, spec.MaxRow)
 	}
 
-	var predFn func(types.Value) bool
-	var predCol types.ColumnNum
 	if pred != nil {
-		predCol = pred.Column()
-		predFn = predicateFunction(pred, tbl.tt.ColumnTypes[predCol])
+		err := storage.CheckPredicate(pred, tbl.tt.ColumnTypes)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	var items []item
-	tbl.tx.tree.AscendGreaterOrEqual(rowToItem(rel, tbl.tt.Key, minRow),
-		func(it item) bool {
-			if it.rel != rel {
+	rel := toRelationId(tbl.tid, primaryIndexId)
+	tbl.tx.readRange(
+		readRange{
+			rel:    rel,
+			min:    minKey,
+			max:    maxKey,
+			prefix: spec.Prefix > 0,
+		})
+
+	tt := tbl.tt
+	rs := &rows{
+		tbl:     tbl,
+		tt:      tt,
+		cols:    cols,
+		tree:    tbl.tx.snapshot(),
+		rel:     rel,
+		reverse: spec.Reverse,
+		fetch: func(it item) (item, bool) {
+			it.row = tt.upgradeRow(it.ver, it.row)
+			it.ver = tt.Version
+			if pred != nil && !storage.EvalPredicate(pred, it.row) {
+				return it, false
+			}
+			return it, true
+		},
+	}
+
+	// The start of the range is handled by seek and the end of the range by past.
+	if spec.Reverse {
+		if maxKey != nil {
+			if spec.MaxExclusive {
+				rs.seek = maxKey
+			} else {
+				rs.seek = prefixEnd(maxKey)
+			}
+		}
+		rs.past = func(it item) bool {
+			if minKey == nil {
 				return false
 			}
-			if maxRow != nil && lessItems(maxItem, it) {
+			cmp := comparePrefix(it.key, minKey)
+			return cmp < 0 || (spec.MinExclusive && cmp == 0)
+		}
+	} else {
+		if minKey != nil {
+			if spec.MinExclusive {
+				rs.seek = prefixEnd(minKey)
+				rs.done = rs.seek == nil
+			} else {
+				rs.seek = minKey
+			}
+		}
+		rs.past = func(it item) bool {
+			if maxKey == nil {
 				return false
 			}
+			cmp := comparePrefix(it.key, maxKey)
+			return cmp > 0 || (spec.MaxExclusive && cmp == 0)
+		}
+	}
 
-			if predFn != nil && !predFn(it.row[predCol]) {
-				return true
-			}
+	tbl.tx.rowsCount += 1
+	return rs, nil
+}
 
-			items = append(items, it)
-			return true
+func (tbl *table) IndexRows(ctx context.Context, iid storage.IndexId, cols []types.ColumnNum,
+	minRow, maxRow types.Row) (storage.Rows, error) {
+
+	idx, ok := tbl.findIndex(iid)
+	if !ok {
+		panic(fmt.Sprintf("basic: table %s: index not found: %d", tbl.tt.Name, iid))
+	}
+	key := tbl.tt.Indexes[idx].Key
+
+	rel := toRelationId(tbl.tid, iid)
+	var minKey, maxKey []byte
+	if minRow != nil {
+		minKey = encode.MakeKey(key, minRow)
+	}
+	if maxRow != nil {
+		maxKey = encode.MakeKey(key, maxRow)
+	}
+
+	tbl.tx.readRange(
+		readRange{
+			rel:    rel,
+			min:    minKey,
+			max:    maxKey,
+			prefix: true,
 		})
 
+	prel := toRelationId(tbl.tid, primaryIndexId)
+	tt := tbl.tt
+	tree := tbl.tx.snapshot()
 	tbl.tx.rowsCount += 1
 	return &rows{
-		tbl:   tbl,
-		cols:  cols,
-		items: items,
+		tbl:  tbl,
+		tt:   tt,
+		cols: cols,
+		tree: tree,
+		rel:  rel,
+		seek: minKey,
+		past: func(it item) bool {
+			// Only compare the index key, which is a prefix of the item key.
+			return maxKey != nil && len(it.key) >= len(maxKey) &&
+				bytes.Compare(it.key[:len(maxKey)], maxKey) > 0
+		},
+		fetch: func(it item) (item, bool) {
+			pk := []byte(it.row[0].(types.BytesValue))
+			tbl.tx.readKey(prel, pk)
+			it, ok := tree.Get(keyToItem(prel, pk))
+			if !ok {
+				panic(fmt.Sprintf("basic: table %s: index %d: missing row: %v", tt.Name, iid,
+					pk))
+			}
+			it.row = tt.upgradeRow(it.ver, it.row)
+			it.ver = tt.Version
+			return it, true
+		},
 	}, nil
 }
 
 func (tbl *table) Insert(ctx context.Context, rows []types.Row) error {
+	err := tbl.tx.checkWrite()
+	if err != nil {
+		return err
+	}
+
 	tbl.tx.forWrite()
 
 	rel := toRelationId(tbl.tid, primaryIndexId)
@@ -375,60 +1308,185 @@ func (tbl *table) Insert(ctx context.Context, rows []types.Row) error {
 		}
 
 		it := rowToItem(rel, tbl.tt.Key, row)
+		it.ver = tbl.tt.Version
 		if tbl.tx.tree.Has(it) {
 			return fmt.Errorf("basic: %s: primary index: existing row with duplicate key: %s",
 				tbl.tt.Name, row)
 		}
 
-		tbl.tx.tree.ReplaceOrInsert(it)
+		tbl.tx.put(it)
+		err = tbl.insertIndexes(row)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (tbl *table) Upsert(ctx context.Context, rows []types.Row) error {
+	err := tbl.tx.checkWrite()
+	if err != nil {
+		return err
+	}
+
+	tbl.tx.forWrite()
+
+	rel := toRelationId(tbl.tid, primaryIndexId)
+	for _, row := range rows {
+		row, err := types.ConvertRow(tbl.tt.ColumnTypes, row)
+		if err != nil {
+			return err
+		}
+
+		it := rowToItem(rel, tbl.tt.Key, row)
+		it.ver = tbl.tt.Version
+		if oit, ok := tbl.tx.tree.Get(it); ok {
+			tbl.deleteIndexes(tbl.tt.upgradeRow(oit.ver, oit.row))
+		}
+
+		tbl.tx.put(it)
+		err = tbl.insertIndexes(row)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+func (tbl *table) DeleteRange(ctx context.Context, minRow, maxRow types.Row) (int64, error) {
+	err := tbl.tx.checkWrite()
+	if err != nil {
+		return 0, err
+	}
+
+	var minKey, maxKey []byte
+	if minRow != nil {
+		minKey = encode.MakeKey(tbl.tt.Key, minRow)
+	}
+	if maxRow != nil {
+		maxKey = encode.MakeKey(tbl.tt.Key, maxRow)
+	}
+
+	rel := toRelationId(tbl.tid, primaryIndexId)
+	tbl.tx.readRange(
+		readRange{
+			rel: rel,
+			min: minKey,
+			max: maxKey,
+		})
+
+	tbl.tx.forWrite()
+
+	// When every row is deleted, the indexes are cleared rather than deleting the index items
+	// for each row.
+	all := minRow == nil && maxRow == nil
+	var fn func(it item)
+	if len(tbl.tt.Indexes) > 0 && !all {
+		fn = func(it item) {
+			tbl.deleteIndexes(tbl.tt.upgradeRow(it.ver, it.row))
+		}
+	}
+	cnt := tbl.tx.deleteRange(rel, minKey, maxKey, fn)
+	if all {
+		for _, idx := range tbl.tt.Indexes {
+			tbl.clearIndex(idx.Id)
+		}
+	}
+	return cnt, nil
+}
+
 func (rs *rows) Next(ctx context.Context) (types.Row, error) {
-	if rs.next < 0 {
+	if rs.closed {
 		panic(fmt.Sprintf("basic: next on closed rows for table %d", rs.tbl.tid))
 	}
 
-	if rs.next == len(rs.items) {
+	if rs.done {
+		return nil, io.EOF
+	}
+
+	var found bool
+	visit := func(it item) bool {
+		it, found = rs.fetch(it)
+		if found {
+			rs.current = &it
+		}
+		return !found
+	}
+
+	if rs.reverse {
+		pivot := keyToItem(rs.rel+1, nil)
+		if rs.seek != nil {
+			pivot = keyToItem(rs.rel, rs.seek)
+		}
+		rs.tree.DescendLessOrEqual(pivot,
+			func(it item) bool {
+				if it.rel > rs.rel || (rs.seek != nil && bytes.Equal(it.key, rs.seek)) {
+					return true
+				} else if it.rel < rs.rel || rs.past(it) {
+					return false
+				}
+
+				rs.seek = it.key
+				return visit(it)
+			})
+	} else {
+		rs.tree.AscendGreaterOrEqual(keyToItem(rs.rel, rs.seek),
+			func(it item) bool {
+				if it.rel != rs.rel || rs.past(it) {
+					return false
+				}
+
+				// The smallest key greater than it.key.
+				rs.seek = append(append(make([]byte, 0, len(it.key)+1), it.key...), 0)
+				return visit(it)
+			})
+	}
+	if !found {
+		rs.done = true
 		return nil, io.EOF
 	}
 
-	rs.next += 1
 	if rs.cols != nil {
 		row := make([]types.Value, len(rs.cols))
 		for idx, col := range rs.cols {
-			row[idx] = rs.items[rs.next-1].row[col]
+			row[idx] = rs.current.row[col]
 		}
 		return row, nil
 	}
 
-	return rs.items[rs.next-1].row, nil
+	return rs.current.row, nil
 }
 
 func (rs *rows) Current() (storage.RowRef, error) {
-	if rs.next <= 0 {
+	if rs.closed || rs.current == nil {
 		panic(fmt.Sprintf("basic: missing current on rows for table %d", rs.tbl.tid))
 	}
 
 	return rowRef{
 		tbl: rs.tbl,
-		key: rs.items[rs.next-1].Key(),
+		key: rs.current.Key(),
 	}, nil
 }
 
 func (rs *rows) Close(ctx context.Context) error {
-	if rs.next < 0 {
+	if rs.closed {
 		panic(fmt.Sprintf("basic: close on closed rows for table %d", rs.tbl.tid))
 	}
 
 	rs.tbl.tx.rowsCount -= 1
-	rs.next = -1
+	rs.closed = true
+	rs.tree = nil
 	return nil
 }
 
 func (rr rowRef) Update(ctx context.Context, cols []types.ColumnNum, vals []types.Value) error {
+	err := rr.tbl.tx.checkWrite()
+	if err != nil {
+		return err
+	}
+
 	if len(cols) != len(vals) {
 		panic(fmt.Sprintf("basic: table %d: update len(cols) != len(vals): %d %d", rr.tbl.tid,
 			len(cols), len(vals)))
@@ -438,7 +1496,8 @@ func (rr rowRef) Update(ctx context.Context, cols []types.ColumnNum, vals []type
 	if !ok {
 		panic(fmt.Sprintf("basic: table %d: missing item to update: %v", rr.tbl.tid, rr.key))
 	}
-	row := append(make([]types.Value, 0, len(it.row)), it.row...)
+	row := append(make([]types.Value, 0, len(it.row)),
+		rr.tbl.tt.upgradeRow(it.ver, it.row)...)
 	for idx, col := range cols {
 		row[col] = vals[idx]
 	}
@@ -455,20 +1514,41 @@ func (rr rowRef) Update(ctx context.Context, cols []types.ColumnNum, vals []type
 			return err
 		}
 	} else {
-		rr.tbl.tx.tree.ReplaceOrInsert(
-			rowToItem(toRelationId(rr.tbl.tid, primaryIndexId), rr.tbl.tt.Key, row))
+		nit := rowToItem(toRelationId(rr.tbl.tid, primaryIndexId), rr.tbl.tt.Key, row)
+		nit.ver = rr.tbl.tt.Version
+		rr.tbl.tx.put(nit)
+
+		orow := rr.tbl.tt.upgradeRow(it.ver, it.row)
+		for _, idx := range rr.tbl.tt.Indexes {
+			if !types.ColumnKeyUpdated(idx.Key, cols) {
+				continue
+			}
+
+			rr.tbl.tx.delete(rowToIndexItem(toRelationId(rr.tbl.tid, idx.Id), idx,
+				rr.tbl.tt.Key, orow))
+			err := rr.tbl.insertIndex(idx, row)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
 func (rr rowRef) Delete(ctx context.Context) error {
+	err := rr.tbl.tx.checkWrite()
+	if err != nil {
+		return err
+	}
+
 	rr.tbl.tx.forWrite()
 
-	_, ok := rr.tbl.tx.tree.Delete(keyToItem(toRelationId(rr.tbl.tid, primaryIndexId), rr.key))
+	it, ok := rr.tbl.tx.delete(keyToItem(toRelationId(rr.tbl.tid, primaryIndexId), rr.key))
 	if !ok {
 		panic(fmt.Sprintf("basic: table %d: missing item to delete: %v", rr.tbl.tid, rr.key))
 	}
+	rr.tbl.deleteIndexes(rr.tbl.tt.upgradeRow(it.ver, it.row))
 
 	return nil
 }
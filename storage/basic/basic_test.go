@@ -1,11 +1,15 @@
 package basic_test
 
 import (
+	"context"
+	"io"
+	"reflect"
 	"testing"
 
 	"github.com/leftmike/maho/storage"
 	"github.com/leftmike/maho/storage/basic"
 	"github.com/leftmike/maho/storage/test"
+	"github.com/leftmike/maho/types"
 )
 
 func TestStore(t *testing.T) {
@@ -17,8 +21,234 @@ func TestStore(t *testing.T) {
 	test.TestCreateTable(t, "basic", newStore)
 	test.TestDropTable(t, "basic", newStore)
 	test.TestRows(t, "basic", newStore)
+	test.TestScan(t, "basic", newStore)
+	test.TestPredicates(t, "basic", newStore)
 	test.TestInsert(t, "basic", newStore)
+	test.TestUpsert(t, "basic", newStore)
 	test.TestDelete(t, "basic", newStore)
+	test.TestDeleteRange(t, "basic", newStore)
 	test.TestUpdate(t, "basic", newStore)
 	test.TestTable(t, "basic", newStore)
+	test.TestSavepoints(t, "basic", newStore)
+	test.TestAlterColumns(t, "basic", newStore)
+	test.TestRenameTable(t, "basic", newStore)
+	test.TestIndexes(t, "basic", newStore)
+}
+
+func TestMVCCStore(t *testing.T) {
+	newStore := func(dataDir string) (storage.Store, error) {
+		return basic.NewMVCCStore(dataDir)
+	}
+
+	test.TestStore(t, "mvcc", newStore)
+	test.TestCreateTable(t, "mvcc", newStore)
+	test.TestDropTable(t, "mvcc", newStore)
+	test.TestRows(t, "mvcc", newStore)
+	test.TestScan(t, "mvcc", newStore)
+	test.TestPredicates(t, "mvcc", newStore)
+	test.TestInsert(t, "mvcc", newStore)
+	test.TestUpsert(t, "mvcc", newStore)
+	test.TestDelete(t, "mvcc", newStore)
+	test.TestDeleteRange(t, "mvcc", newStore)
+	test.TestUpdate(t, "mvcc", newStore)
+	test.TestTable(t, "mvcc", newStore)
+	test.TestSavepoints(t, "mvcc", newStore)
+	test.TestReadCommittedSavepoints(t, "mvcc", newStore)
+	test.TestAlterColumns(t, "mvcc", newStore)
+	test.TestRenameTable(t, "mvcc", newStore)
+	test.TestIndexes(t, "mvcc", newStore)
+}
+
+var (
+	tid = storage.EngineTableId + 1
+	tn  = types.TableName{
+		Database: types.MAHO,
+		Schema:   types.PUBLIC,
+		Table:    types.ID("t", false),
+	}
+	colNames = []types.Identifier{types.ID("c1", false), types.ID("c2", false)}
+	colTypes = []types.ColumnType{types.Int64ColType, types.NullStringColType}
+	primary  = []types.ColumnKey{types.MakeColumnKey(0, false)}
+)
+
+func modifyTable(t *testing.T, st storage.Store, commit bool,
+	fn func(ctx context.Context, tbl storage.Table) error) {
+
+	t.Helper()
+
+	ctx := context.Background()
+	tx := st.Begin()
+	tbl, err := tx.OpenTable(ctx, tid, tn, colNames, colTypes, primary)
+	if err != nil {
+		t.Fatalf("OpenTable(%s) failed with %s", tn, err)
+	}
+	err = fn(ctx, tbl)
+	if err != nil {
+		t.Fatalf("modify %s failed with %s", tn, err)
+	}
+	if commit {
+		err = tx.Commit(ctx)
+	} else {
+		err = tx.Rollback()
+	}
+	if err != nil {
+		t.Fatalf("Commit or Rollback failed with %s", err)
+	}
+}
+
+func checkRows(t *testing.T, st storage.Store, want []types.Row) {
+	t.Helper()
+
+	var got []types.Row
+	modifyTable(t, st, false,
+		func(ctx context.Context, tbl storage.Table) error {
+			rows, err := tbl.Rows(ctx, nil, nil, nil, nil)
+			if err != nil {
+				return err
+			}
+			defer rows.Close(ctx)
+
+			for {
+				row, err := rows.Next(ctx)
+				if err == io.EOF {
+					return nil
+				} else if err != nil {
+					return err
+				}
+				got = append(got, row)
+			}
+		})
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Rows() got %v want %v", got, want)
+	}
+}
+
+// memLog keeps the items of each committed transaction in memory.
+type memLog struct {
+	commits [][]basic.Item
+}
+
+func (ml *memLog) Commit(items []basic.Item, all func(fn func(it basic.Item) bool)) error {
+	ml.commits = append(ml.commits, items)
+	return nil
+}
+
+func (ml *memLog) load(fn func(it basic.Item)) error {
+	for _, items := range ml.commits {
+		for _, it := range items {
+			fn(it)
+		}
+	}
+	return nil
+}
+
+// lastCommit returns the number of cleared relations and deleted items in the last commit.
+func (ml *memLog) lastCommit() (int, int) {
+	var cleared, deleted int
+	for _, it := range ml.commits[len(ml.commits)-1] {
+		if it.Clear {
+			cleared += 1
+		} else if it.Row == nil {
+			deleted += 1
+		}
+	}
+	return cleared, deleted
+}
+
+func TestLogClear(t *testing.T) {
+	ctx := context.Background()
+
+	for _, mvcc := range []bool{false, true} {
+		ml := &memLog{}
+		st, err := basic.NewLogStore("clear", ml, ml.load, mvcc)
+		if err != nil {
+			t.Fatalf("NewLogStore() failed with %s", err)
+		}
+
+		tx := st.Begin()
+		err = tx.CreateTable(ctx, tid, tn, colNames, colTypes, primary)
+		if err != nil {
+			t.Fatalf("CreateTable(%s) failed with %s", tn, err)
+		}
+		err = tx.Commit(ctx)
+		if err != nil {
+			t.Fatalf("Commit() failed with %s", err)
+		}
+
+		modifyTable(t, st, true,
+			func(ctx context.Context, tbl storage.Table) error {
+				err := tbl.CreateIndex(ctx, 1, []types.ColumnKey{types.MakeColumnKey(1, false)},
+					false)
+				if err != nil {
+					return err
+				}
+
+				var rows []types.Row
+				for n := 0; n < 100; n += 1 {
+					rows = append(rows, types.Row{types.Int64Value(n), types.StringValue("row")})
+				}
+				return tbl.Insert(ctx, rows)
+			})
+
+		// Deleting every row of the table is logged as clearing the primary and the index,
+		// rather than deleting each item.
+		want := []types.Row{{types.Int64Value(200), types.StringValue("two hundred")}}
+		modifyTable(t, st, true,
+			func(ctx context.Context, tbl storage.Table) error {
+				_, err := tbl.DeleteRange(ctx, nil, nil)
+				if err != nil {
+					return err
+				}
+				return tbl.Insert(ctx, want)
+			})
+		cleared, deleted := ml.lastCommit()
+		if cleared != 2 || deleted != 0 {
+			t.Errorf("DeleteRange(mvcc: %v) logged %d cleared and %d deleted want 2 and 0", mvcc,
+				cleared, deleted)
+		}
+
+		st, err = basic.NewLogStore("clear", ml, ml.load, mvcc)
+		if err != nil {
+			t.Fatalf("NewLogStore() failed with %s", err)
+		}
+		checkRows(t, st, want)
+
+		if !mvcc {
+			continue
+		}
+
+		// A row inserted by a concurrent transaction is not deleted, so each deleted item must
+		// be logged.
+		tx1 := st.Begin()
+		tx2 := st.Begin()
+		_, err = openTable(t, tx1).DeleteRange(ctx, nil, nil)
+		if err != nil {
+			t.Fatalf("DeleteRange() failed with %s", err)
+		}
+		want = []types.Row{{types.Int64Value(300), types.StringValue("three hundred")}}
+		err = openTable(t, tx2).Insert(ctx, want)
+		if err != nil {
+			t.Fatalf("Insert() failed with %s", err)
+		}
+		err = tx2.Commit(ctx)
+		if err != nil {
+			t.Fatalf("Commit() failed with %s", err)
+		}
+		err = tx1.Commit(ctx)
+		if err != nil {
+			t.Fatalf("Commit() failed with %s", err)
+		}
+		cleared, deleted = ml.lastCommit()
+		if cleared != 0 || deleted != 2 {
+			t.Errorf("DeleteRange(concurrent) logged %d cleared and %d deleted want 0 and 2",
+				cleared, deleted)
+		}
+
+		st, err = basic.NewLogStore("clear", ml, ml.load, mvcc)
+		if err != nil {
+			t.Fatalf("NewLogStore() failed with %s", err)
+		}
+		checkRows(t, st, want)
+	}
 }
@@ -16,6 +16,7 @@ type item struct {
 	rel relationId
 	key []byte
 	row types.Row
+	ver uint32 // version of the table type when the row was written
 }
 
 func lessItems(it1, it2 item) bool {
@@ -42,6 +43,34 @@ func rowToItem(rel relationId, rowKey []types.ColumnKey,
 	return it
 }
 
+// rowToIndexItem returns an item for an index: the key is the index key followed by the
+// primary key, so that it is unique, and the row is the primary key. For a unique index, the
+// primary key is only appended when the index key contains a NULL, so that two rows with the
+// same index key have the same item.
+func rowToIndexItem(rel relationId, idx indexType, rowKey []types.ColumnKey,
+	row types.Row) item {
+
+	pk := encode.MakeKey(rowKey, row)
+	key := encode.MakeKey(idx.Key, row)
+	if !idx.Unique || hasNull(idx.Key, row) {
+		key = append(key, pk...)
+	}
+	return item{
+		rel: rel,
+		key: key,
+		row: types.Row{types.BytesValue(pk)},
+	}
+}
+
+func hasNull(key []types.ColumnKey, row types.Row) bool {
+	for _, ck := range key {
+		if row[ck.Column()] == nil {
+			return true
+		}
+	}
+	return false
+}
+
 func keyToItem(rel relationId, key []byte) item {
 	return item{
 		rel: rel,
@@ -57,6 +86,36 @@ func (it item) Key() []byte {
 	return it.key
 }
 
+// countRelation returns the number of items of rel in tree.
+func countRelation(tree *btree.BTreeG[item], rel relationId) int {
+	var cnt int
+	tree.AscendGreaterOrEqual(keyToItem(rel, nil),
+		func(it item) bool {
+			if it.rel != rel {
+				return false
+			}
+			cnt += 1
+			return true
+		})
+	return cnt
+}
+
+// clearRelation deletes every item of rel from tree.
+func clearRelation(tree *btree.BTreeG[item], rel relationId) {
+	var its []item
+	tree.AscendGreaterOrEqual(keyToItem(rel, nil),
+		func(it item) bool {
+			if it.rel != rel {
+				return false
+			}
+			its = append(its, it)
+			return true
+		})
+	for _, it := range its {
+		tree.Delete(it)
+	}
+}
+
 func newBTree() *btree.BTreeG[item] {
 	return btree.NewG[item](8, lessItems)
 }
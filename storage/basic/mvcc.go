@@ -0,0 +1,306 @@
+package basic
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/btree"
+
+	"github.com/leftmike/maho/storage"
+)
+
+// With multiversion concurrency control, each transaction reads a snapshot of the store as of
+// when it began: the tree of items is copy-on-write, so the snapshot is just the tree. Changes
+// are kept private to the transaction until it commits, at which point they are applied to the
+// latest tree. Read committed transactions get a new snapshot for each statement.
+//
+// Commits are ordered by timestamp. For each item changed by a commit, the timestamp is
+// remembered for as long as there is an active transaction which began before the commit. A
+// transaction which changed an item that was also changed by a transaction which committed
+// after its snapshot was taken fails to commit with storage.ErrSerialization.
+//
+// Serializable transactions use serializable snapshot isolation: the ranges of keys read by
+// each serializable transaction are tracked, as are the items changed by each committed
+// transaction. There is a read-write antidependency from T1 to T2 if they are concurrent and T1
+// read an item that T2 changed. A cycle in the serialization graph must contain a pivot: T1 ->
+// T2 -> T3, where T3 is the first to commit. A transaction fails to commit if it would complete
+// such a pivot. Some transactions which would have been serializable will fail as well.
+//
+// The trees of recent commits are kept for historyRetention, so that read only transactions can
+// read the store as of a time in the past.
+type mvccState struct {
+	ts        uint64                  // timestamp of the last commit
+	versions  map[itemKey]uint64      // timestamp of the last commit which changed each item
+	active    map[*transaction]uint64 // start timestamp of each active transaction
+	committed []*commitRecord         // transactions which committed while others were active
+	history   []historyTree           // oldest first
+}
+
+const historyRetention = 5 * time.Minute
+
+type historyTree struct {
+	time time.Time
+	tree *btree.BTreeG[item]
+}
+
+type commitRecord struct {
+	ts      uint64
+	changed map[itemKey]uint64
+	reads   []readRange
+	out     bool // antidependency to a transaction which committed first
+}
+
+// readRange is the range of keys from min to max, inclusive, in rel; if min is nil, the range
+// starts with the first key, and if max is nil, the range ends with the last key. If prefix is
+// true, keys are compared with max only up to the length of max.
+type readRange struct {
+	rel    relationId
+	min    []byte
+	max    []byte
+	prefix bool
+}
+
+func (rr readRange) contains(ik itemKey) bool {
+	if rr.rel != ik.rel {
+		return false
+	}
+
+	key := []byte(ik.key)
+	if bytes.Compare(key, rr.min) < 0 {
+		return false
+	}
+	if rr.max == nil {
+		return true
+	}
+	if rr.prefix {
+		if len(key) < len(rr.max) {
+			return true
+		}
+		key = key[:len(rr.max)]
+	}
+	return bytes.Compare(key, rr.max) <= 0
+}
+
+func readsChanged(reads []readRange, changed map[itemKey]uint64) bool {
+	for _, rr := range reads {
+		for ik := range changed {
+			if rr.contains(ik) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (tx *transaction) readRange(rr readRange) {
+	if tx.st.mvcc == nil || tx.isolation != storage.Serializable {
+		return
+	}
+
+	// Other transactions check the reads when they commit.
+	tx.st.mutex.Lock()
+	tx.reads = append(tx.reads, rr)
+	tx.st.mutex.Unlock()
+}
+
+func (tx *transaction) readKey(rel relationId, key []byte) {
+	tx.readRange(
+		readRange{
+			rel: rel,
+			min: key,
+			max: key,
+		})
+}
+
+// begin, end, refreshMVCC, checkSerializable, and commitMVCC must be called with the store
+// mutex held.
+
+func (ms *mvccState) begin(tx *transaction) uint64 {
+	ms.active[tx] = ms.ts
+	return ms.ts
+}
+
+func (ms *mvccState) end(tx *transaction) {
+	delete(ms.active, tx)
+
+	if len(ms.active) == 0 {
+		ms.versions = map[itemKey]uint64{}
+		ms.committed = nil
+		return
+	}
+
+	oldest := ms.ts
+	for _, start := range ms.active {
+		if start < oldest {
+			oldest = start
+		}
+	}
+	for ik, ts := range ms.versions {
+		if ts <= oldest {
+			delete(ms.versions, ik)
+		}
+	}
+
+	var committed []*commitRecord
+	for _, cr := range ms.committed {
+		if cr.ts > oldest {
+			committed = append(committed, cr)
+		}
+	}
+	ms.committed = committed
+}
+
+// refreshMVCC moves the snapshot of a read committed transaction to the latest commit; any
+// changes made by the transaction are applied to the new snapshot.
+func (st *store) refreshMVCC(tx *transaction) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	tree := st.tree
+	if tx.private {
+		tree = tree.Clone()
+		for ik := range tx.changed {
+			it, ok := tx.tree.Get(keyToItem(ik.rel, []byte(ik.key)))
+			if ok {
+				tree.ReplaceOrInsert(it)
+			} else {
+				tree.Delete(keyToItem(ik.rel, []byte(ik.key)))
+			}
+		}
+	}
+	tx.tree = tree
+	tx.base = st.tree
+	tx.start = st.mvcc.ts
+}
+
+// checkSerializable returns an error if tx is a pivot or would make a committed transaction a
+// pivot; otherwise, it returns whether there is an antidependency from tx to a transaction
+// which has already committed.
+func (ms *mvccState) checkSerializable(tx *transaction) (bool, error) {
+	var in, out bool
+	for _, cr := range ms.committed {
+		if cr.ts <= tx.start {
+			continue
+		}
+
+		if readsChanged(tx.reads, cr.changed) {
+			if cr.out {
+				return false, fmt.Errorf("basic: %w: antidependency to a pivot",
+					storage.ErrSerialization)
+			}
+			out = true
+		}
+		if readsChanged(cr.reads, tx.changed) {
+			in = true
+		}
+	}
+
+	for atx := range ms.active {
+		if atx != tx && readsChanged(atx.reads, tx.changed) {
+			in = true
+		}
+	}
+
+	if in && out {
+		return false, fmt.Errorf("basic: %w: transaction is a pivot", storage.ErrSerialization)
+	}
+	return out, nil
+}
+
+func (ms *mvccState) addHistory(tree *btree.BTreeG[item]) {
+	now := time.Now()
+	ms.history = append(ms.history,
+		historyTree{
+			time: now,
+			tree: tree,
+		})
+
+	// Keep the newest tree which is older than the retention: it is the store as of the cutoff.
+	cutoff := now.Add(-historyRetention)
+	for len(ms.history) > 1 && !ms.history[1].time.After(cutoff) {
+		ms.history = ms.history[1:]
+	}
+}
+
+func (ms *mvccState) snapshotAt(asOf time.Time) (*btree.BTreeG[item], error) {
+	now := time.Now()
+	if asOf.After(now) {
+		return nil, fmt.Errorf("basic: as of system time %s is in the future",
+			asOf.Format(time.RFC3339Nano))
+	} else if asOf.Before(now.Add(-historyRetention)) || len(ms.history) == 0 ||
+		asOf.Before(ms.history[0].time) {
+
+		return nil, fmt.Errorf("basic: as of system time %s is too old",
+			asOf.Format(time.RFC3339Nano))
+	}
+
+	for idx := len(ms.history) - 1; idx >= 0; idx -= 1 {
+		if !ms.history[idx].time.After(asOf) {
+			return ms.history[idx].tree, nil
+		}
+	}
+	return nil, errors.New("basic: history is missing")
+}
+
+func (st *store) commitMVCC(tx *transaction) error {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	ms := st.mvcc
+	defer ms.end(tx)
+
+	if len(tx.changed) == 0 && len(tx.reads) == 0 {
+		return nil
+	}
+	for ik, ts := range tx.changed {
+		if ms.versions[ik] > ts {
+			return fmt.Errorf("basic: %w", storage.ErrSerialization)
+		}
+	}
+
+	var out bool
+	if tx.isolation == storage.Serializable {
+		var err error
+		out, err = ms.checkSerializable(tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	tree := st.tree
+	if len(tx.changed) > 0 {
+		items := tx.changedItems()
+		tree = st.tree.Clone()
+		for _, it := range items {
+			if it.row == nil {
+				tree.Delete(it)
+			} else {
+				tree.ReplaceOrInsert(it)
+			}
+		}
+
+		err := st.logCommit(items, tx.cleared, tree)
+		if err != nil {
+			return err
+		}
+		ms.addHistory(tree)
+	}
+
+	ms.ts += 1
+	for ik := range tx.changed {
+		ms.versions[ik] = ms.ts
+	}
+	if len(ms.active) > 1 {
+		ms.committed = append(ms.committed,
+			&commitRecord{
+				ts:      ms.ts,
+				changed: tx.changed,
+				reads:   tx.reads,
+				out:     out,
+			})
+	}
+	st.tree = tree
+	return nil
+}
@@ -0,0 +1,774 @@
+package basic_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leftmike/maho/storage"
+	"github.com/leftmike/maho/storage/basic"
+	"github.com/leftmike/maho/types"
+)
+
+func newMVCCStore(t *testing.T, rows []types.Row) storage.Store {
+	t.Helper()
+
+	st, err := basic.NewMVCCStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewMVCCStore() failed with %s", err)
+	}
+
+	ctx := context.Background()
+	tx := st.Begin()
+	err = tx.CreateTable(ctx, tid, tn, colNames, colTypes, primary)
+	if err != nil {
+		t.Fatalf("CreateTable(%s) failed with %s", tn, err)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		t.Fatalf("Commit() failed with %s", err)
+	}
+
+	modifyTable(t, st, true,
+		func(ctx context.Context, tbl storage.Table) error {
+			return tbl.Insert(ctx, rows)
+		})
+	return st
+}
+
+func openTable(t *testing.T, tx storage.Transaction) storage.Table {
+	t.Helper()
+
+	tbl, err := tx.OpenTable(context.Background(), tid, tn, colNames, colTypes, primary)
+	if err != nil {
+		t.Fatalf("OpenTable(%s) failed with %s", tn, err)
+	}
+	return tbl
+}
+
+func readRows(ctx context.Context, tbl storage.Table) ([]types.Row, error) {
+	rows, err := tbl.Rows(ctx, nil, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close(ctx)
+
+	var all []types.Row
+	for {
+		row, err := rows.Next(ctx)
+		if err == io.EOF {
+			return all, nil
+		} else if err != nil {
+			return nil, err
+		}
+		all = append(all, row)
+	}
+}
+
+// updateRow sets the second column of the row with key c1 to the result of fn.
+func updateRow(ctx context.Context, tbl storage.Table, c1 int64,
+	fn func(val types.Value) types.Value) error {
+
+	key := types.Row{types.Int64Value(c1), nil}
+	rows, err := tbl.Rows(ctx, nil, key, key, nil)
+	if err != nil {
+		return err
+	}
+	defer rows.Close(ctx)
+
+	for {
+		row, err := rows.Next(ctx)
+		if err != nil {
+			return err
+		}
+		if row[0] == types.Int64Value(c1) {
+			ref, err := rows.Current()
+			if err != nil {
+				return err
+			}
+			return ref.Update(ctx, []types.ColumnNum{1}, []types.Value{fn(row[1])})
+		}
+	}
+}
+
+func setValue(s string) func(val types.Value) types.Value {
+	return func(val types.Value) types.Value {
+		return types.StringValue(s)
+	}
+}
+
+func mustRows(t *testing.T, tx storage.Transaction, want []types.Row) {
+	t.Helper()
+
+	got, err := readRows(context.Background(), openTable(t, tx))
+	if err != nil {
+		t.Fatalf("Rows() failed with %s", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Rows() got %v want %v", got, want)
+	}
+}
+
+func TestMVCCSnapshot(t *testing.T) {
+	ctx := context.Background()
+	before := []types.Row{
+		{types.Int64Value(1), types.StringValue("one")},
+		{types.Int64Value(2), types.StringValue("two")},
+	}
+	st := newMVCCStore(t, before)
+
+	tx1 := st.Begin()
+	mustRows(t, tx1, before)
+
+	tx2 := st.Begin()
+	tbl := openTable(t, tx2)
+	err := updateRow(ctx, tbl, 1, setValue("ONE"))
+	if err != nil {
+		t.Fatalf("updateRow() failed with %s", err)
+	}
+	err = tbl.Insert(ctx, []types.Row{{types.Int64Value(3), types.StringValue("three")}})
+	if err != nil {
+		t.Fatalf("Insert() failed with %s", err)
+	}
+
+	// Changes are not visible to other transactions until they are committed.
+	mustRows(t, tx1, before)
+	err = tx2.Commit(ctx)
+	if err != nil {
+		t.Fatalf("Commit() failed with %s", err)
+	}
+
+	// A transaction continues to see its snapshot after other transactions commit.
+	mustRows(t, tx1, before)
+	err = tx1.Rollback()
+	if err != nil {
+		t.Fatalf("Rollback() failed with %s", err)
+	}
+
+	checkRows(t, st,
+		[]types.Row{
+			{types.Int64Value(1), types.StringValue("ONE")},
+			{types.Int64Value(2), types.StringValue("two")},
+			{types.Int64Value(3), types.StringValue("three")},
+		})
+}
+
+func TestMVCCConflict(t *testing.T) {
+	ctx := context.Background()
+	st := newMVCCStore(t,
+		[]types.Row{
+			{types.Int64Value(1), types.StringValue("one")},
+			{types.Int64Value(2), types.StringValue("two")},
+			{types.Int64Value(3), types.StringValue("three")},
+		})
+
+	update := func(tx storage.Transaction, c1 int64, s string) {
+		t.Helper()
+
+		err := updateRow(ctx, openTable(t, tx), c1, setValue(s))
+		if err != nil {
+			t.Fatalf("updateRow(%d) failed with %s", c1, err)
+		}
+	}
+
+	insert := func(tx storage.Transaction, c1 int64, s string) {
+		t.Helper()
+
+		err := openTable(t, tx).Insert(ctx,
+			[]types.Row{{types.Int64Value(c1), types.StringValue(s)}})
+		if err != nil {
+			t.Fatalf("Insert(%d) failed with %s", c1, err)
+		}
+	}
+
+	commit := func(tx storage.Transaction, conflict bool) {
+		t.Helper()
+
+		err := tx.Commit(ctx)
+		if conflict {
+			if !errors.Is(err, storage.ErrSerialization) {
+				t.Errorf("Commit() got %v want %s", err, storage.ErrSerialization)
+			}
+		} else if err != nil {
+			t.Errorf("Commit() failed with %s", err)
+		}
+	}
+
+	// Concurrent transactions which change different rows do not conflict.
+	tx1 := st.Begin()
+	tx2 := st.Begin()
+	update(tx1, 1, "ONE")
+	update(tx2, 2, "TWO")
+	commit(tx2, false)
+	commit(tx1, false)
+
+	// The first transaction to commit a change to a row wins.
+	tx1 = st.Begin()
+	tx2 = st.Begin()
+	update(tx1, 3, "three-1")
+	update(tx2, 3, "three-2")
+	commit(tx2, false)
+	commit(tx1, true)
+
+	tx1 = st.Begin()
+	tx2 = st.Begin()
+	insert(tx1, 4, "four-1")
+	insert(tx2, 4, "four-2")
+	commit(tx1, false)
+	commit(tx2, true)
+
+	// A transaction which began after the commit does not conflict.
+	tx1 = st.Begin()
+	update(tx1, 3, "three-3")
+	commit(tx1, false)
+
+	checkRows(t, st,
+		[]types.Row{
+			{types.Int64Value(1), types.StringValue("ONE")},
+			{types.Int64Value(2), types.StringValue("TWO")},
+			{types.Int64Value(3), types.StringValue("three-3")},
+			{types.Int64Value(4), types.StringValue("four-1")},
+		})
+}
+
+func TestMVCCConcurrent(t *testing.T) {
+	const (
+		writers    = 8
+		increments = 25
+		readers    = 4
+	)
+
+	ctx := context.Background()
+	st := newMVCCStore(t,
+		[]types.Row{
+			{types.Int64Value(1), types.StringValue("0")},
+			{types.Int64Value(2), types.StringValue("0")},
+		})
+
+	// Each writer increments both counters in a transaction, retrying on conflicts.
+	increment := func(val types.Value) types.Value {
+		n, err := strconv.Atoi(string(val.(types.StringValue)))
+		if err != nil {
+			panic(err)
+		}
+		return types.StringValue(strconv.Itoa(n + 1))
+	}
+
+	writer := func() error {
+		for cnt := 0; cnt < increments; {
+			tx := st.Begin()
+			tbl, err := tx.OpenTable(ctx, tid, tn, colNames, colTypes, primary)
+			if err == nil {
+				err = updateRow(ctx, tbl, 1, increment)
+			}
+			if err == nil {
+				err = updateRow(ctx, tbl, 2, increment)
+			}
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+			err = tx.Commit(ctx)
+			if err == nil {
+				cnt += 1
+			} else if !errors.Is(err, storage.ErrSerialization) {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Each reader must always see both counters with the same value.
+	done := make(chan struct{})
+	reader := func() error {
+		for {
+			select {
+			case <-done:
+				return nil
+			default:
+			}
+
+			tx := st.Begin()
+			tbl, err := tx.OpenTable(ctx, tid, tn, colNames, colTypes, primary)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+			rows, err := readRows(ctx, tbl)
+			tx.Rollback()
+			if err != nil {
+				return err
+			}
+			if len(rows) != 2 || rows[0][1] != rows[1][1] {
+				return fmt.Errorf("inconsistent snapshot: %v", rows)
+			}
+		}
+	}
+
+	var rwg, wwg sync.WaitGroup
+	run := func(wg *sync.WaitGroup, fn func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := fn()
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	for n := 0; n < readers; n += 1 {
+		run(&rwg, reader)
+	}
+	for n := 0; n < writers; n += 1 {
+		run(&wwg, writer)
+	}
+	wwg.Wait()
+	close(done)
+	rwg.Wait()
+
+	total := types.StringValue(strconv.Itoa(writers * increments))
+	checkRows(t, st,
+		[]types.Row{
+			{types.Int64Value(1), total},
+			{types.Int64Value(2), total},
+		})
+}
+
+func TestMVCCUniqueIndex(t *testing.T) {
+	const inserters = 8
+
+	ctx := context.Background()
+	st := newMVCCStore(t,
+		[]types.Row{
+			{types.Int64Value(1), types.StringValue("one")},
+		})
+	modifyTable(t, st, true,
+		func(ctx context.Context, tbl storage.Table) error {
+			return tbl.CreateIndex(ctx, 1, []types.ColumnKey{types.MakeColumnKey(1, false)},
+				true)
+		})
+
+	insert := func(tx storage.Transaction, c1 int64, c2 types.Value) error {
+		return openTable(t, tx).Insert(ctx, []types.Row{{types.Int64Value(c1), c2}})
+	}
+
+	// Concurrent transactions which insert the same key conflict, even though neither can see
+	// the row inserted by the other.
+	tx1 := st.Begin()
+	tx2 := st.Begin()
+	err := insert(tx1, 2, types.StringValue("two"))
+	if err != nil {
+		t.Fatalf("Insert(2) failed with %s", err)
+	}
+	err = insert(tx2, 3, types.StringValue("two"))
+	if err != nil {
+		t.Fatalf("Insert(3) failed with %s", err)
+	}
+	err = tx1.Commit(ctx)
+	if err != nil {
+		t.Fatalf("Commit() failed with %s", err)
+	}
+	err = tx2.Commit(ctx)
+	if !errors.Is(err, storage.ErrSerialization) {
+		t.Errorf("Commit() got %v want %s", err, storage.ErrSerialization)
+	}
+
+	// Rows with a NULL key are never duplicates.
+	tx1 = st.Begin()
+	tx2 = st.Begin()
+	for _, c1 := range []int64{4, 5} {
+		err = insert(tx1, c1, nil)
+		if err != nil {
+			t.Fatalf("Insert(%d) failed with %s", c1, err)
+		}
+	}
+	err = insert(tx2, 6, nil)
+	if err != nil {
+		t.Fatalf("Insert(6) failed with %s", err)
+	}
+	for _, tx := range []storage.Transaction{tx1, tx2} {
+		err = tx.Commit(ctx)
+		if err != nil {
+			t.Fatalf("Commit() failed with %s", err)
+		}
+	}
+
+	// Of many concurrent transactions inserting the same key, exactly one commits; the others
+	// fail to insert or to commit.
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var committed []int64
+	for n := 0; n < inserters; n += 1 {
+		c1 := int64(10 + n)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			tx := st.Begin()
+			tbl, err := tx.OpenTable(ctx, tid, tn, colNames, colTypes, primary)
+			if err == nil {
+				err = tbl.Insert(ctx,
+					[]types.Row{{types.Int64Value(c1), types.StringValue("ten")}})
+			}
+			if err != nil {
+				tx.Rollback()
+				return
+			}
+			err = tx.Commit(ctx)
+			if err == nil {
+				mutex.Lock()
+				committed = append(committed, c1)
+				mutex.Unlock()
+			} else if !errors.Is(err, storage.ErrSerialization) {
+				t.Errorf("Commit() failed with %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(committed) != 1 {
+		t.Fatalf("committed got %v want one transaction", committed)
+	}
+	checkRows(t, st,
+		[]types.Row{
+			{types.Int64Value(1), types.StringValue("one")},
+			{types.Int64Value(2), types.StringValue("two")},
+			{types.Int64Value(4), nil},
+			{types.Int64Value(5), nil},
+			{types.Int64Value(6), nil},
+			{types.Int64Value(committed[0]), types.StringValue("ten")},
+		})
+}
+
+func TestMVCCWriteSkew(t *testing.T) {
+	ctx := context.Background()
+
+	// Each transaction turns off one row if both rows are on; if the transactions are
+	// serializable, at most one row is turned off.
+	turnOff := func(tx storage.Transaction, c1 int64) {
+		t.Helper()
+
+		tbl := openTable(t, tx)
+		rows, err := readRows(ctx, tbl)
+		if err != nil {
+			t.Fatalf("Rows() failed with %s", err)
+		}
+		for _, row := range rows {
+			if row[1] != types.StringValue("on") {
+				return
+			}
+		}
+		err = updateRow(ctx, tbl, c1, setValue("off"))
+		if err != nil {
+			t.Fatalf("updateRow(%d) failed with %s", c1, err)
+		}
+	}
+
+	for _, level := range []storage.IsolationLevel{storage.Snapshot, storage.Serializable} {
+		st := newMVCCStore(t,
+			[]types.Row{
+				{types.Int64Value(1), types.StringValue("on")},
+				{types.Int64Value(2), types.StringValue("on")},
+			})
+
+		tx1 := st.Begin()
+		tx2 := st.Begin()
+		for _, tx := range []storage.Transaction{tx1, tx2} {
+			err := tx.SetIsolation(level)
+			if err != nil {
+				t.Fatalf("SetIsolation(%s) failed with %s", level, err)
+			}
+		}
+		turnOff(tx1, 1)
+		turnOff(tx2, 2)
+
+		err := tx1.Commit(ctx)
+		if err != nil {
+			t.Errorf("%s: Commit() failed with %s", level, err)
+		}
+		err = tx2.Commit(ctx)
+		if level == storage.Serializable {
+			if !errors.Is(err, storage.ErrSerialization) {
+				t.Errorf("%s: Commit() got %v want %s", level, err, storage.ErrSerialization)
+			}
+		} else if err != nil {
+			t.Errorf("%s: Commit() failed with %s", level, err)
+		}
+	}
+}
+
+func TestMVCCPhantom(t *testing.T) {
+	ctx := context.Background()
+	st := newMVCCStore(t,
+		[]types.Row{
+			{types.Int64Value(1), types.StringValue("one")},
+		})
+
+	// Each transaction inserts a row with the count of rows in the table.
+	insertCount := func(tx storage.Transaction, c1 int64) {
+		t.Helper()
+
+		err := tx.SetIsolation(storage.Serializable)
+		if err != nil {
+			t.Fatalf("SetIsolation() failed with %s", err)
+		}
+		tbl := openTable(t, tx)
+		rows, err := readRows(ctx, tbl)
+		if err != nil {
+			t.Fatalf("Rows() failed with %s", err)
+		}
+		err = tbl.Insert(ctx,
+			[]types.Row{{types.Int64Value(c1), types.StringValue(strconv.Itoa(len(rows)))}})
+		if err != nil {
+			t.Fatalf("Insert(%d) failed with %s", c1, err)
+		}
+	}
+
+	tx1 := st.Begin()
+	tx2 := st.Begin()
+	insertCount(tx1, 10)
+	insertCount(tx2, 20)
+	err := tx1.Commit(ctx)
+	if err != nil {
+		t.Errorf("Commit() failed with %s", err)
+	}
+	err = tx2.Commit(ctx)
+	if !errors.Is(err, storage.ErrSerialization) {
+		t.Errorf("Commit() got %v want %s", err, storage.ErrSerialization)
+	}
+
+	// Transactions which read disjoint ranges do not conflict.
+	tx1 = st.Begin()
+	tx2 = st.Begin()
+	for _, tx := range []storage.Transaction{tx1, tx2} {
+		err := tx.SetIsolation(storage.Serializable)
+		if err != nil {
+			t.Fatalf("SetIsolation() failed with %s", err)
+		}
+	}
+	err = updateRow(ctx, openTable(t, tx1), 1, setValue("ONE"))
+	if err != nil {
+		t.Fatalf("updateRow() failed with %s", err)
+	}
+	err = updateRow(ctx, openTable(t, tx2), 10, setValue("TEN"))
+	if err != nil {
+		t.Fatalf("updateRow() failed with %s", err)
+	}
+	err = tx1.Commit(ctx)
+	if err != nil {
+		t.Errorf("Commit() failed with %s", err)
+	}
+	err = tx2.Commit(ctx)
+	if err != nil {
+		t.Errorf("Commit() failed with %s", err)
+	}
+}
+
+func TestMVCCReadCommitted(t *testing.T) {
+	ctx := context.Background()
+	st := newMVCCStore(t,
+		[]types.Row{
+			{types.Int64Value(1), types.StringValue("one")},
+			{types.Int64Value(2), types.StringValue("two")},
+		})
+
+	tx1 := st.Begin()
+	err := tx1.SetIsolation(storage.ReadCommitted)
+	if err != nil {
+		t.Fatalf("SetIsolation() failed with %s", err)
+	}
+	err = updateRow(ctx, openTable(t, tx1), 1, setValue("ONE"))
+	if err != nil {
+		t.Fatalf("updateRow() failed with %s", err)
+	}
+
+	tx2 := st.Begin()
+	err = updateRow(ctx, openTable(t, tx2), 2, setValue("TWO"))
+	if err != nil {
+		t.Fatalf("updateRow() failed with %s", err)
+	}
+	err = tx2.Commit(ctx)
+	if err != nil {
+		t.Fatalf("Commit() failed with %s", err)
+	}
+
+	// The commit is only visible to the next statement.
+	mustRows(t, tx1,
+		[]types.Row{
+			{types.Int64Value(1), types.StringValue("ONE")},
+			{types.Int64Value(2), types.StringValue("two")},
+		})
+	tx1.NextStmt()
+	mustRows(t, tx1,
+		[]types.Row{
+			{types.Int64Value(1), types.StringValue("ONE")},
+			{types.Int64Value(2), types.StringValue("TWO")},
+		})
+
+	// A row changed after the statement started still conflicts.
+	tx2 = st.Begin()
+	err = updateRow(ctx, openTable(t, tx2), 2, setValue("two-2"))
+	if err != nil {
+		t.Fatalf("updateRow() failed with %s", err)
+	}
+	err = tx2.Commit(ctx)
+	if err != nil {
+		t.Fatalf("Commit() failed with %s", err)
+	}
+	err = updateRow(ctx, openTable(t, tx1), 2, setValue("two-1"))
+	if err != nil {
+		t.Fatalf("updateRow() failed with %s", err)
+	}
+	err = tx1.Commit(ctx)
+	if !errors.Is(err, storage.ErrSerialization) {
+		t.Errorf("Commit() got %v want %s", err, storage.ErrSerialization)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	ctx := context.Background()
+	rows := []types.Row{{types.Int64Value(1), types.StringValue("one")}}
+
+	serial, err := basic.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStore() failed with %s", err)
+	}
+	tx := serial.Begin()
+	err = tx.CreateTable(ctx, tid, tn, colNames, colTypes, primary)
+	if err != nil {
+		t.Fatalf("CreateTable(%s) failed with %s", tn, err)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		t.Fatalf("Commit() failed with %s", err)
+	}
+	modifyTable(t, serial, true,
+		func(ctx context.Context, tbl storage.Table) error {
+			return tbl.Insert(ctx, rows)
+		})
+
+	for _, st := range []storage.Store{serial, newMVCCStore(t, rows)} {
+		// Read only transactions do not block each other.
+		tx1, err := st.BeginReadOnly(time.Time{})
+		if err != nil {
+			t.Fatalf("%s: BeginReadOnly() failed with %s", st.Name(), err)
+		}
+		tx2, err := st.BeginReadOnly(time.Time{})
+		if err != nil {
+			t.Fatalf("%s: BeginReadOnly() failed with %s", st.Name(), err)
+		}
+		mustRows(t, tx1, rows)
+		mustRows(t, tx2, rows)
+
+		tbl := openTable(t, tx1)
+		err = tbl.Insert(ctx, []types.Row{{types.Int64Value(2), types.StringValue("two")}})
+		if err == nil {
+			t.Errorf("%s: Insert() did not fail", st.Name())
+		}
+		err = updateRow(ctx, tbl, 1, setValue("ONE"))
+		if err == nil {
+			t.Errorf("%s: updateRow() did not fail", st.Name())
+		}
+		err = tx1.DropTable(ctx, tid)
+		if err == nil {
+			t.Errorf("%s: DropTable() did not fail", st.Name())
+		}
+
+		err = tx1.Commit(ctx)
+		if err != nil {
+			t.Fatalf("%s: Commit() failed with %s", st.Name(), err)
+		}
+		err = tx2.Rollback()
+		if err != nil {
+			t.Fatalf("%s: Rollback() failed with %s", st.Name(), err)
+		}
+
+		tx = st.Begin()
+		err = tx.SetReadOnly()
+		if err != nil {
+			t.Fatalf("%s: SetReadOnly() failed with %s", st.Name(), err)
+		}
+		err = openTable(t, tx).Insert(ctx,
+			[]types.Row{{types.Int64Value(2), types.StringValue("two")}})
+		if err == nil {
+			t.Errorf("%s: Insert() did not fail", st.Name())
+		}
+		tx.Rollback()
+
+		modifyTable(t, st, false,
+			func(ctx context.Context, tbl storage.Table) error {
+				return updateRow(ctx, tbl, 1, setValue("ONE"))
+			})
+	}
+
+	_, err = serial.BeginReadOnly(time.Now())
+	if err == nil {
+		t.Errorf("BeginReadOnly(as of) did not fail")
+	}
+}
+
+func TestMVCCAsOf(t *testing.T) {
+	ctx := context.Background()
+	one := []types.Row{{types.Int64Value(1), types.StringValue("one")}}
+	st := newMVCCStore(t, one)
+
+	time.Sleep(time.Millisecond)
+	asOf1 := time.Now()
+	time.Sleep(time.Millisecond)
+	modifyTable(t, st, true,
+		func(ctx context.Context, tbl storage.Table) error {
+			return updateRow(ctx, tbl, 1, setValue("ONE"))
+		})
+	time.Sleep(time.Millisecond)
+	asOf2 := time.Now()
+	time.Sleep(time.Millisecond)
+	modifyTable(t, st, true,
+		func(ctx context.Context, tbl storage.Table) error {
+			return tbl.Insert(ctx, []types.Row{{types.Int64Value(2), types.StringValue("two")}})
+		})
+
+	tx1, err := st.BeginReadOnly(asOf1)
+	if err != nil {
+		t.Fatalf("BeginReadOnly() failed with %s", err)
+	}
+	tx2, err := st.BeginReadOnly(asOf2)
+	if err != nil {
+		t.Fatalf("BeginReadOnly() failed with %s", err)
+	}
+
+	// A serializable as of transaction is still reading the same snapshot.
+	err = tx1.SetIsolation(storage.ReadCommitted)
+	if err != nil {
+		t.Fatalf("SetIsolation() failed with %s", err)
+	}
+	modifyTable(t, st, true,
+		func(ctx context.Context, tbl storage.Table) error {
+			return updateRow(ctx, tbl, 2, setValue("TWO"))
+		})
+	tx1.NextStmt()
+
+	mustRows(t, tx1, one)
+	mustRows(t, tx2, []types.Row{{types.Int64Value(1), types.StringValue("ONE")}})
+	err = openTable(t, tx2).Insert(ctx,
+		[]types.Row{{types.Int64Value(3), types.StringValue("three")}})
+	if err == nil {
+		t.Errorf("Insert() did not fail")
+	}
+	tx1.Commit(ctx)
+	tx2.Commit(ctx)
+
+	_, err = st.BeginReadOnly(time.Now().Add(time.Minute))
+	if err == nil {
+		t.Errorf("BeginReadOnly(future) did not fail")
+	}
+	_, err = st.BeginReadOnly(time.Now().Add(-time.Hour))
+	if err == nil {
+		t.Errorf("BeginReadOnly(past) did not fail")
+	}
+}
@@ -0,0 +1,55 @@
+package durable
+
+import (
+	"github.com/leftmike/maho/storage"
+	"github.com/leftmike/maho/storage/basic"
+)
+
+func NewStore(dataDir string) (storage.Store, error) {
+	st, _, err := OpenStore(dataDir)
+	return st, err
+}
+
+// OpenStore opens the store in dataDir, creating it if necessary; created is true if the store
+// is new.
+func OpenStore(dataDir string) (storage.Store, bool, error) {
+	st, w, err := openStore(dataDir, osFS{}, defaultCheckpointSize, false)
+	if err != nil {
+		return nil, false, err
+	}
+	return st, w.created, nil
+}
+
+func NewMVCCStore(dataDir string) (storage.Store, error) {
+	st, _, err := OpenMVCCStore(dataDir)
+	return st, err
+}
+
+// OpenMVCCStore is like OpenStore, except that transactions run concurrently, each reading a
+// snapshot of the store.
+func OpenMVCCStore(dataDir string) (storage.Store, bool, error) {
+	st, w, err := openStore(dataDir, osFS{}, defaultCheckpointSize, true)
+	if err != nil {
+		return nil, false, err
+	}
+	return st, w.created, nil
+}
+
+func openStore(dataDir string, fs walFS, checkpointSize int64, mvcc bool) (storage.Store,
+	*wal, error) {
+
+	w := &wal{
+		dir:            dataDir,
+		fs:             fs,
+		checkpointSize: checkpointSize,
+	}
+	name := "durable"
+	if mvcc {
+		name = "durable mvcc"
+	}
+	st, err := basic.NewLogStore(name, w, w.load, mvcc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return st, w, nil
+}
@@ -0,0 +1,238 @@
+package durable_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/leftmike/maho/storage"
+	"github.com/leftmike/maho/storage/durable"
+	"github.com/leftmike/maho/storage/test"
+	"github.com/leftmike/maho/types"
+)
+
+func TestStore(t *testing.T) {
+	newStore := func(dataDir string) (storage.Store, error) {
+		return durable.NewStore(dataDir)
+	}
+
+	test.TestStore(t, "durable", newStore)
+	test.TestCreateTable(t, "durable", newStore)
+	test.TestDropTable(t, "durable", newStore)
+	test.TestRows(t, "durable", newStore)
+	test.TestScan(t, "durable", newStore)
+	test.TestPredicates(t, "durable", newStore)
+	test.TestInsert(t, "durable", newStore)
+	test.TestUpsert(t, "durable", newStore)
+	test.TestDelete(t, "durable", newStore)
+	test.TestDeleteRange(t, "durable", newStore)
+	test.TestUpdate(t, "durable", newStore)
+	test.TestTable(t, "durable", newStore)
+	test.TestSavepoints(t, "durable", newStore)
+	test.TestAlterColumns(t, "durable", newStore)
+	test.TestRenameTable(t, "durable", newStore)
+	test.TestIndexes(t, "durable", newStore)
+}
+
+func TestMVCCStore(t *testing.T) {
+	newStore := func(dataDir string) (storage.Store, error) {
+		return durable.NewMVCCStore(dataDir)
+	}
+
+	test.TestStore(t, "durable mvcc", newStore)
+	test.TestCreateTable(t, "durable mvcc", newStore)
+	test.TestDropTable(t, "durable mvcc", newStore)
+	test.TestRows(t, "durable mvcc", newStore)
+	test.TestScan(t, "durable mvcc", newStore)
+	test.TestPredicates(t, "durable mvcc", newStore)
+	test.TestInsert(t, "durable mvcc", newStore)
+	test.TestUpsert(t, "durable mvcc", newStore)
+	test.TestDelete(t, "durable mvcc", newStore)
+	test.TestDeleteRange(t, "durable mvcc", newStore)
+	test.TestUpdate(t, "durable mvcc", newStore)
+	test.TestTable(t, "durable mvcc", newStore)
+	test.TestSavepoints(t, "durable mvcc", newStore)
+	test.TestReadCommittedSavepoints(t, "durable mvcc", newStore)
+	test.TestAlterColumns(t, "durable mvcc", newStore)
+	test.TestRenameTable(t, "durable mvcc", newStore)
+	test.TestIndexes(t, "durable mvcc", newStore)
+}
+
+var (
+	tid = storage.EngineTableId + 1
+	tn  = types.TableName{
+		Database: types.MAHO,
+		Schema:   types.PUBLIC,
+		Table:    types.ID("t", false),
+	}
+	colNames = []types.Identifier{types.ID("c1", false), types.ID("c2", false)}
+	colTypes = []types.ColumnType{types.Int64ColType, types.NullStringColType}
+	primary  = []types.ColumnKey{types.MakeColumnKey(0, false)}
+)
+
+func openStore(t *testing.T, dataDir string, wantCreated bool) storage.Store {
+	t.Helper()
+
+	st, created, err := durable.OpenStore(dataDir)
+	if err != nil {
+		t.Fatalf("OpenStore(%s) failed with %s", dataDir, err)
+	}
+	if created != wantCreated {
+		t.Errorf("OpenStore(%s) got created %v want %v", dataDir, created, wantCreated)
+	}
+	return st
+}
+
+func modifyTable(t *testing.T, st storage.Store, commit bool,
+	fn func(ctx context.Context, tbl storage.Table) error) {
+
+	t.Helper()
+
+	ctx := context.Background()
+	tx := st.Begin()
+	tbl, err := tx.OpenTable(ctx, tid, tn, colNames, colTypes, primary)
+	if err != nil {
+		t.Fatalf("OpenTable(%s) failed with %s", tn, err)
+	}
+	err = fn(ctx, tbl)
+	if err != nil {
+		t.Fatalf("modify %s failed with %s", tn, err)
+	}
+	if commit {
+		err = tx.Commit(ctx)
+	} else {
+		err = tx.Rollback()
+	}
+	if err != nil {
+		t.Fatalf("Commit or Rollback failed with %s", err)
+	}
+}
+
+func checkRows(t *testing.T, st storage.Store, want []types.Row) {
+	t.Helper()
+
+	var got []types.Row
+	modifyTable(t, st, false,
+		func(ctx context.Context, tbl storage.Table) error {
+			rows, err := tbl.Rows(ctx, nil, nil, nil, nil)
+			if err != nil {
+				return err
+			}
+			defer rows.Close(ctx)
+
+			for {
+				row, err := rows.Next(ctx)
+				if err == io.EOF {
+					return nil
+				} else if err != nil {
+					return err
+				}
+				got = append(got, row)
+			}
+		})
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Rows() got %v want %v", got, want)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	dataDir := t.TempDir()
+	ctx := context.Background()
+
+	st := openStore(t, dataDir, true)
+	tx := st.Begin()
+	err := tx.CreateTable(ctx, tid, tn, colNames, colTypes, primary)
+	if err != nil {
+		t.Fatalf("CreateTable(%s) failed with %s", tn, err)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		t.Fatalf("Commit() failed with %s", err)
+	}
+
+	modifyTable(t, st, true,
+		func(ctx context.Context, tbl storage.Table) error {
+			return tbl.Insert(ctx,
+				[]types.Row{
+					{types.Int64Value(1), types.StringValue("one")},
+					{types.Int64Value(2), nil},
+					{types.Int64Value(3), types.StringValue("three")},
+				})
+		})
+	modifyTable(t, st, false,
+		func(ctx context.Context, tbl storage.Table) error {
+			return tbl.Insert(ctx, []types.Row{{types.Int64Value(4), types.StringValue("four")}})
+		})
+
+	st = openStore(t, dataDir, false)
+	checkRows(t, st,
+		[]types.Row{
+			{types.Int64Value(1), types.StringValue("one")},
+			{types.Int64Value(2), nil},
+			{types.Int64Value(3), types.StringValue("three")},
+		})
+
+	modifyTable(t, st, true,
+		func(ctx context.Context, tbl storage.Table) error {
+			rows, err := tbl.Rows(ctx, nil, nil, nil, nil)
+			if err != nil {
+				return err
+			}
+			defer rows.Close(ctx)
+
+			for {
+				row, err := rows.Next(ctx)
+				if err != nil {
+					return err
+				}
+				ref, err := rows.Current()
+				if err != nil {
+					return err
+				}
+
+				if row[0] == types.Int64Value(1) {
+					err = ref.Delete(ctx)
+				} else if row[0] == types.Int64Value(2) {
+					err = ref.Update(ctx, []types.ColumnNum{1},
+						[]types.Value{types.StringValue("two")})
+				} else {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+			}
+		})
+
+	want := []types.Row{
+		{types.Int64Value(2), types.StringValue("two")},
+		{types.Int64Value(3), types.StringValue("three")},
+	}
+	st = openStore(t, dataDir, false)
+	checkRows(t, st, want)
+
+	// A partially written record at the end of the log is discarded.
+	f, err := os.OpenFile(filepath.Join(dataDir, "maho.wal"), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile() failed with %s", err)
+	}
+	_, err = f.Write([]byte{0, 0, 1, 0, 1, 2, 3})
+	if err != nil {
+		t.Fatalf("Write() failed with %s", err)
+	}
+	f.Close()
+
+	st = openStore(t, dataDir, false)
+	checkRows(t, st, want)
+	modifyTable(t, st, true,
+		func(ctx context.Context, tbl storage.Table) error {
+			return tbl.Insert(ctx, []types.Row{{types.Int64Value(4), types.StringValue("four")}})
+		})
+
+	st = openStore(t, dataDir, false)
+	checkRows(t, st, append(want, types.Row{types.Int64Value(4), types.StringValue("four")}))
+}
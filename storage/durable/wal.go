@@ -0,0 +1,320 @@
+package durable
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/leftmike/maho/encode"
+	"github.com/leftmike/maho/storage/basic"
+)
+
+const (
+	walName        = "maho.wal"
+	checkpointFile = "maho.checkpoint"
+	checkpointTemp = "maho.checkpoint.tmp"
+
+	defaultCheckpointSize = 64 * 1024 * 1024
+	checkpointItems       = 1024
+)
+
+// walFS is the file system used by the write-ahead log; tests replace it to inject crashes.
+type walFS interface {
+	MkdirAll(path string, perm os.FileMode) error
+	OpenFile(name string, flag int, perm os.FileMode) (walFile, error)
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+	SyncDir(dir string) error
+}
+
+type walFile interface {
+	io.ReadWriteSeeker
+	Sync() error
+	Truncate(size int64) error
+	Close() error
+}
+
+type osFS struct{}
+
+func (_ osFS) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (_ osFS) OpenFile(name string, flag int, perm os.FileMode) (walFile, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (_ osFS) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (_ osFS) Remove(name string) error {
+	err := os.Remove(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (_ osFS) SyncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+// The items changed by each committed transaction are appended to the write-ahead log as a
+// record and synced before the commit completes. Once the log is larger than checkpointSize,
+// all of the items in the store are written to a new checkpoint, and the log is truncated.
+// When the store is opened, the checkpoint is loaded and then the log is replayed; a torn or
+// corrupt record at the end of the log is discarded, but a corrupt record followed by other
+// records fails the open.
+//
+// Each record is the length of the data, a CRC-32 checksum of the data, and the data, which
+// is the gob encoded items.
+type wal struct {
+	dir            string
+	fs             walFS
+	f              walFile
+	end            int64
+	checkpointSize int64
+	created        bool
+	err            error // the log can no longer be written
+}
+
+var (
+	errTornRecord = errors.New("torn record")
+	errBadRecord  = errors.New("bad record")
+)
+
+func appendRecord(buf []byte, items []basic.Item) []byte {
+	var data bytes.Buffer
+	err := gob.NewEncoder(&data).Encode(items)
+	if err != nil {
+		panic(fmt.Sprintf("durable: unable to encode items: %s", err))
+	}
+	if uint64(data.Len()) > math.MaxUint32 {
+		panic(fmt.Sprintf("durable: record too large: %d", data.Len()))
+	}
+
+	buf = encode.EncodeUint32(buf, uint32(data.Len()))
+	buf = encode.EncodeUint32(buf, crc32.ChecksumIEEE(data.Bytes()))
+	return append(buf, data.Bytes()...)
+}
+
+// readRecord returns io.EOF at the end of r, errTornRecord if the record runs past the end
+// of r, and errBadRecord, along with the length of the record, if the record is corrupt.
+func readRecord(r io.Reader, fn func(it basic.Item)) (int64, error) {
+	var hdr [8]byte
+	_, err := io.ReadFull(r, hdr[:])
+	if err == io.EOF {
+		return 0, io.EOF
+	} else if err == io.ErrUnexpectedEOF {
+		return 0, errTornRecord
+	} else if err != nil {
+		return 0, err
+	}
+
+	// The length might be garbage, so only allocate as the data is read.
+	var data bytes.Buffer
+	n := int64(binary.BigEndian.Uint32(hdr[:4]))
+	_, err = io.CopyN(&data, r, n)
+	if err == io.EOF {
+		return 0, errTornRecord
+	} else if err != nil {
+		return 0, err
+	}
+	if crc32.ChecksumIEEE(data.Bytes()) != binary.BigEndian.Uint32(hdr[4:]) {
+		return int64(len(hdr)) + n, errBadRecord
+	}
+
+	var items []basic.Item
+	err = gob.NewDecoder(&data).Decode(&items)
+	if err != nil {
+		return int64(len(hdr)) + n, errBadRecord
+	}
+	for _, it := range items {
+		fn(it)
+	}
+	return int64(len(hdr)) + n, nil
+}
+
+// load restores the items of the store from the checkpoint and then the log; created is set
+// if the store is new.
+func (w *wal) load(fn func(it basic.Item)) error {
+	err := w.fs.MkdirAll(w.dir, 0755)
+	if err != nil {
+		return fmt.Errorf("durable: %s", err)
+	}
+
+	// A checkpoint which was not completed is ignored.
+	err = w.fs.Remove(filepath.Join(w.dir, checkpointTemp))
+	if err != nil {
+		return fmt.Errorf("durable: %s", err)
+	}
+
+	w.created = true
+	cf, err := w.fs.OpenFile(filepath.Join(w.dir, checkpointFile), os.O_RDONLY, 0)
+	if err == nil {
+		w.created = false
+		r := bufio.NewReader(cf)
+		for {
+			_, err = readRecord(r, fn)
+			if err != nil {
+				break
+			}
+		}
+		cf.Close()
+		if err == errTornRecord || err == errBadRecord {
+			return fmt.Errorf("durable: %s: corrupt checkpoint", w.dir)
+		} else if err != io.EOF {
+			return fmt.Errorf("durable: %s", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("durable: %s", err)
+	}
+
+	w.f, err = w.fs.OpenFile(filepath.Join(w.dir, walName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("durable: %s", err)
+	}
+
+	r := bufio.NewReader(w.f)
+	for {
+		n, err := readRecord(r, fn)
+		if err == io.EOF || err == errTornRecord {
+			break
+		} else if err == errBadRecord {
+			// Only the last record can be partially written; any other corrupt record means
+			// that committed transactions would be lost.
+			var size int64
+			size, err = w.f.Seek(0, io.SeekEnd)
+			if err == nil && w.end+n < size {
+				err = fmt.Errorf("%s: corrupt write-ahead log at offset %d", w.dir, w.end)
+			}
+			if err != nil {
+				w.f.Close()
+				return fmt.Errorf("durable: %s", err)
+			}
+			break
+		} else if err != nil {
+			w.f.Close()
+			return fmt.Errorf("durable: %s", err)
+		}
+		w.end += n
+	}
+	if w.end > 0 {
+		w.created = false
+	}
+
+	err = w.truncate()
+	if err != nil {
+		w.f.Close()
+		return err
+	}
+	return nil
+}
+
+// truncate discards anything in the log after the last complete record.
+func (w *wal) truncate() error {
+	err := w.f.Truncate(w.end)
+	if err == nil {
+		_, err = w.f.Seek(w.end, io.SeekStart)
+	}
+	if err == nil {
+		err = w.f.Sync()
+	}
+	if err != nil {
+		w.err = fmt.Errorf("durable: write-ahead log failed: %s", err)
+		return w.err
+	}
+	return nil
+}
+
+func (w *wal) Commit(items []basic.Item, all func(fn func(it basic.Item) bool)) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	rec := appendRecord(nil, items)
+	_, err := w.f.Write(rec)
+	if err == nil {
+		err = w.f.Sync()
+	}
+	if err != nil {
+		w.truncate()
+		return fmt.Errorf("durable: %s", err)
+	}
+	w.end += int64(len(rec))
+
+	if w.end >= w.checkpointSize {
+		// The commit is complete once the record is synced. The log is still complete if the
+		// checkpoint fails, and the checkpoint will be tried again after the next commit.
+		err = w.checkpoint(all)
+		if err != nil {
+			log.Printf("durable: %s: checkpoint failed: %s", w.dir, err)
+		}
+	}
+	return nil
+}
+
+func (w *wal) checkpoint(all func(fn func(it basic.Item) bool)) error {
+	tmp := filepath.Join(w.dir, checkpointTemp)
+	f, err := w.fs.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	var items []basic.Item
+	var buf []byte
+	all(
+		func(it basic.Item) bool {
+			items = append(items, it)
+			if len(items) == checkpointItems {
+				buf = appendRecord(buf, items)
+				items = items[:0]
+				_, err = f.Write(buf)
+				buf = buf[:0]
+			}
+			return err == nil
+		})
+	if err == nil && len(items) > 0 {
+		_, err = f.Write(appendRecord(buf, items))
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	err = w.fs.Rename(tmp, filepath.Join(w.dir, checkpointFile))
+	if err != nil {
+		return err
+	}
+	err = w.fs.SyncDir(w.dir)
+	if err != nil {
+		return err
+	}
+
+	// Replaying the log on top of the checkpoint gives the same items, so it does not matter
+	// if there is a crash before the log is truncated.
+	w.end = 0
+	return w.truncate()
+}
@@ -0,0 +1,487 @@
+package durable
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/leftmike/maho/storage"
+	"github.com/leftmike/maho/types"
+)
+
+var (
+	errCrash = errors.New("crash")
+)
+
+// crashFS crashes after a number of operations which change the file system. At the crash, a
+// write is only partially done, and all later operations fail. Afterwards, tear simulates
+// losing some of the data which was written to each file but not synced.
+type crashFS struct {
+	ops     int
+	crashed bool
+	files   map[string]*crashFile
+}
+
+type crashFile struct {
+	fs     *crashFS
+	f      *os.File
+	name   string
+	synced int64
+}
+
+func (cfs *crashFS) step() bool {
+	if cfs.crashed {
+		return false
+	}
+	cfs.ops -= 1
+	if cfs.ops == 0 {
+		cfs.crashed = true
+		return false
+	}
+	return true
+}
+
+func (cfs *crashFS) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (cfs *crashFS) OpenFile(name string, flag int, perm os.FileMode) (walFile, error) {
+	if flag&(os.O_CREATE|os.O_TRUNC) != 0 && !cfs.step() {
+		return nil, errCrash
+	}
+
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	cf, ok := cfs.files[name]
+	if !ok || flag&os.O_TRUNC != 0 {
+		fi, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		cf = &crashFile{
+			fs:     cfs,
+			name:   name,
+			synced: fi.Size(),
+		}
+		cfs.files[name] = cf
+	}
+	return &crashFile{
+		fs:     cfs,
+		f:      f,
+		name:   name,
+		synced: cf.synced,
+	}, nil
+}
+
+func (cfs *crashFS) Rename(oldpath, newpath string) error {
+	if !cfs.step() {
+		return errCrash
+	}
+	err := os.Rename(oldpath, newpath)
+	if err != nil {
+		return err
+	}
+	cfs.files[newpath] = cfs.files[oldpath]
+	delete(cfs.files, oldpath)
+	return nil
+}
+
+func (cfs *crashFS) Remove(name string) error {
+	if !cfs.step() {
+		return errCrash
+	}
+	delete(cfs.files, name)
+	return osFS{}.Remove(name)
+}
+
+func (cfs *crashFS) SyncDir(dir string) error {
+	if !cfs.step() {
+		return errCrash
+	}
+	return osFS{}.SyncDir(dir)
+}
+
+func (cfs *crashFS) tear() error {
+	for name, cf := range cfs.files {
+		fi, err := os.Stat(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if fi.Size() > cf.synced {
+			err = os.Truncate(name, cf.synced+(fi.Size()-cf.synced)/2)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (cf *crashFile) Read(p []byte) (int, error) {
+	return cf.f.Read(p)
+}
+
+func (cf *crashFile) Seek(offset int64, whence int) (int64, error) {
+	return cf.f.Seek(offset, whence)
+}
+
+func (cf *crashFile) Write(p []byte) (int, error) {
+	if !cf.fs.step() {
+		if cf.fs.crashed && len(p) > 1 {
+			cf.f.Write(p[:len(p)/2])
+		}
+		return 0, errCrash
+	}
+	return cf.f.Write(p)
+}
+
+func (cf *crashFile) Sync() error {
+	if !cf.fs.step() {
+		return errCrash
+	}
+	err := cf.f.Sync()
+	if err != nil {
+		return err
+	}
+	fi, err := cf.f.Stat()
+	if err != nil {
+		return err
+	}
+	if sf, ok := cf.fs.files[cf.name]; ok {
+		sf.synced = fi.Size()
+	}
+	return nil
+}
+
+func (cf *crashFile) Truncate(size int64) error {
+	if !cf.fs.step() {
+		return errCrash
+	}
+	err := cf.f.Truncate(size)
+	if err != nil {
+		return err
+	}
+	if sf, ok := cf.fs.files[cf.name]; ok && sf.synced > size {
+		sf.synced = size
+	}
+	return nil
+}
+
+func (cf *crashFile) Close() error {
+	return cf.f.Close()
+}
+
+var (
+	crashTID      = storage.EngineTableId + 1
+	crashTN       = types.TableName{Table: types.ID("crash", false)}
+	crashColNames = []types.Identifier{types.ID("c1", false), types.ID("c2", false)}
+	crashColTypes = []types.ColumnType{types.Int64ColType, types.Int64ColType}
+	crashPrimary  = []types.ColumnKey{types.MakeColumnKey(0, false)}
+)
+
+const (
+	crashCheckpointSize = 2048
+	crashTransactions   = 20
+)
+
+// crashTransaction inserts a row, updates the previous row, and deletes an older row; the
+// same changes are made to the model, which is a map of c1 to c2.
+func crashTransaction(ctx context.Context, tbl storage.Table, n int64,
+	model map[int64]int64) error {
+
+	err := tbl.Insert(ctx, []types.Row{{types.Int64Value(n), types.Int64Value(n)}})
+	if err != nil {
+		return err
+	}
+	model[n] = n
+
+	rows, err := tbl.Rows(ctx, nil, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer rows.Close(ctx)
+
+	for {
+		row, err := rows.Next(ctx)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		c1 := int64(row[0].(types.Int64Value))
+		if c1 == n-1 {
+			ref, err := rows.Current()
+			if err != nil {
+				return err
+			}
+			err = ref.Update(ctx, []types.ColumnNum{1}, []types.Value{types.Int64Value(n * 10)})
+			if err != nil {
+				return err
+			}
+			model[c1] = n * 10
+		} else if c1 == n-3 && n%2 == 0 {
+			ref, err := rows.Current()
+			if err != nil {
+				return err
+			}
+			err = ref.Delete(ctx)
+			if err != nil {
+				return err
+			}
+			delete(model, c1)
+		}
+	}
+}
+
+func copyModel(model map[int64]int64) map[int64]int64 {
+	m := map[int64]int64{}
+	for k, v := range model {
+		m[k] = v
+	}
+	return m
+}
+
+// crashWorkload runs transactions against a store until all of them are done or there is a
+// crash; it returns the models after each committed transaction and the model of the
+// transaction which was being committed at the crash.
+func crashWorkload(dataDir string, fs walFS) ([]map[int64]int64, map[int64]int64) {
+	ctx := context.Background()
+	st, _, err := openStore(dataDir, fs, crashCheckpointSize, false)
+	if err != nil {
+		return nil, nil
+	}
+
+	tx := st.Begin()
+	err = tx.CreateTable(ctx, crashTID, crashTN, crashColNames, crashColTypes, crashPrimary)
+	if err != nil {
+		panic(err)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, map[int64]int64{}
+	}
+
+	models := []map[int64]int64{{}}
+	for n := int64(1); n <= crashTransactions; n += 1 {
+		model := copyModel(models[len(models)-1])
+		tx := st.Begin()
+		tbl, err := tx.OpenTable(ctx, crashTID, crashTN, crashColNames, crashColTypes,
+			crashPrimary)
+		if err != nil {
+			panic(err)
+		}
+		err = crashTransaction(ctx, tbl, n, model)
+		if err != nil {
+			panic(err)
+		}
+		err = tx.Commit(ctx)
+		if err != nil {
+			return models, model
+		}
+		models = append(models, model)
+	}
+	return models, nil
+}
+
+// openCrashTable returns nil if the table was not recovered because the crash was before it
+// was created.
+func openCrashTable(ctx context.Context, tx storage.Transaction) (tbl storage.Table,
+	err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			if msg, ok := r.(string); !ok || !strings.Contains(msg, "table not found") {
+				panic(r)
+			}
+			tbl = nil
+			err = nil
+		}
+	}()
+
+	return tx.OpenTable(ctx, crashTID, crashTN, crashColNames, crashColTypes, crashPrimary)
+}
+
+func recoveredModel(dataDir string) (map[int64]int64, error) {
+	ctx := context.Background()
+	st, _, err := openStore(dataDir, osFS{}, crashCheckpointSize, false)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := st.Begin()
+	defer tx.Rollback()
+
+	tbl, err := openCrashTable(ctx, tx)
+	if err != nil || tbl == nil {
+		return nil, err
+	}
+	rows, err := tbl.Rows(ctx, nil, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close(ctx)
+
+	model := map[int64]int64{}
+	for {
+		row, err := rows.Next(ctx)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		model[int64(row[0].(types.Int64Value))] = int64(row[1].(types.Int64Value))
+	}
+	return model, nil
+}
+
+func TestCrashRecovery(t *testing.T) {
+	var checkpoints bool
+	for ops := 1; ; ops += 1 {
+		dataDir := t.TempDir()
+		cfs := &crashFS{
+			ops:   ops,
+			files: map[string]*crashFile{},
+		}
+		models, inflight := crashWorkload(dataDir, cfs)
+		if !cfs.crashed {
+			if len(models) != crashTransactions+1 {
+				t.Fatalf("workload failed without a crash: %d transactions", len(models))
+			}
+			break
+		}
+		if _, ok := cfs.files[filepath.Join(dataDir, checkpointFile)]; ok {
+			checkpoints = true
+		}
+
+		err := cfs.tear()
+		if err != nil {
+			t.Fatalf("tear() failed with %s", err)
+		}
+		model, err := recoveredModel(dataDir)
+		if err != nil {
+			t.Fatalf("crash at %d: recovery failed with %s", ops, err)
+		}
+
+		// Every committed transaction must be recovered; the transaction being committed at
+		// the crash might or might not be recovered.
+		var want []string
+		if models == nil {
+			want = append(want, fmt.Sprint(map[int64]int64(nil)))
+		} else {
+			want = append(want, fmt.Sprint(models[len(models)-1]))
+		}
+		if inflight != nil {
+			want = append(want, fmt.Sprint(inflight))
+		}
+
+		got := fmt.Sprint(model)
+		if got != want[0] && (len(want) == 1 || got != want[1]) {
+			t.Errorf("crash at %d: recovered %s want %v", ops, got, want)
+		}
+	}
+
+	if !checkpoints {
+		t.Errorf("workload did not checkpoint")
+	}
+}
+
+func TestCorruptRecord(t *testing.T) {
+	ctx := context.Background()
+	dataDir := t.TempDir()
+	st, w, err := openStore(dataDir, osFS{}, defaultCheckpointSize, false)
+	if err != nil {
+		t.Fatalf("openStore() failed with %s", err)
+	} else if !w.created {
+		t.Errorf("openStore() not created")
+	}
+
+	models := []map[int64]int64{{}}
+	var ends []int64
+	tx := st.Begin()
+	err = tx.CreateTable(ctx, crashTID, crashTN, crashColNames, crashColTypes, crashPrimary)
+	if err != nil {
+		t.Fatalf("CreateTable() failed with %s", err)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		t.Fatalf("Commit() failed with %s", err)
+	}
+	ends = append(ends, w.end)
+
+	for n := int64(1); n <= 3; n += 1 {
+		model := copyModel(models[len(models)-1])
+		tx := st.Begin()
+		tbl, err := tx.OpenTable(ctx, crashTID, crashTN, crashColNames, crashColTypes,
+			crashPrimary)
+		if err != nil {
+			t.Fatalf("OpenTable() failed with %s", err)
+		}
+		err = crashTransaction(ctx, tbl, n, model)
+		if err != nil {
+			t.Fatalf("transaction %d failed with %s", n, err)
+		}
+		err = tx.Commit(ctx)
+		if err != nil {
+			t.Fatalf("Commit() failed with %s", err)
+		}
+		models = append(models, model)
+		ends = append(ends, w.end)
+	}
+
+	// Corrupt the data of the third record: the log can not be recovered, because the fourth
+	// record would be lost.
+	name := filepath.Join(dataDir, walName)
+	buf, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("ReadFile() failed with %s", err)
+	}
+	buf[ends[1]+12] ^= 0xFF
+	err = os.WriteFile(name, buf, 0644)
+	if err != nil {
+		t.Fatalf("WriteFile() failed with %s", err)
+	}
+
+	_, err = recoveredModel(dataDir)
+	if err == nil {
+		t.Errorf("recovery did not fail")
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatalf("Stat() failed with %s", err)
+	} else if fi.Size() != ends[3] {
+		t.Errorf("log size got %d want %d", fi.Size(), ends[3])
+	}
+
+	// Corrupt the data of the last record instead: it is discarded.
+	buf[ends[1]+12] ^= 0xFF
+	buf[ends[2]+12] ^= 0xFF
+	err = os.WriteFile(name, buf, 0644)
+	if err != nil {
+		t.Fatalf("WriteFile() failed with %s", err)
+	}
+
+	model, err := recoveredModel(dataDir)
+	if err != nil {
+		t.Fatalf("recovery failed with %s", err)
+	}
+	if !reflect.DeepEqual(model, models[2]) {
+		t.Errorf("recovered %v want %v", model, models[2])
+	}
+	fi, err = os.Stat(name)
+	if err != nil {
+		t.Fatalf("Stat() failed with %s", err)
+	} else if fi.Size() != ends[2] {
+		t.Errorf("log size got %d want %d", fi.Size(), ends[2])
+	}
+}
@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/leftmike/maho/types"
+)
+
+// Predicate is a condition on the rows of a table: either a ColumnPredicate or a tree of
+// AndPredicate, OrPredicate, NotPredicate, ComparePredicate, ColumnsPredicate, NullPredicate,
+// and InPredicate. Rows are returned only if the predicate is true; as in SQL, a comparison with
+// NULL is neither true nor false.
+//
+// A table might not be able to evaluate every predicate; use Table.SupportsPredicate to check.
+type Predicate interface{}
+
+type CompareOp int
+
+const (
+	EqualOp CompareOp = iota + 1
+	NotEqualOp
+	LessOp
+	LessEqualOp
+	GreaterOp
+	GreaterEqualOp
+)
+
+type AndPredicate []Predicate
+type OrPredicate []Predicate
+
+type NotPredicate struct {
+	Predicate Predicate
+}
+
+// ComparePredicate compares Column with Value: Column Op Value.
+type ComparePredicate struct {
+	Op     CompareOp
+	Column types.ColumnNum
+	Value  types.Value
+}
+
+// ColumnsPredicate compares two columns of the same row: Left Op Right.
+type ColumnsPredicate struct {
+	Op    CompareOp
+	Left  types.ColumnNum
+	Right types.ColumnNum
+}
+
+// NullPredicate is true if Column is NULL.
+type NullPredicate struct {
+	Column types.ColumnNum
+}
+
+// InPredicate is true if Column is equal to one of Values.
+type InPredicate struct {
+	Column types.ColumnNum
+	Values []types.Value
+}
+
+type truth int
+
+const (
+	falseTruth truth = iota
+	unknownTruth
+	trueTruth
+)
+
+// EvalPredicate returns whether pred is true for row; pred must be a supported predicate.
+func EvalPredicate(pred Predicate, row types.Row) bool {
+	return evalPredicate(pred, row) == trueTruth
+}
+
+func compareTruth(op CompareOp, left, right types.Value) truth {
+	if left == nil || right == nil {
+		return unknownTruth
+	}
+
+	var b bool
+	cmp := types.Compare(left, right)
+	switch op {
+	case EqualOp:
+		b = cmp == 0
+	case NotEqualOp:
+		b = cmp != 0
+	case LessOp:
+		b = cmp < 0
+	case LessEqualOp:
+		b = cmp <= 0
+	case GreaterOp:
+		b = cmp > 0
+	case GreaterEqualOp:
+		b = cmp >= 0
+	default:
+		panic(fmt.Sprintf("storage: unexpected compare op: %d", op))
+	}
+
+	if b {
+		return trueTruth
+	}
+	return falseTruth
+}
+
+func boolTruth(b bool) truth {
+	if b {
+		return trueTruth
+	}
+	return falseTruth
+}
+
+func evalPredicate(pred Predicate, row types.Row) truth {
+	switch pred := pred.(type) {
+	case AndPredicate:
+		t := trueTruth
+		for _, p := range pred {
+			pt := evalPredicate(p, row)
+			if pt == falseTruth {
+				return falseTruth
+			} else if pt == unknownTruth {
+				t = unknownTruth
+			}
+		}
+		return t
+	case OrPredicate:
+		t := falseTruth
+		for _, p := range pred {
+			pt := evalPredicate(p, row)
+			if pt == trueTruth {
+				return trueTruth
+			} else if pt == unknownTruth {
+				t = unknownTruth
+			}
+		}
+		return t
+	case NotPredicate:
+		return trueTruth - evalPredicate(pred.Predicate, row)
+	case ComparePredicate:
+		return compareTruth(pred.Op, row[pred.Column], pred.Value)
+	case ColumnsPredicate:
+		return compareTruth(pred.Op, row[pred.Left], row[pred.Right])
+	case NullPredicate:
+		return boolTruth(row[pred.Column] == nil)
+	case InPredicate:
+		val := row[pred.Column]
+		if val == nil {
+			return unknownTruth
+		}
+		t := falseTruth
+		for _, v := range pred.Values {
+			if v == nil {
+				t = unknownTruth
+			} else if types.Compare(val, v) == 0 {
+				return trueTruth
+			}
+		}
+		return t
+	case ColumnPredicate:
+		switch val := row[pred.Column()].(type) {
+		case nil:
+			return unknownTruth
+		case types.BoolValue:
+			return boolTruth(pred.(BoolPredicate).BoolPred(val))
+		case types.StringValue:
+			return boolTruth(pred.(StringPredicate).StringPred(val))
+		case types.BytesValue:
+			return boolTruth(pred.(BytesPredicate).BytesPred(val))
+		case types.Float64Value:
+			return boolTruth(pred.(Float64Predicate).Float64Pred(val))
+		case types.Int64Value:
+			return boolTruth(pred.(Int64Predicate).Int64Pred(val))
+		}
+	}
+
+	panic(fmt.Sprintf("storage: unexpected predicate: %#v", pred))
+}
+
+// CheckPredicate returns an error if pred is not a predicate tree, or if it uses a column which
+// is not in colTypes, or if a ColumnPredicate does not implement the predicate interface for the
+// type of its column.
+func CheckPredicate(pred Predicate, colTypes []types.ColumnType) error {
+	checkColumn := func(col types.ColumnNum) error {
+		if int(col) >= len(colTypes) {
+			return fmt.Errorf("storage: predicate: column out of range: %d", col)
+		}
+		return nil
+	}
+
+	switch pred := pred.(type) {
+	case AndPredicate:
+		for _, p := range pred {
+			err := CheckPredicate(p, colTypes)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case OrPredicate:
+		for _, p := range pred {
+			err := CheckPredicate(p, colTypes)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case NotPredicate:
+		return CheckPredicate(pred.Predicate, colTypes)
+	case ComparePredicate:
+		if pred.Op < EqualOp || pred.Op > GreaterEqualOp {
+			return fmt.Errorf("storage: predicate: unexpected compare op: %d", pred.Op)
+		}
+		return checkColumn(pred.Column)
+	case ColumnsPredicate:
+		if pred.Op < EqualOp || pred.Op > GreaterEqualOp {
+			return fmt.Errorf("storage: predicate: unexpected compare op: %d", pred.Op)
+		}
+		err := checkColumn(pred.Left)
+		if err != nil {
+			return err
+		}
+		return checkColumn(pred.Right)
+	case NullPredicate:
+		return checkColumn(pred.Column)
+	case InPredicate:
+		return checkColumn(pred.Column)
+	case ColumnPredicate:
+		col := pred.Column()
+		err := checkColumn(col)
+		if err != nil {
+			return err
+		}
+
+		var ok bool
+		switch colTypes[col].Type {
+		case types.BoolType:
+			_, ok = pred.(BoolPredicate)
+		case types.StringType:
+			_, ok = pred.(StringPredicate)
+		case types.BytesType:
+			_, ok = pred.(BytesPredicate)
+		case types.Float64Type:
+			_, ok = pred.(Float64Predicate)
+		case types.Int64Type:
+			_, ok = pred.(Int64Predicate)
+		}
+		if !ok {
+			return fmt.Errorf("storage: predicate: column %d: wrong type of predicate: %T", col,
+				pred)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("storage: unexpected predicate: %T", pred)
+}
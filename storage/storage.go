@@ -2,19 +2,47 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/leftmike/maho/types"
 )
 
+var (
+	// ErrSerialization is returned when a transaction conflicts with a concurrent transaction;
+	// the transaction may be retried.
+	ErrSerialization = errors.New("storage: could not serialize access due to concurrent update")
+)
+
 type OptionsMap map[types.Identifier]string
 
+type IsolationLevel int
+
+const (
+	// Each statement sees the changes committed before the statement started.
+	ReadCommitted IsolationLevel = iota + 1
+	// The transaction sees the changes committed before it started; it fails to commit if a
+	// concurrent transaction committed a change to the same row.
+	Snapshot
+	// Like Snapshot, and the transaction fails to commit if it might not be serializable with
+	// concurrent transactions.
+	Serializable
+)
+
 type Store interface {
 	Name() string
 	SetupColumns(colNames []types.Identifier, colTypes []types.ColumnType,
 		primary []types.ColumnKey) ([]types.Identifier, []types.ColumnType, []types.ColumnKey)
 	Begin() Transaction
+	// BeginReadOnly begins a transaction which can not make changes; if asOf is not zero, the
+	// transaction reads the store as it was at asOf.
+	BeginReadOnly(asOf time.Time) (Transaction, error)
 }
 
+// Savepoint is returned by Transaction.Savepoint and passed to Transaction.RollbackTo.
+type Savepoint interface{}
+
 type TableId uint32
 type IndexId uint32
 
@@ -29,13 +57,33 @@ type Transaction interface {
 	CreateTable(ctx context.Context, tid TableId, tn types.TableName,
 		colNames []types.Identifier, colTypes []types.ColumnType, primary []types.ColumnKey) error
 	DropTable(ctx context.Context, tid TableId) error
+	RenameTable(ctx context.Context, tid TableId, tn types.TableName) error
 
 	Commit(ctx context.Context) error
 	Rollback() error
 	NextStmt()
+	SetIsolation(level IsolationLevel) error
+	SetReadOnly() error
+	Savepoint() Savepoint
+	// RollbackTo undoes the changes made since sp was returned; sp may be used again.
+	RollbackTo(sp Savepoint) error
+}
+
+func (il IsolationLevel) String() string {
+	switch il {
+	case ReadCommitted:
+		return "read committed"
+	case Snapshot:
+		return "snapshot"
+	case Serializable:
+		return "serializable"
+	}
+	return fmt.Sprintf("isolation level %d", il)
 }
 
-type Predicate interface {
+// ColumnPredicate is a predicate on a single column; it must also implement the predicate
+// interface for the type of the column: BoolPredicate, StringPredicate, etc.
+type ColumnPredicate interface {
 	Column() types.ColumnNum
 }
 
@@ -59,6 +107,19 @@ type Int64Predicate interface {
 	Int64Pred(i types.Int64Value) bool
 }
 
+// ScanSpec is the range of rows returned by Table.Scan, in primary key order, or in reverse order
+// if Reverse is true. If MinRow or MaxRow is nil, the range is unbounded in that direction;
+// otherwise, only the columns of the primary key are used. If Prefix is not zero, only the first
+// Prefix columns of the primary key are compared with MinRow and MaxRow.
+type ScanSpec struct {
+	MinRow       types.Row
+	MinExclusive bool
+	MaxRow       types.Row
+	MaxExclusive bool
+	Prefix       int
+	Reverse      bool
+}
+
 type Table interface {
 	TID() TableId
 	Name() types.TableName
@@ -68,12 +129,34 @@ type Table interface {
 	ColumnTypes() []types.ColumnType
 	Key() []types.ColumnKey
 
-	// XXX: AddColumn, DropColumn, UpdateColumn
-	// XXX: CreateIndex, DropIndex
+	AddColumn(ctx context.Context, nam types.Identifier, ct types.ColumnType,
+		dflt types.Value) error
+	DropColumn(ctx context.Context, col types.ColumnNum) error
+	UpdateColumn(ctx context.Context, col types.ColumnNum, nam types.Identifier,
+		ct types.ColumnType) error
+
+	// CreateIndex fails if unique and two rows have the same key; rows with a NULL in the key
+	// are never duplicates.
+	CreateIndex(ctx context.Context, iid IndexId, key []types.ColumnKey, unique bool) error
+	DropIndex(ctx context.Context, iid IndexId) error
 
 	Rows(ctx context.Context, cols []types.ColumnNum, minRow, maxRow types.Row,
 		pred Predicate) (Rows, error)
+	Scan(ctx context.Context, cols []types.ColumnNum, spec ScanSpec, pred Predicate) (Rows,
+		error)
+	// SupportsPredicate returns whether the table can evaluate pred while scanning rows; if
+	// it can not, pred must be applied by the caller.
+	SupportsPredicate(pred Predicate) bool
+	// IndexRows returns the rows of the table in index order; minRow and maxRow are rows of
+	// the table and only the columns of the index key are used.
+	IndexRows(ctx context.Context, iid IndexId, cols []types.ColumnNum, minRow,
+		maxRow types.Row) (Rows, error)
 	Insert(ctx context.Context, rows []types.Row) error
+	// Upsert inserts rows, replacing any existing rows with the same primary key.
+	Upsert(ctx context.Context, rows []types.Row) error
+	// DeleteRange deletes the rows between minRow and maxRow, inclusive, and returns the number
+	// of rows deleted; minRow and maxRow are used like they are by Rows.
+	DeleteRange(ctx context.Context, minRow, maxRow types.Row) (int64, error)
 }
 
 type Rows interface {
@@ -2,7 +2,9 @@ package test
 
 import (
 	"bytes"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/leftmike/maho/storage"
 	"github.com/leftmike/maho/testutil"
@@ -215,6 +217,8 @@ func TestDropTable(t *testing.T, store string, newStore NewStore) {
 			colTypes: colTypes,
 			primary:  primary,
 		},
+		Insert{rows: testutil.MustParseRows("(1, 10), (2, 20)")},
+		CreateIndex{iid: 1, key: []types.ColumnKey{types.MakeColumnKey(1, false)}},
 		CreateTable{
 			tid:      storage.EngineTableId + 2,
 			colNames: colNames,
@@ -277,6 +281,26 @@ func TestDropTable(t *testing.T, store string, newStore NewStore) {
 		},
 		Rollback{},
 	})
+
+	// The rows and indexes of a dropped table are deleted.
+	testStorage(t, st.Begin(), []interface{}{
+		CreateTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		OpenTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		Select{},
+		CreateIndex{iid: 1, key: []types.ColumnKey{types.MakeColumnKey(1, false)}},
+		IndexSelect{iid: 1},
+		Rollback{},
+	})
 }
 
 func TestInsert(t *testing.T, store string, newStore NewStore) {
@@ -1207,6 +1231,50 @@ func TestUpdate(t *testing.T, store string, newStore NewStore) {
 		Close{},
 		Rollback{},
 	})
+
+	// Rows are not changed by updates, deletes, and inserts made while iterating.
+	testStorage(t, st.Begin(), []interface{}{
+		OpenTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		Rows{},
+		Next{row: testutil.MustParseRow("(0, 0, 0, 'zero')")},
+		Current{},
+		Update{
+			cols: []types.ColumnNum{0},
+			vals: []types.Value{types.Int64Value(10)},
+		},
+		Insert{rows: testutil.MustParseRows("(3, 30, 3.3, 'three'), (8, 80, 8.8, 'eight')")},
+		Next{row: testutil.MustParseRow("(1, 40, 8.8, 'four')")},
+		Current{},
+		Delete{},
+		Next{row: testutil.MustParseRow("(2, 200, 2.2, 'two two')")},
+		Current{},
+		Update{
+			cols: []types.ColumnNum{1},
+			vals: []types.Value{types.Int64Value(222)},
+		},
+		Next{row: testutil.MustParseRow("(6, 60, 6.6, 'six')")},
+		Current{},
+		Update{
+			cols: []types.ColumnNum{0},
+			vals: []types.Value{types.Int64Value(7)},
+		},
+		Next{eof: true},
+		Close{},
+		Select{
+			rows: testutil.MustParseRows(`
+(2, 222, 2.2, 'two two'),
+(3, 30, 3.3, 'three'),
+(7, 60, 6.6, 'six'),
+(8, 80, 8.8, 'eight'),
+(10, 0, 0, 'zero')`),
+		},
+		Rollback{},
+	})
 }
 
 func TestTable(t *testing.T, store string, newStore NewStore) {
@@ -1346,3 +1414,1360 @@ func TestTable(t *testing.T, store string, newStore NewStore) {
 		Commit{},
 	})
 }
+
+func TestSavepoints(t *testing.T, store string, newStore NewStore) {
+	st, err := newStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("%s.NewStore() failed with %s", store, err)
+	}
+
+	colNames := []types.Identifier{col1, col2}
+	colTypes := []types.ColumnType{types.Int64ColType, types.StringColType}
+	primary := []types.ColumnKey{types.MakeColumnKey(0, false)}
+	open := OpenTable{
+		tid:      storage.EngineTableId + 1,
+		colNames: colNames,
+		colTypes: colTypes,
+		primary:  primary,
+	}
+	row := func(n int64, s string) types.Row {
+		return types.Row{types.Int64Value(n), types.StringValue(s)}
+	}
+
+	testStorage(t, st.Begin(), []interface{}{
+		CreateTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		Commit{},
+	})
+
+	testStorage(t, st.Begin(), []interface{}{
+		open,
+		Savepoint{name: "empty"},
+		Insert{rows: []types.Row{row(1, "one"), row(2, "two")}},
+		Savepoint{name: "sp1"},
+		Insert{rows: []types.Row{row(3, "three")}},
+		UpdateSet{
+			update: func(row types.Row) ([]types.ColumnNum, []types.Value) {
+				return []types.ColumnNum{1}, []types.Value{types.StringValue("updated")}
+			},
+		},
+		Select{rows: []types.Row{row(1, "updated"), row(2, "updated"), row(3, "updated")}},
+		RollbackTo{name: "sp1"},
+		Select{rows: []types.Row{row(1, "one"), row(2, "two")}},
+		DeleteFrom{minRow: row(1, ""), maxRow: row(1, "")},
+		Insert{rows: []types.Row{row(4, "four")}},
+		Select{rows: []types.Row{row(2, "two"), row(4, "four")}},
+
+		// A savepoint can be used more than once.
+		RollbackTo{name: "sp1"},
+		Select{rows: []types.Row{row(1, "one"), row(2, "two")}},
+		RollbackTo{name: "empty"},
+		Select{},
+		Insert{rows: []types.Row{row(5, "five")}},
+		Commit{},
+	})
+
+	testStorage(t, st.Begin(), []interface{}{
+		open,
+		Select{rows: []types.Row{row(5, "five")}},
+		Savepoint{name: "sp"},
+		DeleteFrom{},
+		Select{},
+		RollbackTo{name: "sp"},
+		Commit{},
+	})
+
+	testStorage(t, st.Begin(), []interface{}{
+		open,
+		Select{rows: []types.Row{row(5, "five")}},
+		Rollback{},
+	})
+}
+
+// TestReadCommittedSavepoints requires a store whose transactions run concurrently.
+func TestReadCommittedSavepoints(t *testing.T, store string, newStore NewStore) {
+	st, err := newStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("%s.NewStore() failed with %s", store, err)
+	}
+
+	colNames := []types.Identifier{col1, col2}
+	colTypes := []types.ColumnType{types.Int64ColType, types.StringColType}
+	primary := []types.ColumnKey{types.MakeColumnKey(0, false)}
+	open := OpenTable{
+		tid:      storage.EngineTableId + 1,
+		colNames: colNames,
+		colTypes: colTypes,
+		primary:  primary,
+	}
+	row := func(n int64, s string) types.Row {
+		return types.Row{types.Int64Value(n), types.StringValue(s)}
+	}
+
+	testStorage(t, st.Begin(), []interface{}{
+		CreateTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		open,
+		Insert{rows: []types.Row{row(1, "one")}},
+		Commit{},
+	})
+
+	testStorage(t, st.Begin(), []interface{}{
+		SetIsolation{level: storage.ReadCommitted},
+		open,
+		Select{rows: []types.Row{row(1, "one")}},
+		Savepoint{name: "sp1"},
+		Insert{rows: []types.Row{row(2, "two")}},
+		Concurrent{
+			cases: []interface{}{
+				open,
+				Insert{rows: []types.Row{row(10, "ten")}},
+				Commit{},
+			},
+		},
+		Select{rows: []types.Row{row(1, "one"), row(2, "two")}},
+
+		// Rolling back to a savepoint does not move the snapshot within a statement.
+		RollbackTo{name: "sp1"},
+		Select{rows: []types.Row{row(1, "one")}},
+
+		NextStmt{},
+		Select{rows: []types.Row{row(1, "one"), row(10, "ten")}},
+		Insert{rows: []types.Row{row(3, "three")}},
+		Savepoint{name: "sp2"},
+		Insert{rows: []types.Row{row(4, "four")}},
+		Concurrent{
+			cases: []interface{}{
+				open,
+				Insert{rows: []types.Row{row(20, "twenty")}},
+				Commit{},
+			},
+		},
+
+		// Or back to the snapshot of an earlier statement.
+		NextStmt{},
+		Select{
+			rows: []types.Row{row(1, "one"), row(3, "three"), row(4, "four"), row(10, "ten"),
+				row(20, "twenty")},
+		},
+		RollbackTo{name: "sp2"},
+		Select{
+			rows: []types.Row{row(1, "one"), row(3, "three"), row(10, "ten"),
+				row(20, "twenty")},
+		},
+		RollbackTo{name: "sp1"},
+		Select{rows: []types.Row{row(1, "one"), row(10, "ten"), row(20, "twenty")}},
+		Insert{rows: []types.Row{row(5, "five")}},
+		Commit{},
+	})
+
+	testStorage(t, st.Begin(), []interface{}{
+		open,
+		Select{
+			rows: []types.Row{row(1, "one"), row(5, "five"), row(10, "ten"),
+				row(20, "twenty")},
+		},
+		Rollback{},
+	})
+
+	// Rolling back to a savepoint of an earlier statement while other read committed
+	// transactions move their snapshots to the same commit.
+	parallel := [][]interface{}{
+		{
+			SetIsolation{level: storage.ReadCommitted},
+			open,
+			Insert{rows: []types.Row{row(30, "thirty")}},
+			Savepoint{name: "sp3"},
+			Insert{rows: []types.Row{row(31, "thirty-one")}},
+			Concurrent{
+				cases: []interface{}{
+					open,
+					Insert{rows: []types.Row{row(40, "forty")}},
+					Commit{},
+				},
+			},
+			NextStmt{},
+			RollbackTo{name: "sp3"},
+			Sleep{d: 50 * time.Millisecond},
+			Commit{},
+		},
+	}
+	want := []types.Row{row(1, "one"), row(5, "five"), row(10, "ten"), row(20, "twenty"),
+		row(30, "thirty"), row(40, "forty")}
+	for n := int64(50); n < 54; n += 1 {
+		parallel = append(parallel,
+			[]interface{}{
+				SetIsolation{level: storage.ReadCommitted},
+				open,
+				Insert{rows: []types.Row{row(n, "fifty")}},
+				Sleep{d: 25 * time.Millisecond},
+				NextStmt{},
+				Commit{},
+			})
+		want = append(want, row(n, "fifty"))
+	}
+
+	testStorage(t, st.Begin(), []interface{}{
+		Parallel{cases: parallel},
+		Rollback{},
+	})
+
+	testStorage(t, st.Begin(), []interface{}{
+		open,
+		Select{rows: want},
+		Rollback{},
+	})
+}
+
+func TestAlterColumns(t *testing.T, store string, newStore NewStore) {
+	st, err := newStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("%s.NewStore() failed with %s", store, err)
+	}
+
+	colNames := []types.Identifier{col1, col2, col3}
+	colTypes := []types.ColumnType{
+		types.ColumnType{Type: types.Int64Type, Size: 4, NotNull: true},
+		types.ColumnType{Type: types.Int64Type, Size: 8},
+		types.ColumnType{Type: types.StringType, Size: 16},
+	}
+	primary := []types.ColumnKey{types.MakeColumnKey(0, false)}
+
+	testStorage(t, st.Begin(), []interface{}{
+		CreateTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		OpenTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		Insert{
+			rows: testutil.MustParseRows(`
+(1, 10, 'one'),
+(2, 20, 'two'),
+(3, null, 'three')`),
+		},
+		AddColumn{
+			nam:  col1,
+			ct:   types.BoolColType,
+			fail: true,
+		},
+		AddColumn{
+			nam:  col4,
+			ct:   types.BoolColType,
+			fail: true,
+		},
+		AddColumn{
+			nam:  col4,
+			ct:   types.BoolColType,
+			dflt: types.StringValue("abc"),
+			fail: true,
+		},
+		AddColumn{
+			nam:  col4,
+			ct:   types.BoolColType,
+			dflt: types.BoolValue(true),
+		},
+		TableType{
+			tid:      storage.EngineTableId + 1,
+			ver:      2,
+			colNames: []types.Identifier{col1, col2, col3, col4},
+			colTypes: append(colTypes, types.BoolColType),
+			key:      primary,
+		},
+		Insert{
+			rows: testutil.MustParseRows("(4, 40, 'four', false)"),
+		},
+		Select{
+			rows: testutil.MustParseRows(`
+(1, 10, 'one', true),
+(2, 20, 'two', true),
+(3, null, 'three', true),
+(4, 40, 'four', false)`),
+		},
+		Commit{},
+	})
+
+	colNames = []types.Identifier{col1, col2, col3, col4}
+	colTypes = []types.ColumnType{
+		types.ColumnType{Type: types.Int64Type, Size: 4, NotNull: true},
+		types.ColumnType{Type: types.Int64Type, Size: 8},
+		types.ColumnType{Type: types.StringType, Size: 16},
+		types.BoolColType,
+	}
+
+	testStorage(t, st.Begin(), []interface{}{
+		OpenTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		DropColumn{
+			col:  0,
+			fail: true,
+		},
+		DropColumn{
+			col: 2,
+		},
+		TableType{
+			tid:      storage.EngineTableId + 1,
+			ver:      3,
+			colNames: []types.Identifier{col1, col2, col4},
+			colTypes: []types.ColumnType{colTypes[0], colTypes[1], colTypes[3]},
+			key:      primary,
+		},
+		Select{
+			rows: testutil.MustParseRows(`
+(1, 10, true),
+(2, 20, true),
+(3, null, true),
+(4, 40, false)`),
+		},
+		UpdateSet{
+			pred: predicateFunc{
+				col:       0,
+				int64Pred: func(i types.Int64Value) bool { return i == 2 },
+			},
+			update: func(row types.Row) ([]types.ColumnNum, []types.Value) {
+				return []types.ColumnNum{2}, []types.Value{types.BoolValue(false)}
+			},
+		},
+		UpdateColumn{
+			col:  1,
+			nam:  col4,
+			ct:   colTypes[1],
+			fail: true,
+		},
+		UpdateColumn{
+			col:  1,
+			nam:  col2,
+			ct:   types.ColumnType{Type: types.Int64Type, Size: 8, NotNull: true},
+			fail: true,
+		},
+		UpdateColumn{
+			col:  0,
+			nam:  col1,
+			ct:   types.ColumnType{Type: types.StringType, Size: 16, NotNull: true},
+			fail: true,
+		},
+		UpdateColumn{
+			col: 1,
+			nam: col5,
+			ct:  types.ColumnType{Type: types.StringType, Size: 16},
+		},
+		TableType{
+			tid:      storage.EngineTableId + 1,
+			ver:      4,
+			colNames: []types.Identifier{col1, col5, col4},
+			colTypes: []types.ColumnType{
+				colTypes[0],
+				types.ColumnType{Type: types.StringType, Size: 16},
+				colTypes[3],
+			},
+			key: primary,
+		},
+		Select{
+			rows: testutil.MustParseRows(`
+(1, '10', true),
+(2, '20', false),
+(3, null, true),
+(4, '40', false)`),
+		},
+		Rollback{},
+	})
+
+	testStorage(t, st.Begin(), []interface{}{
+		OpenTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		TableType{
+			tid:      storage.EngineTableId + 1,
+			ver:      2,
+			colNames: colNames,
+			colTypes: colTypes,
+			key:      primary,
+		},
+		Select{
+			rows: testutil.MustParseRows(`
+(1, 10, 'one', true),
+(2, 20, 'two', true),
+(3, null, 'three', true),
+(4, 40, 'four', false)`),
+		},
+		Commit{},
+	})
+}
+
+func TestRenameTable(t *testing.T, store string, newStore NewStore) {
+	st, err := newStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("%s.NewStore() failed with %s", store, err)
+	}
+
+	colNames := []types.Identifier{col1, col2}
+	colTypes := []types.ColumnType{types.Int64ColType, types.NullStringColType}
+	primary := []types.ColumnKey{types.MakeColumnKey(0, false)}
+	tn := types.TableName{
+		Database: types.ID("db", false),
+		Schema:   types.ID("scm2", false),
+		Table:    types.ID("renamed", false),
+	}
+
+	testStorage(t, st.Begin(), []interface{}{
+		CreateTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		OpenTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		Insert{
+			rows: testutil.MustParseRows("(1, 'one'), (2, 'two')"),
+		},
+		Commit{},
+	})
+
+	testStorage(t, st.Begin(), []interface{}{
+		RenameTable{
+			tid: storage.EngineTableId + 1,
+			tn:  tn,
+		},
+		OpenTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		TableType{
+			tid:      storage.EngineTableId + 1,
+			tn:       tn,
+			ver:      1,
+			colNames: colNames,
+			colTypes: colTypes,
+			key:      primary,
+		},
+		Rollback{},
+	})
+
+	testStorage(t, st.Begin(), []interface{}{
+		OpenTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		TableType{
+			tid:      storage.EngineTableId + 1,
+			ver:      1,
+			colNames: colNames,
+			colTypes: colTypes,
+			key:      primary,
+		},
+		Commit{},
+	})
+
+	testStorage(t, st.Begin(), []interface{}{
+		RenameTable{
+			tid: storage.EngineTableId + 1,
+			tn:  tn,
+		},
+		Commit{},
+	})
+
+	testStorage(t, st.Begin(), []interface{}{
+		OpenTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		TableType{
+			tid:      storage.EngineTableId + 1,
+			tn:       tn,
+			ver:      1,
+			colNames: colNames,
+			colTypes: colTypes,
+			key:      primary,
+		},
+		Select{
+			rows: testutil.MustParseRows("(1, 'one'), (2, 'two')"),
+		},
+		Commit{},
+	})
+}
+
+func TestIndexes(t *testing.T, store string, newStore NewStore) {
+	st, err := newStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("%s.NewStore() failed with %s", store, err)
+	}
+
+	colNames := []types.Identifier{col1, col2, col3}
+	colTypes := []types.ColumnType{types.Int64ColType, types.NullInt64ColType,
+		types.NullStringColType}
+	primary := []types.ColumnKey{types.MakeColumnKey(0, false)}
+	key2 := []types.ColumnKey{types.MakeColumnKey(1, false)}
+	key3 := []types.ColumnKey{types.MakeColumnKey(2, true), types.MakeColumnKey(1, false)}
+
+	testStorage(t, st.Begin(), []interface{}{
+		CreateTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		OpenTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		Insert{
+			rows: testutil.MustParseRows(`
+(1, 30, 'one'),
+(2, 20, 'two'),
+(3, 10, 'three'),
+(4, 20, 'four'),
+(5, null, 'five')`),
+		},
+		CreateIndex{
+			iid:      0,
+			key:      key2,
+			panicked: true,
+		},
+		CreateIndex{
+			iid: 1,
+			key: key2,
+		},
+		CreateIndex{
+			iid:      1,
+			key:      key3,
+			panicked: true,
+		},
+		CreateIndex{
+			iid:      2,
+			key:      []types.ColumnKey{types.MakeColumnKey(3, false)},
+			panicked: true,
+		},
+		Insert{
+			rows: testutil.MustParseRows("(6, 20, 'six')"),
+		},
+		IndexSelect{
+			iid: 1,
+			rows: testutil.MustParseRows(`
+(5, null, 'five'),
+(3, 10, 'three'),
+(2, 20, 'two'),
+(4, 20, 'four'),
+(6, 20, 'six'),
+(1, 30, 'one')`),
+		},
+		IndexSelect{
+			iid:    1,
+			minRow: testutil.MustParseRow("(null, 20, null)"),
+			maxRow: testutil.MustParseRow("(null, 20, null)"),
+			rows: testutil.MustParseRows(`
+(2, 20, 'two'),
+(4, 20, 'four'),
+(6, 20, 'six')`),
+		},
+		IndexSelect{
+			iid:    1,
+			cols:   []types.ColumnNum{0},
+			minRow: testutil.MustParseRow("(null, 15, null)"),
+			rows:   testutil.MustParseRows("(2), (4), (6), (1)"),
+		},
+		IndexSelect{
+			iid:    1,
+			maxRow: testutil.MustParseRow("(null, 10, null)"),
+			rows:   testutil.MustParseRows("(5, null, 'five'), (3, 10, 'three')"),
+		},
+		Commit{},
+	})
+
+	testStorage(t, st.Begin(), []interface{}{
+		OpenTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		CreateIndex{
+			iid: 2,
+			key: key3,
+		},
+		UpdateSet{
+			minRow: testutil.MustParseRow("(2, null, null)"),
+			maxRow: testutil.MustParseRow("(2, null, null)"),
+			update: func(row types.Row) ([]types.ColumnNum, []types.Value) {
+				return []types.ColumnNum{1}, []types.Value{types.Int64Value(40)}
+			},
+		},
+		UpdateSet{
+			minRow: testutil.MustParseRow("(3, null, null)"),
+			maxRow: testutil.MustParseRow("(3, null, null)"),
+			update: func(row types.Row) ([]types.ColumnNum, []types.Value) {
+				return []types.ColumnNum{0, 2}, []types.Value{types.Int64Value(7),
+					types.StringValue("seven")}
+			},
+		},
+		DeleteFrom{
+			minRow: testutil.MustParseRow("(4, null, null)"),
+			maxRow: testutil.MustParseRow("(4, null, null)"),
+		},
+		IndexSelect{
+			iid: 1,
+			rows: testutil.MustParseRows(`
+(5, null, 'five'),
+(7, 10, 'seven'),
+(6, 20, 'six'),
+(1, 30, 'one'),
+(2, 40, 'two')`),
+		},
+		IndexSelect{
+			iid: 2,
+			rows: testutil.MustParseRows(`
+(2, 40, 'two'),
+(6, 20, 'six'),
+(7, 10, 'seven'),
+(1, 30, 'one'),
+(5, null, 'five')`),
+		},
+		Rollback{},
+	})
+
+	testStorage(t, st.Begin(), []interface{}{
+		OpenTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		IndexSelect{
+			iid: 1,
+			rows: testutil.MustParseRows(`
+(5, null, 'five'),
+(3, 10, 'three'),
+(2, 20, 'two'),
+(4, 20, 'four'),
+(6, 20, 'six'),
+(1, 30, 'one')`),
+		},
+		DropColumn{
+			col:  1,
+			fail: true,
+		},
+		DropIndex{
+			iid:      2,
+			panicked: true,
+		},
+		CreateIndex{
+			iid: 2,
+			key: []types.ColumnKey{types.MakeColumnKey(2, false)},
+		},
+		DropIndex{
+			iid: 1,
+		},
+		DropColumn{
+			col: 1,
+		},
+		Insert{
+			rows: testutil.MustParseRows("(8, 'eight')"),
+		},
+		IndexSelect{
+			iid:    2,
+			minRow: testutil.MustParseRow("(null, 'f')"),
+			maxRow: testutil.MustParseRow("(null, 's')"),
+			rows: testutil.MustParseRows(`
+(5, 'five'),
+(4, 'four'),
+(1, 'one')`),
+		},
+		Commit{},
+	})
+
+	open := OpenTable{
+		tid:      storage.EngineTableId + 2,
+		colNames: colNames,
+		colTypes: colTypes,
+		primary:  primary,
+	}
+	testStorage(t, st.Begin(), []interface{}{
+		CreateTable{
+			tid:      storage.EngineTableId + 2,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		open,
+		Insert{
+			rows: testutil.MustParseRows(`
+(1, 10, 'one'),
+(2, 20, 'two'),
+(3, null, 'three'),
+(4, null, 'four')`),
+		},
+		Commit{},
+	})
+
+	testStorage(t, st.Begin(), []interface{}{
+		open,
+		Insert{rows: testutil.MustParseRows("(5, 20, 'five')")},
+		CreateIndex{
+			iid:    1,
+			key:    key2,
+			unique: true,
+			fail:   true,
+		},
+		Rollback{},
+	})
+
+	// Rows with a NULL in the key of a unique index are never duplicates.
+	testStorage(t, st.Begin(), []interface{}{
+		open,
+		CreateIndex{
+			iid:    1,
+			key:    key2,
+			unique: true,
+		},
+		Insert{rows: testutil.MustParseRows("(5, null, 'five'), (6, 30, 'six')")},
+		Upsert{rows: testutil.MustParseRows("(2, 20, 'TWO')")},
+		UpdateSet{
+			minRow: testutil.MustParseRow("(6, null, null)"),
+			maxRow: testutil.MustParseRow("(6, null, null)"),
+			update: func(row types.Row) ([]types.ColumnNum, []types.Value) {
+				return []types.ColumnNum{1}, []types.Value{types.Int64Value(60)}
+			},
+		},
+		IndexSelect{
+			iid: 1,
+			rows: testutil.MustParseRows(`
+(3, null, 'three'),
+(4, null, 'four'),
+(5, null, 'five'),
+(1, 10, 'one'),
+(2, 20, 'TWO'),
+(6, 60, 'six')`),
+		},
+		IndexSelect{
+			iid:    1,
+			minRow: testutil.MustParseRow("(null, 20, null)"),
+			maxRow: testutil.MustParseRow("(null, 20, null)"),
+			rows:   testutil.MustParseRows("(2, 20, 'TWO')"),
+		},
+		Commit{},
+	})
+
+	testStorage(t, st.Begin(), []interface{}{
+		open,
+		Insert{
+			rows: testutil.MustParseRows("(7, 10, 'seven')"),
+			fail: true,
+		},
+		Rollback{},
+	})
+
+	testStorage(t, st.Begin(), []interface{}{
+		open,
+		Upsert{
+			rows: testutil.MustParseRows("(1, 20, 'one')"),
+			fail: true,
+		},
+		Rollback{},
+	})
+
+	testStorage(t, st.Begin(), []interface{}{
+		open,
+		UpdateSet{
+			minRow: testutil.MustParseRow("(1, null, null)"),
+			maxRow: testutil.MustParseRow("(1, null, null)"),
+			update: func(row types.Row) ([]types.ColumnNum, []types.Value) {
+				return []types.ColumnNum{1}, []types.Value{types.Int64Value(60)}
+			},
+			fail: true,
+		},
+		Rollback{},
+	})
+
+	// A key is no longer a duplicate after the row is deleted or changed.
+	testStorage(t, st.Begin(), []interface{}{
+		open,
+		DeleteFrom{
+			minRow: testutil.MustParseRow("(1, null, null)"),
+			maxRow: testutil.MustParseRow("(1, null, null)"),
+		},
+		UpdateSet{
+			minRow: testutil.MustParseRow("(2, null, null)"),
+			maxRow: testutil.MustParseRow("(2, null, null)"),
+			update: func(row types.Row) ([]types.ColumnNum, []types.Value) {
+				return []types.ColumnNum{1}, []types.Value{types.Int64Value(10)}
+			},
+		},
+		Insert{rows: testutil.MustParseRows("(7, 20, 'seven')")},
+		IndexSelect{
+			iid:  1,
+			cols: []types.ColumnNum{0, 1},
+			rows: testutil.MustParseRows(
+				"(3, null), (4, null), (5, null), (2, 10), (7, 20), (6, 60)"),
+		},
+		Commit{},
+	})
+}
+
+func TestScan(t *testing.T, store string, newStore NewStore) {
+	st, err := newStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("%s.NewStore() failed with %s", store, err)
+	}
+
+	colNames := []types.Identifier{col1, col2, col3}
+	colTypes := []types.ColumnType{types.Int64ColType, types.StringColType, types.Int64ColType}
+	primary := []types.ColumnKey{types.MakeColumnKey(0, false), types.MakeColumnKey(1, false)}
+	open := func(tid storage.TableId) OpenTable {
+		return OpenTable{
+			tid:      tid,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		}
+	}
+	rows := testutil.MustParseRows(`
+(1, 'a', 10),
+(1, 'b', 11),
+(2, 'a', 20),
+(2, 'b', 21),
+(2, 'c', 22),
+(3, 'a', 30)`)
+	reverse := func(rows []types.Row) []types.Row {
+		rows = slices.Clone(rows)
+		slices.Reverse(rows)
+		return rows
+	}
+
+	// The rows of the tables before and after must not be included in scans.
+	for tid := storage.EngineTableId + 1; tid <= storage.EngineTableId+3; tid += 1 {
+		testStorage(t, st.Begin(), []interface{}{
+			CreateTable{
+				tid:      tid,
+				colNames: colNames,
+				colTypes: colTypes,
+				primary:  primary,
+			},
+			open(tid),
+			Insert{rows: rows},
+			Commit{},
+		})
+	}
+
+	testStorage(t, st.Begin(), []interface{}{
+		open(storage.EngineTableId + 2),
+		ScanSelect{rows: rows},
+		ScanSelect{spec: storage.ScanSpec{Reverse: true}, rows: reverse(rows)},
+		ScanSelect{
+			cols: []types.ColumnNum{2},
+			spec: storage.ScanSpec{Reverse: true},
+			rows: testutil.MustParseRows("(30), (22), (21), (20), (11), (10)"),
+		},
+		ScanSelect{
+			spec: storage.ScanSpec{
+				MinRow: testutil.MustParseRow("(1, 'b', 0)"),
+				MaxRow: testutil.MustParseRow("(2, 'c', 0)"),
+			},
+			rows: rows[1:5],
+		},
+		ScanSelect{
+			spec: storage.ScanSpec{
+				MinRow:       testutil.MustParseRow("(1, 'b', 0)"),
+				MinExclusive: true,
+				MaxRow:       testutil.MustParseRow("(2, 'c', 0)"),
+				MaxExclusive: true,
+			},
+			rows: rows[2:4],
+		},
+		ScanSelect{
+			spec: storage.ScanSpec{
+				MinRow:       testutil.MustParseRow("(1, 'b', 0)"),
+				MinExclusive: true,
+				MaxRow:       testutil.MustParseRow("(2, 'c', 0)"),
+				MaxExclusive: true,
+				Reverse:      true,
+			},
+			rows: reverse(rows[2:4]),
+		},
+		ScanSelect{
+			spec: storage.ScanSpec{
+				MinRow:  testutil.MustParseRow("(1, 'b', 0)"),
+				MaxRow:  testutil.MustParseRow("(2, 'c', 0)"),
+				Reverse: true,
+			},
+			rows: reverse(rows[1:5]),
+		},
+		ScanSelect{
+			spec: storage.ScanSpec{
+				MinRow: testutil.MustParseRow("(2, NULL, 0)"),
+				MaxRow: testutil.MustParseRow("(2, NULL, 0)"),
+				Prefix: 1,
+			},
+			rows: rows[2:5],
+		},
+		ScanSelect{
+			spec: storage.ScanSpec{
+				MinRow:  testutil.MustParseRow("(2, NULL, 0)"),
+				MaxRow:  testutil.MustParseRow("(2, NULL, 0)"),
+				Prefix:  1,
+				Reverse: true,
+			},
+			rows: reverse(rows[2:5]),
+		},
+		ScanSelect{
+			spec: storage.ScanSpec{
+				MinRow:       testutil.MustParseRow("(1, NULL, 0)"),
+				MinExclusive: true,
+				Prefix:       1,
+				Reverse:      true,
+			},
+			rows: reverse(rows[2:]),
+		},
+		ScanSelect{
+			spec: storage.ScanSpec{
+				MinRow:       testutil.MustParseRow("(1, NULL, 0)"),
+				MinExclusive: true,
+				Prefix:       1,
+			},
+			rows: rows[2:],
+		},
+		ScanSelect{
+			spec: storage.ScanSpec{
+				MaxRow:       testutil.MustParseRow("(2, NULL, 0)"),
+				MaxExclusive: true,
+				Prefix:       1,
+			},
+			rows: rows[:2],
+		},
+		ScanSelect{
+			spec: storage.ScanSpec{
+				MaxRow:  testutil.MustParseRow("(2, NULL, 0)"),
+				Prefix:  1,
+				Reverse: true,
+			},
+			rows: reverse(rows[:5]),
+		},
+		ScanSelect{
+			spec: storage.ScanSpec{
+				MaxRow:       testutil.MustParseRow("(2, NULL, 0)"),
+				MaxExclusive: true,
+				Prefix:       1,
+				Reverse:      true,
+			},
+			rows: reverse(rows[:2]),
+		},
+		ScanSelect{
+			spec: storage.ScanSpec{
+				MinRow: testutil.MustParseRow("(5, NULL, 0)"),
+				Prefix: 1,
+			},
+		},
+		ScanSelect{
+			spec: storage.ScanSpec{
+				MaxRow:  testutil.MustParseRow("(0, NULL, 0)"),
+				Prefix:  1,
+				Reverse: true,
+			},
+		},
+		ScanSelect{
+			spec: storage.ScanSpec{Reverse: true},
+			pred: predicateFunc{
+				col:       2,
+				int64Pred: func(i types.Int64Value) bool { return i < 22 },
+			},
+			rows: testutil.MustParseRows("(2, 'b', 21), (2, 'a', 20), (1, 'b', 11), (1, 'a', 10)"),
+		},
+		Commit{},
+	})
+
+	// Changes made while scanning in reverse do not change the rows returned.
+	testStorage(t, st.Begin(), []interface{}{
+		open(storage.EngineTableId + 2),
+		UpdateSet{
+			update: func(row types.Row) ([]types.ColumnNum, []types.Value) {
+				return []types.ColumnNum{0}, []types.Value{row[0].(types.Int64Value) + 10}
+			},
+		},
+		ScanSelect{
+			spec: storage.ScanSpec{Reverse: true},
+			rows: reverse(testutil.MustParseRows(`
+(11, 'a', 10),
+(11, 'b', 11),
+(12, 'a', 20),
+(12, 'b', 21),
+(12, 'c', 22),
+(13, 'a', 30)`)),
+		},
+		Rollback{},
+	})
+}
+
+func TestPredicates(t *testing.T, store string, newStore NewStore) {
+	st, err := newStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("%s.NewStore() failed with %s", store, err)
+	}
+
+	colNames := []types.Identifier{col1, col2, col3, col4}
+	colTypes := []types.ColumnType{
+		types.Int64ColType,
+		types.NullInt64ColType,
+		types.NullStringColType,
+		types.NullInt64ColType,
+	}
+	primary := []types.ColumnKey{types.MakeColumnKey(0, false)}
+	rows := testutil.MustParseRows(`
+(1, 10, 'one', 10),
+(2, 20, NULL, 30),
+(3, NULL, 'three', 30),
+(4, 40, 'four', NULL),
+(5, 50, 'five', 40)`)
+	// selectRows returns the rows with the col1 values in keys.
+	selectRows := func(keys ...int64) []types.Row {
+		var sel []types.Row
+		for _, key := range keys {
+			sel = append(sel, rows[key-1])
+		}
+		return sel
+	}
+
+	testStorage(t, st.Begin(), []interface{}{
+		CreateTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		OpenTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		Insert{rows: rows},
+		Select{
+			pred: storage.ComparePredicate{
+				Op:     storage.GreaterOp,
+				Column: 1,
+				Value:  types.Int64Value(20),
+			},
+			rows: selectRows(4, 5),
+		},
+		Select{
+			pred: storage.NotPredicate{
+				Predicate: storage.ComparePredicate{
+					Op:     storage.GreaterOp,
+					Column: 1,
+					Value:  types.Int64Value(20),
+				},
+			},
+			rows: selectRows(1, 2),
+		},
+		Select{
+			pred: storage.ColumnsPredicate{Op: storage.LessOp, Left: 1, Right: 3},
+			rows: selectRows(2),
+		},
+		Select{
+			pred: storage.ColumnsPredicate{Op: storage.EqualOp, Left: 1, Right: 3},
+			rows: selectRows(1),
+		},
+		Select{pred: storage.NullPredicate{Column: 2}, rows: selectRows(2)},
+		Select{
+			pred: storage.NotPredicate{Predicate: storage.NullPredicate{Column: 1}},
+			rows: selectRows(1, 2, 4, 5),
+		},
+		Select{
+			pred: storage.InPredicate{
+				Column: 3,
+				Values: []types.Value{types.Int64Value(30), types.Int64Value(40)},
+			},
+			rows: selectRows(2, 3, 5),
+		},
+		// NOT IN with a NULL is never true.
+		Select{
+			pred: storage.NotPredicate{
+				Predicate: storage.InPredicate{
+					Column: 3,
+					Values: []types.Value{types.Int64Value(30), nil},
+				},
+			},
+		},
+		Select{
+			pred: storage.NotPredicate{
+				Predicate: storage.InPredicate{
+					Column: 3,
+					Values: []types.Value{types.Int64Value(30)},
+				},
+			},
+			rows: selectRows(1, 5),
+		},
+		Select{
+			pred: storage.OrPredicate{
+				storage.ComparePredicate{
+					Op:     storage.EqualOp,
+					Column: 2,
+					Value:  types.StringValue("one"),
+				},
+				storage.AndPredicate{
+					storage.ComparePredicate{
+						Op:     storage.GreaterEqualOp,
+						Column: 0,
+						Value:  types.Int64Value(3),
+					},
+					storage.NotPredicate{Predicate: storage.NullPredicate{Column: 3}},
+				},
+			},
+			rows: selectRows(1, 3, 5),
+		},
+		Select{
+			pred: storage.AndPredicate{
+				storage.ComparePredicate{
+					Op:     storage.NotEqualOp,
+					Column: 0,
+					Value:  types.Int64Value(1),
+				},
+				predicateFunc{
+					col:       3,
+					int64Pred: func(i types.Int64Value) bool { return i == 30 },
+				},
+			},
+			rows: selectRows(2, 3),
+		},
+		SupportsPredicate{pred: storage.NullPredicate{Column: 2}, supported: true},
+		SupportsPredicate{pred: storage.NullPredicate{Column: 4}},
+		SupportsPredicate{
+			pred: storage.AndPredicate{
+				storage.NullPredicate{Column: 1},
+				predicateFunc{col: 2},
+			},
+			supported: true,
+		},
+		SupportsPredicate{pred: types.Int64Value(1)},
+		SupportsPredicate{
+			pred: storage.ComparePredicate{Op: 0, Column: 1, Value: types.Int64Value(1)},
+		},
+		Rows{pred: types.Int64Value(1), fail: true},
+		Rows{pred: storage.OrPredicate{storage.NullPredicate{Column: 10}}, fail: true},
+		Commit{},
+	})
+}
+
+func TestUpsert(t *testing.T, store string, newStore NewStore) {
+	st, err := newStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("%s.NewStore() failed with %s", store, err)
+	}
+
+	colNames := []types.Identifier{col1, col2, col3}
+	colTypes := []types.ColumnType{types.Int64ColType, types.NullInt64ColType,
+		types.NullStringColType}
+	primary := []types.ColumnKey{types.MakeColumnKey(0, false)}
+	key2 := []types.ColumnKey{types.MakeColumnKey(1, false)}
+
+	testStorage(t, st.Begin(), []interface{}{
+		CreateTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		OpenTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		Insert{rows: testutil.MustParseRows("(1, 10, 'one'), (2, 20, 'two'), (3, 30, 'three')")},
+		CreateIndex{iid: 1, key: key2},
+		Commit{},
+	})
+
+	testStorage(t, st.Begin(), []interface{}{
+		OpenTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		Upsert{rows: testutil.MustParseRows("(2, 25, 'TWO'), (4, 5, 'four'), (1, 10, NULL)")},
+		Select{
+			rows: testutil.MustParseRows(`
+(1, 10, NULL),
+(2, 25, 'TWO'),
+(3, 30, 'three'),
+(4, 5, 'four')`),
+		},
+		IndexSelect{
+			iid: 1,
+			rows: testutil.MustParseRows(`
+(4, 5, 'four'),
+(1, 10, NULL),
+(2, 25, 'TWO'),
+(3, 30, 'three')`),
+		},
+		Upsert{rows: testutil.MustParseRows("(5, 'five', 'five')"), fail: true},
+		Commit{},
+	})
+
+	testStorage(t, st.Begin(), []interface{}{
+		OpenTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		Select{
+			rows: testutil.MustParseRows(`
+(1, 10, NULL),
+(2, 25, 'TWO'),
+(3, 30, 'three'),
+(4, 5, 'four')`),
+		},
+		Rollback{},
+	})
+
+	tx, err := st.BeginReadOnly(time.Time{})
+	if err != nil {
+		t.Fatalf("%s.BeginReadOnly() failed with %s", store, err)
+	}
+	testStorage(t, tx, []interface{}{
+		OpenTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		Upsert{rows: testutil.MustParseRows("(1, 100, 'one')"), fail: true},
+		Rollback{},
+	})
+}
+
+func TestDeleteRange(t *testing.T, store string, newStore NewStore) {
+	st, err := newStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("%s.NewStore() failed with %s", store, err)
+	}
+
+	colNames := []types.Identifier{col1, col2, col3}
+	colTypes := []types.ColumnType{types.Int64ColType, types.Int64ColType,
+		types.NullStringColType}
+	primary := []types.ColumnKey{types.MakeColumnKey(0, false), types.MakeColumnKey(1, true)}
+	key3 := []types.ColumnKey{types.MakeColumnKey(2, false)}
+	rows := testutil.MustParseRows(`
+(1, 2, 'a'),
+(1, 1, 'b'),
+(2, 3, 'c'),
+(2, 2, 'd'),
+(2, 1, 'e'),
+(3, 1, 'f'),
+(4, 2, 'g'),
+(4, 1, 'h')`)
+
+	testStorage(t, st.Begin(), []interface{}{
+		CreateTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		OpenTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		Insert{rows: rows},
+		CreateIndex{iid: 1, key: key3},
+		Commit{},
+	})
+
+	testStorage(t, st.Begin(), []interface{}{
+		OpenTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		DeleteRange{
+			minRow: types.Row{types.Int64Value(2), types.Int64Value(2)},
+			maxRow: types.Row{types.Int64Value(4), types.Int64Value(2)},
+			count:  4,
+		},
+		Select{rows: testutil.MustParseRows("(1, 2, 'a'), (1, 1, 'b'), (2, 3, 'c'), (4, 1, 'h')")},
+		IndexSelect{
+			iid:  1,
+			rows: testutil.MustParseRows("(1, 2, 'a'), (1, 1, 'b'), (2, 3, 'c'), (4, 1, 'h')"),
+		},
+		DeleteRange{maxRow: types.Row{types.Int64Value(1), types.Int64Value(1)}, count: 2},
+		DeleteRange{minRow: types.Row{types.Int64Value(3), types.Int64Value(1)}, count: 1},
+		DeleteRange{minRow: types.Row{types.Int64Value(3), types.Int64Value(1)}},
+		Select{rows: testutil.MustParseRows("(2, 3, 'c')")},
+		IndexSelect{iid: 1, rows: testutil.MustParseRows("(2, 3, 'c')")},
+		Commit{},
+	})
+
+	testStorage(t, st.Begin(), []interface{}{
+		OpenTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		Select{rows: testutil.MustParseRows("(2, 3, 'c')")},
+		Insert{rows: rows[:2]},
+		DeleteRange{count: 3},
+		Select{},
+		IndexSelect{iid: 1},
+		Insert{rows: rows},
+		Rows{},
+		Next{row: rows[0]},
+		DeleteRange{count: 8},
+		Next{row: rows[1]},
+		Close{},
+		Select{},
+		Rollback{},
+	})
+
+	tx, err := st.BeginReadOnly(time.Time{})
+	if err != nil {
+		t.Fatalf("%s.BeginReadOnly() failed with %s", store, err)
+	}
+	testStorage(t, tx, []interface{}{
+		OpenTable{
+			tid:      storage.EngineTableId + 1,
+			colNames: colNames,
+			colTypes: colTypes,
+			primary:  primary,
+		},
+		DeleteRange{fail: true},
+		Select{rows: testutil.MustParseRows("(2, 3, 'c')")},
+		Rollback{},
+	})
+}
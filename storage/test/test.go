@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/leftmike/maho/storage"
 	"github.com/leftmike/maho/testutil"
@@ -97,14 +99,52 @@ type DropTable struct {
 	panicked bool
 }
 
+type RenameTable struct {
+	tid storage.TableId
+	tn  types.TableName
+}
+
 type TableType struct {
 	tid      storage.TableId
+	tn       types.TableName // defaults to tableName(tid)
 	ver      uint32
 	colNames []types.Identifier
 	colTypes []types.ColumnType
 	key      []types.ColumnKey
 }
 
+type AddColumn struct {
+	nam  types.Identifier
+	ct   types.ColumnType
+	dflt types.Value
+	fail bool
+}
+
+type DropColumn struct {
+	col  types.ColumnNum
+	fail bool
+}
+
+type UpdateColumn struct {
+	col  types.ColumnNum
+	nam  types.Identifier
+	ct   types.ColumnType
+	fail bool
+}
+
+type CreateIndex struct {
+	iid      storage.IndexId
+	key      []types.ColumnKey
+	unique   bool
+	fail     bool
+	panicked bool
+}
+
+type DropIndex struct {
+	iid      storage.IndexId
+	panicked bool
+}
+
 type Commit struct {
 	panicked bool
 }
@@ -115,6 +155,34 @@ type Rollback struct {
 
 type NextStmt struct{}
 
+type SetIsolation struct {
+	level storage.IsolationLevel
+}
+
+// Concurrent runs cases in a new transaction while the transaction is still active.
+type Concurrent struct {
+	cases []interface{}
+}
+
+// Sleep pauses the transaction, without synchronizing with any other transaction.
+type Sleep struct {
+	d time.Duration
+}
+
+// Parallel runs each list of cases in a new transaction in its own goroutine, and waits for
+// all of them to complete.
+type Parallel struct {
+	cases [][]interface{}
+}
+
+type Savepoint struct {
+	name string
+}
+
+type RollbackTo struct {
+	name string
+}
+
 type Rows struct {
 	cols   []types.ColumnNum
 	minRow types.Row
@@ -140,6 +208,18 @@ type Insert struct {
 	fail bool
 }
 
+type Upsert struct {
+	rows []types.Row
+	fail bool
+}
+
+type DeleteRange struct {
+	minRow types.Row
+	maxRow types.Row
+	count  int64
+	fail   bool
+}
+
 type Next struct {
 	row      types.Row
 	fail     bool
@@ -166,6 +246,26 @@ type Select struct {
 	unordered bool
 }
 
+type SupportsPredicate struct {
+	pred      storage.Predicate
+	supported bool
+}
+
+type ScanSelect struct {
+	cols []types.ColumnNum
+	spec storage.ScanSpec
+	pred storage.Predicate
+	rows []types.Row
+}
+
+type IndexSelect struct {
+	iid    storage.IndexId
+	cols   []types.ColumnNum
+	minRow types.Row
+	maxRow types.Row
+	rows   []types.Row
+}
+
 type DeleteFrom struct {
 	minRow types.Row
 	maxRow types.Row
@@ -177,6 +277,7 @@ type UpdateSet struct {
 	maxRow types.Row
 	pred   storage.Predicate
 	update func(row types.Row) ([]types.ColumnNum, []types.Value)
+	fail   bool
 }
 
 func selectFunc(t *testing.T, what string, tbl storage.Table, cols []types.ColumnNum,
@@ -226,6 +327,7 @@ func testStorage(t *testing.T, tx storage.Transaction, cases []interface{}) {
 	var rows storage.Rows
 	var rowRef storage.RowRef
 	var err error
+	savepoints := map[string]storage.Savepoint{}
 	for _, c := range cases {
 		switch c := c.(type) {
 		case OpenTable:
@@ -274,13 +376,21 @@ func testStorage(t *testing.T, tx storage.Transaction, cases []interface{}) {
 			} else if err != nil {
 				t.Errorf("DropTable(%d) failed with %s", c.tid, err)
 			}
+		case RenameTable:
+			err := tx.RenameTable(ctx, c.tid, c.tn)
+			if err != nil {
+				t.Errorf("RenameTable(%d) failed with %s", c.tid, err)
+			}
 		case TableType:
 			tid := tbl.TID()
 			if tid != c.tid {
 				t.Errorf("%d.TID() got %d want %d", c.tid, tid, c.tid)
 			}
 
-			tn := tableName(c.tid)
+			tn := c.tn
+			if tn == (types.TableName{}) {
+				tn = tableName(c.tid)
+			}
 			if tbl.Name() != tn {
 				t.Errorf("%d.Name() got %s want %s", c.tid, tbl.Name(), tn)
 			}
@@ -300,6 +410,63 @@ func testStorage(t *testing.T, tx storage.Transaction, cases []interface{}) {
 			if !reflect.DeepEqual(tbl.Key(), key) {
 				t.Errorf("%d.Key() got %#v want %#v", c.tid, tbl.Key(), key)
 			}
+		case AddColumn:
+			err := tbl.AddColumn(ctx, c.nam, c.ct, c.dflt)
+			if c.fail {
+				if err == nil {
+					t.Errorf("%d.AddColumn(%s) did not fail", tbl.TID(), c.nam)
+				}
+			} else if err != nil {
+				t.Errorf("%d.AddColumn(%s) failed with %s", tbl.TID(), c.nam, err)
+			}
+		case DropColumn:
+			err := tbl.DropColumn(ctx, c.col)
+			if c.fail {
+				if err == nil {
+					t.Errorf("%d.DropColumn(%d) did not fail", tbl.TID(), c.col)
+				}
+			} else if err != nil {
+				t.Errorf("%d.DropColumn(%d) failed with %s", tbl.TID(), c.col, err)
+			}
+		case UpdateColumn:
+			err := tbl.UpdateColumn(ctx, c.col, c.nam, c.ct)
+			if c.fail {
+				if err == nil {
+					t.Errorf("%d.UpdateColumn(%d) did not fail", tbl.TID(), c.col)
+				}
+			} else if err != nil {
+				t.Errorf("%d.UpdateColumn(%d) failed with %s", tbl.TID(), c.col, err)
+			}
+		case CreateIndex:
+			err, panicked := testutil.ErrorPanicked(func() error {
+				return tbl.CreateIndex(ctx, c.iid, c.key, c.unique)
+			})
+			if panicked {
+				if !c.panicked {
+					t.Errorf("%d.CreateIndex(%d) panicked", tbl.TID(), c.iid)
+				}
+			} else if c.panicked {
+				t.Errorf("%d.CreateIndex(%d) did not panic", tbl.TID(), c.iid)
+			} else if c.fail {
+				if err == nil {
+					t.Errorf("%d.CreateIndex(%d) did not fail", tbl.TID(), c.iid)
+				}
+			} else if err != nil {
+				t.Errorf("%d.CreateIndex(%d) failed with %s", tbl.TID(), c.iid, err)
+			}
+		case DropIndex:
+			err, panicked := testutil.ErrorPanicked(func() error {
+				return tbl.DropIndex(ctx, c.iid)
+			})
+			if panicked {
+				if !c.panicked {
+					t.Errorf("%d.DropIndex(%d) panicked", tbl.TID(), c.iid)
+				}
+			} else if c.panicked {
+				t.Errorf("%d.DropIndex(%d) did not panic", tbl.TID(), c.iid)
+			} else if err != nil {
+				t.Errorf("%d.DropIndex(%d) failed with %s", tbl.TID(), c.iid, err)
+			}
 		case Commit:
 			err, panicked := testutil.ErrorPanicked(func() error {
 				return tx.Commit(ctx)
@@ -337,6 +504,36 @@ func testStorage(t *testing.T, tx storage.Transaction, cases []interface{}) {
 			}
 		case NextStmt:
 			tx.NextStmt()
+		case SetIsolation:
+			err := tx.SetIsolation(c.level)
+			if err != nil {
+				t.Errorf("SetIsolation(%s) failed with %s", c.level, err)
+			}
+		case Concurrent:
+			testStorage(t, tx.Store().Begin(), c.cases)
+		case Sleep:
+			time.Sleep(c.d)
+		case Parallel:
+			var wg sync.WaitGroup
+			for _, cases := range c.cases {
+				wg.Add(1)
+				go func(cases []interface{}) {
+					defer wg.Done()
+					testStorage(t, tx.Store().Begin(), cases)
+				}(cases)
+			}
+			wg.Wait()
+		case Savepoint:
+			savepoints[c.name] = tx.Savepoint()
+		case RollbackTo:
+			sp, ok := savepoints[c.name]
+			if !ok {
+				panic(fmt.Sprintf("savepoint not found: %s", c.name))
+			}
+			err := tx.RollbackTo(sp)
+			if err != nil {
+				t.Errorf("RollbackTo(%s) failed with %s", c.name, err)
+			}
 		case Rows:
 			var err error
 			rows, err = tbl.Rows(ctx, c.cols, c.minRow, c.maxRow, c.pred)
@@ -390,6 +587,26 @@ func testStorage(t *testing.T, tx storage.Transaction, cases []interface{}) {
 			} else if err != nil {
 				t.Errorf("%d.Insert() failed with %s", tbl.TID(), err)
 			}
+		case Upsert:
+			err := tbl.Upsert(ctx, c.rows)
+			if c.fail {
+				if err == nil {
+					t.Errorf("%d.Upsert() did not fail", tbl.TID())
+				}
+			} else if err != nil {
+				t.Errorf("%d.Upsert() failed with %s", tbl.TID(), err)
+			}
+		case DeleteRange:
+			cnt, err := tbl.DeleteRange(ctx, c.minRow, c.maxRow)
+			if c.fail {
+				if err == nil {
+					t.Errorf("%d.DeleteRange() did not fail", tbl.TID())
+				}
+			} else if err != nil {
+				t.Errorf("%d.DeleteRange() failed with %s", tbl.TID(), err)
+			} else if cnt != c.count {
+				t.Errorf("%d.DeleteRange() got %d want %d", tbl.TID(), cnt, c.count)
+			}
 		case Next:
 			row, err, panicked := rowErrorPanicked(func() (types.Row, error) {
 				return rows.Next(ctx)
@@ -461,6 +678,66 @@ func testStorage(t *testing.T, tx storage.Transaction, cases []interface{}) {
 				t.Errorf("Select(%d) got %s want %s", tbl.TID(), testutil.FormatRows(rows, ",\n"),
 					testutil.FormatRows(c.rows, ",\n"))
 			}
+		case SupportsPredicate:
+			supported := tbl.SupportsPredicate(c.pred)
+			if supported != c.supported {
+				t.Errorf("%d.SupportsPredicate(%v) got %v want %v", tbl.TID(), c.pred, supported,
+					c.supported)
+			}
+		case ScanSelect:
+			rs, err := tbl.Scan(ctx, c.cols, c.spec, c.pred)
+			if err != nil {
+				t.Errorf("ScanSelect(%d, %v) failed with %s", tbl.TID(), c.spec, err)
+				continue
+			}
+
+			var rows []types.Row
+			for {
+				row, err := rs.Next(ctx)
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					t.Errorf("ScanSelect(%d, %v).Next() failed with %s", tbl.TID(), c.spec, err)
+					break
+				}
+				rows = append(rows, row)
+			}
+			err = rs.Close(ctx)
+			if err != nil {
+				t.Errorf("ScanSelect(%d, %v).Close() failed with %s", tbl.TID(), c.spec, err)
+			}
+
+			if !testutil.RowsEqual(rows, c.rows, false) {
+				t.Errorf("ScanSelect(%d, %v) got %s want %s", tbl.TID(), c.spec,
+					testutil.FormatRows(rows, ",\n"), testutil.FormatRows(c.rows, ",\n"))
+			}
+		case IndexSelect:
+			rs, err := tbl.IndexRows(ctx, c.iid, c.cols, c.minRow, c.maxRow)
+			if err != nil {
+				t.Errorf("IndexSelect(%d, %d) failed with %s", tbl.TID(), c.iid, err)
+				continue
+			}
+
+			var rows []types.Row
+			for {
+				row, err := rs.Next(ctx)
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					t.Errorf("IndexSelect(%d, %d).Next() failed with %s", tbl.TID(), c.iid, err)
+					break
+				}
+				rows = append(rows, row)
+			}
+			err = rs.Close(ctx)
+			if err != nil {
+				t.Errorf("IndexSelect(%d, %d).Close() failed with %s", tbl.TID(), c.iid, err)
+			}
+
+			if !testutil.RowsEqual(rows, c.rows, false) {
+				t.Errorf("IndexSelect(%d, %d) got %s want %s", tbl.TID(), c.iid,
+					testutil.FormatRows(rows, ",\n"), testutil.FormatRows(c.rows, ",\n"))
+			}
 		case DeleteFrom:
 			selectFunc(t, "DeleteFrom", tbl, nil, c.minRow, c.maxRow, c.pred,
 				func(rowRef storage.RowRef, row types.Row) {
@@ -474,7 +751,11 @@ func testStorage(t *testing.T, tx storage.Transaction, cases []interface{}) {
 				func(rowRef storage.RowRef, row types.Row) {
 					cols, vals := c.update(row)
 					err := rowRef.Update(ctx, cols, vals)
-					if err != nil {
+					if c.fail {
+						if err == nil {
+							t.Errorf("UpdateSet(%d).Update() did not fail", tbl.TID())
+						}
+					} else if err != nil {
 						t.Errorf("UpdateSet(%d).Update() failed with %s", tbl.TID(), err)
 					}
 				})
@@ -14,7 +14,9 @@ const (
 
 // Known identifiers and keywords
 const (
-	BIGINT Identifier = iota + 1
+	ALWAYS Identifier = iota + 1
+	BIGINT
+	BIGSERIAL
 	BINARY
 	BLOB
 	BOOL
@@ -25,41 +27,81 @@ const (
 	CHAR
 	CHARACTER
 	COLUMNS
+	COMMITTED
 	CONFIG
+	CONFLICT
 	CONSTRAINTS
+	CONTINUE
 	COUNT
 	COUNT_ALL
+	CSV
+	CYCLE
+	DATA
 	DATABASES
+	DEFERRABLE
+	DEFERRED
 	DESCRIPTION
+	DO
 	DOUBLE
+	ESCAPE
 	FLAGS
 	FIELD
+	FORCE_QUOTE
+	FORMAT
+	GENERATED
+	HEADER
+	IDENTITY
+	IMMEDIATE
+	INCREMENT
 	INDEXES
 	INFO
+	INITIALLY
 	INT
 	INT2
 	INT4
 	INT8
 	INTEGER
+	ISOLATION
+	LEVEL
 	MAHO
+	MAXVALUE
 	METADATA
+	MINVALUE
+	NOTHING
+	OF
+	ONLY
 	PATH
 	PRIMARY_QUOTED
 	PRIVATE
 	PUBLIC
 	PRECISION
+	QUOTE
 	REAL
+	READ
+	RENAME
+	REPEATABLE
+	RESTART
 	ROWID
 	SCHEMAS
+	SEQUENCE
 	SEQUENCES
+	SERIALIZABLE
+	SERIAL
 	SMALLINT
+	SMALLSERIAL
+	SNAPSHOT
 	STDIN
+	STDOUT
 	SYSTEM
 	TABLES
 	TEXT
+	TIME
 	TREE
+	TYPE
+	UNCOMMITTED
 	VARBINARY
 	VARCHAR
+	WRITE
 )
 
 // Reserved keywords
@@ -118,9 +160,12 @@ const (
 	PREPARE
 	PRIMARY
 	REFERENCES
+	RELEASE
 	RESTRICT
+	RETURNING
 	RIGHT
 	ROLLBACK
+	SAVEPOINT
 	SCHEMA
 	SELECT
 	SET
@@ -131,6 +176,7 @@ const (
 	TO
 	TRANSACTION
 	TRUE
+	TRUNCATE
 	UNIQUE
 	UPDATE
 	USE
@@ -150,6 +196,7 @@ var (
 		"constraints": CONSTRAINTS,
 		"count":       COUNT,
 		"count_all":   COUNT_ALL,
+		"data":        DATA,
 		"databases":   DATABASES,
 		"description": DESCRIPTION,
 		"field":       FIELD,
@@ -161,113 +208,158 @@ var (
 		"primary":     PRIMARY_QUOTED,
 		"private":     PRIVATE,
 		"public":      PUBLIC,
+		"rename":      RENAME,
 		"__rowid":     ROWID,
 		"schemas":     SCHEMAS,
 		"sequences":   SEQUENCES,
 		"system":      SYSTEM,
 		"tables":      TABLES,
 		"tree":        TREE,
+		"type":        TYPE,
 	}
 
 	keywords = map[string]Identifier{
-		"ACTION":      ACTION,
-		"ADD":         ADD,
-		"ALL":         ALL,
-		"ALTER":       ALTER,
-		"AND":         AND,
-		"ANY":         ANY,
-		"AS":          AS,
-		"ASC":         ASC,
-		"BEGIN":       BEGIN,
-		"BY":          BY,
-		"BIGINT":      BIGINT,
-		"BINARY":      BINARY,
-		"BLOB":        BLOB,
-		"BOOL":        BOOL,
-		"BOOLEAN":     BOOLEAN,
-		"BYTEA":       BYTEA,
-		"BYTES":       BYTES,
-		"CASCADE":     CASCADE,
-		"CHAR":        CHAR,
-		"CHARACTER":   CHARACTER,
-		"CHECK":       CHECK,
-		"COLUMN":      COLUMN,
-		"COMMIT":      COMMIT,
-		"CONSTRAINT":  CONSTRAINT,
-		"COPY":        COPY,
-		"CREATE":      CREATE,
-		"CROSS":       CROSS,
-		"DATABASE":    DATABASE,
-		"DEFAULT":     DEFAULT,
-		"DELETE":      DELETE,
-		"DELIMITER":   DELIMITER,
-		"DESC":        DESC,
-		"DETACH":      DETACH,
-		"DOUBLE":      DOUBLE,
-		"DROP":        DROP,
-		"EXECUTE":     EXECUTE,
-		"EXISTS":      EXISTS,
-		"EXPLAIN":     EXPLAIN,
-		"FALSE":       FALSE,
-		"FOREIGN":     FOREIGN,
-		"FROM":        FROM,
-		"FULL":        FULL,
-		"GROUP":       GROUP,
-		"HAVING":      HAVING,
-		"IF":          IF,
-		"IN":          IN,
-		"INDEX":       INDEX,
-		"INNER":       INNER,
-		"INSERT":      INSERT,
-		"INT":         INT,
-		"INT2":        INT2,
-		"INT4":        INT4,
-		"INT8":        INT8,
-		"INTEGER":     INTEGER,
-		"INTO":        INTO,
-		"IS":          IS,
-		"JOIN":        JOIN,
-		"KEY":         KEY,
-		"LEFT":        LEFT,
-		"NO":          NO,
-		"NOT":         NOT,
-		"NULL":        NULL,
-		"ON":          ON,
-		"OR":          OR,
-		"ORDER":       ORDER,
-		"OUTER":       OUTER,
-		"PATH":        PATH,
-		"PRECISION":   PRECISION,
-		"PREPARE":     PREPARE,
-		"PRIMARY":     PRIMARY,
-		"REAL":        REAL,
-		"RESTRICT":    RESTRICT,
-		"REFERENCES":  REFERENCES,
-		"RIGHT":       RIGHT,
-		"ROLLBACK":    ROLLBACK,
-		"SCHEMA":      SCHEMA,
-		"SELECT":      SELECT,
-		"SET":         SET,
-		"SHOW":        SHOW,
-		"SMALLINT":    SMALLINT,
-		"SOME":        SOME,
-		"STDIN":       STDIN,
-		"START":       START,
-		"TABLE":       TABLE,
-		"TEXT":        TEXT,
-		"TO":          TO,
-		"TRANSACTION": TRANSACTION,
-		"TRUE":        TRUE,
-		"UNIQUE":      UNIQUE,
-		"UPDATE":      UPDATE,
-		"USE":         USE,
-		"USING":       USING,
-		"VALUES":      VALUES,
-		"VARBINARY":   VARBINARY,
-		"VARCHAR":     VARCHAR,
-		"VERBOSE":     VERBOSE,
-		"WHERE":       WHERE,
-		"WITH":        WITH,
+		"ACTION":       ACTION,
+		"ADD":          ADD,
+		"ALL":          ALL,
+		"ALWAYS":       ALWAYS,
+		"ALTER":        ALTER,
+		"AND":          AND,
+		"ANY":          ANY,
+		"AS":           AS,
+		"ASC":          ASC,
+		"BEGIN":        BEGIN,
+		"BY":           BY,
+		"BIGINT":       BIGINT,
+		"BIGSERIAL":    BIGSERIAL,
+		"BINARY":       BINARY,
+		"BLOB":         BLOB,
+		"BOOL":         BOOL,
+		"BOOLEAN":      BOOLEAN,
+		"BYTEA":        BYTEA,
+		"BYTES":        BYTES,
+		"CASCADE":      CASCADE,
+		"CHAR":         CHAR,
+		"CHARACTER":    CHARACTER,
+		"CHECK":        CHECK,
+		"COLUMN":       COLUMN,
+		"COMMIT":       COMMIT,
+		"COMMITTED":    COMMITTED,
+		"CONFLICT":     CONFLICT,
+		"CONSTRAINT":   CONSTRAINT,
+		"CONTINUE":     CONTINUE,
+		"COPY":         COPY,
+		"CREATE":       CREATE,
+		"CROSS":        CROSS,
+		"CSV":          CSV,
+		"CYCLE":        CYCLE,
+		"DATABASE":     DATABASE,
+		"DEFAULT":      DEFAULT,
+		"DEFERRABLE":   DEFERRABLE,
+		"DEFERRED":     DEFERRED,
+		"DELETE":       DELETE,
+		"DELIMITER":    DELIMITER,
+		"DESC":         DESC,
+		"DETACH":       DETACH,
+		"DO":           DO,
+		"DOUBLE":       DOUBLE,
+		"DROP":         DROP,
+		"ESCAPE":       ESCAPE,
+		"EXECUTE":      EXECUTE,
+		"EXISTS":       EXISTS,
+		"EXPLAIN":      EXPLAIN,
+		"FALSE":        FALSE,
+		"FORCE_QUOTE":  FORCE_QUOTE,
+		"FOREIGN":      FOREIGN,
+		"FORMAT":       FORMAT,
+		"FROM":         FROM,
+		"FULL":         FULL,
+		"GENERATED":    GENERATED,
+		"GROUP":        GROUP,
+		"HAVING":       HAVING,
+		"HEADER":       HEADER,
+		"IDENTITY":     IDENTITY,
+		"IF":           IF,
+		"IMMEDIATE":    IMMEDIATE,
+		"IN":           IN,
+		"INCREMENT":    INCREMENT,
+		"INDEX":        INDEX,
+		"INITIALLY":    INITIALLY,
+		"INNER":        INNER,
+		"INSERT":       INSERT,
+		"INT":          INT,
+		"INT2":         INT2,
+		"INT4":         INT4,
+		"INT8":         INT8,
+		"INTEGER":      INTEGER,
+		"INTO":         INTO,
+		"IS":           IS,
+		"ISOLATION":    ISOLATION,
+		"JOIN":         JOIN,
+		"KEY":          KEY,
+		"LEFT":         LEFT,
+		"LEVEL":        LEVEL,
+		"MAXVALUE":     MAXVALUE,
+		"MINVALUE":     MINVALUE,
+		"NO":           NO,
+		"NOT":          NOT,
+		"NOTHING":      NOTHING,
+		"NULL":         NULL,
+		"OF":           OF,
+		"ON":           ON,
+		"ONLY":         ONLY,
+		"OR":           OR,
+		"ORDER":        ORDER,
+		"OUTER":        OUTER,
+		"PATH":         PATH,
+		"PRECISION":    PRECISION,
+		"PREPARE":      PREPARE,
+		"PRIMARY":      PRIMARY,
+		"QUOTE":        QUOTE,
+		"READ":         READ,
+		"REAL":         REAL,
+		"RESTRICT":     RESTRICT,
+		"RETURNING":    RETURNING,
+		"REFERENCES":   REFERENCES,
+		"RELEASE":      RELEASE,
+		"REPEATABLE":   REPEATABLE,
+		"RESTART":      RESTART,
+		"RIGHT":        RIGHT,
+		"ROLLBACK":     ROLLBACK,
+		"SAVEPOINT":    SAVEPOINT,
+		"SCHEMA":       SCHEMA,
+		"SELECT":       SELECT,
+		"SEQUENCE":     SEQUENCE,
+		"SERIAL":       SERIAL,
+		"SERIALIZABLE": SERIALIZABLE,
+		"SET":          SET,
+		"SHOW":         SHOW,
+		"SMALLINT":     SMALLINT,
+		"SMALLSERIAL":  SMALLSERIAL,
+		"SNAPSHOT":     SNAPSHOT,
+		"SOME":         SOME,
+		"STDIN":        STDIN,
+		"STDOUT":       STDOUT,
+		"START":        START,
+		"TABLE":        TABLE,
+		"TEXT":         TEXT,
+		"TIME":         TIME,
+		"TO":           TO,
+		"TRANSACTION":  TRANSACTION,
+		"TRUE":         TRUE,
+		"TRUNCATE":     TRUNCATE,
+		"UNCOMMITTED":  UNCOMMITTED,
+		"UNIQUE":       UNIQUE,
+		"UPDATE":       UPDATE,
+		"USE":          USE,
+		"USING":        USING,
+		"VALUES":       VALUES,
+		"VARBINARY":    VARBINARY,
+		"VARCHAR":      VARCHAR,
+		"VERBOSE":      VERBOSE,
+		"WHERE":        WHERE,
+		"WITH":         WITH,
+		"WRITE":        WRITE,
 	}
 
 	names          = map[Identifier]string{}
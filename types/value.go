@@ -2,6 +2,7 @@ package types
 
 import (
 	"bytes"
+	"encoding/gob"
 	"errors"
 	"fmt"
 	"math"
@@ -18,6 +19,15 @@ type Value interface {
 
 type Row []Value
 
+func init() {
+	// Values are stored as part of gob encoded table types.
+	gob.Register(BoolValue(false))
+	gob.Register(StringValue(""))
+	gob.Register(BytesValue(nil))
+	gob.Register(Float64Value(0))
+	gob.Register(Int64Value(0))
+}
+
 type ValueType int
 
 const (